@@ -46,7 +46,10 @@ type Request struct {
 }
 
 type Response struct {
-	URL         string
+	URL string
+	// Canonical is the URL the page names as its own with
+	// <link rel="canonical">, if it does.
+	Canonical   string
 	Image       string
 	Favicon     string
 	Title       string
@@ -204,6 +207,7 @@ func (c *Client) extract(ctx context.Context, rlog log.Printer, inputURL *url.UR
 	return &Response{
 		Title:       getValidUTF8NonZeroString(iframelyRes.Title, simpleRes.Title),
 		URL:         getValidUTF8NonZeroString(iframelyRes.URL, simpleRes.URL),
+		Canonical:   getNonZeroString(iframelyRes.Canonical, simpleRes.Canonical),
 		Site:        getValidUTF8NonZeroString(iframelyRes.Site, simpleRes.Site),
 		Description: getValidUTF8NonZeroString(iframelyRes.Description, simpleRes.Description),
 		Favicon:     getValidUTF8NonZeroString(iframelyRes.Favicon, simpleRes.Favicon),
@@ -235,6 +239,7 @@ func (c *Client) simpleExtract(ctx context.Context, inputURL string) (*Response,
 	return &Response{
 		Title:       oembed.Title,
 		URL:         oembed.URL,
+		Canonical:   info.CanonicalURL,
 		Site:        oembed.ProviderName,
 		Description: description,
 		Favicon:     info.FaviconURL,
@@ -500,6 +505,18 @@ func (c *TestClient) GatherCorpus(ctx context.Context, url string) (*Response, e
 	}, nil
 }
 
+func (c *TestClient) FetchPage(ctx context.Context, url string) (*Page, error) {
+	return &Page{
+		URL:  url,
+		HTML: "<h1>Testing</h1><p>It&#39;s only a test.</p>",
+		Text: "Testing It's only a test.",
+	}, nil
+}
+
+func (c *TestClient) ReadableText(ctx context.Context, url string) (string, error) {
+	return "Testing\nIt's only a test.", nil
+}
+
 func (c *TestClient) Close() error {
 	return nil
 }
@@ -61,6 +61,7 @@ func (c *Client) iframely(ctx context.Context, inputUrl string) (*Response, erro
 	return &Response{
 		Title:       iframelyRes.Meta.Title,
 		URL:         getNonZeroString(iframelyRes.Meta.Canonical, iframelyRes.URL),
+		Canonical:   iframelyRes.Meta.Canonical,
 		Site:        iframelyRes.Meta.Site,
 		Favicon:     favicon,
 		Image:       image,
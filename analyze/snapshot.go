@@ -0,0 +1,137 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// maxPageSize is the most of a page that is read when fetching it.
+const maxPageSize = 5 << 20
+
+var (
+	ErrNotHTML   = errors.New("not an HTML page")
+	ErrPageError = errors.New("page returned an error")
+)
+
+// Page is a copy of a web page that is safe to show.
+type Page struct {
+	// URL is where the page was found, after following redirects.
+	URL string
+	// HTML is the page with its scripts, styles and anything else that could
+	// run or track removed, and its links and images made absolute.
+	HTML string
+	// Text is the text of the page's main content, or of the whole page when
+	// the main content can't be told apart, with its whitespace collapsed.
+	Text string
+}
+
+// FetchPage fetches the page at the URL for a snapshot.
+func (c *Client) FetchPage(ctx context.Context, rawURL string) (*Page, error) {
+	final, body, err := c.fetchHTML(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(final, body)
+}
+
+// fetchHTML fetches the HTML page at the URL and returns where it was found,
+// after following redirects, and its body. Pages that return an error or
+// aren't HTML are refused.
+func (c *Client) fetchHTML(ctx context.Context, rawURL string) (*url.URL, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnparsableURI, err.Error())
+	}
+
+	req.Header.Set("User-Agent", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to do http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, "", fmt.Errorf("%w: status code %d", ErrPageError, resp.StatusCode)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err == nil && mt != "text/html" && mt != "application/xhtml+xml" {
+			return nil, "", fmt.Errorf("%w: %s", ErrNotHTML, mt)
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed read http response body: %w", err)
+	}
+
+	return resp.Request.URL, string(body), nil
+}
+
+func newPage(base *url.URL, rawhtml string) (*Page, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawhtml))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	absolutize(doc, base)
+
+	full, err := doc.Html()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render html: %w", err)
+	}
+
+	sanitized := strings.TrimSpace(bluemonday.UGCPolicy().Sanitize(full))
+
+	main := applyReadability(full)
+	if main == "" {
+		main = sanitized
+	}
+
+	return &Page{
+		URL:  base.String(),
+		HTML: sanitized,
+		Text: plainText(main),
+	}, nil
+}
+
+// absolutize makes the page's links and images point at absolute URLs, so
+// that they still work when the page is shown somewhere else.
+func absolutize(doc *goquery.Document, base *url.URL) {
+	for _, attr := range []struct{ selector, name string }{
+		{"a[href]", "href"},
+		{"img[src]", "src"},
+	} {
+		doc.Find(attr.selector).Each(func(_ int, s *goquery.Selection) {
+			ref, err := url.Parse(strings.TrimSpace(s.AttrOr(attr.name, "")))
+			if err != nil {
+				s.RemoveAttr(attr.name)
+				return
+			}
+
+			s.SetAttr(attr.name, base.ResolveReference(ref).String())
+		})
+	}
+}
+
+// plainText returns the text of the HTML with its whitespace collapsed. Tags
+// are replaced by spaces so that the words of neighbouring elements stay
+// apart.
+func plainText(s string) string {
+	p := bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true)
+
+	return strings.Join(strings.Fields(html.UnescapeString(p.Sanitize(s))), " ")
+}
@@ -0,0 +1,48 @@
+package analyze
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// blockEnd matches the line breaks and the ends of the block elements of an
+// HTML document, which is where its text is broken into lines.
+var blockEnd = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|h[1-6]|blockquote|pre|tr|table|section|article|header|footer|dt|dd)>`)
+
+// ReadableText fetches the page at the URL and returns the text of its main
+// content, found the same way as when a link is saved, with one block of text
+// per line so that versions of it can be compared line by line. When the main
+// content can't be told apart, the text of the whole page is returned.
+func (c *Client) ReadableText(ctx context.Context, rawURL string) (string, error) {
+	_, body, err := c.fetchHTML(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	main := applyReadability(body)
+	if main == "" {
+		main = bluemonday.UGCPolicy().Sanitize(body)
+	}
+
+	return textLines(main), nil
+}
+
+// textLines returns the text of the HTML, one block per line, with the
+// whitespace within each line collapsed and empty lines left out.
+func textLines(s string) string {
+	s = blockEnd.ReplaceAllString(s, "$0\n")
+	text := html.UnescapeString(bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true).Sanitize(s))
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
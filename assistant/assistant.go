@@ -10,7 +10,9 @@ import (
 	"github.com/linksort/linksort/agent"
 	"github.com/linksort/linksort/handler/folder"
 	"github.com/linksort/linksort/handler/link"
+	"github.com/linksort/linksort/handler/savedsearch"
 	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/payload"
 )
 
 var agenticSystemPrompt string = `## Identity
@@ -108,15 +110,20 @@ type Assistant struct {
 type Client struct {
 	LinkController interface {
 		GetLinks(context.Context, *model.User, *link.GetLinksRequest) ([]*model.Link, error)
+		GetLinkFacets(context.Context, *model.User, *link.GetLinksRequest) (*model.FacetCounts, error)
 		GetLink(context.Context, *model.User, string) (*model.Link, error)
 		UpdateLink(context.Context, *model.User, *link.UpdateLinkRequest) (*model.Link, *model.User, error)
 		SummarizeLink(context.Context, *model.User, string) (*model.Link, error)
+		GetRelatedLinks(context.Context, *model.User, string, *model.Pagination) ([]*model.RelatedLink, error)
 	}
 	FolderController interface {
 		CreateFolder(context.Context, *model.User, *folder.CreateFolderRequest) (*model.User, error)
 		UpdateFolder(context.Context, *model.User, *folder.UpdateFolderRequest) (*model.User, error)
 		DeleteFolder(context.Context, *model.User, string) (*model.User, error)
 	}
+	SavedSearchController interface {
+		CreateSavedSearch(context.Context, *model.User, *savedsearch.CreateSavedSearchRequest) (*model.User, error)
+	}
 	BedrockClient agent.ConverseStreamProvider
 }
 
@@ -144,6 +151,10 @@ func (c *Client) NewAssistant(u *model.User, conv *model.Conversation, userMsg *
 				User:           u,
 				LinkController: c.LinkController,
 			},
+			&GetRelatedLinksTool{
+				User:           u,
+				LinkController: c.LinkController,
+			},
 			&SummarizeLinkTool{
 				User:           u,
 				LinkController: c.LinkController,
@@ -160,6 +171,10 @@ func (c *Client) NewAssistant(u *model.User, conv *model.Conversation, userMsg *
 				User:             u,
 				FolderController: c.FolderController,
 			},
+			&CreateSavedSearchTool{
+				User:                  u,
+				SavedSearchController: c.SavedSearchController,
+			},
 			&AddLinkToFolderTool{
 				User:           u,
 				LinkController: c.LinkController,
@@ -173,31 +188,50 @@ func (c *Client) NewAssistant(u *model.User, conv *model.Conversation, userMsg *
 	})}
 }
 
+// Act runs the agent. Changes made by its tools are attributed to the
+// assistant rather than the user.
 func (a *Assistant) Act(ctx context.Context) error {
-	return a.agent.Act(ctx)
+	return a.agent.Act(model.WithActor(ctx, model.ActorAssistant))
 }
 
 func (a *Assistant) Stream() chan any {
 	return a.agent.Stream
 }
 
+// searchDescription describes the search query language to the model.
+const searchDescription = `Search query. Words and "quoted phrases" are searched for in the text of links and their highlights; a link matches when it has any of the words and all of the phrases. Operators narrow the results further:
+- site:arxiv.org matches links on the site and its subdomains
+- tag:Science/Physics matches an auto tag path and usertag:reading a user tag
+- folder:Reading matches a folder by name or ID
+- title:rust and url:github match part of the title or URL
+- is:favorite, is:annotated and is:article
+- is:unread, is:read and is:inprogress match the user's reading; in progress links are unread ones that have been partly read
+- before:2024-01-01, after:2024-01-01 and created:2024-01-01..2024-03-01 match the date the link was saved; dates can also be a month (2024-01) or a year (2024)
+Prefix a term with - to exclude it, such as -site:medium.com, and join terms with OR to match either, such as site:arxiv.org OR site:openreview.net. Quote operator values that contain spaces, such as folder:"To read". When the query is invalid, the error says what is wrong and where.`
+
 type GetLinksTool struct {
 	User           *model.User
 	LinkController interface {
 		GetLinks(context.Context, *model.User, *link.GetLinksRequest) ([]*model.Link, error)
+		GetLinkFacets(context.Context, *model.User, *link.GetLinksRequest) (*model.FacetCounts, error)
 	}
 }
 
 func (t *GetLinksTool) Spec() agent.Spec {
 	return agent.Spec{
 		Name:        "get_links",
-		Description: "Use this tool to query and filter the user's links. Supports search, sorting, filtering by favorites/annotations/folders/tags, and pagination. Returns basic link information - use get_link for full details.",
+		Description: "Use this tool to query and filter the user's links. Supports a search query language, sorting, filtering by favorites/annotations/folders/tags/read state/link health/page changes, and pagination. Returns basic link information - use get_link for full details.",
 		InputSchema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
 				"search": map[string]any{
 					"type":        "string",
-					"description": "Text search across link content",
+					"description": searchDescription,
+				},
+				"semantic": map[string]any{
+					"type":        "string",
+					"description": "Find links by meaning rather than by their words, such as 'articles about managing remote teams'. The links are ranked by how close they are to it instead of sorted, and the other filters still apply",
+					"maxLength":   1000,
 				},
 				"sort": map[string]any{
 					"type":        "string",
@@ -214,6 +248,21 @@ func (t *GetLinksTool) Spec() agent.Spec {
 					"description": "Filter annotated links: '1' to show only annotated",
 					"enum":        []string{"1"},
 				},
+				"read": map[string]any{
+					"type":        "string",
+					"description": "Filter by read state, such as 'unread' to answer what the user hasn't read yet. 'inprogress' is unread links that have been partly read",
+					"enum":        []string{model.ReadStateUnread, model.ReadStateInProgress, model.ReadStateRead},
+				},
+				"health": map[string]any{
+					"type":        "string",
+					"description": "Filter by what was found the last time the links' URLs were checked, such as 'broken' for dead links or 'redirected' for links that have moved",
+					"enum":        model.HealthStatuses,
+				},
+				"changed": map[string]any{
+					"type":        "string",
+					"description": "Filter watched links: '1' to show only those whose page changed since the user last looked",
+					"enum":        []string{"1"},
+				},
 				"folderId": map[string]any{
 					"type":        "string",
 					"description": "Filter by folder ID",
@@ -226,6 +275,14 @@ func (t *GetLinksTool) Spec() agent.Spec {
 					"type":        "string",
 					"description": "Filter by user tag",
 				},
+				"facets": map[string]any{
+					"type":        "array",
+					"description": "Also count all of the links that match the filters, in total and by these facets, such as to say how many results there are and which sites they are from",
+					"items": map[string]any{
+						"type": "string",
+						"enum": model.Facets,
+					},
+				},
 				"page": map[string]any{
 					"type":        "integer",
 					"description": "Page number (0-based)",
@@ -264,6 +321,10 @@ func (t *GetLinksTool) Use(ctx context.Context, id, input string) agent.ToolUseR
 		req.Search = search
 	}
 
+	if semantic, ok := typedInput["semantic"].(string); ok {
+		req.Semantic = semantic
+	}
+
 	if sort, ok := typedInput["sort"].(string); ok {
 		if sort == "1" || sort == "-1" {
 			req.Sort = sort
@@ -297,6 +358,25 @@ func (t *GetLinksTool) Use(ctx context.Context, id, input string) agent.ToolUseR
 		}
 	}
 
+	if read, ok := typedInput["read"].(string); ok {
+		req.Read = read
+	}
+
+	if health, ok := typedInput["health"].(string); ok {
+		req.Health = health
+	}
+
+	if changed, ok := typedInput["changed"].(string); ok {
+		if changed == "1" {
+			req.Changed = changed
+		} else {
+			return agent.ToolUseResponse{
+				Status: agent.ToolUseStatusError,
+				Text:   "changed parameter must be '1' to filter changed links",
+			}
+		}
+	}
+
 	if folderId, ok := typedInput["folderId"].(string); ok {
 		req.FolderID = folderId
 	}
@@ -307,6 +387,14 @@ func (t *GetLinksTool) Use(ctx context.Context, id, input string) agent.ToolUseR
 		req.UserTag = userTag
 	}
 
+	if facets, ok := typedInput["facets"].([]any); ok {
+		for _, f := range facets {
+			if name, ok := f.(string); ok {
+				req.Facets = append(req.Facets, name)
+			}
+		}
+	}
+
 	// Extract and validate pagination parameters (can be float64 from JSON)
 	if pageVal, ok := typedInput["page"]; ok {
 		if pageFloat, ok := pageVal.(float64); ok {
@@ -342,6 +430,16 @@ func (t *GetLinksTool) Use(ctx context.Context, id, input string) agent.ToolUseR
 	}
 
 	response := link.GetLinksResponse{Links: links}
+
+	if len(req.Facets) > 0 {
+		response.Facets, err = t.LinkController.GetLinkFacets(ctx, t.User, req)
+		if err != nil {
+			return agent.ToolUseResponse{
+				Status: agent.ToolUseStatusError,
+				Text:   err.Error(),
+			}
+		}
+	}
 	responseJSON, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
 		return agent.ToolUseResponse{
@@ -424,6 +522,86 @@ func (t *GetLinkTool) Use(ctx context.Context, id, input string) agent.ToolUseRe
 	}
 }
 
+// GetRelatedLinksTool finds the links that are most like a given link
+type GetRelatedLinksTool struct {
+	User           *model.User
+	LinkController interface {
+		GetRelatedLinks(context.Context, *model.User, string, *model.Pagination) ([]*model.RelatedLink, error)
+	}
+}
+
+func (t *GetRelatedLinksTool) Spec() agent.Spec {
+	return agent.Spec{
+		Name:        "get_related_links",
+		Description: "Use this tool to find the user's other links that are most like a given link, such as when the user asks what else they have saved like it. Links are related by similar content, shared tags and user tags, and being from the same site. Each result says why it matched.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id": map[string]any{
+					"type":    "string",
+					"pattern": "^[0-9a-f]{24}$",
+				},
+				"size": map[string]any{
+					"type":        "integer",
+					"description": "How many links to return (default 18, max 32)",
+					"minimum":     1,
+					"maximum":     32,
+				},
+			},
+			"required": []string{"id"},
+		},
+	}
+}
+
+func (t *GetRelatedLinksTool) Use(ctx context.Context, id, input string) agent.ToolUseResponse {
+	var typedInput struct {
+		ID   string `json:"id"`
+		Size int    `json:"size"`
+	}
+
+	if err := json.Unmarshal([]byte(input), &typedInput); err != nil {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   err.Error(),
+		}
+	}
+
+	if typedInput.ID == "" {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   "'id' was not included in the input and is required.",
+		}
+	}
+
+	if typedInput.Size < 0 || typedInput.Size > 32 {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   "size parameter must be between 1 and 32",
+		}
+	}
+
+	links, err := t.LinkController.GetRelatedLinks(ctx, t.User, typedInput.ID, &model.Pagination{Size: typedInput.Size})
+	if err != nil {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   err.Error(),
+		}
+	}
+
+	b, err := json.MarshalIndent(link.GetRelatedLinksResponse{Links: links}, "", "  ")
+	if err != nil {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   err.Error(),
+		}
+	}
+
+	return agent.ToolUseResponse{
+		Status: agent.ToolUseStatusSuccess,
+		Text:   string(b),
+	}
+}
+
 // CreateFolderTool handles creating a new folder
 type CreateFolderTool struct {
 	User             *model.User
@@ -639,6 +817,95 @@ func (t *RenameFolderTool) Use(ctx context.Context, id, input string) agent.Tool
 	}
 }
 
+// CreateSavedSearchTool handles saving a set of link filters under a name
+type CreateSavedSearchTool struct {
+	User                  *model.User
+	SavedSearchController interface {
+		CreateSavedSearch(context.Context, *model.User, *savedsearch.CreateSavedSearchRequest) (*model.User, error)
+	}
+}
+
+func (t *CreateSavedSearchTool) Spec() agent.Spec {
+	return agent.Spec{
+		Name:        "create_saved_search",
+		Description: "Use this tool to create a saved search, which works like a folder that holds every link matching its filters, including links saved later. Use it when the user asks for a smart folder or wants to keep coming back to the same filtered view of their links. The filters are the same as for get_links; try them with get_links first when unsure.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name": map[string]any{
+					"type":        "string",
+					"description": "Name shown next to the user's folders",
+					"maxLength":   128,
+				},
+				"search": map[string]any{
+					"type":        "string",
+					"description": searchDescription,
+					"maxLength":   512,
+				},
+				"sort": map[string]any{
+					"type":        "string",
+					"description": "Sort order: '1' for ascending by creation date, '-1' for descending",
+					"enum":        []string{"1", "-1"},
+				},
+				"favorites": map[string]any{
+					"type":        "boolean",
+					"description": "Only include favorites",
+				},
+				"annotated": map[string]any{
+					"type":        "boolean",
+					"description": "Only include annotated links",
+				},
+				"folderId": map[string]any{
+					"type":        "string",
+					"description": "Only include links from this folder",
+					"pattern":     "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$",
+				},
+				"tagPath": map[string]any{
+					"type":        "string",
+					"description": "Only include links with this tag path",
+				},
+				"userTag": map[string]any{
+					"type":        "string",
+					"description": "Only include links with this user tag",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}
+}
+
+func (t *CreateSavedSearchTool) Use(ctx context.Context, id, input string) agent.ToolUseResponse {
+	req := new(savedsearch.CreateSavedSearchRequest)
+	if err := json.Unmarshal([]byte(input), req); err != nil {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   fmt.Sprintf("Failed to parse input: %v", err),
+		}
+	}
+
+	if err := payload.Valid(req); err != nil {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   fmt.Sprintf("Invalid input: %v", err),
+		}
+	}
+
+	u, err := t.SavedSearchController.CreateSavedSearch(ctx, t.User, req)
+	if err != nil {
+		return agent.ToolUseResponse{
+			Status: agent.ToolUseStatusError,
+			Text:   fmt.Sprintf("Failed to create saved search: %v", err),
+		}
+	}
+
+	search := u.SavedSearches[len(u.SavedSearches)-1]
+
+	return agent.ToolUseResponse{
+		Status: agent.ToolUseStatusSuccess,
+		Text:   fmt.Sprintf("Successfully created saved search '%s'. Its ID is %s and its links are at /?saved=%s", search.Name, search.ID, search.ID),
+	}
+}
+
 // AddLinkToFolderTool handles adding a link to a folder
 type AddLinkToFolderTool struct {
 	User           *model.User
@@ -874,6 +1141,13 @@ func userSummary(u *model.User, pageContext map[string]any) string {
 	}
 
 	summary += fmt.Sprintf("- The user's folder tree is this:\n%s", string(bFolderTree))
+
+	if len(u.SavedSearches) > 0 {
+		bSavedSearches, err := json.MarshalIndent(u.SavedSearches, "", "  ")
+		if err == nil {
+			summary += fmt.Sprintf("\n- The user's saved searches are these:\n%s", string(bSavedSearches))
+		}
+	}
 	
 	return summary
 }
@@ -0,0 +1,125 @@
+// Package bookmarks reads and writes the files that browsers and other
+// bookmark managers export.
+package bookmarks
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+)
+
+// Bookmark is a link read from an export file, before it is saved.
+type Bookmark struct {
+	URL         string
+	Title       string
+	Description string
+	Annotation  string
+	// Folder is the path of folder names from the root folder down to the
+	// folder the bookmark is in. It is empty for bookmarks in the root.
+	Folder     []string
+	Tags       []string
+	IsFavorite bool
+	// CreatedAt is zero when the export doesn't say when the bookmark was
+	// added.
+	CreatedAt time.Time
+}
+
+// Parser reads the bookmarks in an export file.
+type Parser func(io.Reader) ([]*Bookmark, error)
+
+// Parsers returns a parser for each supported export format, keyed by the
+// format's name.
+func Parsers() map[string]Parser {
+	return map[string]Parser{
+		"netscape":   ParseNetscape,
+		"raindrop":   ParseRaindrop,
+		"pinboard":   ParsePinboard,
+		"instapaper": ParseInstapaper,
+		"omnivore":   ParseOmnivore,
+		"pocket":     ParsePocket,
+	}
+}
+
+// parseUnix parses a timestamp given as seconds, milliseconds or
+// microseconds since the epoch. Exports don't agree on which one to use.
+func parseUnix(s string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+
+	switch {
+	case n > 1e14:
+		return time.UnixMicro(n)
+	case n > 1e11:
+		return time.UnixMilli(n)
+	default:
+		return time.Unix(n, 0)
+	}
+}
+
+func splitTags(s, sep string) []string {
+	tags := make([]string, 0)
+
+	for _, t := range strings.Split(s, sep) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// readCSV reads a CSV file with a header row and returns each row as a map
+// from lowercased column name to value. Rows may be shorter than the header.
+// It's an error for the header to lack any of the required columns, since
+// that means the file isn't in the expected format.
+func readCSV(r io.Reader, required ...string) ([]map[string]string, error) {
+	op := errors.Op("bookmarks.readCSV")
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	columns := make(map[string]bool, len(headers))
+	for i, h := range headers {
+		headers[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		columns[headers[i]] = true
+	}
+
+	for _, c := range required {
+		if !columns[c] {
+			return nil, errors.E(op, errors.Strf("missing %q column", c))
+		}
+	}
+
+	rows := make([]map[string]string, 0)
+
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		row := make(map[string]string, len(headers))
+		for i, h := range headers {
+			if i < len(rec) {
+				row[h] = strings.TrimSpace(rec[i])
+			}
+		}
+
+		rows = append(rows, row)
+	}
+}
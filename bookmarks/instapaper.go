@@ -0,0 +1,58 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/linksort/linksort/errors"
+)
+
+// ParseInstapaper reads an Instapaper CSV export. Bookmarks in the "Starred"
+// folder are favorites, those in "Unread" and "Archive" go in the root folder,
+// and those in any other folder go in a folder of the same name.
+func ParseInstapaper(r io.Reader) ([]*Bookmark, error) {
+	op := errors.Op("bookmarks.ParseInstapaper")
+
+	rows, err := readCSV(r, "url", "title", "folder")
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	out := make([]*Bookmark, 0, len(rows))
+
+	for _, row := range rows {
+		bm := &Bookmark{
+			URL:         row["url"],
+			Title:       row["title"],
+			Description: row["selection"],
+			Tags:        parseInstapaperTags(row["tags"]),
+			CreatedAt:   parseUnix(row["timestamp"]),
+		}
+
+		switch folder := row["folder"]; strings.ToLower(folder) {
+		case "", "unread", "archive":
+		case "starred":
+			bm.IsFavorite = true
+		default:
+			bm.Folder = []string{folder}
+		}
+
+		out = append(out, bm)
+	}
+
+	return out, nil
+}
+
+// parseInstapaperTags reads the tags column, which newer exports write as a
+// JSON array and older ones as a comma-separated list.
+func parseInstapaperTags(s string) []string {
+	if strings.HasPrefix(s, "[") {
+		var tags []string
+		if err := json.Unmarshal([]byte(s), &tags); err == nil {
+			return tags
+		}
+	}
+
+	return splitTags(s, ",")
+}
@@ -0,0 +1,207 @@
+package bookmarks
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+// ParseNetscape reads a bookmarks.html file in the Netscape format that
+// browsers import and export. Each <H3> heading followed by a <DL> list is a
+// folder, and the TAGS and ADD_DATE attributes of each <A> give its tags and
+// when it was added.
+func ParseNetscape(r io.Reader) ([]*Bookmark, error) {
+	op := errors.Op("bookmarks.ParseNetscape")
+
+	z := nethtml.NewTokenizer(r)
+	out := make([]*Bookmark, 0)
+
+	var (
+		// path holds the names of the folders that are open. opened records,
+		// for each open <DL>, whether it opened a folder.
+		path   []string
+		opened []bool
+		// heading is the name of the folder that the next <DL> opens.
+		heading *string
+		// describing is the bookmark that text after a <DD> describes.
+		describing *Bookmark
+		seenList   bool
+	)
+
+	for {
+		tt := z.Next()
+
+		switch tt {
+		case nethtml.ErrorToken:
+			if z.Err() != io.EOF {
+				return nil, errors.E(op, z.Err())
+			}
+
+			if !seenList {
+				return nil, errors.E(op, errors.Str("no bookmark list found"))
+			}
+
+			for _, bm := range out {
+				bm.Description = strings.TrimSpace(bm.Description)
+			}
+
+			return out, nil
+		case nethtml.TextToken:
+			if describing != nil {
+				describing.Description += string(z.Text())
+			}
+		case nethtml.StartTagToken:
+			name, hasAttr := z.TagName()
+
+			switch string(name) {
+			case "h3":
+				describing = nil
+				text := strings.TrimSpace(readText(z, "h3"))
+				heading = &text
+			case "dl":
+				describing = nil
+				seenList = true
+
+				if heading != nil {
+					path = append(path, *heading)
+					opened = append(opened, true)
+					heading = nil
+				} else {
+					opened = append(opened, false)
+				}
+			case "a":
+				heading = nil
+				bm := &Bookmark{Folder: append([]string(nil), path...)}
+
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+
+					switch string(key) {
+					case "href":
+						bm.URL = strings.TrimSpace(string(val))
+					case "add_date":
+						bm.CreatedAt = parseUnix(string(val))
+					case "tags":
+						bm.Tags = splitTags(string(val), ",")
+					}
+				}
+
+				bm.Title = strings.TrimSpace(readText(z, "a"))
+				out = append(out, bm)
+				describing = bm
+			case "dd":
+				// Keep describing the bookmark that came before.
+			default:
+				describing = nil
+			}
+		case nethtml.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) != "dl" || len(opened) == 0 {
+				continue
+			}
+
+			describing = nil
+
+			if opened[len(opened)-1] {
+				path = path[:len(path)-1]
+			}
+
+			opened = opened[:len(opened)-1]
+		}
+	}
+}
+
+// readText returns the text up to the closing tag with the given name.
+func readText(z *nethtml.Tokenizer, tag string) string {
+	var b strings.Builder
+
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return b.String()
+		case nethtml.TextToken:
+			b.Write(z.Text())
+		case nethtml.EndTagToken:
+			if name, _ := z.TagName(); string(name) == tag {
+				return b.String()
+			}
+		}
+	}
+}
+
+const netscapeHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+`
+
+// WriteNetscape writes the folder tree and the links in it as a Netscape
+// bookmarks.html file that browsers can import. Links in folders that are not
+// in the tree are written to the root.
+func WriteNetscape(w io.Writer, root *model.Folder, links []*model.Link) error {
+	op := errors.Op("bookmarks.WriteNetscape")
+
+	byFolder := make(map[string][]*model.Link)
+	for _, l := range links {
+		folderID := l.FolderID
+		if folderID == "" || root.BFS(folderID) == nil {
+			folderID = root.ID
+		}
+
+		byFolder[folderID] = append(byFolder[folderID], l)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(netscapeHeader)
+	writeNetscapeFolder(bw, root, byFolder, 0)
+
+	if err := bw.Flush(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func writeNetscapeFolder(w *bufio.Writer, f *model.Folder, byFolder map[string][]*model.Link, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	fmt.Fprintf(w, "%s<DL><p>\n", indent)
+
+	for _, child := range f.Children {
+		fmt.Fprintf(w, "%s    <DT><H3>%s</H3>\n", indent, html.EscapeString(child.Name))
+		writeNetscapeFolder(w, child, byFolder, depth+1)
+	}
+
+	for _, l := range byFolder[f.ID] {
+		title := l.Title
+		if title == "" {
+			title = l.URL
+		}
+
+		fmt.Fprintf(w, `%s    <DT><A HREF="%s" ADD_DATE="%d" LAST_MODIFIED="%d"`,
+			indent, html.EscapeString(l.URL), l.CreatedAt.Unix(), l.UpdatedAt.Unix())
+
+		if len(l.UserTags) > 0 {
+			fmt.Fprintf(w, ` TAGS="%s"`, html.EscapeString(strings.Join(l.UserTags, ",")))
+		}
+
+		fmt.Fprintf(w, ">%s</A>\n", html.EscapeString(title))
+
+		if l.Description != "" {
+			fmt.Fprintf(w, "%s    <DD>%s\n", indent, html.EscapeString(l.Description))
+		}
+	}
+
+	fmt.Fprintf(w, "%s</DL><p>\n", indent)
+}
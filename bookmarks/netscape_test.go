@@ -0,0 +1,110 @@
+package bookmarks
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linksort/linksort/model"
+)
+
+const firefoxExport = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks Menu</H1>
+
+<DL><p>
+    <DT><A HREF="https://example.com/" ADD_DATE="1700000000" TAGS="go,Web Dev">Example &amp; Co</A>
+    <DD>An example site
+    <DT><H3 ADD_DATE="1700000000">Reading</H3>
+    <DL><p>
+        <DT><H3>Later</H3>
+        <DL><p>
+            <DT><A HREF="https://later.example.com/">Later</A>
+        </DL><p>
+        <DT><A HREF="https://reading.example.com/" ADD_DATE="1700000001000">Reading</A>
+    </DL><p>
+    <DT><A HREF="https://root.example.com/">Root</A>
+</DL>
+`
+
+func TestParseNetscape(t *testing.T) {
+	got, err := ParseNetscape(strings.NewReader(firefoxExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Bookmark{
+		{
+			URL:         "https://example.com/",
+			Title:       "Example & Co",
+			Description: "An example site",
+			Tags:        []string{"go", "Web Dev"},
+			CreatedAt:   time.Unix(1700000000, 0),
+		},
+		{URL: "https://later.example.com/", Title: "Later", Folder: []string{"Reading", "Later"}},
+		{
+			URL:       "https://reading.example.com/",
+			Title:     "Reading",
+			Folder:    []string{"Reading"},
+			CreatedAt: time.UnixMilli(1700000001000),
+		},
+		{URL: "https://root.example.com/", Title: "Root"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d bookmarks, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if len(got[i].Folder) == 0 {
+			got[i].Folder = nil
+		}
+
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("bookmark %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseNetscape_NotBookmarks(t *testing.T) {
+	if _, err := ParseNetscape(strings.NewReader("title,url\nExample,https://example.com")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestWriteNetscape_RoundTrip(t *testing.T) {
+	root := &model.Folder{Name: "root", ID: "root"}
+	child := model.NewFolder("Reading <list>", root)
+	created := time.Unix(1700000000, 0)
+
+	links := []*model.Link{
+		{URL: "https://example.com/?a=1&b=2", Title: "Example", FolderID: child.ID, CreatedAt: created, UserTags: []string{"go", "web"}},
+		{URL: "https://gone.example.com/", Title: "Gone", FolderID: "deleted-folder", CreatedAt: created, Description: "Desc"},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteNetscape(buf, root, links); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseNetscape(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d bookmarks, want 2", len(got))
+	}
+
+	if got[0].URL != links[0].URL || !reflect.DeepEqual(got[0].Folder, []string{"Reading <list>"}) ||
+		!reflect.DeepEqual(got[0].Tags, []string{"go", "web"}) || !got[0].CreatedAt.Equal(created) {
+		t.Errorf("unexpected first bookmark: %+v", got[0])
+	}
+
+	if len(got[1].Folder) != 0 || got[1].Description != "Desc" {
+		t.Errorf("unexpected second bookmark: %+v", got[1])
+	}
+}
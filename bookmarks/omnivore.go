@@ -0,0 +1,78 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+)
+
+type omnivoreItem struct {
+	URL         string         `json:"url"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Labels      omnivoreLabels `json:"labels"`
+	SavedAt     string         `json:"savedAt"`
+}
+
+// omnivoreLabels accepts labels written either as names or as objects with
+// a name, since both appear in exports.
+type omnivoreLabels []string
+
+func (l *omnivoreLabels) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	for _, r := range raw {
+		var name string
+		if err := json.Unmarshal(r, &name); err == nil {
+			*l = append(*l, name)
+			continue
+		}
+
+		var obj struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(r, &obj); err != nil {
+			return err
+		}
+
+		*l = append(*l, obj.Name)
+	}
+
+	return nil
+}
+
+// ParseOmnivore reads the metadata JSON file from an Omnivore export. Labels
+// become tags.
+func ParseOmnivore(r io.Reader) ([]*Bookmark, error) {
+	op := errors.Op("bookmarks.ParseOmnivore")
+
+	var items []*omnivoreItem
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	out := make([]*Bookmark, 0, len(items))
+
+	for _, item := range items {
+		bm := &Bookmark{
+			URL:         strings.TrimSpace(item.URL),
+			Title:       strings.TrimSpace(item.Title),
+			Description: strings.TrimSpace(item.Description),
+			Tags:        item.Labels,
+		}
+
+		if created, err := time.Parse(time.RFC3339, item.SavedAt); err == nil {
+			bm.CreatedAt = created
+		}
+
+		out = append(out, bm)
+	}
+
+	return out, nil
+}
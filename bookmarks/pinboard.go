@@ -0,0 +1,48 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+)
+
+type pinboardPost struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+	Extended    string `json:"extended"`
+	Time        string `json:"time"`
+	Tags        string `json:"tags"`
+}
+
+// ParsePinboard reads a Pinboard JSON export. Pinboard's "description" is
+// the title of the bookmark and its "extended" text is the user's notes.
+func ParsePinboard(r io.Reader) ([]*Bookmark, error) {
+	op := errors.Op("bookmarks.ParsePinboard")
+
+	var posts []*pinboardPost
+	if err := json.NewDecoder(r).Decode(&posts); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	out := make([]*Bookmark, 0, len(posts))
+
+	for _, p := range posts {
+		bm := &Bookmark{
+			URL:        strings.TrimSpace(p.Href),
+			Title:      strings.TrimSpace(p.Description),
+			Annotation: strings.TrimSpace(p.Extended),
+			Tags:       strings.Fields(p.Tags),
+		}
+
+		if created, err := time.Parse(time.RFC3339, p.Time); err == nil {
+			bm.CreatedAt = created
+		}
+
+		out = append(out, bm)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,41 @@
+package bookmarks
+
+import (
+	"io"
+
+	"github.com/linksort/linksort/errors"
+)
+
+// ParsePocket reads a Pocket CSV export. Tags are separated by "|", and the
+// "starred" tag marks a favorite rather than being kept as a tag.
+func ParsePocket(r io.Reader) ([]*Bookmark, error) {
+	op := errors.Op("bookmarks.ParsePocket")
+
+	rows, err := readCSV(r, "url")
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	out := make([]*Bookmark, 0, len(rows))
+
+	for _, row := range rows {
+		bm := &Bookmark{
+			URL:       row["url"],
+			Title:     row["title"],
+			Tags:      make([]string, 0),
+			CreatedAt: parseUnix(row["time_added"]),
+		}
+
+		for _, t := range splitTags(row["tags"], "|") {
+			if t == "starred" {
+				bm.IsFavorite = true
+			} else {
+				bm.Tags = append(bm.Tags, t)
+			}
+		}
+
+		out = append(out, bm)
+	}
+
+	return out, nil
+}
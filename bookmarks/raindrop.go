@@ -0,0 +1,46 @@
+package bookmarks
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+)
+
+// ParseRaindrop reads a Raindrop.io CSV export. Collections become folders,
+// with the names of nested collections separated by slashes, and bookmarks
+// in the "Unsorted" collection go in the root folder.
+func ParseRaindrop(r io.Reader) ([]*Bookmark, error) {
+	op := errors.Op("bookmarks.ParseRaindrop")
+
+	rows, err := readCSV(r, "url", "title")
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	out := make([]*Bookmark, 0, len(rows))
+
+	for _, row := range rows {
+		bm := &Bookmark{
+			URL:         row["url"],
+			Title:       row["title"],
+			Description: row["excerpt"],
+			Annotation:  row["note"],
+			Tags:        splitTags(row["tags"], ","),
+			IsFavorite:  strings.EqualFold(row["favorite"], "true"),
+		}
+
+		if folder := row["folder"]; folder != "" && !strings.EqualFold(folder, "unsorted") {
+			bm.Folder = splitTags(folder, "/")
+		}
+
+		if created, err := time.Parse(time.RFC3339, row["created"]); err == nil {
+			bm.CreatedAt = created
+		}
+
+		out = append(out, bm)
+	}
+
+	return out, nil
+}
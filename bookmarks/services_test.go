@@ -0,0 +1,136 @@
+package bookmarks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseServices(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Parse  Parser
+		Given  string
+		Expect []*Bookmark
+	}{
+		{
+			Name:  "raindrop",
+			Parse: ParseRaindrop,
+			Given: "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n" +
+				"1,Go,My note,About Go,https://go.dev,Dev/Go,\"go, lang\",2024-01-02T03:04:05.000Z,,,true\n" +
+				"2,Other,,,https://other.com,Unsorted,,,,,false\n",
+			Expect: []*Bookmark{
+				{
+					URL:         "https://go.dev",
+					Title:       "Go",
+					Description: "About Go",
+					Annotation:  "My note",
+					Folder:      []string{"Dev", "Go"},
+					Tags:        []string{"go", "lang"},
+					IsFavorite:  true,
+					CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				},
+				{URL: "https://other.com", Title: "Other", Tags: []string{}},
+			},
+		},
+		{
+			Name:  "pinboard",
+			Parse: ParsePinboard,
+			Given: `[{"href":"https://go.dev","description":"Go","extended":"Notes","time":"2024-01-02T03:04:05Z","shared":"no","toread":"yes","tags":"go lang"}]`,
+			Expect: []*Bookmark{
+				{
+					URL:        "https://go.dev",
+					Title:      "Go",
+					Annotation: "Notes",
+					Tags:       []string{"go", "lang"},
+					CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				},
+			},
+		},
+		{
+			Name:  "instapaper",
+			Parse: ParseInstapaper,
+			Given: "URL,Title,Selection,Folder,Timestamp,Tags\n" +
+				"https://go.dev,Go,Selected,Starred,1700000000,\"[\"\"go\"\"]\"\n" +
+				"https://other.com,Other,,Reading,1700000000,\n" +
+				"https://third.com,Third,,Unread,,\n",
+			Expect: []*Bookmark{
+				{
+					URL:         "https://go.dev",
+					Title:       "Go",
+					Description: "Selected",
+					Tags:        []string{"go"},
+					IsFavorite:  true,
+					CreatedAt:   time.Unix(1700000000, 0),
+				},
+				{
+					URL:       "https://other.com",
+					Title:     "Other",
+					Folder:    []string{"Reading"},
+					Tags:      []string{},
+					CreatedAt: time.Unix(1700000000, 0),
+				},
+				{URL: "https://third.com", Title: "Third", Tags: []string{}},
+			},
+		},
+		{
+			Name:  "omnivore",
+			Parse: ParseOmnivore,
+			Given: `[{"id":"1","url":"https://go.dev","title":"Go","description":"About Go","labels":["go",{"name":"lang"}],"savedAt":"2024-01-02T03:04:05.000Z","state":"Archived"}]`,
+			Expect: []*Bookmark{
+				{
+					URL:         "https://go.dev",
+					Title:       "Go",
+					Description: "About Go",
+					Tags:        []string{"go", "lang"},
+					CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				},
+			},
+		},
+		{
+			Name:  "pocket",
+			Parse: ParsePocket,
+			Given: "title,url,time_added,cursor,tags,status\n" +
+				"Go,https://go.dev,1700000000,1,go|starred|lang,unread\n" +
+				"Short,https://short.com\n",
+			Expect: []*Bookmark{
+				{
+					URL:        "https://go.dev",
+					Title:      "Go",
+					Tags:       []string{"go", "lang"},
+					IsFavorite: true,
+					CreatedAt:  time.Unix(1700000000, 0),
+				},
+				{URL: "https://short.com", Title: "Short", Tags: []string{}},
+			},
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			got, err := tcase.Parse(strings.NewReader(tcase.Given))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tcase.Expect) {
+				t.Fatalf("got %d bookmarks, want %d", len(got), len(tcase.Expect))
+			}
+
+			for i := range got {
+				if !reflect.DeepEqual(got[i], tcase.Expect[i]) {
+					t.Errorf("bookmark %d: got %+v, want %+v", i, got[i], tcase.Expect[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseServices_WrongFormat(t *testing.T) {
+	for name, parse := range Parsers() {
+		if _, err := parse(strings.NewReader("title\nnot a url")); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
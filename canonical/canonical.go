@@ -0,0 +1,242 @@
+// Package canonical reduces the many URLs a page can be reached at to one, so
+// that saving the same page twice can be noticed.
+//
+// The canonical form of a URL uses https, drops the www., m., mobile. and
+// amp. subdomains, default ports, fragments, tracking parameters and trailing
+// slashes, sorts the query parameters, and turns AMP cache URLs into the pages
+// they are copies of. Paths and parameters that mark AMP pages, such as /amp/
+// or ?amp=1, are only dropped on hosts known to serve AMP pages, since other
+// sites use them for pages of their own; the AMP pages of other sites are
+// turned into the pages they are copies of by Resolve.
+package canonical
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var hostPrefixes = []string{"www.", "m.", "mobile.", "amp."}
+
+var trackingParams = map[string]bool{
+	"fbclid":   true,
+	"gclid":    true,
+	"dclid":    true,
+	"gclsrc":   true,
+	"msclkid":  true,
+	"yclid":    true,
+	"igshid":   true,
+	"twclid":   true,
+	"ttclid":   true,
+	"mc_cid":   true,
+	"mc_eid":   true,
+	"_hsenc":   true,
+	"_hsmi":    true,
+	"mkt_tok":  true,
+	"ref_src":  true,
+	"ref_url":  true,
+	"oly_anon": true,
+	"oly_enc":  true,
+	"vero_id":  true,
+	"wt.mc_id": true,
+}
+
+var trackingPrefixes = []string{"utm_", "pk_", "__hs"}
+
+// URL returns the canonical form of a web URL.
+func URL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("canonical: %w", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("canonical: %q is not a web URL", raw)
+	}
+
+	u, amp := unwrapAMPCache(u)
+	amp = amp || strings.HasPrefix(strings.ToLower(u.Hostname()), "amp.")
+
+	u.Scheme = "https"
+	u.User = nil
+	u.Host = canonicalHost(u)
+	u.Path = canonicalPath(u.Path, amp)
+	u.RawPath = ""
+	u.RawQuery = canonicalQuery(u.RawQuery, amp)
+
+	// Fragments only pick a place on the page, except for the routes of
+	// single-page apps.
+	if !strings.HasPrefix(u.Fragment, "!") && !strings.HasPrefix(u.Fragment, "/") {
+		u.Fragment = ""
+	}
+
+	u.RawFragment = ""
+
+	return u.String(), nil
+}
+
+// Resolve returns the canonical form of a page's URL, taking into account the
+// URL the page itself names as canonical with <link rel="canonical">, which
+// can be empty. The page is only trusted to name a URL on its own site, and
+// not to name the home page unless it is the home page, since sites that get
+// this wrong do so for every page. This is how AMP pages outside of the known
+// AMP hosts are turned into the pages they are copies of, as they name them as
+// canonical.
+func Resolve(pageURL, relCanonical string) (string, error) {
+	page, err := URL(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	if relCanonical == "" {
+		return page, nil
+	}
+
+	rel, err := URL(relCanonical)
+	if err != nil {
+		return page, nil
+	}
+
+	pu, _ := url.Parse(page)
+	ru, _ := url.Parse(rel)
+
+	if pu.Host != ru.Host || (ru.Path == "/" && pu.Path != "/") {
+		return page, nil
+	}
+
+	return rel, nil
+}
+
+func canonicalHost(u *url.URL) string {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	for _, p := range hostPrefixes {
+		// Only a subdomain is dropped, so m.com stays as it is.
+		if strings.HasPrefix(host, p) && strings.Count(host, ".") > 1 {
+			host = strings.TrimPrefix(host, p)
+
+			break
+		}
+	}
+
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return net.JoinHostPort(host, port)
+	}
+
+	// IPv6 addresses keep their brackets.
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+
+	return host
+}
+
+// canonicalPath cleans up the path, dropping the AMP markers in it if the URL
+// is known to be of an AMP page.
+func canonicalPath(p string, amp bool) string {
+	if amp {
+		switch {
+		case strings.HasSuffix(p, ".amp.html"):
+			p = strings.TrimSuffix(p, ".amp.html") + ".html"
+		case strings.HasSuffix(p, "/amp") || strings.HasSuffix(p, "/amp/"):
+			p = p[:strings.LastIndex(strings.TrimSuffix(p, "/"), "/")]
+		case strings.HasPrefix(p, "/amp/"):
+			p = strings.TrimPrefix(p, "/amp")
+		}
+	}
+
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return "/"
+	}
+
+	return p
+}
+
+func canonicalQuery(raw string, amp bool) string {
+	if raw == "" {
+		return ""
+	}
+
+	// Pairs that can't be parsed, such as ones separated by semicolons, are
+	// left out of the values but the rest are kept.
+	values, _ := url.ParseQuery(raw)
+
+	for key, vals := range values {
+		if isTrackingParam(key) {
+			delete(values, key)
+
+			continue
+		}
+
+		if amp && key == "amp" && (len(vals) == 0 || vals[0] == "" || vals[0] == "1") {
+			delete(values, key)
+		}
+	}
+
+	// Encode sorts the parameters by key.
+	return values.Encode()
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+
+	if trackingParams[key] {
+		return true
+	}
+
+	for _, p := range trackingPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unwrapAMPCache returns the URL of the page that an AMP cache URL, such as
+// https://example-com.cdn.ampproject.org/c/s/example.com/story or
+// https://www.google.com/amp/s/example.com/story, is a copy of, and whether it
+// was one. Other URLs are returned as they are.
+func unwrapAMPCache(u *url.URL) (*url.URL, bool) {
+	host := strings.ToLower(u.Hostname())
+
+	var rest string
+
+	switch {
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		for _, p := range []string{"/c/", "/v/", "/i/"} {
+			if strings.HasPrefix(u.Path, p) {
+				rest = strings.TrimPrefix(u.Path, p)
+
+				break
+			}
+		}
+	case strings.TrimPrefix(host, "www.") == "google.com":
+		if strings.HasPrefix(u.Path, "/amp/") {
+			rest = strings.TrimPrefix(u.Path, "/amp/")
+		}
+	}
+
+	if rest == "" {
+		return u, false
+	}
+
+	// The s/ segment means the page is served over https.
+	scheme := "http"
+	if strings.HasPrefix(rest, "s/") {
+		scheme = "https"
+		rest = strings.TrimPrefix(rest, "s/")
+	}
+
+	inner, err := url.Parse(scheme + "://" + rest)
+	if err != nil || inner.Host == "" {
+		return u, false
+	}
+
+	inner.RawQuery = u.RawQuery
+	inner.Fragment = u.Fragment
+
+	return inner, true
+}
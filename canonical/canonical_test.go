@@ -0,0 +1,217 @@
+package canonical
+
+import "testing"
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Given  string
+		Expect string
+	}{
+		{
+			Name:   "already canonical",
+			Given:  "https://example.com/posts/1",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:   "scheme, host and port",
+			Given:  "HTTP://WWW.Example.COM:80/posts/1",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:   "other ports are kept",
+			Given:  "http://example.com:8080/posts/1",
+			Expect: "https://example.com:8080/posts/1",
+		},
+		{
+			Name:   "mobile subdomains",
+			Given:  "https://m.example.com/posts/1",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:   "single-label domains are kept",
+			Given:  "https://m.com/posts/1",
+			Expect: "https://m.com/posts/1",
+		},
+		{
+			Name:   "trailing slash and fragment",
+			Given:  "https://example.com/posts/1/#comments",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:   "root",
+			Given:  "https://example.com",
+			Expect: "https://example.com/",
+		},
+		{
+			Name:   "hashbang routes are kept",
+			Given:  "https://example.com/#!/posts/1",
+			Expect: "https://example.com/#!/posts/1",
+		},
+		{
+			Name:   "tracking parameters",
+			Given:  "https://example.com/posts/1?utm_source=twitter&UTM_Medium=social&fbclid=abc&gclid=def",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:   "query is sorted",
+			Given:  "https://example.com/search?q=go&page=2&utm_campaign=x",
+			Expect: "https://example.com/search?page=2&q=go",
+		},
+		{
+			Name:   "ipv6 with port",
+			Given:  "http://[2001:db8::1]:8080/posts/1",
+			Expect: "https://[2001:db8::1]:8080/posts/1",
+		},
+		{
+			Name:   "ipv6",
+			Given:  "http://[2001:db8::1]:443/posts/1",
+			Expect: "https://[2001:db8::1]/posts/1",
+		},
+		{
+			Name:   "amp query parameter",
+			Given:  "https://amp.example.com/posts/1?amp=1",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:   "amp query parameter on other hosts",
+			Given:  "https://example.com/posts/1?amp=1",
+			Expect: "https://example.com/posts/1?amp=1",
+		},
+		{
+			Name:   "amp path suffix",
+			Given:  "https://amp.example.com/news/story/amp/",
+			Expect: "https://example.com/news/story",
+		},
+		{
+			Name:   "amp tag page",
+			Given:  "https://example.com/tags/amp",
+			Expect: "https://example.com/tags/amp",
+		},
+		{
+			Name:   "amp path prefix",
+			Given:  "https://example-com.cdn.ampproject.org/c/s/example.com/amp/news/story",
+			Expect: "https://example.com/news/story",
+		},
+		{
+			Name:   "amp guide",
+			Given:  "https://example.com/amp/guide",
+			Expect: "https://example.com/amp/guide",
+		},
+		{
+			Name:   "amp html",
+			Given:  "https://amp.example.com/news/story.amp.html",
+			Expect: "https://example.com/news/story.html",
+		},
+		{
+			Name:   "amp html on other hosts",
+			Given:  "https://example.com/news/story.amp.html",
+			Expect: "https://example.com/news/story.amp.html",
+		},
+		{
+			Name:   "amp subdomain",
+			Given:  "https://amp.example.com/news/story",
+			Expect: "https://example.com/news/story",
+		},
+		{
+			Name:   "amp cache",
+			Given:  "https://example-com.cdn.ampproject.org/c/s/example.com/news/story?utm_source=x",
+			Expect: "https://example.com/news/story",
+		},
+		{
+			Name:   "google amp viewer",
+			Given:  "https://www.google.com/amp/s/www.example.com/news/story/amp",
+			Expect: "https://example.com/news/story",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := URL(tt.Given)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.Expect {
+				t.Errorf("got %q, want %q", got, tt.Expect)
+			}
+		})
+	}
+}
+
+func TestURLErrors(t *testing.T) {
+	for _, given := range []string{"", "mailto:someone@example.com", "/relative/path", "https://"} {
+		if got, err := URL(given); err == nil {
+			t.Errorf("%q: expected an error, got %q", given, got)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Page      string
+		Canonical string
+		Expect    string
+	}{
+		{
+			Name:   "no canonical",
+			Page:   "https://example.com/posts/1?utm_source=x",
+			Expect: "https://example.com/posts/1",
+		},
+		{
+			Name:      "same site",
+			Page:      "https://example.com/posts/1?ref=home",
+			Canonical: "https://www.example.com/posts/1",
+			Expect:    "https://example.com/posts/1",
+		},
+		{
+			Name:      "same site, different path",
+			Page:      "https://example.com/p/123",
+			Canonical: "https://example.com/posts/why-go",
+			Expect:    "https://example.com/posts/why-go",
+		},
+		{
+			Name:      "other site",
+			Page:      "https://mirror.example.org/posts/1",
+			Canonical: "https://example.com/posts/1",
+			Expect:    "https://mirror.example.org/posts/1",
+		},
+		{
+			Name:      "home page",
+			Page:      "https://example.com/posts/1",
+			Canonical: "https://example.com/",
+			Expect:    "https://example.com/posts/1",
+		},
+		{
+			Name:      "amp page",
+			Page:      "https://example.com/news/story/amp",
+			Canonical: "https://example.com/news/story",
+			Expect:    "https://example.com/news/story",
+		},
+		{
+			Name:   "amp guide",
+			Page:   "https://example.com/amp/guide",
+			Expect: "https://example.com/amp/guide",
+		},
+		{
+			Name:      "unparsable",
+			Page:      "https://example.com/posts/1",
+			Canonical: "/posts/1",
+			Expect:    "https://example.com/posts/1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := Resolve(tt.Page, tt.Canonical)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.Expect {
+				t.Errorf("got %q, want %q", got, tt.Expect)
+			}
+		})
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"os"
 	"os/signal"
 	"time"
+	// The time zone database is embedded for digest schedules, since the
+	// server's image may not have one.
+	_ "time/tzdata"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
@@ -19,10 +22,14 @@ import (
 	"github.com/linksort/linksort/analyze"
 	"github.com/linksort/linksort/db"
 	"github.com/linksort/linksort/email"
+	"github.com/linksort/linksort/embed"
+	"github.com/linksort/linksort/epub"
 	"github.com/linksort/linksort/errors"
 	"github.com/linksort/linksort/handler"
+	"github.com/linksort/linksort/health"
 	"github.com/linksort/linksort/log"
 	"github.com/linksort/linksort/magic"
+	"github.com/linksort/linksort/queue"
 )
 
 // @title					Linksort API
@@ -91,6 +98,9 @@ func main() {
 	}
 	defer analyzer.Close()
 
+	// Create the job queue. It's started once the handlers are registered.
+	jobQueue := queue.New(db.NewJobStore(mongo))
+
 	// Create the server instance
 	port := getenv("PORT", "8080")
 	srv := http.Server{
@@ -98,11 +108,20 @@ func main() {
 			Transactor:            db.NewTxnClient(mongo),
 			UserStore:             db.NewUserStore(mongo),
 			LinkStore:             db.NewLinkStore(mongo),
+			RevisionStore:         db.NewRevisionStore(mongo),
+			SnapshotStore:         db.NewSnapshotStore(mongo),
+			LinkChangeStore:       db.NewLinkChangeStore(mongo),
+			ImportJobStore:        db.NewImportJobStore(mongo),
 			ConversationStore:     db.NewConversationStore(mongo),
 			Magic:                 magic.New(getenv("APP_SECRET", "")),
 			Email:                 email.New(getenv("MAILGUN_KEY", "")),
 			Analyzer:              analyzer,
+			HealthChecker:         health.New(),
+			ImageFetcher:          epub.NewImageFetcher(),
+			Queue:                 jobQueue,
+			Embedder:              embed.NewBedrock(bedrockClient),
 			BedrockClient:         agent.AdaptBedrock(bedrockClient),
+			ImageProxyURL:         getenv("IMAGE_PROXY_URL", ""),
 			FrontendProxyHostname: getenv("FRONTEND_HOSTNAME", "localhost"),
 			FrontendProxyPort:     getenv("FRONTEND_PORT", "3000"),
 			IsProd:                isProd,
@@ -112,14 +131,31 @@ func main() {
 		Addr:         fmt.Sprintf(":%s", port),
 	}
 
-	// Handle shutdown properly
+	jobQueue.Start()
+
+	// Handle shutdown properly. Requests in flight are finished first, then
+	// the jobs that are running. Jobs that don't finish in time are picked up
+	// again when the server next starts.
+	drained := make(chan struct{})
 	go func() {
+		defer close(drained)
+
 		signalC := make(chan os.Signal, 1)
 		signal.Notify(signalC, os.Interrupt)
 		defer signal.Stop(signalC)
 
 		<-signalC
-		srv.Shutdown(ctx)
+
+		shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Alarm(err)
+		}
+
+		if err := jobQueue.Shutdown(shutdownCtx); err != nil {
+			log.Alarm(err)
+		}
 	}()
 
 	log.Printf("Listening on port :%s", port)
@@ -130,6 +166,7 @@ func main() {
 		log.Panicf("ListenAndServe: %v", err)
 	}
 
+	<-drained
 	log.Print("Bye")
 }
 
@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/linksort/linksort/db"
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/search"
+)
+
+const (
+	// digestBatchSize is the most users a JobQueueDigests run queues.
+	digestBatchSize = 500
+	// digestSectionSize is how many links each section of a digest has, and
+	// digestCandidates how many links each section picks from.
+	digestSectionSize = 3
+	digestCandidates  = 50
+	// digestHighlights is the most highlights a digest has.
+	digestHighlights = 5
+	// digestUnreadAge and digestFavoriteAge are how long ago links must have
+	// been saved to be resurfaced as unread or favorite.
+	digestUnreadAge   = 7 * 24 * time.Hour
+	digestFavoriteAge = 90 * 24 * time.Hour
+	// digestOnThisDayYears is how many years back "on this day" looks.
+	digestOnThisDayYears = 10
+	// digestUnsubscribeSalt sets unsubscribe links apart from other signed
+	// links, and digestUnsubscribeExpiry is how long they work.
+	digestUnsubscribeSalt   = "digest-unsubscribe"
+	digestUnsubscribeExpiry = 365 * 24 * time.Hour
+)
+
+// Digester sends the digest emails of the users who opted in to them, each
+// on the user's schedule.
+type Digester struct {
+	Store interface {
+		GetLinksByUser(context.Context, *model.User, *model.Pagination, ...model.GetLinksOption) ([]*model.Link, error)
+		GetHighlightsByUser(context.Context, *model.User, *model.Pagination) ([]*model.LinkHighlight, error)
+	}
+	UserStore interface {
+		GetUserByID(context.Context, string) (*model.User, error)
+		GetUsersDueForDigest(ctx context.Context, limit int) ([]*model.User, error)
+		UpdateUserDigest(context.Context, *model.User) error
+	}
+	Email interface {
+		SendDigest(context.Context, *model.User, *model.Digest) error
+	}
+	Magic interface {
+		Link(action, email, salt string) string
+	}
+	Queue interface {
+		EnqueueUnique(ctx context.Context, kind, key string, payload interface{}) error
+	}
+}
+
+// QueueDigests queues a JobSendDigest for each user whose digest is due.
+// Users who already have one queued aren't queued again.
+func (d *Digester) QueueDigests(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.QueueDigests(%q)", job.ID)
+
+	users, err := d.UserStore.GetUsersDueForDigest(ctx, digestBatchSize)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, u := range users {
+		err := d.Queue.EnqueueUnique(ctx, JobSendDigest, JobSendDigest+":"+u.ID, &digestJob{u.ID})
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// SendDigest runs a JobSendDigest. A digest that fails to send is retried by
+// the queue. Once the job is out of attempts the digest is skipped and the
+// next one is scheduled as usual, so that a broken address doesn't get tried
+// over and over.
+func (d *Digester) SendDigest(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.SendDigest(%q)", job.ID)
+
+	p := new(digestJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	u, err := d.UserStore.GetUserByID(ctx, p.UserID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	now := time.Now()
+
+	if !u.Digest.IsEnabled || u.Digest.NextSendAt == nil || u.Digest.NextSendAt.After(now) {
+		return nil
+	}
+
+	err = d.Send(ctx, u)
+	if err != nil && job.IsLastAttempt() && ctx.Err() == nil {
+		if serr := d.scheduleNext(ctx, u, now); serr != nil {
+			return errors.E(op, serr)
+		}
+	}
+
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// Send sends the user's digest, unless there is nothing in it, and schedules
+// the next one.
+func (d *Digester) Send(ctx context.Context, u *model.User) error {
+	op := errors.Opf("controller.Digester.Send(%q)", u.ID)
+
+	now := time.Now()
+
+	digest, err := d.GetDigest(ctx, u, now)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if !digest.IsEmpty() {
+		if err := d.Email.SendDigest(ctx, u, digest); err != nil {
+			return errors.E(op, err)
+		}
+
+		u.Digest.LastSentAt = &now
+	}
+
+	if err := d.scheduleNext(ctx, u, now); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// scheduleNext saves when the user's next digest is due after now.
+func (d *Digester) scheduleNext(ctx context.Context, u *model.User, now time.Time) error {
+	next := u.Digest.Next(now)
+	u.Digest.NextSendAt = &next
+
+	return d.UserStore.UpdateUserDigest(ctx, u)
+}
+
+// GetDigest picks the links and highlights to resurface for the user.
+func (d *Digester) GetDigest(ctx context.Context, u *model.User, now time.Time) (*model.Digest, error) {
+	op := errors.Opf("controller.Digester.GetDigest(%q)", u.ID)
+
+	digest := &model.Digest{
+		UnsubscribeURL: d.Magic.Link("unsubscribe", u.Email, digestUnsubscribeSalt),
+	}
+
+	unread, err := d.Store.GetLinksByUser(ctx, u, &model.Pagination{Size: digestCandidates},
+		db.GetLinksReadState(model.ReadStateUnread),
+		db.GetLinksQuery(savedBefore(now.Add(-digestUnreadAge))),
+		db.GetLinksSort("1"))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	digest.Unread = pickLinks(unread, digestSectionSize)
+
+	favorites, err := d.Store.GetLinksByUser(ctx, u, &model.Pagination{Size: digestCandidates},
+		db.GetLinksFavorites("1"),
+		db.GetLinksQuery(savedBefore(now.Add(-digestFavoriteAge))),
+		db.GetLinksSort("1"))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	digest.Favorites = pickLinks(notOpenedSince(favorites, now.Add(-digestFavoriteAge)), digestSectionSize)
+
+	onThisDay, err := d.Store.GetLinksByUser(ctx, u, &model.Pagination{Size: digestCandidates},
+		db.GetLinksQuery(savedOnThisDay(now.In(digestLocation(u)))))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	digest.OnThisDay = pickLinks(onThisDay, digestSectionSize)
+
+	highlights, err := d.Store.GetHighlightsByUser(ctx, u, &model.Pagination{Size: digestHighlights})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	since := now.Add(-7 * 24 * time.Hour)
+	if u.Digest.LastSentAt != nil {
+		since = *u.Digest.LastSentAt
+	}
+
+	for _, h := range highlights {
+		if h.Highlight.CreatedAt.After(since) {
+			digest.Highlights = append(digest.Highlights, h)
+		}
+	}
+
+	return digest, nil
+}
+
+func digestLocation(u *model.User) *time.Location {
+	loc, err := time.LoadLocation(u.Digest.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// savedBefore matches the links saved before t.
+func savedBefore(t time.Time) *search.Query {
+	return &search.Query{Clauses: [][]*search.Filter{{{Field: search.FieldBefore, To: t}}}}
+}
+
+// savedOnThisDay matches the links saved on the same day as t in earlier
+// years.
+func savedOnThisDay(t time.Time) *search.Query {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	clause := make([]*search.Filter, 0, digestOnThisDayYears)
+	for y := 1; y <= digestOnThisDayYears; y++ {
+		from := day.AddDate(-y, 0, 0)
+		clause = append(clause, &search.Filter{Field: search.FieldCreated, From: from, To: from.AddDate(0, 0, 1)})
+	}
+
+	return &search.Query{Clauses: [][]*search.Filter{clause}}
+}
+
+func notOpenedSince(links []*model.Link, t time.Time) []*model.Link {
+	kept := make([]*model.Link, 0, len(links))
+	for _, l := range links {
+		if l.LastOpenedAt == nil || l.LastOpenedAt.Before(t) {
+			kept = append(kept, l)
+		}
+	}
+
+	return kept
+}
+
+// pickLinks picks n of the links at random, so that digests don't show the
+// same links every time, and keeps them in their order.
+func pickLinks(links []*model.Link, n int) []*model.Link {
+	if len(links) <= n {
+		return links
+	}
+
+	picked := rand.Perm(len(links))[:n]
+	keep := make(map[int]bool, n)
+	for _, i := range picked {
+		keep[i] = true
+	}
+
+	out := make([]*model.Link, 0, n)
+	for i, l := range links {
+		if keep[i] {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+type mockDigestStore struct {
+	links      []*model.Link
+	highlights []*model.LinkHighlight
+}
+
+func (m *mockDigestStore) GetLinksByUser(
+	context.Context,
+	*model.User,
+	*model.Pagination,
+	...model.GetLinksOption,
+) ([]*model.Link, error) {
+	return m.links, nil
+}
+
+func (m *mockDigestStore) GetHighlightsByUser(context.Context, *model.User, *model.Pagination) ([]*model.LinkHighlight, error) {
+	return m.highlights, nil
+}
+
+type mockDigestUserStore struct {
+	user    *model.User
+	updated *model.User
+}
+
+func (m *mockDigestUserStore) GetUserByID(context.Context, string) (*model.User, error) {
+	u := *m.user
+	return &u, nil
+}
+
+func (m *mockDigestUserStore) GetUsersDueForDigest(context.Context, int) ([]*model.User, error) {
+	return nil, errors.Str("not implemented")
+}
+
+func (m *mockDigestUserStore) UpdateUserDigest(_ context.Context, u *model.User) error {
+	m.updated = u
+	return nil
+}
+
+type mockDigestEmail struct {
+	sent []*model.Digest
+	err  error
+}
+
+func (m *mockDigestEmail) SendDigest(_ context.Context, _ *model.User, d *model.Digest) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.sent = append(m.sent, d)
+	return nil
+}
+
+type mockMagic struct{}
+
+func (mockMagic) Link(action, email, salt string) string {
+	return "https://linksort.com/" + action + "?u=" + email
+}
+
+func TestDigesterSend(t *testing.T) {
+	ctx := context.Background()
+	lastSent := time.Now().Add(-7 * 24 * time.Hour)
+	usr := &model.User{
+		ID:     "u",
+		Email:  "ada@example.com",
+		Digest: model.DigestSchedule{IsEnabled: true, Weekday: time.Monday, Hour: 9, LastSentAt: &lastSent},
+	}
+
+	store := &mockDigestStore{}
+	users := &mockDigestUserStore{}
+	mail := &mockDigestEmail{}
+	digester := Digester{Store: store, UserStore: users, Email: mail, Magic: mockMagic{}}
+
+	if err := digester.Send(ctx, usr); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mail.sent) != 0 {
+		t.Error("expected an empty digest not to be sent")
+	}
+
+	if users.updated == nil || users.updated.Digest.NextSendAt == nil || !users.updated.Digest.NextSendAt.After(time.Now()) {
+		t.Fatal("expected the next digest to be scheduled")
+	}
+
+	if users.updated.Digest.LastSentAt != &lastSent {
+		t.Error("expected the last sent time to be kept when nothing was sent")
+	}
+
+	link := &model.Link{ID: "l", URL: "https://example.com"}
+	store.links = []*model.Link{link, {ID: "m"}, {ID: "n"}, {ID: "o"}}
+	store.highlights = []*model.LinkHighlight{
+		{Highlight: &model.Highlight{ID: "new", CreatedAt: time.Now()}, Link: link},
+		{Highlight: &model.Highlight{ID: "old", CreatedAt: lastSent.Add(-time.Hour)}, Link: link},
+	}
+
+	if err := digester.Send(ctx, usr); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mail.sent) != 1 {
+		t.Fatalf("expected a digest to be sent, got %d", len(mail.sent))
+	}
+
+	d := mail.sent[0]
+	if len(d.Unread) != digestSectionSize {
+		t.Errorf("got %d unread links, want %d", len(d.Unread), digestSectionSize)
+	}
+
+	if len(d.Highlights) != 1 || d.Highlights[0].Highlight.ID != "new" {
+		t.Errorf("expected only the highlights since the last digest: %+v", d.Highlights)
+	}
+
+	if d.UnsubscribeURL != "https://linksort.com/unsubscribe?u=ada@example.com" {
+		t.Errorf("unexpected unsubscribe URL: %s", d.UnsubscribeURL)
+	}
+
+	if !usr.Digest.LastSentAt.After(lastSent) {
+		t.Error("expected the last sent time to be updated")
+	}
+}
+
+func TestSendDigest_Failed(t *testing.T) {
+	due := time.Now().Add(-time.Minute)
+	users := &mockDigestUserStore{user: &model.User{
+		ID:     "u",
+		Email:  "ada@example.com",
+		Digest: model.DigestSchedule{IsEnabled: true, Weekday: time.Monday, Hour: 9, NextSendAt: &due},
+	}}
+	store := &mockDigestStore{links: []*model.Link{{ID: "l", URL: "https://example.com"}}}
+	mail := &mockDigestEmail{err: errors.Str("mailbox unavailable")}
+	digester := Digester{Store: store, UserStore: users, Email: mail, Magic: mockMagic{}}
+
+	job := newTestJob(t, &digestJob{"u"})
+	job.Attempts, job.MaxAttempts = 1, 2
+
+	if err := digester.SendDigest(context.Background(), job); err == nil {
+		t.Fatal("expected the job to fail so that it is retried")
+	}
+
+	if users.updated != nil {
+		t.Fatal("expected the digest to still be due")
+	}
+
+	job.Attempts = 2
+
+	if err := digester.SendDigest(context.Background(), job); err == nil {
+		t.Fatal("expected the last attempt to fail too")
+	}
+
+	if users.updated == nil || !users.updated.Digest.NextSendAt.After(time.Now()) {
+		t.Fatal("expected the next digest to be scheduled once the job is out of attempts")
+	}
+
+	if users.updated.Digest.LastSentAt != nil {
+		t.Error("expected the digest not to count as sent")
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/log"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+)
+
+const (
+	// embedBatchSize is how many links the backfill reads at a time.
+	embedBatchSize = 100
+	// maxSemanticLength is how long a semantic search query can be, in
+	// characters.
+	maxSemanticLength = 1000
+)
+
+// Embed is the job that embeds a link's text so that it can be found by
+// semantic search. It runs after the link's corpus is gathered and whenever
+// the text it embeds changes.
+func (l *Link) Embed(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.Embed(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := l.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	if err := l.embed(ctx, link); err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// BackfillEmbeddings is the job that embeds the links that have no embedding
+// from the current model, such as links saved before semantic search existed
+// or before the model changed. Links that fail are logged and skipped, and
+// the job fails at the end so that the queue tries them again later.
+func (l *Link) BackfillEmbeddings(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.BackfillEmbeddings(%q)", job.ID)
+
+	var after string
+	var failed int
+
+	for {
+		links, err := l.Store.GetLinksWithoutEmbedding(ctx, l.Embedder.Model(), after, embedBatchSize)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, link := range links {
+			if err := l.embed(ctx, link); err != nil && !isNotFound(err) {
+				if ctx.Err() != nil {
+					return errors.E(op, ctx.Err())
+				}
+
+				log.Alarm(errors.E(op, err))
+				failed++
+			}
+		}
+
+		if len(links) < embedBatchSize {
+			break
+		}
+
+		after = links[len(links)-1].ID
+	}
+
+	if failed > 0 {
+		return errors.E(op, errors.Strf("failed to embed %d links", failed))
+	}
+
+	return nil
+}
+
+func (l *Link) embed(ctx context.Context, link *model.Link) error {
+	op := errors.Opf("controller.embed(%q)", link.ID)
+
+	text := embeddingText(link)
+	if text == "" {
+		return nil
+	}
+
+	vector, err := l.Embedder.Embed(ctx, text)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	err = l.Store.SetLinkEmbedding(ctx, link, &model.Embedding{
+		Model:     l.Embedder.Model(),
+		Vector:    vector,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// embeddingText is the text of the link that is embedded: what the page says
+// about itself first, then what the user said about it, then the page's text.
+func embeddingText(link *model.Link) string {
+	corpus := model.CorpusText(link.Corpus)
+
+	parts := make([]string, 0, 4)
+	for _, s := range []string{link.Title, link.Description, link.Annotation, corpus} {
+		if s = strings.Join(strings.Fields(s), " "); s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
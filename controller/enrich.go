@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/linksort/linksort/analyze"
+	"github.com/linksort/linksort/db"
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+// enrichBatchSize is the most links a JobQueueEnrichments run queues.
+const enrichBatchSize = 500
+
+// Enricher fetches the metadata of links that were saved without it, such as
+// imported links, and adds their tags to the owner's tag tree.
+type Enricher struct {
+	Store interface {
+		GetLinkByID(ctx context.Context, id string) (*model.Link, error)
+		UpdateLink(ctx context.Context, link *model.Link) (*model.Link, error)
+		GetLinksToEnrich(ctx context.Context, limit int) ([]*model.Link, error)
+		UpdateLinkEnrichment(ctx context.Context, link *model.Link) error
+	}
+	UserStore interface {
+		GetUserByID(ctx context.Context, id string) (*model.User, error)
+		UpdateUser(ctx context.Context, u *model.User) (*model.User, error)
+	}
+	Analyzer interface {
+		Do(context.Context, *analyze.Request) (*analyze.Response, error)
+	}
+	Transactor db.Transactor
+	Queue      interface {
+		Enqueue(ctx context.Context, kind string, payload interface{}) error
+		EnqueueUnique(ctx context.Context, kind, key string, payload interface{}) error
+	}
+	// Limiter spaces out requests to the same domain.
+	Limiter *ratelimit.Limiter
+}
+
+// QueueEnrichments queues a JobEnrich for each link that is waiting for its
+// metadata. Links that already have one queued aren't queued again.
+func (e *Enricher) QueueEnrichments(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.QueueEnrichments(%q)", job.ID)
+
+	links, err := e.Store.GetLinksToEnrich(ctx, enrichBatchSize)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, link := range links {
+		err := e.Queue.EnqueueUnique(ctx, JobEnrich, JobEnrich+":"+link.ID, &linkJob{link.ID})
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// EnrichLink runs a JobEnrich. A link whose metadata can't be fetched is
+// retried by the queue, and given up on once the job is out of attempts.
+func (e *Enricher) EnrichLink(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.EnrichLink(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := e.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	if !link.NeedsEnrichment || link.IsTrashed {
+		return nil
+	}
+
+	if err := waitForHost(ctx, e.Limiter, link.URL); err != nil {
+		return err
+	}
+
+	err = e.Enrich(ctx, link)
+	if err != nil && job.IsLastAttempt() && ctx.Err() == nil {
+		if gerr := e.giveUp(ctx, link); gerr != nil {
+			return errors.E(op, gerr)
+		}
+	}
+
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// Enrich fetches the link's metadata and saves it. A link whose URL can't be
+// fetched at all is given up on right away.
+func (e *Enricher) Enrich(ctx context.Context, link *model.Link) error {
+	op := errors.Opf("controller.Enrich(%q)", link.ID)
+
+	if _, err := url.Parse(link.URL); err != nil {
+		return e.giveUp(ctx, link)
+	}
+
+	dat, err := e.Analyzer.Do(ctx, &analyze.Request{
+		URL:         link.URL,
+		Title:       link.Title,
+		Description: link.Description,
+	})
+	if errors.Is(err, analyze.ErrUnparsableURI) {
+		return e.giveUp(ctx, link)
+	}
+
+	if err != nil && !errors.Is(err, analyze.ErrNoClassify) {
+		return errors.E(op, err)
+	}
+
+	if dat == nil {
+		return errors.E(op, errors.Str("no metadata"))
+	}
+
+	err = e.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+		innerOp := errors.Opf("%s.innerTxn", op)
+
+		// The link is read again since the user may have changed it while its
+		// page was being fetched.
+		current, err := e.Store.GetLinkByID(sessCtx, link.ID)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		user, err := e.UserStore.GetUserByID(sessCtx, current.UserID)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		tagDetails := model.ParseTagDetails(dat.Tags)
+
+		// Tags of trashed links don't count towards the tag tree.
+		if !current.IsTrashed {
+			if err := user.TagTree.UpdateWithDeletedTagDetails(current.TagDetails); err != nil {
+				return errors.E(innerOp, err)
+			}
+
+			if err := user.TagTree.UpdateWithNewTagDetails(tagDetails); err != nil {
+				return errors.E(innerOp, err)
+			}
+		}
+
+		applyEnrichment(current, dat)
+		current.TagDetails = tagDetails
+		current.TagPaths = model.ParseTagDetailsToPathList(dat.Tags)
+
+		if _, err = e.Store.UpdateLink(sessCtx, current); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if _, err = e.UserStore.UpdateUser(sessCtx, user); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if isNotFound(err) {
+			// The link was deleted in the meantime.
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	// The full text is fetched and the first snapshot taken the same way as
+	// for links the user saves.
+	if err := e.Queue.Enqueue(ctx, JobGatherCorpus, &linkJob{link.ID}); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := e.Queue.Enqueue(ctx, JobSnapshot, &linkJob{link.ID}); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// giveUp marks the link as having failed to be enriched, so that it isn't
+// tried again.
+func (e *Enricher) giveUp(ctx context.Context, link *model.Link) error {
+	op := errors.Opf("controller.Enricher.giveUp(%q)", link.ID)
+
+	link.NeedsEnrichment = false
+	link.EnrichFailed = true
+
+	if err := e.Store.UpdateLinkEnrichment(ctx, link); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// applyEnrichment fills in the link's metadata from the analyzer's response.
+// The title and description are only filled in when the link doesn't have
+// them, since they may have come from the user.
+func applyEnrichment(link *model.Link, dat *analyze.Response) {
+	if link.Title == "" {
+		link.Title = dat.Title
+	}
+
+	if link.Description == "" {
+		link.Description = dat.Description
+	}
+
+	link.Favicon = dat.Favicon
+	link.Image = dat.Image
+	link.Site = dat.Site
+	link.Corpus = dat.Corpus
+	link.ReanchorHighlights()
+	link.IsArticle = dat.IsArticle
+	link.IsSummarized = link.IsSummarized || !dat.IsArticle
+	link.NeedsEnrichment = false
+	link.EnrichFailed = false
+}
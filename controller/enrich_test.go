@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/linksort/linksort/analyze"
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+type mockEnrichStore struct {
+	link    *model.Link
+	updated *model.Link
+}
+
+func (m *mockEnrichStore) GetLinkByID(context.Context, string) (*model.Link, error) {
+	l := *m.link
+	return &l, nil
+}
+func (m *mockEnrichStore) UpdateLink(_ context.Context, l *model.Link) (*model.Link, error) {
+	m.updated = l
+	return l, nil
+}
+func (m *mockEnrichStore) GetLinksToEnrich(context.Context, int) ([]*model.Link, error) {
+	return []*model.Link{m.link}, nil
+}
+func (m *mockEnrichStore) UpdateLinkEnrichment(_ context.Context, l *model.Link) error {
+	m.updated = l
+	return nil
+}
+
+type mockEnrichUserStore struct {
+	user *model.User
+}
+
+func (m *mockEnrichUserStore) GetUserByID(context.Context, string) (*model.User, error) {
+	return m.user, nil
+}
+func (m *mockEnrichUserStore) UpdateUser(_ context.Context, u *model.User) (*model.User, error) {
+	return u, nil
+}
+
+type mockAnalyzer struct {
+	err error
+}
+
+func (m *mockAnalyzer) Do(_ context.Context, req *analyze.Request) (*analyze.Response, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &analyze.Response{
+		URL:     req.URL,
+		Title:   "Fetched title",
+		Favicon: "https://example.com/favicon.ico",
+		Tags:    []*analyze.Tag{{Name: "/Computers", Confidence: 0.9}},
+	}, nil
+}
+
+type mockQueue struct {
+	kinds []string
+	keys  []string
+}
+
+func (m *mockQueue) Enqueue(_ context.Context, kind string, _ interface{}) error {
+	m.kinds = append(m.kinds, kind)
+	return nil
+}
+func (m *mockQueue) EnqueueUnique(_ context.Context, kind, key string, _ interface{}) error {
+	m.kinds = append(m.kinds, kind)
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+type mockTransactor struct{}
+
+func (mockTransactor) DoInTransaction(ctx context.Context, f func(context.Context) error) error {
+	return f(ctx)
+}
+
+// newTestJob makes a job with the given payload, as the queue would.
+func newTestJob(t *testing.T, payload interface{}) *model.Job {
+	t.Helper()
+
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &model.Job{ID: "job", Payload: raw, Attempts: 1, MaxAttempts: 5}
+}
+
+func newTestEnricher(link *model.Link, analyzerErr error) (*Enricher, *mockEnrichStore, *model.User) {
+	store := &mockEnrichStore{link: link}
+	user := &model.User{TagTree: &model.TagNode{Name: "root", Path: "root"}}
+
+	return &Enricher{
+		Store:      store,
+		UserStore:  &mockEnrichUserStore{user: user},
+		Analyzer:   &mockAnalyzer{err: analyzerErr},
+		Transactor: mockTransactor{},
+		Queue:      &mockQueue{},
+		Limiter:    ratelimit.New(0),
+	}, store, user
+}
+
+func TestEnrich(t *testing.T) {
+	link := &model.Link{
+		ID:              "1",
+		URL:             "https://example.com/a",
+		Title:           "Imported title",
+		NeedsEnrichment: true,
+	}
+
+	e, store, user := newTestEnricher(link, nil)
+
+	if err := e.Enrich(context.Background(), link); err != nil {
+		t.Fatal(err)
+	}
+
+	got := store.updated
+	if got == nil {
+		t.Fatal("expected the link to be updated")
+	}
+
+	if got.Title != "Imported title" {
+		t.Errorf("title was overwritten: %q", got.Title)
+	}
+
+	if got.Favicon == "" {
+		t.Errorf("metadata not filled in: %+v", got)
+	}
+
+	kinds := e.Queue.(*mockQueue).kinds
+	if len(kinds) != 2 || kinds[0] != JobGatherCorpus || kinds[1] != JobSnapshot {
+		t.Errorf("expected the corpus and a snapshot to be queued, got %v", kinds)
+	}
+
+	if got.NeedsEnrichment {
+		t.Errorf("link still marked for enrichment: %+v", got)
+	}
+
+	if user.TagTree.FindByPathname("Computers") == nil {
+		t.Error("expected the tag to be added to the tag tree")
+	}
+}
+
+func TestEnrichLink_GiveUp(t *testing.T) {
+	link := &model.Link{ID: "1", URL: "https://example.com/a", NeedsEnrichment: true}
+	e, store, _ := newTestEnricher(link, errors.Str("timeout"))
+
+	job := newTestJob(t, &linkJob{link.ID})
+	job.Attempts, job.MaxAttempts = 1, 2
+
+	if err := e.EnrichLink(context.Background(), job); err == nil {
+		t.Fatal("expected the job to fail so that it is retried")
+	}
+
+	if store.updated != nil {
+		t.Fatalf("link changed before it was given up on: %+v", store.updated)
+	}
+
+	job.Attempts = 2
+
+	if err := e.EnrichLink(context.Background(), job); err == nil {
+		t.Fatal("expected the last attempt to fail too")
+	}
+
+	if store.updated == nil || store.updated.NeedsEnrichment || !store.updated.EnrichFailed {
+		t.Errorf("expected enrichment to be given up: %+v", store.updated)
+	}
+}
+
+func TestEnrichLink_UnparsableURI(t *testing.T) {
+	link := &model.Link{ID: "1", URL: "https://example.com/a", NeedsEnrichment: true}
+	e, store, _ := newTestEnricher(link, analyze.ErrUnparsableURI)
+
+	job := newTestJob(t, &linkJob{link.ID})
+	job.Attempts, job.MaxAttempts = 1, 5
+
+	if err := e.EnrichLink(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.updated == nil || !store.updated.EnrichFailed {
+		t.Errorf("expected enrichment to be given up right away: %+v", store.updated)
+	}
+}
+
+func TestQueueEnrichments(t *testing.T) {
+	link := &model.Link{ID: "1", URL: "https://example.com/a", NeedsEnrichment: true}
+	e, _, _ := newTestEnricher(link, nil)
+
+	if err := e.QueueEnrichments(context.Background(), &model.Job{}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := e.Queue.(*mockQueue).keys
+	if len(keys) != 1 || keys[0] != "enrich:1" {
+		t.Errorf("expected one enrich job for the link, got %v", keys)
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/linksort/linksort/epub"
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/link"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/reader"
+)
+
+const (
+	// maxEPUBLinks is the most links that go in one book.
+	maxEPUBLinks = 500
+	// exportBatchSize is how many links are read from the store at a time
+	// while exporting.
+	exportBatchSize = 50
+)
+
+// ExportEPUB writes the links that GetLinks would list for the same request
+// to w as an EPUB book, one chapter per link, with their highlights and
+// annotations as endnotes. Only the first 500 links are included. Nothing is
+// written if the request isn't valid or no links match it.
+func (l *Link) ExportEPUB(
+	ctx context.Context,
+	u *model.User,
+	req *handler.GetLinksRequest,
+	w io.Writer,
+) error {
+	op := errors.Op("controller.ExportEPUB")
+
+	if req.Semantic != "" {
+		return errors.E(op,
+			errors.Str("semantic export"),
+			errors.M{"semantic": "Exports can't be ranked by meaning."},
+			http.StatusBadRequest)
+	}
+
+	opts, err := getLinksOptions(u, req)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	p := &model.Pagination{Page: 0, Size: exportBatchSize}
+
+	batch, err := l.Store.GetAllLinksByUser(ctx, u, p, opts...)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if len(batch) == 0 {
+		return errors.E(op,
+			errors.Str("no links"),
+			errors.M{"message": "There are no links to export."},
+			http.StatusNotFound)
+	}
+
+	book, err := epub.NewWriter(w, bookTitle(u, req), l.Images)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	count := 0
+
+	for {
+		for _, link := range batch {
+			if count == maxEPUBLinks {
+				break
+			}
+
+			ch, err := epubChapter(link)
+			if err != nil {
+				return errors.E(op, err)
+			}
+
+			if err := book.AddChapter(ctx, ch); err != nil {
+				return errors.E(op, err)
+			}
+
+			count++
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if count == maxEPUBLinks || len(batch) < p.Size {
+			break
+		}
+
+		p.Page++
+
+		batch, err = l.Store.GetAllLinksByUser(ctx, u, p, opts...)
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := book.Close(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// bookTitle names the book after what its links were chosen by.
+func bookTitle(u *model.User, req *handler.GetLinksRequest) string {
+	if req.SavedSearchID != "" {
+		if saved := u.SavedSearches.Find(req.SavedSearchID); saved != nil {
+			return saved.Name
+		}
+	}
+
+	if req.FolderID != "" && req.FolderID != "root" {
+		if f := u.FolderTree.BFS(req.FolderID); f != nil {
+			return f.Name
+		}
+	}
+
+	switch {
+	case req.TagPath != "":
+		return req.TagPath
+	case req.UserTag != "":
+		return req.UserTag
+	case req.Favorites == "1":
+		return "Favorites"
+	default:
+		return "Linksort"
+	}
+}
+
+// epubChapter makes the link into a chapter, with its page's text made safe
+// to show. Links without any text get their description instead.
+func epubChapter(link *model.Link) (*epub.Chapter, error) {
+	ch := &epub.Chapter{
+		Title:      link.Title,
+		Site:       link.Site,
+		URL:        link.URL,
+		SavedAt:    link.CreatedAt,
+		Annotation: link.Annotation,
+	}
+
+	for _, h := range link.Highlights {
+		ch.Highlights = append(ch.Highlights, &epub.Highlight{Quote: h.Quote, Note: h.Note})
+	}
+
+	if strings.TrimSpace(link.Corpus) == "" {
+		if link.Description != "" {
+			ch.HTML = fmt.Sprintf("<p>%s</p>", html.EscapeString(link.Description))
+		}
+
+		return ch, nil
+	}
+
+	base, err := url.Parse(link.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	article, err := reader.Render(link.Corpus, base, &reader.Options{Format: reader.FormatHTML})
+	if err != nil {
+		return nil, err
+	}
+
+	ch.HTML = article.Content
+
+	return ch, nil
+}
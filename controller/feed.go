@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/linksort/linksort/db"
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/feed"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/reader"
+	"github.com/linksort/linksort/syndication"
+)
+
+type Feed struct {
+	Store     model.UserStore
+	LinkStore model.LinkStore
+}
+
+const (
+	maxFeedCount = 100
+	// feedSize is how many of the newest links are in a feed.
+	feedSize = 50
+	// feedBaseURL is where the links in feeds are opened.
+	feedBaseURL = "https://linksort.com"
+)
+
+func (f *Feed) CreateFeed(
+	ctx context.Context,
+	usr *model.User,
+	req *handler.CreateFeedRequest,
+) (*model.User, error) {
+	op := errors.Op("controller.CreateFeed")
+
+	if len(usr.Feeds) >= maxFeedCount {
+		return nil, errors.E(op,
+			errors.Str("feed limit reached"),
+			errors.M{"message": "You have reached the limit of 100 feeds."},
+			http.StatusBadRequest)
+	}
+
+	if req.FolderID != "" && usr.FolderTree.BFS(req.FolderID) == nil {
+		return nil, errors.E(op,
+			errors.Strf("folder %q not found", req.FolderID),
+			errors.M{"folderId": "The given folder was not found."},
+			http.StatusBadRequest)
+	}
+
+	feed := model.NewFeed(req.Name)
+	feed.Favorites = req.Favorites
+	feed.FolderID = req.FolderID
+	feed.TagPath = req.TagPath
+	feed.UserTag = req.UserTag
+
+	usr.Feeds = append(usr.Feeds, feed)
+
+	usr, err := f.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+func (f *Feed) DeleteFeed(
+	ctx context.Context,
+	usr *model.User,
+	id string,
+) (*model.User, error) {
+	op := errors.Opf("controller.DeleteFeed(%q)", id)
+
+	var found *model.Feed
+
+	usr.Feeds, found = usr.Feeds.Remove(id)
+	if found == nil {
+		return nil, errFeedNotFound(op)
+	}
+
+	usr, err := f.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+// ResetFeedToken gives the feed a new token, so that whoever has the old one
+// can no longer read it.
+func (f *Feed) ResetFeedToken(
+	ctx context.Context,
+	usr *model.User,
+	id string,
+) (*model.User, error) {
+	op := errors.Opf("controller.ResetFeedToken(%q)", id)
+
+	feed := usr.Feeds.Find(id)
+	if feed == nil {
+		return nil, errFeedNotFound(op)
+	}
+
+	feed.ResetToken()
+
+	usr, err := f.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+// GetFeed gets the feed with the given token, with the newest of its links
+// that aren't in the trash.
+func (f *Feed) GetFeed(ctx context.Context, token string) (*syndication.Feed, error) {
+	op := errors.Op("controller.GetFeed")
+
+	usr, err := f.Store.GetUserByFeedToken(ctx, token)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errFeedNotFound(op)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	feed := usr.Feeds.FindByToken(token)
+	if feed == nil {
+		return nil, errFeedNotFound(op)
+	}
+
+	favorites := ""
+	if feed.Favorites {
+		favorites = "1"
+	}
+
+	links, err := f.LinkStore.GetAllLinksByUser(ctx, usr,
+		&model.Pagination{Page: 0, Size: feedSize},
+		db.GetLinksFolder(feed.FolderID),
+		db.GetLinksTag(feed.TagPath),
+		db.GetLinksUserTag(feed.UserTag),
+		db.GetLinksFavorites(favorites))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	out := &syndication.Feed{
+		ID:      fmt.Sprintf("%s/feeds/%s", feedBaseURL, feed.ID),
+		Title:   feed.Name,
+		Link:    feedBaseURL,
+		Author:  strings.TrimSpace(usr.FirstName + " " + usr.LastName),
+		Updated: feed.CreatedAt,
+		Entries: make([]*syndication.Entry, 0, len(links)),
+	}
+
+	for _, link := range links {
+		entry, err := feedEntry(link)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if entry.Updated.After(out.Updated) {
+			out.Updated = entry.Updated
+		}
+
+		out.Entries = append(out.Entries, entry)
+	}
+
+	return out, nil
+}
+
+// feedEntry makes the link into a feed entry. Its content is the link's
+// description, its summary made safe to show and its annotation.
+func feedEntry(link *model.Link) (*syndication.Entry, error) {
+	title := link.Title
+	if title == "" {
+		title = link.URL
+	}
+
+	entry := &syndication.Entry{
+		ID:         fmt.Sprintf("%s/links/%s", feedBaseURL, link.ID),
+		Title:      title,
+		Link:       link.URL,
+		Summary:    link.Description,
+		Categories: append([]string{}, link.UserTags...),
+		Published:  link.CreatedAt,
+		Updated:    link.UpdatedAt,
+	}
+
+	var content strings.Builder
+
+	if link.Description != "" {
+		fmt.Fprintf(&content, "<p>%s</p>\n", html.EscapeString(link.Description))
+	}
+
+	if strings.TrimSpace(link.Summary) != "" {
+		base, err := url.Parse(link.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		article, err := reader.Render(link.Summary, base, &reader.Options{Format: reader.FormatHTML})
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&content, "<h3>Summary</h3>\n%s\n", article.Content)
+	}
+
+	if annotation := strings.TrimSpace(link.Annotation); annotation != "" {
+		content.WriteString("<h3>Notes</h3>\n")
+
+		for _, p := range strings.Split(annotation, "\n\n") {
+			if p = strings.TrimSpace(p); p != "" {
+				fmt.Fprintf(&content, "<p>%s</p>\n",
+					strings.ReplaceAll(html.EscapeString(p), "\n", "<br>"))
+			}
+		}
+	}
+
+	entry.Content = strings.TrimSpace(content.String())
+
+	return entry, nil
+}
+
+func errFeedNotFound(op errors.Op) error {
+	return errors.E(
+		op,
+		errors.Str("feed not found"),
+		errors.M{"message": "The given feed was not found."},
+		http.StatusNotFound)
+}
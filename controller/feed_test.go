@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/feed"
+	"github.com/linksort/linksort/model"
+)
+
+func TestFeed(t *testing.T) {
+	ctx := context.Background()
+	usr := &model.User{
+		FolderTree: &model.Folder{Name: "root", ID: "root"},
+		Feeds:      make(model.Feeds, 0),
+	}
+	folder := model.NewFolder("Reading", usr.FolderTree)
+	controller := Feed{Store: &mockUserStore{}}
+
+	usr, err := controller.CreateFeed(ctx, usr, &handler.CreateFeedRequest{
+		Name:     "Go articles",
+		FolderID: folder.ID,
+		UserTag:  "golang",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usr.Feeds) != 1 {
+		t.Fatalf("got %d feeds, want 1", len(usr.Feeds))
+	}
+
+	feed := usr.Feeds[0]
+	token := feed.Token
+
+	if len(token) < 40 || strings.ContainsAny(token, "/+=") {
+		t.Errorf("expected a long token that can go in a URL, got %q", token)
+	}
+
+	usr, err = controller.ResetFeedToken(ctx, usr, feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usr.Feeds.FindByToken(token) != nil || usr.Feeds.Find(feed.ID).Token == "" {
+		t.Errorf("expected the feed to have a new token: %+v", usr.Feeds.Find(feed.ID))
+	}
+
+	usr, err = controller.DeleteFeed(ctx, usr, feed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usr.Feeds) != 0 {
+		t.Fatalf("feed was not deleted: %+v", usr.Feeds)
+	}
+
+	_, err = controller.ResetFeedToken(ctx, usr, feed.ID)
+
+	var e *errors.Error
+	if !errors.As(err, &e) || e.Status() != http.StatusNotFound {
+		t.Fatalf("expected a 404, got %v", err)
+	}
+}
+
+func TestCreateFeed_UnknownFolder(t *testing.T) {
+	usr := &model.User{
+		FolderTree: &model.Folder{Name: "root", ID: "root"},
+		Feeds:      make(model.Feeds, 0),
+	}
+	store := &mockUserStore{}
+	controller := Feed{Store: store}
+
+	_, err := controller.CreateFeed(context.Background(), usr, &handler.CreateFeedRequest{
+		Name:     "Missing",
+		FolderID: "6f1c5d7e-6a53-4b8e-9f57-1d8f2a1b9c3e",
+	})
+
+	var e *errors.Error
+	if !errors.As(err, &e) || e.Status() != http.StatusBadRequest {
+		t.Fatalf("expected a 400, got %v", err)
+	}
+
+	if store.updateCalled || len(usr.Feeds) != 0 {
+		t.Fatal("the feed should not have been saved")
+	}
+}
+
+func TestFeedEntry(t *testing.T) {
+	entry, err := feedEntry(&model.Link{
+		ID:          "65f1a2b3c4d5e6f7a8b9c0d1",
+		URL:         "https://example.com/post",
+		Title:       "A post",
+		Description: "About <things>",
+		Summary:     `<p>It is <strong>short</strong>.</p><script>alert(1)</script>`,
+		Annotation:  "First\nline\n\nSecond",
+		UserTags:    []string{"golang"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.ID != "https://linksort.com/links/65f1a2b3c4d5e6f7a8b9c0d1" || entry.Summary != "About <things>" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	want := "<p>About &lt;things&gt;</p>\n" +
+		"<h3>Summary</h3>\n<p>It is <strong>short</strong>.</p>\n" +
+		"<h3>Notes</h3>\n<p>First<br>line</p>\n<p>Second</p>"
+
+	if entry.Content != want {
+		t.Errorf("expected the content\n%s\ngot\n%s", want, entry.Content)
+	}
+}
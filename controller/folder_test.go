@@ -22,15 +22,27 @@ func (m *mockUserStore) GetUserBySessionID(context.Context, string) (*model.User
 func (m *mockUserStore) GetUserByToken(context.Context, string) (*model.User, error) {
 	return nil, errors.Str("not implemented")
 }
+func (m *mockUserStore) GetUserByFeedToken(context.Context, string) (*model.User, error) {
+	return nil, errors.Str("not implemented")
+}
 func (m *mockUserStore) GetUserByEmail(context.Context, string) (*model.User, error) {
 	return nil, errors.Str("not implemented")
 }
+func (m *mockUserStore) GetUserByID(context.Context, string) (*model.User, error) {
+	return nil, errors.Str("not implemented")
+}
 func (m *mockUserStore) CreateUser(context.Context, *model.User) (*model.User, error) {
 	return nil, errors.Str("not implemented")
 }
 func (m *mockUserStore) DeleteUser(context.Context, *model.User) error {
 	return errors.Str("not implemented")
 }
+func (m *mockUserStore) GetUsersDueForDigest(context.Context, int) ([]*model.User, error) {
+	return nil, errors.Str("not implemented")
+}
+func (m *mockUserStore) UpdateUserDigest(context.Context, *model.User) error {
+	return errors.Str("not implemented")
+}
 
 func (m *mockUserStore) UpdateUser(ctx context.Context, u *model.User) (*model.User, error) {
 	m.updateCalled = true
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+// healthBatchSize is the most links a JobQueueHealthChecks run queues.
+const healthBatchSize = 500
+
+// healthCheckIntervals is how long to wait before checking a link again, by
+// what was found the last time. Broken links are checked again sooner, in
+// case their site was only gone for a while, and links that couldn't be
+// checked sooner still.
+var healthCheckIntervals = map[string]time.Duration{
+	model.HealthOK:         30 * 24 * time.Hour,
+	model.HealthRedirected: 30 * 24 * time.Hour,
+	model.HealthBroken:     7 * 24 * time.Hour,
+	model.HealthUnknown:    24 * time.Hour,
+}
+
+// HealthChecker checks the URLs of links on a schedule, to find the ones
+// that are broken or have moved.
+type HealthChecker struct {
+	Store interface {
+		GetLinkByID(ctx context.Context, id string) (*model.Link, error)
+		GetLinksDueForHealthCheck(ctx context.Context, limit int) ([]*model.Link, error)
+		UpdateLinkHealth(ctx context.Context, link *model.Link, checkAfter *time.Time) error
+	}
+	Checker interface {
+		Check(ctx context.Context, url string) *model.LinkHealth
+	}
+	Queue interface {
+		EnqueueUnique(ctx context.Context, kind, key string, payload interface{}) error
+	}
+	// Limiter spaces out requests to the same domain.
+	Limiter *ratelimit.Limiter
+}
+
+// QueueHealthChecks queues a JobCheckHealth for each link whose URL is due to
+// be checked. Links that already have one queued aren't queued again.
+func (h *HealthChecker) QueueHealthChecks(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.QueueHealthChecks(%q)", job.ID)
+
+	links, err := h.Store.GetLinksDueForHealthCheck(ctx, healthBatchSize)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, link := range links {
+		err := h.Queue.EnqueueUnique(ctx, JobCheckHealth, JobCheckHealth+":"+link.ID, &linkJob{link.ID})
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckHealth runs a JobCheckHealth. Links that are no longer due, such as
+// ones that were checked since the job was queued, are left alone.
+func (h *HealthChecker) CheckHealth(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.CheckHealth(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := h.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	if link.IsTrashed || (link.HealthCheckAfter != nil && link.HealthCheckAfter.After(time.Now())) {
+		return nil
+	}
+
+	if err := waitForHost(ctx, h.Limiter, link.URL); err != nil {
+		return err
+	}
+
+	if err := h.CheckLink(ctx, link); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// CheckLink checks the URL of a link that is due for it, records what was
+// found and schedules the next check.
+func (h *HealthChecker) CheckLink(ctx context.Context, link *model.Link) error {
+	op := errors.Opf("controller.CheckLink(%q)", link.ID)
+
+	checkAfter := link.HealthCheckAfter
+
+	health := h.Checker.Check(ctx, link.URL)
+	if ctx.Err() != nil {
+		return errors.E(op, ctx.Err())
+	}
+
+	link.Health = health
+	link.HealthCheckAfter = nextHealthCheck(health.CheckedAt, health.Status)
+
+	if err := h.Store.UpdateLinkHealth(ctx, link, checkAfter); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// nextHealthCheck returns when a link whose URL was found to have the given
+// health status at the given time is next due to be checked.
+func nextHealthCheck(checkedAt time.Time, status string) *time.Time {
+	interval, ok := healthCheckIntervals[status]
+	if !ok {
+		interval = healthCheckIntervals[model.HealthUnknown]
+	}
+
+	next := checkedAt.Add(interval)
+
+	return &next
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+type mockHealthStore struct {
+	link       *model.Link
+	updated    *model.Link
+	checkAfter *time.Time
+}
+
+func (m *mockHealthStore) GetLinkByID(context.Context, string) (*model.Link, error) {
+	l := *m.link
+	return &l, nil
+}
+func (m *mockHealthStore) GetLinksDueForHealthCheck(context.Context, int) ([]*model.Link, error) {
+	return []*model.Link{m.link}, nil
+}
+func (m *mockHealthStore) UpdateLinkHealth(_ context.Context, l *model.Link, checkAfter *time.Time) error {
+	m.updated = l
+	m.checkAfter = checkAfter
+	return nil
+}
+
+type mockChecker struct {
+	status string
+}
+
+func (m *mockChecker) Check(_ context.Context, url string) *model.LinkHealth {
+	return &model.LinkHealth{Status: m.status, FinalURL: url, CheckedAt: time.Now()}
+}
+
+func TestCheckLink(t *testing.T) {
+	tests := []struct {
+		Name         string
+		GivenStatus  string
+		ExpectNextIn time.Duration
+	}{
+		{
+			Name:         "ok",
+			GivenStatus:  model.HealthOK,
+			ExpectNextIn: 30 * 24 * time.Hour,
+		},
+		{
+			Name:         "broken",
+			GivenStatus:  model.HealthBroken,
+			ExpectNextIn: 7 * 24 * time.Hour,
+		},
+		{
+			Name:         "unknown",
+			GivenStatus:  model.HealthUnknown,
+			ExpectNextIn: 24 * time.Hour,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			store := &mockHealthStore{}
+			h := &HealthChecker{
+				Store:   store,
+				Checker: &mockChecker{status: tcase.GivenStatus},
+				Limiter: ratelimit.New(time.Millisecond),
+			}
+
+			due := time.Now().Add(-time.Hour)
+			link := &model.Link{ID: "1", URL: "https://example.com/posts/1", HealthCheckAfter: &due}
+
+			if err := h.CheckLink(context.Background(), link); err != nil {
+				t.Fatal(err)
+			}
+
+			if store.updated == nil {
+				t.Fatal("expected the link's health to be saved")
+			}
+
+			if store.checkAfter == nil || !store.checkAfter.Equal(due) {
+				t.Errorf("expected the save to be conditional on the link still being due, got %v", store.checkAfter)
+			}
+
+			if store.updated.Health.Status != tcase.GivenStatus {
+				t.Errorf("got status %q, want %q", store.updated.Health.Status, tcase.GivenStatus)
+			}
+
+			next := store.updated.HealthCheckAfter.Sub(store.updated.Health.CheckedAt)
+			if next != tcase.ExpectNextIn {
+				t.Errorf("got next check in %v, want %v", next, tcase.ExpectNextIn)
+			}
+		})
+	}
+}
+
+func TestCheckLink_ContextDone(t *testing.T) {
+	store := &mockHealthStore{}
+	h := &HealthChecker{
+		Store:   store,
+		Checker: &mockChecker{status: model.HealthUnknown},
+		Limiter: ratelimit.New(time.Millisecond),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := h.CheckLink(ctx, &model.Link{ID: "1", URL: "https://example.com"}); err == nil {
+		t.Fatal("expected the job to be retried")
+	}
+
+	if store.updated != nil {
+		t.Error("expected nothing to be saved once the context is done")
+	}
+}
+
+func TestCheckHealth_NotDue(t *testing.T) {
+	next := time.Now().Add(time.Hour)
+	store := &mockHealthStore{link: &model.Link{ID: "1", URL: "https://example.com", HealthCheckAfter: &next}}
+	h := &HealthChecker{
+		Store:   store,
+		Checker: &mockChecker{status: model.HealthOK},
+		Limiter: ratelimit.New(time.Millisecond),
+	}
+
+	if err := h.CheckHealth(context.Background(), newTestJob(t, &linkJob{"1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.updated != nil {
+		t.Error("expected a link that isn't due to be left alone")
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	redirected := &model.LinkHealth{Status: model.HealthRedirected, FinalURL: "https://example.com/new"}
+
+	tests := []struct {
+		Name       string
+		GivenLink  *model.Link
+		GivenSaved map[string]string
+		ExpectOK   bool
+	}{
+		{
+			Name:       "redirected",
+			GivenLink:  &model.Link{ID: "1", Health: redirected},
+			GivenSaved: map[string]string{},
+			ExpectOK:   true,
+		},
+		{
+			Name:       "not checked",
+			GivenLink:  &model.Link{ID: "1"},
+			GivenSaved: map[string]string{},
+		},
+		{
+			Name:       "not redirected",
+			GivenLink:  &model.Link{ID: "1", Health: &model.LinkHealth{Status: model.HealthOK}},
+			GivenSaved: map[string]string{},
+		},
+		{
+			Name:       "already saved",
+			GivenLink:  &model.Link{ID: "1", Health: redirected},
+			GivenSaved: map[string]string{"https://example.com/new": "2"},
+		},
+		{
+			Name:       "already saved in another form",
+			GivenLink:  &model.Link{ID: "1", Health: &model.LinkHealth{Status: model.HealthRedirected, FinalURL: "http://www.example.com/new/"}},
+			GivenSaved: map[string]string{"https://example.com/new": "2"},
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			msg := checkRedirect(tcase.GivenLink, tcase.GivenSaved)
+			if (msg == "") != tcase.ExpectOK {
+				t.Errorf("got %q, expected ok to be %v", msg, tcase.ExpectOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/highlight"
+	"github.com/linksort/linksort/model"
+)
+
+const maxHighlightsPerLink = 500
+
+type Highlight struct {
+	Store model.LinkStore
+}
+
+func (c *Highlight) CreateHighlight(
+	ctx context.Context,
+	u *model.User,
+	req *handler.CreateHighlightRequest,
+) (*model.Highlight, error) {
+	op := errors.Opf("controller.CreateHighlight(%q)", req.LinkID)
+
+	link, err := c.getLink(ctx, u, req.LinkID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if link.IsTrashed {
+		return nil, errors.E(op, errors.Str("link is in the trash"), http.StatusBadRequest,
+			errors.M{"message": "This link is in the trash. Restore it first."})
+	}
+
+	if len(link.Highlights) >= maxHighlightsPerLink {
+		return nil, errors.E(op,
+			errors.Str("highlight limit reached"),
+			errors.M{"message": "This link has reached the limit of 500 highlights."},
+			http.StatusBadRequest)
+	}
+
+	h := model.NewHighlight(req.Quote, req.Start)
+	h.Note = req.Note
+
+	if req.Color != "" {
+		h.Color = req.Color
+	}
+
+	if !h.Anchor(model.CorpusText(link.Corpus)) {
+		return nil, errors.E(op,
+			errors.Str("quote not in corpus"),
+			errors.M{"quote": "This passage isn't in the link's text."},
+			http.StatusBadRequest)
+	}
+
+	if err := c.Store.CreateHighlight(ctx, link, h); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return h, nil
+}
+
+// GetHighlights returns the link's highlights in the order they appear in its
+// text, followed by the ones that are orphaned.
+func (c *Highlight) GetHighlights(ctx context.Context, u *model.User, linkID string) ([]*model.Highlight, error) {
+	op := errors.Opf("controller.GetHighlights(%q)", linkID)
+
+	link, err := c.getLink(ctx, u, linkID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	hh := append([]*model.Highlight{}, link.Highlights...)
+
+	sort.SliceStable(hh, func(i, j int) bool {
+		if hh[i].IsOrphaned != hh[j].IsOrphaned {
+			return !hh[i].IsOrphaned
+		}
+
+		return hh[i].Start < hh[j].Start
+	})
+
+	return hh, nil
+}
+
+func (c *Highlight) UpdateHighlight(
+	ctx context.Context,
+	u *model.User,
+	req *handler.UpdateHighlightRequest,
+) (*model.Highlight, error) {
+	op := errors.Opf("controller.UpdateHighlight(%q, %q)", req.LinkID, req.ID)
+
+	link, err := c.getLink(ctx, u, req.LinkID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	h := link.Highlights.Find(req.ID)
+	if h == nil {
+		return nil, errHighlightNotFound(op)
+	}
+
+	if req.Note != nil {
+		h.Note = *req.Note
+	}
+
+	if req.Color != nil {
+		h.Color = *req.Color
+	}
+
+	if err := c.Store.UpdateHighlight(ctx, link, h); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return h, nil
+}
+
+func (c *Highlight) DeleteHighlight(ctx context.Context, u *model.User, linkID, id string) error {
+	op := errors.Opf("controller.DeleteHighlight(%q, %q)", linkID, id)
+
+	link, err := c.getLink(ctx, u, linkID)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if link.Highlights.Find(id) == nil {
+		return errHighlightNotFound(op)
+	}
+
+	if err := c.Store.DeleteHighlight(ctx, link, id); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (c *Highlight) GetHighlightFeed(
+	ctx context.Context,
+	u *model.User,
+	p *model.Pagination,
+) ([]*model.LinkHighlight, error) {
+	op := errors.Op("controller.GetHighlightFeed")
+
+	hh, err := c.Store.GetHighlightsByUser(ctx, u, p)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return hh, nil
+}
+
+func (c *Highlight) getLink(ctx context.Context, u *model.User, id string) (*model.Link, error) {
+	op := errors.Opf("controller.Highlight.getLink(%q)", id)
+
+	link, err := c.Store.GetLinkByID(ctx, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if link.UserID != u.ID {
+		return nil, errors.E(op, errors.Str("no permission"), http.StatusNotFound)
+	}
+
+	return link, nil
+}
+
+func errHighlightNotFound(op errors.Op) error {
+	return errors.E(
+		op,
+		errors.Str("highlight not found"),
+		errors.M{"message": "The given highlight was not found."},
+		http.StatusNotFound)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+// Kinds of the jobs that controllers run in the background through the job
+// queue.
+const (
+	JobGatherCorpus = "gather-corpus"
+	JobSummarize    = "summarize"
+	JobImport       = "import"
+	JobEmbed        = "embed"
+	JobSnapshot     = "snapshot"
+	JobEnrich       = "enrich"
+	JobCheckHealth  = "check-health"
+	JobWatch        = "watch"
+	JobSendDigest   = "send-digest"
+	// JobPurgeTrash is run on a schedule to delete the links that have been in
+	// the trash for too long.
+	JobPurgeTrash = "purge-trash"
+	// JobBackfillEmbeddings is run when the queue starts.
+	JobBackfillEmbeddings = "backfill-embeddings"
+	// JobQueueEnrichments is run on a schedule to queue a JobEnrich for each
+	// link that is waiting for its metadata.
+	JobQueueEnrichments = "queue-enrichments"
+	// JobQueueHealthChecks is run on a schedule to queue a JobCheckHealth for
+	// each link whose URL is due to be checked.
+	JobQueueHealthChecks = "queue-health-checks"
+	// JobQueueWatches is run on a schedule to queue a JobWatch for each
+	// watched link whose page is due to be checked.
+	JobQueueWatches = "queue-watches"
+	// JobQueueDigests is run on a schedule to queue a JobSendDigest for each
+	// user whose digest is due.
+	JobQueueDigests = "queue-digests"
+)
+
+// purgeBatchSize is how many links are purged from the trash in each
+// transaction.
+const purgeBatchSize = 100
+
+// maxHostWait is the longest a job waits for its turn to fetch from a domain.
+// A job whose turn is further off is run again when it comes, so that it
+// doesn't hold up a worker meanwhile.
+const maxHostWait = 10 * time.Second
+
+// linkJob is the payload of jobs that work on a link.
+type linkJob struct {
+	LinkID string `bson:"linkid"`
+}
+
+// digestJob is the payload of jobs that send a user's digest.
+type digestJob struct {
+	UserID string `bson:"userid"`
+}
+
+// importJob is the payload of import jobs.
+type importJob struct {
+	ImportID string `bson:"importid"`
+}
+
+// waitForHost waits for the job's turn to fetch the given URL. When the turn
+// is too far off it returns an error that runs the job again later instead.
+func waitForHost(ctx context.Context, l *ratelimit.Limiter, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	d, ok := l.ReserveWithin(u.Hostname(), maxHostWait)
+	if !ok {
+		return queue.Later(d)
+	}
+
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isNotFound(err error) bool {
+	var e *errors.Error
+
+	return errors.As(err, &e) && e.Status() == http.StatusNotFound
+}
@@ -2,16 +2,26 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/linksort/linksort/analyze"
+	"github.com/linksort/linksort/canonical"
 	"github.com/linksort/linksort/db"
+	"github.com/linksort/linksort/epub"
 	"github.com/linksort/linksort/errors"
 	handler "github.com/linksort/linksort/handler/link"
 	"github.com/linksort/linksort/log"
 	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/reader"
+	"github.com/linksort/linksort/search"
 )
 
 type Link struct {
@@ -22,7 +32,22 @@ type Link struct {
 		GatherCorpus(context.Context, string) (*analyze.Response, error)
 		Summarize(context.Context, string) (string, error)
 	}
-	Transactor db.Transactor
+	RevisionStore model.RevisionStore
+	ChangeStore   model.LinkChangeStore
+	SnapshotStore model.SnapshotStore
+	Transactor    db.Transactor
+	Queue         interface {
+		Enqueue(ctx context.Context, kind string, payload interface{}) error
+	}
+	Embedder interface {
+		Embed(ctx context.Context, text string) ([]float32, error)
+		Model() string
+	}
+	// ImageProxyURL, if set, is the URL of the proxy the images of reader
+	// views can be loaded through.
+	ImageProxyURL string
+	// Images fetches the images that are embedded in exported books.
+	Images epub.ImageFetcher
 }
 
 func (l *Link) CreateLink(
@@ -58,11 +83,12 @@ func (l *Link) CreateLink(
 			return errors.E(innerOp, err)
 		}
 
-		link, err = l.Store.CreateLink(sessCtx, &model.Link{
+		link = &model.Link{
 			UserID:       u.ID,
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 			URL:          dat.URL,
+			CanonicalURL: canonicalURL(dat.URL, dat.Canonical),
 			Image:        dat.Image,
 			Favicon:      dat.Favicon,
 			Title:        dat.Title,
@@ -73,7 +99,16 @@ func (l *Link) CreateLink(
 			TagPaths:     model.ParseTagDetailsToPathList(dat.Tags),
 			IsArticle:    dat.IsArticle,
 			IsSummarized: !dat.IsArticle,
-		})
+			// The page was just fetched, so it isn't checked again until
+			// it is due.
+			HealthCheckAfter: nextHealthCheck(time.Now(), model.HealthOK),
+		}
+
+		if err = l.checkDuplicate(sessCtx, user, link); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		link, err = l.Store.CreateLink(sessCtx, link)
 		if err != nil {
 			return errors.E(innerOp, err)
 		}
@@ -92,23 +127,83 @@ func (l *Link) CreateLink(
 		return nil, nil, errors.E(op, err)
 	}
 
-	go func() {
-		res, err := l.Analyzer.GatherCorpus(context.Background(), link.URL)
-		if err != nil {
-			log.Printf("async corpus gathering failed for link %s: %v", link.ID, err)
-			return
+	// The link is already saved, so failing to queue the job shouldn't fail
+	// the request.
+	if err := l.Queue.Enqueue(context.Background(), JobGatherCorpus, &linkJob{link.ID}); err != nil {
+		log.AlarmWithContext(ctx, errors.E(op, err))
+	}
+
+	if err := l.Queue.Enqueue(context.Background(), JobSnapshot, &linkJob{link.ID}); err != nil {
+		log.AlarmWithContext(ctx, errors.E(op, err))
+	}
+
+	return link, user, nil
+}
+
+// GatherCorpus is the job that fetches the full text of a link after it is
+// created or enriched, queues the link to be summarized if it turns out to be
+// an article, and queues it to be embedded.
+func (l *Link) GatherCorpus(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.GatherCorpus(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := l.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
 		}
 
-		if res.Corpus != "" {
-			link.Corpus = res.Corpus
-			link.IsArticle = res.IsArticle
-			if _, err := l.Store.UpdateLink(context.Background(), link); err != nil {
-				log.Printf("async corpus update failed for link %s: %v", link.ID, err)
-			}
+		return errors.E(op, err)
+	}
+
+	res, err := l.Analyzer.GatherCorpus(ctx, link.URL)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.Corpus == "" {
+		// There's still the title and description to embed.
+		if err := l.Queue.Enqueue(ctx, JobEmbed, &linkJob{link.ID}); err != nil {
+			return errors.E(op, err)
 		}
-	}()
 
-	return link, user, nil
+		return nil
+	}
+
+	// The link is read again since the user may have changed it while its
+	// page was being fetched.
+	link, err = l.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	link.Corpus = res.Corpus
+	link.ReanchorHighlights()
+	link.IsArticle = res.IsArticle
+
+	if _, err := l.Store.UpdateLink(ctx, link); err != nil {
+		return errors.E(op, err)
+	}
+
+	if link.IsArticle && !link.IsSummarized {
+		if err := l.Queue.Enqueue(ctx, JobSummarize, &linkJob{link.ID}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := l.Queue.Enqueue(ctx, JobEmbed, &linkJob{link.ID}); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
 }
 
 func (l *Link) GetLink(ctx context.Context, u *model.User, id string) (*model.Link, error) {
@@ -129,19 +224,215 @@ func (l *Link) GetLink(ctx context.Context, u *model.User, id string) (*model.Li
 func (l *Link) GetLinks(ctx context.Context, u *model.User, req *handler.GetLinksRequest) ([]*model.Link, error) {
 	op := errors.Op("controller.GetLinks")
 
-	links, err := l.Store.GetLinksByUser(ctx, u, req.Pagination,
-		db.GetLinksSearch(req.Search),
+	opts, err := getLinksOptions(u, req)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if req.Semantic != "" {
+		if utf8.RuneCountInString(req.Semantic) > maxSemanticLength {
+			return nil, errors.E(op, errors.Str("semantic query too long"), http.StatusBadRequest,
+				errors.M{"semantic": fmt.Sprintf("This must be at most %d characters.", maxSemanticLength)})
+		}
+
+		vector, err := l.Embedder.Embed(ctx, req.Semantic)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		opts = append(opts, db.GetLinksSimilar(l.Embedder.Model(), vector))
+	}
+
+	links, err := l.Store.GetLinksByUser(ctx, u, req.Pagination, opts...)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return links, nil
+}
+
+// GetRelatedLinks ranks the user's other links by how related they are to the
+// link with the given ID.
+func (l *Link) GetRelatedLinks(
+	ctx context.Context,
+	u *model.User,
+	id string,
+	p *model.Pagination,
+) ([]*model.RelatedLink, error) {
+	op := errors.Opf("controller.GetRelatedLinks(%q)", id)
+
+	link, err := l.GetLink(ctx, u, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links, err := l.Store.GetRelatedLinks(ctx, link, p)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return links, nil
+}
+
+// GetLinkFacets counts the links that GetLinks would list for the same
+// request, in total and by each of the request's facets.
+func (l *Link) GetLinkFacets(
+	ctx context.Context,
+	u *model.User,
+	req *handler.GetLinksRequest,
+) (*model.FacetCounts, error) {
+	op := errors.Op("controller.GetLinkFacets")
+
+	for _, f := range req.Facets {
+		if !isFacet(f) {
+			return nil, errors.E(op,
+				errors.Strf("unknown facet %q", f),
+				errors.M{"facets": fmt.Sprintf("%q is not a facet. Use %s.", f, strings.Join(model.Facets, ", "))},
+				http.StatusBadRequest)
+		}
+	}
+
+	opts, err := getLinksOptions(u, req)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	counts, err := l.Store.GetLinkFacets(ctx, u, req.Facets, opts...)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return counts, nil
+}
+
+func isFacet(name string) bool {
+	for _, f := range model.Facets {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getLinksOptions returns the store options that filter links as the request
+// asks.
+func getLinksOptions(u *model.User, req *handler.GetLinksRequest) ([]model.GetLinksOption, error) {
+	op := errors.Op("controller.getLinksOptions")
+
+	if req.SavedSearchID != "" {
+		saved := u.SavedSearches.Find(req.SavedSearchID)
+		if saved == nil {
+			return nil, errSavedSearchNotFound(op)
+		}
+
+		req = savedSearchRequest(saved, req.Pagination)
+	}
+
+	switch req.Read {
+	case "", model.ReadStateUnread, model.ReadStateInProgress, model.ReadStateRead:
+	default:
+		return nil, errors.E(op,
+			errors.Strf("unknown read state %q", req.Read),
+			errors.M{"read": "This must be one of unread, inprogress and read."},
+			http.StatusBadRequest)
+	}
+
+	if req.Health != "" && !model.IsHealthStatus(req.Health) {
+		return nil, errors.E(op,
+			errors.Strf("unknown health status %q", req.Health),
+			errors.M{"health": "This must be one of ok, redirected, broken and unknown."},
+			http.StatusBadRequest)
+	}
+
+	query, err := parseSearch(u, req.Search)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return []model.GetLinksOption{
+		db.GetLinksQuery(query),
 		db.GetLinksSort(req.Sort),
 		db.GetLinksFolder(req.FolderID),
 		db.GetLinksTag(req.TagPath),
 		db.GetLinksUserTag(req.UserTag),
 		db.GetLinksFavorites(req.Favorites),
-		db.GetLinksAnnotated(req.Annotations))
+		db.GetLinksAnnotated(req.Annotations),
+		db.GetLinksTrashed(req.Trashed),
+		db.GetLinksReadState(req.Read),
+		db.GetLinksHealth(req.Health),
+		db.GetLinksChanged(req.Changed),
+	}, nil
+}
+
+// parseSearch parses a search query and resolves the folders it names, which
+// can be given by name or by ID.
+func parseSearch(u *model.User, q string) (*search.Query, error) {
+	op := errors.Op("controller.parseSearch")
+
+	query, err := search.Parse(q)
 	if err != nil {
+		var serr *search.SyntaxError
+		if errors.As(err, &serr) {
+			return nil, errSearchSyntax(op, serr)
+		}
+
 		return nil, errors.E(op, err)
 	}
 
-	return links, nil
+	for _, clause := range query.Clauses {
+		for _, f := range clause {
+			if f.Field != search.FieldFolder {
+				continue
+			}
+
+			folder := u.FolderTree.BFS(f.Value)
+			if folder == nil {
+				folder = u.FolderTree.FindByName(f.Value)
+			}
+
+			if folder == nil {
+				return nil, errSearchSyntax(op, &search.SyntaxError{
+					Pos: f.Pos,
+					Msg: fmt.Sprintf("There is no folder %q", f.Value),
+				})
+			}
+
+			f.Value = folder.ID
+		}
+	}
+
+	return query, nil
+}
+
+func errSearchSyntax(op errors.Op, err *search.SyntaxError) error {
+	return errors.E(op, err, http.StatusBadRequest, errors.M{
+		"search":   err.Msg + ".",
+		"position": strconv.Itoa(err.Pos),
+	})
+}
+
+// savedSearchRequest returns the request for the links that match the saved
+// search.
+func savedSearchRequest(s *model.SavedSearch, p *model.Pagination) *handler.GetLinksRequest {
+	req := &handler.GetLinksRequest{
+		Sort:       s.Sort,
+		Search:     s.Search,
+		FolderID:   s.FolderID,
+		TagPath:    s.TagPath,
+		UserTag:    s.UserTag,
+		Pagination: p,
+	}
+
+	if s.Favorites {
+		req.Favorites = "1"
+	}
+
+	if s.Annotated {
+		req.Annotations = "1"
+	}
+
+	return req
 }
 
 func (l *Link) UpdateLink(
@@ -153,6 +444,7 @@ func (l *Link) UpdateLink(
 
 	var link *model.Link
 	var user *model.User
+	var before model.Link
 	var err error
 
 	err = l.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
@@ -163,11 +455,18 @@ func (l *Link) UpdateLink(
 			return errors.E(innerOp, err)
 		}
 
+		if link.IsTrashed {
+			return errors.E(innerOp, errors.Str("link is in the trash"), http.StatusBadRequest,
+				errors.M{"message": "This link is in the trash. Restore it first."})
+		}
+
 		user, err = l.UserStore.GetUserByEmail(sessCtx, u.Email)
 		if err != nil {
 			return errors.E(innerOp, err)
 		}
 
+		before = *link
+
 		uv := reflect.ValueOf(link).Elem()
 		rv := reflect.ValueOf(req).Elem()
 		rt := rv.Type()
@@ -206,6 +505,25 @@ func (l *Link) UpdateLink(
 						Set(reflect.ValueOf(reqLinkUserTags))
 
 				}
+			case "IsRead":
+				if isNil := rv.Field(i).IsNil(); !isNil {
+					link.MarkRead(rv.Field(i).Elem().Bool())
+				}
+			case "ReadProgress":
+				if isNil := rv.Field(i).IsNil(); !isNil {
+					link.SetReadProgress(int(rv.Field(i).Elem().Int()))
+
+					now := time.Now()
+					link.LastOpenedAt = &now
+				}
+			case "IsWatched":
+				if isNil := rv.Field(i).IsNil(); !isNil {
+					link.Watch(rv.Field(i).Elem().Bool())
+				}
+			case "IsChanged":
+				if isNil := rv.Field(i).IsNil(); !isNil {
+					link.IsChanged = rv.Field(i).Elem().Bool()
+				}
 			default:
 				if isNil := rv.Field(i).IsNil(); !isNil {
 					image := rv.Field(i).Elem().String()
@@ -216,11 +534,36 @@ func (l *Link) UpdateLink(
 			}
 		}
 
+		if link.IsRead && !before.IsRead {
+			// Links leave the reading queue once they've been read.
+			user.ReadingQueue, _ = user.ReadingQueue.Remove(link.ID)
+		}
+
+		if link.URL != before.URL {
+			link.CanonicalURL = canonicalURL(link.URL, "")
+			// The new URL is checked as soon as possible.
+			link.Health = nil
+			link.HealthCheckAfter = nil
+			// The new page is watched from scratch.
+			link.WatchedText = ""
+			link.WatchAfter = nil
+
+			if err = l.checkDuplicate(sessCtx, user, link); err != nil {
+				return errors.E(innerOp, err)
+			}
+		}
+
 		link, err = l.Store.UpdateLink(sessCtx, link)
 		if err != nil {
 			return errors.E(innerOp, err)
 		}
 
+		if rev := model.NewLinkRevision(ctx, model.RevisionActionUpdate, &before, link); rev != nil {
+			if err = l.RevisionStore.CreateRevisions(sessCtx, []*model.LinkRevision{rev}); err != nil {
+				return errors.E(innerOp, err)
+			}
+		}
+
 		if user != nil {
 			user, err = l.UserStore.UpdateUser(sessCtx, user)
 			if err != nil {
@@ -234,9 +577,17 @@ func (l *Link) UpdateLink(
 		return nil, nil, errors.E(op, err)
 	}
 
+	if link.Title != before.Title || link.Description != before.Description || link.Annotation != before.Annotation {
+		if err := l.Queue.Enqueue(ctx, JobEmbed, &linkJob{link.ID}); err != nil {
+			log.AlarmWithContext(ctx, errors.E(op, err))
+		}
+	}
+
 	return link, user, nil
 }
 
+// DeleteLink moves the link to the trash. Links that are already in the trash
+// are deleted for good.
 func (l *Link) DeleteLink(ctx context.Context, u *model.User, id string) (*model.User, error) {
 	op := errors.Opf("controller.DeleteLink(%q)", id)
 
@@ -257,15 +608,30 @@ func (l *Link) DeleteLink(ctx context.Context, u *model.User, id string) (*model
 			return errors.E(op, err)
 		}
 
-		if err = user.TagTree.UpdateWithDeletedTagDetails(link.TagDetails); err != nil {
-			return errors.E(innerOp, err)
+		if link.IsTrashed {
+			// The link's tags stopped counting when it was trashed, so the user
+			// doesn't need to change. Its history goes with it.
+			if err = l.purgeLinks(sessCtx, []*model.Link{link}); err != nil {
+				return errors.E(innerOp, err)
+			}
+
+			return nil
 		}
 
-		user.UserTags.UpdateWithRemovedTags(link.UserTags)
+		before := *link
+
+		if err = trashLink(user, link); err != nil {
+			return errors.E(innerOp, err)
+		}
 
-		err = l.Store.DeleteLink(sessCtx, link)
+		link, err = l.Store.UpdateLink(sessCtx, link)
 		if err != nil {
-			return errors.E(op, err)
+			return errors.E(innerOp, err)
+		}
+
+		rev := model.NewLinkRevision(ctx, model.RevisionActionTrash, &before, link)
+		if err = l.RevisionStore.CreateRevisions(sessCtx, []*model.LinkRevision{rev}); err != nil {
+			return errors.E(innerOp, err)
 		}
 
 		if _, err = l.UserStore.UpdateUser(sessCtx, user); err != nil {
@@ -281,42 +647,938 @@ func (l *Link) DeleteLink(ctx context.Context, u *model.User, id string) (*model
 	return user, nil
 }
 
-func (l *Link) SummarizeLink(ctx context.Context, u *model.User, id string) (*model.Link, error) {
-	op := errors.Opf("controller.SummarizeLink(%q)", id)
+// RestoreLink takes the link out of the trash. Its tags count towards the
+// user's tags again and, if its folder was deleted in the meantime, it is
+// moved to the root folder.
+func (l *Link) RestoreLink(ctx context.Context, u *model.User, id string) (*model.Link, *model.User, error) {
+	op := errors.Opf("controller.RestoreLink(%q)", id)
 
-	link, err := l.GetLink(ctx, u, id)
-	if err != nil {
-		return nil, errors.E(op, err)
-	}
+	var link *model.Link
+	var user *model.User
+	var err error
 
-	if link.IsSummarized {
-		// Link already has a summary.
-		return link, nil
-	}
+	err = l.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+		innerOp := errors.Opf("%s.innerTxn", op)
 
-	link.IsSummarized = true
+		user, err = l.UserStore.GetUserByEmail(sessCtx, u.Email)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
 
-	if link.IsArticle && link.Corpus != "" {
-		link.Summary, err = l.Analyzer.Summarize(ctx, link.Corpus)
+		link, err = l.GetLink(sessCtx, u, id)
 		if err != nil {
-			return nil, errors.E(op, err)
+			return errors.E(innerOp, err)
 		}
-	}
 
-	updatedLink, err := l.Store.UpdateLink(ctx, link)
+		if !link.IsTrashed {
+			return nil
+		}
+
+		if err = l.checkDuplicate(sessCtx, user, link); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		before := *link
+
+		if err = restoreLink(user, link); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		link, err = l.Store.UpdateLink(sessCtx, link)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		rev := model.NewLinkRevision(ctx, model.RevisionActionRestore, &before, link)
+		if err = l.RevisionStore.CreateRevisions(sessCtx, []*model.LinkRevision{rev}); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if _, err = l.UserStore.UpdateUser(sessCtx, user); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, errors.E(op, errors.Str("failed to update link with summary"), err)
+		return nil, nil, errors.E(op, err)
 	}
 
-	return updatedLink, nil
+	return link, user, nil
 }
 
-func doesFolderExist(u *model.User, folderID string) bool {
-	if folderID == "root" {
-		return true
-	}
+func (l *Link) BatchLinks(
+	ctx context.Context,
+	u *model.User,
+	req *handler.BatchLinksRequest,
+) ([]*handler.BatchLinkResult, *model.User, error) {
+	op := errors.Opf("controller.BatchLinks(%s)", req.Action)
 
-	found := u.FolderTree.BFS(folderID)
+	var results []*handler.BatchLinkResult
+	var user *model.User
+	var err error
 
-	return found != nil
+	err = l.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+		innerOp := errors.Opf("%s.innerTxn", op)
+
+		user, err = l.UserStore.GetUserByEmail(sessCtx, u.Email)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if req.Action == handler.BatchActionMove && !doesFolderExist(user, req.FolderID) {
+			return errors.E(innerOp,
+				errors.Str("folder does not exist"),
+				errors.M{"folderId": "This folder does not exist."},
+				http.StatusBadRequest)
+		}
+
+		links, err := l.Store.GetLinksByIDs(sessCtx, user, req.IDs)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		byID := make(map[string]*model.Link, len(links))
+		for _, link := range links {
+			byID[link.ID] = link
+		}
+
+		var saved map[string]string
+		if req.Action == handler.BatchActionFollowRedirect {
+			saved, err = l.savedRedirectTargets(sessCtx, user, links)
+			if err != nil {
+				return errors.E(innerOp, err)
+			}
+		}
+
+		results = make([]*handler.BatchLinkResult, 0, len(req.IDs))
+		changed := make([]*model.Link, 0, len(links))
+		purged := make([]*model.Link, 0)
+		revs := make([]*model.LinkRevision, 0, len(links))
+		seen := make(map[string]bool, len(req.IDs))
+
+		for _, id := range req.IDs {
+			link, found := byID[id]
+			if !found {
+				results = append(results, &handler.BatchLinkResult{
+					ID:      id,
+					Message: "The requested resource was not found",
+				})
+
+				continue
+			}
+
+			if link.IsTrashed && req.Action != handler.BatchActionDelete {
+				results = append(results, &handler.BatchLinkResult{
+					ID:      id,
+					Message: "The link is in the trash",
+				})
+
+				continue
+			}
+
+			if req.Action == handler.BatchActionFollowRedirect && !seen[id] {
+				if msg := checkRedirect(link, saved); msg != "" {
+					results = append(results, &handler.BatchLinkResult{ID: id, Message: msg})
+
+					continue
+				}
+			}
+
+			results = append(results, &handler.BatchLinkResult{ID: id, OK: true})
+
+			if seen[id] {
+				continue
+			}
+
+			seen[id] = true
+			before := *link
+
+			switch req.Action {
+			case handler.BatchActionMove:
+				link.FolderID = req.FolderID
+			case handler.BatchActionFavorite:
+				link.IsFavorite = true
+			case handler.BatchActionUnfavorite:
+				link.IsFavorite = false
+			case handler.BatchActionAddTags:
+				tags := addTags(link.UserTags, req.UserTags)
+				model.ReconcileUserTags(user, link.UserTags, tags)
+				link.UserTags = tags
+			case handler.BatchActionRemoveTags:
+				tags := removeTags(link.UserTags, req.UserTags)
+				model.ReconcileUserTags(user, link.UserTags, tags)
+				link.UserTags = tags
+			case handler.BatchActionDelete:
+				if link.IsTrashed {
+					purged = append(purged, link)
+
+					continue
+				}
+
+				if err := trashLink(user, link); err != nil {
+					return errors.E(innerOp, err)
+				}
+			case handler.BatchActionFollowRedirect:
+				link.URL = link.Health.FinalURL
+				link.CanonicalURL = canonicalURL(link.URL, "")
+				// The new URL is checked as soon as possible.
+				link.Health = nil
+				link.HealthCheckAfter = nil
+				saved[link.URL] = link.ID
+				saved[link.CanonicalURL] = link.ID
+			}
+
+			changed = append(changed, link)
+
+			action := model.RevisionActionUpdate
+			if req.Action == handler.BatchActionDelete {
+				action = model.RevisionActionTrash
+			}
+
+			if rev := model.NewLinkRevision(ctx, action, &before, link); rev != nil {
+				revs = append(revs, rev)
+			}
+		}
+
+		if err = l.Store.UpdateLinks(sessCtx, changed); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if err = l.purgeLinks(sessCtx, purged); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if err = l.RevisionStore.CreateRevisions(sessCtx, revs); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if _, err = l.UserStore.UpdateUser(sessCtx, user); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.E(op, err)
+	}
+
+	return results, user, nil
+}
+
+// PurgeTrash runs a JobPurgeTrash. It deletes the links that have been in the
+// trash for longer than model.TrashRetention, a batch at a time.
+func (l *Link) PurgeTrash(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.PurgeTrash(%q)", job.ID)
+
+	before := time.Now().Add(-model.TrashRetention)
+
+	for {
+		var n int
+
+		err := l.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+			innerOp := errors.Opf("%s.innerTxn", op)
+
+			// The links are read in the transaction so that one restored
+			// meanwhile isn't purged.
+			links, err := l.Store.GetTrashedLinksBefore(sessCtx, before, purgeBatchSize)
+			if err != nil {
+				return errors.E(innerOp, err)
+			}
+
+			n = len(links)
+
+			if err := l.purgeLinks(sessCtx, links); err != nil {
+				return errors.E(innerOp, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if n < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// purgeLinks deletes the links for good, along with their revisions,
+// snapshots and the changes found on their pages. It is called in a
+// transaction, so that nothing of the links is left if it fails part way.
+func (l *Link) purgeLinks(ctx context.Context, links []*model.Link) error {
+	op := errors.Opf("controller.purgeLinks(n=%d)", len(links))
+
+	if len(links) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(links))
+	for i, link := range links {
+		ids[i] = link.ID
+	}
+
+	if err := l.Store.DeleteLinks(ctx, links); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := l.RevisionStore.DeleteRevisionsByLinks(ctx, ids); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := l.SnapshotStore.DeleteSnapshotsByLinks(ctx, ids); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := l.ChangeStore.DeleteLinkChangesByLinks(ctx, ids); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (l *Link) GetLinkHistory(
+	ctx context.Context,
+	u *model.User,
+	id string,
+	p *model.Pagination,
+) ([]*model.LinkRevision, error) {
+	op := errors.Opf("controller.GetLinkHistory(%q)", id)
+
+	link, err := l.GetLink(ctx, u, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	revs, err := l.RevisionStore.GetRevisionsByLink(ctx, link.ID, p)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return revs, nil
+}
+
+// GetLinkReader renders the saved text of the link's page for reading, as
+// sanitized HTML, plain text or Markdown.
+func (l *Link) GetLinkReader(
+	ctx context.Context,
+	u *model.User,
+	req *handler.GetLinkReaderRequest,
+) (*reader.Article, error) {
+	op := errors.Opf("controller.GetLinkReader(%q)", req.ID)
+
+	if req.Format != "" && !reader.IsFormat(req.Format) {
+		return nil, errors.E(op,
+			errors.Strf("unknown format %q", req.Format),
+			errors.M{"format": "This must be one of html, text and markdown."},
+			http.StatusBadRequest)
+	}
+
+	if req.ProxyImages && l.ImageProxyURL == "" {
+		return nil, errors.E(op,
+			errors.Str("no image proxy"),
+			errors.M{"proxyImages": "Images can't be proxied."},
+			http.StatusBadRequest)
+	}
+
+	link, err := l.GetLink(ctx, u, req.ID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if strings.TrimSpace(link.Corpus) == "" {
+		return nil, errors.E(op,
+			errors.Str("no corpus"),
+			errors.M{"message": "The link's page has no text to read."},
+			http.StatusNotFound)
+	}
+
+	base, err := url.Parse(link.URL)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	opts := &reader.Options{Format: req.Format}
+	if req.ProxyImages {
+		opts.ImageProxy = l.ImageProxyURL
+	}
+
+	article, err := reader.Render(link.Corpus, base, opts)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return article, nil
+}
+
+// GetLinkChanges returns the changes found to the page of the link, newest
+// first.
+func (l *Link) GetLinkChanges(
+	ctx context.Context,
+	u *model.User,
+	id string,
+	p *model.Pagination,
+) ([]*model.LinkChange, error) {
+	op := errors.Opf("controller.GetLinkChanges(%q)", id)
+
+	link, err := l.GetLink(ctx, u, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	changes, err := l.ChangeStore.GetLinkChangesByLink(ctx, link.ID, p)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return changes, nil
+}
+
+// RevertLink puts the link back into the state it was in before the given
+// revision was made. The revert is itself recorded as a revision, so it can
+// be undone too.
+func (l *Link) RevertLink(
+	ctx context.Context,
+	u *model.User,
+	id string,
+	revisionID string,
+) (*model.Link, *model.User, error) {
+	op := errors.Opf("controller.RevertLink(%q, %q)", id, revisionID)
+
+	var link *model.Link
+	var user *model.User
+	var err error
+
+	err = l.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+		innerOp := errors.Opf("%s.innerTxn", op)
+
+		link, err = l.GetLink(sessCtx, u, id)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		user, err = l.UserStore.GetUserByEmail(sessCtx, u.Email)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		rev, err := l.RevisionStore.GetRevisionByID(sessCtx, revisionID)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if rev.LinkID != link.ID || rev.UserID != user.ID {
+			return errors.E(innerOp, errors.Str("revision does not belong to link"), http.StatusNotFound)
+		}
+
+		revs, err := l.RevisionStore.GetRevisionsSince(sessCtx, rev)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		before := *link
+
+		// Revisions are undone newest first so that each one finds the link in
+		// the state it left it in.
+		for _, r := range revs {
+			r.Undo(link)
+		}
+
+		if !doesFolderExist(user, link.FolderID) {
+			link.FolderID = "root"
+		}
+
+		if link.URL != before.URL {
+			link.CanonicalURL = canonicalURL(link.URL, "")
+		}
+
+		if !link.IsTrashed && (before.IsTrashed || link.URL != before.URL) {
+			if err = l.checkDuplicate(sessCtx, user, link); err != nil {
+				return errors.E(innerOp, err)
+			}
+		}
+
+		switch {
+		case !before.IsTrashed && link.IsTrashed:
+			trashed := before
+			if err = trashLink(user, &trashed); err != nil {
+				return errors.E(innerOp, err)
+			}
+
+			link.TrashedAt = trashed.TrashedAt
+		case before.IsTrashed && !link.IsTrashed:
+			if err = restoreLink(user, link); err != nil {
+				return errors.E(innerOp, err)
+			}
+		case !link.IsTrashed:
+			model.ReconcileUserTags(user, before.UserTags, link.UserTags)
+		}
+
+		link, err = l.Store.UpdateLink(sessCtx, link)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if revert := model.NewLinkRevision(ctx, model.RevisionActionRevert, &before, link); revert != nil {
+			revert.RevertedTo = rev.ID
+			if err = l.RevisionStore.CreateRevisions(sessCtx, []*model.LinkRevision{revert}); err != nil {
+				return errors.E(innerOp, err)
+			}
+		}
+
+		if _, err = l.UserStore.UpdateUser(sessCtx, user); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.E(op, err)
+	}
+
+	return link, user, nil
+}
+
+func (l *Link) GetEnrichmentStatus(ctx context.Context, u *model.User) (*model.EnrichmentStatus, error) {
+	op := errors.Opf("controller.GetEnrichmentStatus(%q)", u.Email)
+
+	status, err := l.Store.GetEnrichmentStatus(ctx, u)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return status, nil
+}
+
+func (l *Link) SummarizeLink(ctx context.Context, u *model.User, id string) (*model.Link, error) {
+	op := errors.Opf("controller.SummarizeLink(%q)", id)
+
+	link, err := l.GetLink(ctx, u, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if link.IsSummarized {
+		// Link already has a summary.
+		return link, nil
+	}
+
+	updatedLink, err := l.summarize(ctx, link)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return updatedLink, nil
+}
+
+// OpenLink records that the user opened the link to read it.
+func (l *Link) OpenLink(ctx context.Context, u *model.User, id string) (*model.Link, error) {
+	op := errors.Opf("controller.OpenLink(%q)", id)
+
+	link, err := l.GetLink(ctx, u, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if err := l.Store.SetLinkOpened(ctx, link); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return link, nil
+}
+
+// Summarize is the job that summarizes an article ahead of time, so that the
+// summary is ready when the link is opened.
+func (l *Link) Summarize(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.Summarize(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := l.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	if link.IsSummarized {
+		return nil
+	}
+
+	if _, err := l.summarize(ctx, link); err != nil && !isNotFound(err) {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (l *Link) summarize(ctx context.Context, link *model.Link) (*model.Link, error) {
+	op := errors.Opf("controller.summarize(%q)", link.ID)
+
+	var (
+		summary string
+		err     error
+	)
+
+	if link.IsArticle && link.Corpus != "" {
+		summary, err = l.Analyzer.Summarize(ctx, link.Corpus)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	// The link is read again since summarizing can take a while.
+	link, err = l.Store.GetLinkByID(ctx, link.ID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	link.Summary = summary
+	link.IsSummarized = true
+
+	updatedLink, err := l.Store.UpdateLink(ctx, link)
+	if err != nil {
+		return nil, errors.E(op, errors.Str("failed to update link with summary"), err)
+	}
+
+	return updatedLink, nil
+}
+
+// GetDuplicateLinks returns the user's links that are for the same page as
+// another of their links, grouped by canonical URL.
+func (l *Link) GetDuplicateLinks(ctx context.Context, u *model.User) ([]*model.DuplicateLinks, error) {
+	op := errors.Opf("controller.GetDuplicateLinks(%q)", u.Email)
+
+	dupes, err := l.Store.GetDuplicateLinks(ctx, u)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return dupes, nil
+}
+
+// MergeDuplicateLinks merges each group of duplicate links into its oldest
+// link and moves the others to the trash, so that nothing the user added to
+// any of them is lost. It returns the links that were merged into.
+func (l *Link) MergeDuplicateLinks(
+	ctx context.Context,
+	u *model.User,
+	req *handler.MergeDuplicateLinksRequest,
+) ([]*model.Link, *model.User, error) {
+	op := errors.Opf("controller.MergeDuplicateLinks(%q)", u.Email)
+
+	var merged []*model.Link
+	var user *model.User
+	var err error
+
+	err = l.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+		innerOp := errors.Opf("%s.innerTxn", op)
+
+		user, err = l.UserStore.GetUserByEmail(sessCtx, u.Email)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		dupes, err := l.Store.GetDuplicateLinks(sessCtx, user)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		wanted := make(map[string]bool, len(req.CanonicalURLs))
+		for _, curl := range req.CanonicalURLs {
+			wanted[curl] = true
+		}
+
+		// The duplicates are listed without their annotations, so they are
+		// read again in full.
+		groups := make([][]string, 0, len(dupes))
+		ids := make([]string, 0)
+
+		for _, d := range dupes {
+			if len(groups) == maxMergeGroups {
+				break
+			}
+
+			if len(wanted) > 0 && !wanted[d.CanonicalURL] {
+				continue
+			}
+
+			group := make([]string, len(d.Links))
+			for i, link := range d.Links {
+				group[i] = link.ID
+			}
+
+			groups = append(groups, group)
+			ids = append(ids, group...)
+		}
+
+		links, err := l.Store.GetLinksByIDs(sessCtx, user, ids)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		byID := make(map[string]*model.Link, len(links))
+		for _, link := range links {
+			byID[link.ID] = link
+		}
+
+		merged = make([]*model.Link, 0, len(groups))
+		changed := make([]*model.Link, 0, len(links))
+		revs := make([]*model.LinkRevision, 0, len(links))
+
+		for _, group := range groups {
+			keep := byID[group[0]]
+			if keep == nil {
+				continue
+			}
+
+			before := *keep
+
+			for _, id := range group[1:] {
+				other := byID[id]
+				if other == nil {
+					continue
+				}
+
+				mergeLink(user, keep, other)
+
+				trashed := *other
+				if err = trashLink(user, other); err != nil {
+					return errors.E(innerOp, err)
+				}
+
+				changed = append(changed, other)
+				revs = append(revs, model.NewLinkRevision(ctx, model.RevisionActionTrash, &trashed, other))
+			}
+
+			changed = append(changed, keep)
+			merged = append(merged, keep)
+
+			if rev := model.NewLinkRevision(ctx, model.RevisionActionUpdate, &before, keep); rev != nil {
+				revs = append(revs, rev)
+			}
+		}
+
+		if err = l.Store.UpdateLinks(sessCtx, changed); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if err = l.RevisionStore.CreateRevisions(sessCtx, revs); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if _, err = l.UserStore.UpdateUser(sessCtx, user); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.E(op, err)
+	}
+
+	return merged, user, nil
+}
+
+// maxMergeGroups is how many groups of duplicates are merged at a time, so
+// that the transaction stays small.
+const maxMergeGroups = 100
+
+// mergeLink gives the link the user tags, annotation and highlights of its
+// duplicate. The link becomes a favorite if the duplicate is one, and takes
+// the duplicate's folder if it isn't in a folder itself.
+func mergeLink(u *model.User, link, dupe *model.Link) {
+	tags := addTags(link.UserTags, dupe.UserTags)
+	model.ReconcileUserTags(u, link.UserTags, tags)
+	link.UserTags = tags
+
+	if dupe.Annotation != "" && !strings.Contains(link.Annotation, dupe.Annotation) {
+		if link.Annotation == "" {
+			link.Annotation = dupe.Annotation
+		} else {
+			link.Annotation = link.Annotation + "\n\n" + dupe.Annotation
+		}
+	}
+
+	if len(dupe.Highlights) > 0 {
+		link.Highlights = append(link.Highlights, dupe.Highlights...)
+		link.ReanchorHighlights()
+	}
+
+	link.IsFavorite = link.IsFavorite || dupe.IsFavorite
+
+	if (link.FolderID == "" || link.FolderID == "root") && dupe.FolderID != "" {
+		link.FolderID = dupe.FolderID
+	}
+}
+
+// checkDuplicate returns an error that names the user's link for the same
+// page as the given link, if they have one other than the link itself.
+func (l *Link) checkDuplicate(ctx context.Context, u *model.User, link *model.Link) error {
+	op := errors.Op("controller.checkDuplicate")
+
+	urls := []string{link.URL}
+	if link.CanonicalURL != "" {
+		urls = append(urls, link.CanonicalURL)
+	}
+
+	found, err := l.Store.GetLinksByURLs(ctx, u, urls)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, other := range found {
+		if other.ID != link.ID {
+			return errors.E(op,
+				errors.Strf("duplicate of link %q", other.ID),
+				errors.M{"url": "This link has already been saved.", "linkId": other.ID},
+				http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
+// savedRedirectTargets returns the user's links for the pages that the given
+// links were found to redirect to, as a map from their URLs and canonical
+// URLs to their IDs.
+func (l *Link) savedRedirectTargets(
+	ctx context.Context,
+	u *model.User,
+	links []*model.Link,
+) (map[string]string, error) {
+	op := errors.Op("controller.savedRedirectTargets")
+
+	urls := make([]string, 0, 2*len(links))
+	for _, link := range links {
+		if link.Health != nil && link.Health.Status == model.HealthRedirected {
+			urls = append(urls, link.Health.FinalURL, canonicalURL(link.Health.FinalURL, ""))
+		}
+	}
+
+	found, err := l.Store.GetLinksByURLs(ctx, u, urls)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	saved := make(map[string]string, 2*len(found))
+	for _, other := range found {
+		saved[other.URL] = other.ID
+		saved[other.CanonicalURL] = other.ID
+	}
+
+	return saved, nil
+}
+
+// checkRedirect returns why the link can't be moved to the URL it was found to
+// redirect to, or "" if it can. Links can't be moved to a page that one of
+// the user's other links in saved is already for.
+func checkRedirect(link *model.Link, saved map[string]string) string {
+	if link.Health == nil || link.Health.Status != model.HealthRedirected || link.Health.FinalURL == "" {
+		return "The link isn't known to redirect"
+	}
+
+	for _, u := range []string{link.Health.FinalURL, canonicalURL(link.Health.FinalURL, "")} {
+		if id, ok := saved[u]; ok && id != link.ID {
+			return "The page the link redirects to has already been saved"
+		}
+	}
+
+	return ""
+}
+
+// canonicalURL returns the canonical form of a link's URL, given the URL its
+// page names as canonical, which can be empty. URLs that can't be
+// canonicalized are used as they are.
+func canonicalURL(rawURL, relCanonical string) string {
+	curl, err := canonical.Resolve(rawURL, relCanonical)
+	if err != nil {
+		return rawURL
+	}
+
+	return curl
+}
+
+// trashLink moves the link to the trash. Its tags stop counting towards the
+// user's tag tree and user tags until it is restored.
+func trashLink(u *model.User, link *model.Link) error {
+	if err := u.TagTree.UpdateWithDeletedTagDetails(link.TagDetails); err != nil {
+		return err
+	}
+
+	u.UserTags.UpdateWithRemovedTags(link.UserTags)
+	u.ReadingQueue, _ = u.ReadingQueue.Remove(link.ID)
+
+	now := time.Now()
+	link.IsTrashed = true
+	link.TrashedAt = &now
+
+	return nil
+}
+
+func restoreLink(u *model.User, link *model.Link) error {
+	if err := u.TagTree.UpdateWithNewTagDetails(link.TagDetails); err != nil {
+		return err
+	}
+
+	u.UserTags.UpdateWithAddedTags(link.UserTags)
+
+	link.IsTrashed = false
+	link.TrashedAt = nil
+
+	if !doesFolderExist(u, link.FolderID) {
+		link.FolderID = "root"
+	}
+
+	return nil
+}
+
+func doesFolderExist(u *model.User, folderID string) bool {
+	if folderID == "root" {
+		return true
+	}
+
+	found := u.FolderTree.BFS(folderID)
+
+	return found != nil
+}
+
+func addTags(existing, added []string) []string {
+	out := append(make([]string, 0, len(existing)+len(added)), existing...)
+
+	for _, tag := range added {
+		if !containsTag(out, tag) {
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
+
+func removeTags(existing, removed []string) []string {
+	out := make([]string, 0, len(existing))
+
+	for _, tag := range existing {
+		if !containsTag(removed, tag) {
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/readingqueue"
+	"github.com/linksort/linksort/model"
+)
+
+type ReadingQueue struct {
+	Store interface {
+		GetLinkByID(context.Context, string) (*model.Link, error)
+		GetLinksByIDs(context.Context, *model.User, []string) ([]*model.Link, error)
+	}
+	UserStore model.UserStore
+}
+
+// GetReadingQueue returns a page of the links in the user's reading queue, in
+// the order they are queued.
+func (c *ReadingQueue) GetReadingQueue(
+	ctx context.Context,
+	u *model.User,
+	p *model.Pagination,
+) ([]*model.Link, error) {
+	op := errors.Op("controller.GetReadingQueue")
+
+	start, end := p.Offset(), p.Offset()+p.Limit()
+	if start > len(u.ReadingQueue) {
+		start = len(u.ReadingQueue)
+	}
+
+	if end > len(u.ReadingQueue) {
+		end = len(u.ReadingQueue)
+	}
+
+	ids := u.ReadingQueue[start:end]
+
+	found, err := c.Store.GetLinksByIDs(ctx, u, ids)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	byID := make(map[string]*model.Link, len(found))
+	for _, link := range found {
+		byID[link.ID] = link
+	}
+
+	links := make([]*model.Link, 0, len(ids))
+	for _, id := range ids {
+		if link, ok := byID[id]; ok {
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+func (c *ReadingQueue) AddToReadingQueue(
+	ctx context.Context,
+	u *model.User,
+	req *handler.AddToReadingQueueRequest,
+) (*model.User, error) {
+	op := errors.Opf("controller.AddToReadingQueue(%q)", req.LinkID)
+
+	link, err := c.Store.GetLinkByID(ctx, req.LinkID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if link.UserID != u.ID {
+		return nil, errors.E(op, errors.Str("no permission"), http.StatusNotFound)
+	}
+
+	if link.IsTrashed {
+		return nil, errors.E(op, errors.Str("link is in the trash"), http.StatusBadRequest,
+			errors.M{"message": "This link is in the trash. Restore it first."})
+	}
+
+	if len(u.ReadingQueue) >= model.MaxReadingQueueLength && !u.ReadingQueue.Contains(link.ID) {
+		return nil, errors.E(op,
+			errors.Str("reading queue limit reached"),
+			errors.M{"message": "Your reading queue has reached the limit of 1000 links."},
+			http.StatusBadRequest)
+	}
+
+	pos := len(u.ReadingQueue)
+	if req.Position != nil {
+		pos = *req.Position
+	}
+
+	u.ReadingQueue = u.ReadingQueue.Insert(link.ID, pos)
+
+	u, err = c.UserStore.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return u, nil
+}
+
+func (c *ReadingQueue) ReorderReadingQueue(
+	ctx context.Context,
+	u *model.User,
+	req *handler.ReorderReadingQueueRequest,
+) (*model.User, error) {
+	op := errors.Op("controller.ReorderReadingQueue")
+
+	if !u.ReadingQueue.IsReorderOf(req.LinkIDs) {
+		return nil, errors.E(op,
+			errors.Str("not a reordering of the queue"),
+			errors.M{"linkIds": "These must be the links in your reading queue, each given once."},
+			http.StatusBadRequest)
+	}
+
+	u.ReadingQueue = append(model.ReadingQueue{}, req.LinkIDs...)
+
+	u, err := c.UserStore.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return u, nil
+}
+
+func (c *ReadingQueue) RemoveFromReadingQueue(
+	ctx context.Context,
+	u *model.User,
+	linkID string,
+) (*model.User, error) {
+	op := errors.Opf("controller.RemoveFromReadingQueue(%q)", linkID)
+
+	var found bool
+
+	u.ReadingQueue, found = u.ReadingQueue.Remove(linkID)
+	if !found {
+		return nil, errors.E(
+			op,
+			errors.Str("link not queued"),
+			errors.M{"message": "The given link is not in the reading queue."},
+			http.StatusNotFound)
+	}
+
+	u, err := c.UserStore.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/readingqueue"
+	"github.com/linksort/linksort/model"
+)
+
+type mockReadingQueueStore struct {
+	links map[string]*model.Link
+}
+
+func (m *mockReadingQueueStore) GetLinkByID(_ context.Context, id string) (*model.Link, error) {
+	if l, ok := m.links[id]; ok {
+		return l, nil
+	}
+
+	return nil, errors.E(errors.Op("mockReadingQueueStore.GetLinkByID"), errors.Str("no documents"), http.StatusNotFound)
+}
+
+func (m *mockReadingQueueStore) GetLinksByIDs(_ context.Context, _ *model.User, ids []string) ([]*model.Link, error) {
+	// The store doesn't keep the order of the IDs it's given.
+	links := make([]*model.Link, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		if l, ok := m.links[ids[i]]; ok {
+			links = append(links, l)
+		}
+	}
+
+	return links, nil
+}
+
+func TestReadingQueue(t *testing.T) {
+	ctx := context.Background()
+	usr := &model.User{ID: "u"}
+	store := &mockReadingQueueStore{links: map[string]*model.Link{
+		"a":     {ID: "a", UserID: "u"},
+		"b":     {ID: "b", UserID: "u"},
+		"c":     {ID: "c", UserID: "u"},
+		"other": {ID: "other", UserID: "someone else"},
+		"trash": {ID: "trash", UserID: "u", IsTrashed: true},
+	}}
+	controller := ReadingQueue{Store: store, UserStore: &mockUserStore{}}
+
+	for _, id := range []string{"a", "b"} {
+		if _, err := controller.AddToReadingQueue(ctx, usr, &handler.AddToReadingQueueRequest{LinkID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := 0
+	usr, err := controller.AddToReadingQueue(ctx, usr, &handler.AddToReadingQueueRequest{LinkID: "c", Position: &first})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(usr.ReadingQueue, model.ReadingQueue{"c", "a", "b"}) {
+		t.Fatalf("unexpected queue: %v", usr.ReadingQueue)
+	}
+
+	for id, status := range map[string]int{"other": http.StatusNotFound, "trash": http.StatusBadRequest} {
+		_, err := controller.AddToReadingQueue(ctx, usr, &handler.AddToReadingQueueRequest{LinkID: id})
+
+		var lerr *errors.Error
+		if !errors.As(err, &lerr) || lerr.Status() != status {
+			t.Errorf("adding %q: expected a %d, got %v", id, status, err)
+		}
+	}
+
+	_, err = controller.ReorderReadingQueue(ctx, usr, &handler.ReorderReadingQueueRequest{LinkIDs: []string{"a", "b"}})
+	if err == nil {
+		t.Error("expected an error when leaving a link out of the new order")
+	}
+
+	usr, err = controller.ReorderReadingQueue(ctx, usr, &handler.ReorderReadingQueueRequest{LinkIDs: []string{"b", "c", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := controller.GetReadingQueue(ctx, usr, &model.Pagination{Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links) != 2 || links[0].ID != "b" || links[1].ID != "c" {
+		t.Errorf("links are not in queue order: %v", links)
+	}
+
+	usr, err = controller.RemoveFromReadingQueue(ctx, usr, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(usr.ReadingQueue, model.ReadingQueue{"b", "a"}) {
+		t.Errorf("unexpected queue: %v", usr.ReadingQueue)
+	}
+
+	if _, err := controller.RemoveFromReadingQueue(ctx, usr, "c"); err == nil {
+		t.Error("expected an error when removing a link that isn't queued")
+	}
+}
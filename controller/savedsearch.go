@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/savedsearch"
+	"github.com/linksort/linksort/model"
+)
+
+type SavedSearch struct {
+	Store model.UserStore
+}
+
+const maxSavedSearchCount = 100
+
+func (s *SavedSearch) CreateSavedSearch(
+	ctx context.Context,
+	usr *model.User,
+	req *handler.CreateSavedSearchRequest,
+) (*model.User, error) {
+	op := errors.Op("controller.CreateSavedSearch")
+
+	if len(usr.SavedSearches) >= maxSavedSearchCount {
+		return nil, errors.E(op,
+			errors.Str("saved search limit reached"),
+			errors.M{"message": "You have reached the limit of 100 saved searches."},
+			http.StatusBadRequest)
+	}
+
+	search := model.NewSavedSearch(req.Name)
+	if err := applySavedSearchRequest(usr, search, req); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	usr.SavedSearches = append(usr.SavedSearches, search)
+
+	usr, err := s.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+func (s *SavedSearch) UpdateSavedSearch(
+	ctx context.Context,
+	usr *model.User,
+	req *handler.UpdateSavedSearchRequest,
+) (*model.User, error) {
+	op := errors.Opf("controller.UpdateSavedSearch(%q)", req.ID)
+
+	search := usr.SavedSearches.Find(req.ID)
+	if search == nil {
+		return nil, errSavedSearchNotFound(op)
+	}
+
+	if err := applySavedSearchRequest(usr, search, &req.CreateSavedSearchRequest); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	usr, err := s.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+func (s *SavedSearch) DeleteSavedSearch(
+	ctx context.Context,
+	usr *model.User,
+	id string,
+) (*model.User, error) {
+	op := errors.Opf("controller.DeleteSavedSearch(%q)", id)
+
+	var found *model.SavedSearch
+
+	usr.SavedSearches, found = usr.SavedSearches.Remove(id)
+	if found == nil {
+		return nil, errSavedSearchNotFound(op)
+	}
+
+	usr, err := s.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+func applySavedSearchRequest(
+	usr *model.User,
+	search *model.SavedSearch,
+	req *handler.CreateSavedSearchRequest,
+) error {
+	op := errors.Op("controller.applySavedSearchRequest")
+
+	if req.FolderID != "" && usr.FolderTree.BFS(req.FolderID) == nil {
+		return errors.E(op,
+			errors.Strf("folder %q not found", req.FolderID),
+			errors.M{"folderId": "The given folder was not found."},
+			http.StatusBadRequest)
+	}
+
+	// The query is checked now rather than when the saved search is opened.
+	if _, err := parseSearch(usr, req.Search); err != nil {
+		return errors.E(op, err)
+	}
+
+	search.Name = req.Name
+	search.Search = req.Search
+	search.Sort = req.Sort
+	search.Favorites = req.Favorites
+	search.Annotated = req.Annotated
+	search.FolderID = req.FolderID
+	search.TagPath = req.TagPath
+	search.UserTag = req.UserTag
+
+	return nil
+}
+
+func errSavedSearchNotFound(op errors.Op) error {
+	return errors.E(
+		op,
+		errors.Str("saved search not found"),
+		errors.M{"message": "The given saved search was not found."},
+		http.StatusNotFound)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/linksort/linksort/errors"
+	handler "github.com/linksort/linksort/handler/savedsearch"
+	"github.com/linksort/linksort/model"
+)
+
+func TestSavedSearch(t *testing.T) {
+	ctx := context.Background()
+	usr := &model.User{
+		FolderTree:    &model.Folder{Name: "root", ID: "root"},
+		SavedSearches: make(model.SavedSearches, 0),
+	}
+	folder := model.NewFolder("Reading", usr.FolderTree)
+	controller := SavedSearch{Store: &mockUserStore{}}
+
+	usr, err := controller.CreateSavedSearch(ctx, usr, &handler.CreateSavedSearchRequest{
+		Name:      "Go articles",
+		Search:    "golang",
+		Favorites: true,
+		FolderID:  folder.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usr.SavedSearches) != 1 {
+		t.Fatalf("got %d saved searches, want 1", len(usr.SavedSearches))
+	}
+
+	search := usr.SavedSearches[0]
+	req := savedSearchRequest(search, &model.Pagination{})
+
+	if req.Search != "golang" || req.Favorites != "1" || req.Annotations != "" || req.FolderID != folder.ID {
+		t.Errorf("unexpected request for saved search: %+v", req)
+	}
+
+	usr, err = controller.UpdateSavedSearch(ctx, usr, &handler.UpdateSavedSearchRequest{
+		CreateSavedSearchRequest: handler.CreateSavedSearchRequest{Name: "Everything"},
+		ID:                       search.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := usr.SavedSearches.Find(search.ID); got.Name != "Everything" || got.Search != "" || got.Favorites {
+		t.Errorf("saved search was not replaced: %+v", got)
+	}
+
+	usr, err = controller.DeleteSavedSearch(ctx, usr, search.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usr.SavedSearches) != 0 {
+		t.Fatalf("saved search was not deleted: %+v", usr.SavedSearches)
+	}
+
+	_, err = controller.DeleteSavedSearch(ctx, usr, search.ID)
+
+	var e *errors.Error
+	if !errors.As(err, &e) || e.Status() != http.StatusNotFound {
+		t.Fatalf("expected a 404, got %v", err)
+	}
+}
+
+func TestCreateSavedSearch_UnknownFolder(t *testing.T) {
+	usr := &model.User{
+		FolderTree:    &model.Folder{Name: "root", ID: "root"},
+		SavedSearches: make(model.SavedSearches, 0),
+	}
+	store := &mockUserStore{}
+	controller := SavedSearch{Store: store}
+
+	_, err := controller.CreateSavedSearch(context.Background(), usr, &handler.CreateSavedSearchRequest{
+		Name:     "Missing",
+		FolderID: "6f1c5d7e-6a53-4b8e-9f57-1d8f2a1b9c3e",
+	})
+
+	var e *errors.Error
+	if !errors.As(err, &e) || e.Status() != http.StatusBadRequest {
+		t.Fatalf("expected a 400, got %v", err)
+	}
+
+	if store.updateCalled || len(usr.SavedSearches) != 0 {
+		t.Fatal("the saved search should not have been saved")
+	}
+}
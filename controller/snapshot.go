@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/linksort/linksort/analyze"
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+)
+
+type Snapshot struct {
+	Store interface {
+		GetLinkByID(ctx context.Context, id string) (*model.Link, error)
+	}
+	SnapshotStore model.SnapshotStore
+	Fetcher       interface {
+		FetchPage(ctx context.Context, url string) (*analyze.Page, error)
+	}
+}
+
+func (c *Snapshot) GetSnapshots(
+	ctx context.Context,
+	u *model.User,
+	linkID string,
+	p *model.Pagination,
+) ([]*model.Snapshot, error) {
+	op := errors.Opf("controller.GetSnapshots(%q)", linkID)
+
+	link, err := c.getLink(ctx, u, linkID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	snaps, err := c.SnapshotStore.GetSnapshotsByLink(ctx, link, p)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return snaps, nil
+}
+
+func (c *Snapshot) GetSnapshot(ctx context.Context, u *model.User, linkID, id string) (*model.Snapshot, error) {
+	op := errors.Opf("controller.GetSnapshot(%q, %q)", linkID, id)
+
+	link, err := c.getLink(ctx, u, linkID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	snap, err := c.SnapshotStore.GetSnapshotByID(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errSnapshotNotFound(op)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	if snap.LinkID != link.ID {
+		return nil, errSnapshotNotFound(op)
+	}
+
+	return snap, nil
+}
+
+// CreateSnapshot takes a snapshot of the link's page now. When the page hasn't
+// changed since the last snapshot, no new one is made and the last one is
+// returned instead, without its HTML and text. It reports whether a new
+// snapshot was made.
+func (c *Snapshot) CreateSnapshot(ctx context.Context, u *model.User, linkID string) (*model.Snapshot, bool, error) {
+	op := errors.Opf("controller.CreateSnapshot(%q)", linkID)
+
+	link, err := c.getLink(ctx, u, linkID)
+	if err != nil {
+		return nil, false, errors.E(op, err)
+	}
+
+	if link.IsTrashed {
+		return nil, false, errors.E(op, errors.Str("link is in the trash"), http.StatusBadRequest,
+			errors.M{"message": "This link is in the trash. Restore it first."})
+	}
+
+	page, err := c.Fetcher.FetchPage(ctx, link.URL)
+	if err != nil {
+		return nil, false, errors.E(op, err, http.StatusBadGateway,
+			errors.M{"message": "The link's page couldn't be fetched."})
+	}
+
+	snap, created, err := c.save(ctx, link, page)
+	if err != nil {
+		return nil, false, errors.E(op, err)
+	}
+
+	return snap, created, nil
+}
+
+// SnapshotLink is the job that takes the first snapshot of a link after it is
+// saved or enriched.
+func (c *Snapshot) SnapshotLink(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.SnapshotLink(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := c.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	page, err := c.Fetcher.FetchPage(ctx, link.URL)
+	if err != nil {
+		if errors.Is(err, analyze.ErrNotHTML) ||
+			errors.Is(err, analyze.ErrPageError) ||
+			errors.Is(err, analyze.ErrUnparsableURI) {
+			err = queue.Permanent(err)
+		}
+
+		return errors.E(op, err)
+	}
+
+	if _, _, err := c.save(ctx, link, page); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// save stores the page as a snapshot of the link unless it is the same as the
+// last one, and deletes the oldest snapshots past MaxSnapshotsPerLink.
+func (c *Snapshot) save(ctx context.Context, link *model.Link, page *analyze.Page) (*model.Snapshot, bool, error) {
+	op := errors.Opf("controller.Snapshot.save(%q)", link.ID)
+
+	snap := model.NewSnapshot(link, page.URL, page.HTML, page.Text)
+
+	last, err := c.SnapshotStore.GetSnapshotsByLink(ctx, link, &model.Pagination{Size: 1})
+	if err != nil {
+		return nil, false, errors.E(op, err)
+	}
+
+	if len(last) > 0 && last[0].ContentHash == snap.ContentHash {
+		return last[0], false, nil
+	}
+
+	snap, err = c.SnapshotStore.CreateSnapshot(ctx, snap)
+	if err != nil {
+		return nil, false, errors.E(op, err)
+	}
+
+	if err := c.SnapshotStore.DeleteOldSnapshots(ctx, link, model.MaxSnapshotsPerLink); err != nil {
+		return nil, false, errors.E(op, err)
+	}
+
+	return snap, true, nil
+}
+
+func (c *Snapshot) getLink(ctx context.Context, u *model.User, id string) (*model.Link, error) {
+	op := errors.Opf("controller.Snapshot.getLink(%q)", id)
+
+	link, err := c.Store.GetLinkByID(ctx, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if link.UserID != u.ID {
+		return nil, errors.E(op, errors.Str("no permission"), http.StatusNotFound)
+	}
+
+	return link, nil
+}
+
+func errSnapshotNotFound(op errors.Op) error {
+	return errors.E(
+		op,
+		errors.Str("snapshot not found"),
+		errors.M{"message": "The given snapshot was not found."},
+		http.StatusNotFound)
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/linksort/linksort/analyze"
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+type mockSnapshotLinkStore struct {
+	link *model.Link
+}
+
+func (m *mockSnapshotLinkStore) GetLinkByID(context.Context, string) (*model.Link, error) {
+	return m.link, nil
+}
+
+type mockSnapshotStore struct {
+	snaps []*model.Snapshot
+	kept  int
+}
+
+func (m *mockSnapshotStore) CreateSnapshot(_ context.Context, s *model.Snapshot) (*model.Snapshot, error) {
+	s.ID = string(rune('a' + len(m.snaps)))
+	m.snaps = append([]*model.Snapshot{s}, m.snaps...)
+	return s, nil
+}
+func (m *mockSnapshotStore) GetSnapshotByID(_ context.Context, id string) (*model.Snapshot, error) {
+	for _, s := range m.snaps {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, errors.E(errors.Op("mockSnapshotStore.GetSnapshotByID"), http.StatusNotFound)
+}
+func (m *mockSnapshotStore) GetSnapshotsByLink(_ context.Context, _ *model.Link, p *model.Pagination) ([]*model.Snapshot, error) {
+	if len(m.snaps) > p.Limit() {
+		return m.snaps[:p.Limit()], nil
+	}
+	return m.snaps, nil
+}
+func (m *mockSnapshotStore) DeleteOldSnapshots(_ context.Context, _ *model.Link, keep int) error {
+	m.kept = keep
+	return nil
+}
+func (m *mockSnapshotStore) DeleteSnapshotsByLinks(context.Context, []string) error {
+	return nil
+}
+func (m *mockSnapshotStore) DeleteAllSnapshotsByUser(context.Context, *model.User) error {
+	return nil
+}
+
+type mockFetcher struct {
+	html string
+	err  error
+}
+
+func (m *mockFetcher) FetchPage(_ context.Context, url string) (*analyze.Page, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &analyze.Page{URL: url, HTML: m.html, Text: "text"}, nil
+}
+
+func newTestSnapshot(fetcher *mockFetcher) (*Snapshot, *mockSnapshotStore, *model.User) {
+	usr := &model.User{ID: "user"}
+	store := &mockSnapshotStore{}
+
+	return &Snapshot{
+		Store: &mockSnapshotLinkStore{
+			link: &model.Link{ID: "link", UserID: usr.ID, URL: "https://example.com"},
+		},
+		SnapshotStore: store,
+		Fetcher:       fetcher,
+	}, store, usr
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fetcher := &mockFetcher{html: "<p>First</p>"}
+	c, store, usr := newTestSnapshot(fetcher)
+
+	first, created, err := c.CreateSnapshot(ctx, usr, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !created || first.ContentHash == "" || first.Size != len("<p>First</p>") {
+		t.Errorf("expected a new snapshot, got %+v", first)
+	}
+
+	if store.kept != model.MaxSnapshotsPerLink {
+		t.Errorf("expected old snapshots to be pruned to %d, got %d", model.MaxSnapshotsPerLink, store.kept)
+	}
+
+	again, created, err := c.CreateSnapshot(ctx, usr, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created || again.ID != first.ID {
+		t.Errorf("expected the unchanged page to return the last snapshot, got %+v", again)
+	}
+
+	fetcher.html = "<p>Second</p>"
+
+	second, created, err := c.CreateSnapshot(ctx, usr, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !created || second.ContentHash == first.ContentHash || len(store.snaps) != 2 {
+		t.Errorf("expected a new snapshot of the changed page, got %+v", second)
+	}
+}
+
+func TestCreateSnapshot_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		Name         string
+		GivenUser    *model.User
+		GivenFetcher *mockFetcher
+		ExpectStatus int
+	}{
+		{
+			Name:         "someone else's link",
+			GivenUser:    &model.User{ID: "other"},
+			GivenFetcher: &mockFetcher{},
+			ExpectStatus: http.StatusNotFound,
+		},
+		{
+			Name:         "page can't be fetched",
+			GivenFetcher: &mockFetcher{err: analyze.ErrPageError},
+			ExpectStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			c, _, usr := newTestSnapshot(tcase.GivenFetcher)
+			if tcase.GivenUser != nil {
+				usr = tcase.GivenUser
+			}
+
+			_, _, err := c.CreateSnapshot(ctx, usr, "link")
+
+			var e *errors.Error
+			if !errors.As(err, &e) || e.Status() != tcase.ExpectStatus {
+				t.Errorf("got %v, want status %d", err, tcase.ExpectStatus)
+			}
+		})
+	}
+}
+
+func TestGetSnapshot_OtherLink(t *testing.T) {
+	ctx := context.Background()
+	c, store, usr := newTestSnapshot(&mockFetcher{})
+	store.snaps = []*model.Snapshot{{ID: "a", LinkID: "other-link"}}
+
+	if _, err := c.GetSnapshot(ctx, usr, "link", "a"); !isNotFound(err) {
+		t.Errorf("expected a snapshot of another link to be not found, got %v", err)
+	}
+}
@@ -2,22 +2,28 @@ package controller
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/linksort/linksort/bookmarks"
+	"github.com/linksort/linksort/db"
 	"github.com/linksort/linksort/errors"
 	handler "github.com/linksort/linksort/handler/user"
 	"github.com/linksort/linksort/log"
 	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
 	"github.com/linksort/linksort/random"
+	"github.com/linksort/linksort/vault"
 )
 
 type User struct {
@@ -25,7 +31,19 @@ type User struct {
 	LinkStore interface {
 		CreateLink(ctx context.Context, link *model.Link) (*model.Link, error)
 		DeleteAllLinksByUser(ctx context.Context, u *model.User) error
-		GetAllLinksByUser(ctx context.Context, u *model.User, p *model.Pagination) ([]*model.Link, error)
+		GetAllLinksByUser(ctx context.Context, u *model.User, p *model.Pagination, opts ...model.GetLinksOption) ([]*model.Link, error)
+		GetHighlightsByUser(ctx context.Context, u *model.User, p *model.Pagination) ([]*model.LinkHighlight, error)
+		GetLinksByURLs(ctx context.Context, u *model.User, urls []string) ([]*model.Link, error)
+		CreateLinks(ctx context.Context, ll []*model.Link) error
+	}
+	RevisionStore interface {
+		DeleteAllRevisionsByUser(ctx context.Context, u *model.User) error
+	}
+	SnapshotStore interface {
+		DeleteAllSnapshotsByUser(ctx context.Context, u *model.User) error
+	}
+	ChangeStore interface {
+		DeleteAllLinkChangesByUser(ctx context.Context, u *model.User) error
 	}
 	Email interface {
 		SendForgotPassword(context.Context, *model.User, string) error
@@ -34,6 +52,14 @@ type User struct {
 		Link(action, email, salt string) string
 		Verify(email, b64ts, salt, sig string, expiry time.Duration) error
 	}
+	// Importers parse the export files of other services, keyed by the name
+	// of the format.
+	Importers      map[string]bookmarks.Parser
+	ImportJobStore model.ImportJobStore
+	Transactor     db.Transactor
+	Queue          interface {
+		Enqueue(ctx context.Context, kind string, payload interface{}) error
+	}
 }
 
 func (u *User) CreateUser(ctx context.Context, req *handler.CreateUserRequest) (*model.User, error) {
@@ -59,6 +85,7 @@ func (u *User) CreateUser(ctx context.Context, req *handler.CreateUserRequest) (
 			ID:       "root",
 			Children: make([]*model.Folder, 0),
 		},
+		SavedSearches: make(model.SavedSearches, 0),
 		TagTree: &model.TagNode{
 			Name:     "root",
 			Path:     "root",
@@ -133,6 +160,26 @@ func (u *User) DeleteUser(ctx context.Context, usr *model.User) error {
 		return errors.E(op, err)
 	}
 
+	err = u.RevisionStore.DeleteAllRevisionsByUser(ctx, usr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	err = u.SnapshotStore.DeleteAllSnapshotsByUser(ctx, usr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	err = u.ChangeStore.DeleteAllLinkChangesByUser(ctx, usr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	err = u.ImportJobStore.DeleteAllImportJobsByUser(ctx, usr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
 	err = u.Store.DeleteUser(ctx, usr)
 	if err != nil {
 		return errors.E(op, err)
@@ -196,6 +243,70 @@ func (u *User) ChangePassword(ctx context.Context, req *handler.ChangePasswordRe
 	return usr, nil
 }
 
+// UpdateDigestSchedule turns the user's digest on or off and sets when it is
+// sent.
+func (u *User) UpdateDigestSchedule(
+	ctx context.Context,
+	usr *model.User,
+	req *handler.UpdateDigestScheduleRequest,
+) (*model.User, error) {
+	op := errors.Opf("controller.UpdateDigestSchedule(%q)", usr.Email)
+
+	if _, err := time.LoadLocation(req.TimeZone); err != nil {
+		return nil, errors.E(op, err,
+			errors.M{"timeZone": "This is not a known time zone."},
+			http.StatusBadRequest)
+	}
+
+	usr.Digest.IsEnabled = req.IsEnabled
+	usr.Digest.Weekday = time.Weekday(req.Weekday)
+	usr.Digest.Hour = req.Hour
+	usr.Digest.TimeZone = req.TimeZone
+	usr.Digest.NextSendAt = nil
+
+	if usr.Digest.IsEnabled {
+		next := usr.Digest.Next(time.Now())
+		usr.Digest.NextSendAt = &next
+	}
+
+	usr, err := u.Store.UpdateUser(ctx, usr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return usr, nil
+}
+
+// Unsubscribe turns off the digest of the user that the signed unsubscribe
+// link in their digest email was made for.
+func (u *User) Unsubscribe(ctx context.Context, req *handler.UnsubscribeRequest) error {
+	op := errors.Opf("controller.Unsubscribe(%q)", req.Email)
+
+	usr, err := u.Store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		return errors.E(op, err, http.StatusUnauthorized)
+	}
+
+	if err := u.Magic.Verify(
+		usr.Email,
+		req.Timestamp,
+		digestUnsubscribeSalt,
+		req.Signature,
+		digestUnsubscribeExpiry,
+	); err != nil {
+		return errors.E(op, err)
+	}
+
+	usr.Digest.IsEnabled = false
+	usr.Digest.NextSendAt = nil
+
+	if _, err := u.Store.UpdateUser(ctx, usr); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
 func (u *User) DownloadUserData(ctx context.Context, usr *model.User, w io.Writer) error {
 	op := errors.Opf("controller.DownloadUserData(%q)", u.Email)
 	flusher, ok := w.(http.Flusher)
@@ -239,6 +350,33 @@ func (u *User) DownloadUserData(ctx context.Context, usr *model.User, w io.Write
 			pagination.Page++
 		}
 	}
+	// Write highlights to zip file, with the links they are on
+	pagination = &model.Pagination{Page: 0, Size: 500}
+	for {
+		batch, err := u.LinkStore.GetHighlightsByUser(ctx, usr, pagination)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		batchW, err := zipW.CreateHeader(&zip.FileHeader{
+			Name:     fmt.Sprintf("highlights-%d.json", pagination.Page),
+			Modified: time.Now(),
+			Method:   zip.Deflate,
+		})
+		if err != nil {
+			return errors.E(op, err)
+		}
+		enc := json.NewEncoder(batchW)
+		enc.Encode(batch)
+		flusher.Flush()
+		if len(batch) < pagination.Size {
+			break
+		} else {
+			pagination.Page++
+		}
+	}
 	zipW.Close()
 	flusher.Flush()
 	return nil
@@ -332,11 +470,9 @@ func (u *User) ImportPocket(ctx context.Context, usr *model.User, r io.Reader) (
 
 		_, err = u.LinkStore.CreateLink(ctx, link)
 		if err != nil {
-			if e, ok := err.(*errors.Error); ok {
-				if e.Status() == http.StatusBadRequest && e.Message()["url"] == "This link has already been saved." {
-					// skip duplicates
-					continue
-				}
+			if isDuplicateLink(err) {
+				// skip duplicates
+				continue
 			}
 			return count, errors.E(op, err)
 		}
@@ -350,3 +486,443 @@ func (u *User) ImportPocket(ctx context.Context, usr *model.User, r io.Reader) (
 
 	return count, nil
 }
+
+// maxImportSize is the largest export file that can be imported.
+const maxImportSize = 64 << 20
+
+// importBatchSize is how many bookmarks an import job saves in each
+// transaction.
+const importBatchSize = 500
+
+// StartImport creates a job that imports the bookmarks in an export file of
+// the given format and starts it in the background. The file is read up front
+// so that one that can't be parsed is rejected right away.
+func (u *User) StartImport(
+	ctx context.Context,
+	usr *model.User,
+	format string,
+	r io.Reader,
+) (*model.ImportJob, error) {
+	op := errors.Opf("controller.StartImport(%s)", format)
+
+	parse, ok := u.Importers[format]
+	if !ok {
+		return nil, errors.E(op, errors.Str("unknown format"), http.StatusBadRequest,
+			errors.M{"format": "This format is not supported."})
+	}
+
+	file, err := io.ReadAll(io.LimitReader(r, maxImportSize+1))
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest,
+			errors.M{"file": "This file could not be read."})
+	}
+
+	if len(file) > maxImportSize {
+		return nil, errors.E(op, errors.Str("file too large"), http.StatusBadRequest,
+			errors.M{"file": "This file is too large."})
+	}
+
+	bms, err := parse(bytes.NewReader(file))
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest,
+			errors.M{"file": "This file could not be read."})
+	}
+
+	job, err := u.ImportJobStore.CreateImportJob(ctx, &model.ImportJob{
+		UserID:    usr.ID,
+		Format:    format,
+		Status:    model.ImportStatusPending,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Total:     len(bms),
+		Errors:    make([]*model.ImportError, 0),
+	}, bytes.NewReader(file))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if err := u.Queue.Enqueue(ctx, JobImport, &importJob{job.ID}); err != nil {
+		// Nothing will ever run the import, so it mustn't be left pending
+		// with its file.
+		job.Status = model.ImportStatusFailed
+		job.Error = "The import could not be started."
+		job.UpdatedAt = time.Now()
+
+		if _, uerr := u.ImportJobStore.UpdateImportJob(ctx, job); uerr != nil {
+			log.Alarm(errors.E(op, uerr))
+		}
+
+		if derr := u.ImportJobStore.DeleteImportFile(ctx, job); derr != nil {
+			log.Alarm(errors.E(op, derr))
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	return job, nil
+}
+
+func (u *User) GetImport(ctx context.Context, usr *model.User, id string) (*model.ImportJob, error) {
+	op := errors.Opf("controller.GetImport(%q)", id)
+
+	job, err := u.ImportJobStore.GetImportJobByID(ctx, id)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if job.UserID != usr.ID {
+		return nil, errors.E(op, errors.Str("no access"), http.StatusNotFound)
+	}
+
+	return job, nil
+}
+
+// RunImport is the job that saves the bookmarks of an import batch by batch
+// and marks the import as done. A retried job picks up after the last batch
+// that was saved. The import is marked as failed once the job has no
+// attempts left, unless it was cut off by the queue shutting down, and its
+// file is deleted once it is done or failed.
+func (u *User) RunImport(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.RunImport(%q)", job.ID)
+
+	p := new(importJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	imp, err := u.ImportJobStore.GetImportJobByID(ctx, p.ImportID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	if imp.Status == model.ImportStatusDone || imp.Status == model.ImportStatusFailed {
+		return nil
+	}
+
+	err = u.processImport(ctx, imp)
+	if err != nil && isNotFound(err) {
+		// The user was deleted in the meantime.
+		return nil
+	}
+
+	if err != nil && (!job.IsLastAttempt() || ctx.Err() != nil) {
+		// A job cut off by shutdown is run again, so the import isn't failed.
+		return errors.E(op, err)
+	}
+
+	if err != nil {
+		imp.Status = model.ImportStatusFailed
+		imp.Error = "The import could not be finished."
+
+		if _, uerr := u.ImportJobStore.UpdateImportJob(ctx, imp); uerr != nil {
+			log.Alarm(errors.E(op, uerr))
+		}
+	}
+
+	if derr := u.ImportJobStore.DeleteImportFile(ctx, imp); derr != nil {
+		log.Alarm(errors.E(op, derr))
+	}
+
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (u *User) processImport(ctx context.Context, job *model.ImportJob) error {
+	op := errors.Op("controller.processImport")
+
+	parse, ok := u.Importers[job.Format]
+	if !ok {
+		return errors.E(op, errors.Strf("unknown format %q", job.Format))
+	}
+
+	f, err := u.ImportJobStore.OpenImportFile(ctx, job)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	bms, err := parse(f)
+	f.Close()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	job.Status = model.ImportStatusRunning
+	if _, err = u.ImportJobStore.UpdateImportJob(ctx, job); err != nil {
+		return errors.E(op, err)
+	}
+
+	for job.Processed < len(bms) {
+		end := job.Processed + importBatchSize
+		if end > len(bms) {
+			end = len(bms)
+		}
+
+		next, err := u.importBatch(ctx, job, bms[job.Processed:end])
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		*job = *next
+	}
+
+	job.Status = model.ImportStatusDone
+	if _, err = u.ImportJobStore.UpdateImportJob(ctx, job); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// importBatch saves a batch of bookmarks as links, creating the folders they
+// are in when the user doesn't have them yet. The links, the user's folders
+// and tags, and the job's progress are saved in one transaction, so that a
+// resumed job neither repeats nor loses a batch. Links are flagged so that
+// their metadata is filled in later.
+func (u *User) importBatch(
+	ctx context.Context,
+	job *model.ImportJob,
+	bms []*bookmarks.Bookmark,
+) (*model.ImportJob, error) {
+	op := errors.Opf("controller.importBatch(%d)", job.Processed)
+
+	var next model.ImportJob
+
+	err := u.Transactor.DoInTransaction(ctx, func(sessCtx context.Context) error {
+		innerOp := errors.Opf("%s.innerTxn", op)
+
+		// Work on a copy so that a retried transaction starts over.
+		next = *job
+		next.Errors = append(make([]*model.ImportError, 0, len(job.Errors)), job.Errors...)
+
+		user, err := u.Store.GetUserByID(sessCtx, job.UserID)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		// Bookmarks are matched to links by their URLs as they are and in
+		// canonical form, so that the same page isn't imported twice.
+		canonicalURLs := make([]string, len(bms))
+		urls := make([]string, 0, 2*len(bms))
+
+		for i, bm := range bms {
+			if isWebURL(bm.URL) {
+				canonicalURLs[i] = canonicalURL(bm.URL, "")
+				urls = append(urls, bm.URL, canonicalURLs[i])
+			}
+		}
+
+		existing, err := u.LinkStore.GetLinksByURLs(sessCtx, user, urls)
+		if err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		saved := make(map[string]bool, 2*(len(existing)+len(bms)))
+		for _, l := range existing {
+			saved[l.URL] = true
+			saved[l.CanonicalURL] = true
+		}
+
+		links := make([]*model.Link, 0, len(bms))
+
+		for i, bm := range bms {
+			if !isWebURL(bm.URL) {
+				next.AddError(job.Processed+i+1, bm.URL, "This is not a web URL.")
+				continue
+			}
+
+			if saved[bm.URL] || saved[canonicalURLs[i]] {
+				next.Skipped++
+				continue
+			}
+
+			saved[bm.URL] = true
+			saved[canonicalURLs[i]] = true
+
+			created := bm.CreatedAt
+			if created.IsZero() {
+				created = time.Now()
+			}
+
+			link := &model.Link{
+				UserID:          user.ID,
+				CreatedAt:       created,
+				UpdatedAt:       created,
+				URL:             bm.URL,
+				CanonicalURL:    canonicalURLs[i],
+				Title:           bm.Title,
+				Description:     bm.Description,
+				Annotation:      bm.Annotation,
+				IsAnnotated:     len(bm.Annotation) > 0,
+				IsFavorite:      bm.IsFavorite,
+				FolderID:        folderForPath(user.FolderTree, bm.Folder),
+				UserTags:        model.NormalizeTags(bm.Tags),
+				NeedsEnrichment: true,
+			}
+
+			user.UserTags.UpdateWithAddedTags(link.UserTags)
+			links = append(links, link)
+		}
+
+		if err = u.LinkStore.CreateLinks(sessCtx, links); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		if _, err = u.Store.UpdateUser(sessCtx, user); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		next.Imported += len(links)
+		next.Processed += len(bms)
+
+		if _, err = u.ImportJobStore.UpdateImportJob(sessCtx, &next); err != nil {
+			return errors.E(innerOp, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &next, nil
+}
+
+// ExportBookmarks writes the user's folders and links as a Netscape
+// bookmarks.html file. Links in the trash are left out.
+func (u *User) ExportBookmarks(ctx context.Context, usr *model.User, w io.Writer) error {
+	op := errors.Opf("controller.ExportBookmarks(%q)", usr.Email)
+
+	links := make([]*model.Link, 0)
+	pagination := &model.Pagination{Page: 0, Size: 500}
+
+	for {
+		batch, err := u.LinkStore.GetAllLinksByUser(ctx, usr, pagination)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, l := range batch {
+			if !l.IsTrashed {
+				links = append(links, l)
+			}
+		}
+
+		if len(batch) < pagination.Size {
+			break
+		}
+
+		pagination.Page++
+	}
+
+	if err := bookmarks.WriteNetscape(w, usr.FolderTree, links); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// ExportMarkdown writes the user's links as a zip of Markdown notes, in
+// directories that mirror their folders. Links in the trash are left out.
+func (u *User) ExportMarkdown(ctx context.Context, usr *model.User, w io.Writer) error {
+	op := errors.Opf("controller.ExportMarkdown(%q)", usr.Email)
+
+	flusher, _ := w.(http.Flusher)
+	vw := vault.NewWriter(w, usr.FolderTree)
+	pagination := &model.Pagination{Page: 0, Size: 500}
+
+	for {
+		batch, err := u.LinkStore.GetAllLinksByUser(ctx, usr, pagination)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, l := range batch {
+			if l.IsTrashed {
+				continue
+			}
+
+			if err := vw.AddLink(l); err != nil {
+				return errors.E(op, err)
+			}
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(batch) < pagination.Size {
+			break
+		}
+
+		pagination.Page++
+	}
+
+	if err := vw.Close(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// folderForPath returns the ID of the folder at the given path of folder
+// names below root, creating the folders that don't exist yet. Once the
+// folder limit is reached, the deepest folder that exists is used instead.
+func folderForPath(root *model.Folder, path []string) string {
+	folder := root
+
+	for _, name := range path {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if len(name) > 128 {
+			name = name[:128]
+		}
+
+		var next *model.Folder
+		for _, child := range folder.Children {
+			if child.Name == name {
+				next = child
+				break
+			}
+		}
+
+		if next == nil {
+			if root.Count() >= maxFolderCount {
+				break
+			}
+
+			next = model.NewFolder(name, folder)
+		}
+
+		folder = next
+	}
+
+	return folder.ID
+}
+
+func isWebURL(s string) bool {
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func isDuplicateLink(err error) bool {
+	var e *errors.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	return e.Status() == http.StatusBadRequest && e.Message()["url"] == "This link has already been saved."
+}
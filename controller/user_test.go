@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/linksort/linksort/bookmarks"
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+type mockImportJobStore struct {
+	model.ImportJobStore
+	job         *model.ImportJob
+	fileDeleted bool
+	open        func(context.Context) (io.ReadCloser, error)
+}
+
+func (m *mockImportJobStore) GetImportJobByID(context.Context, string) (*model.ImportJob, error) {
+	return m.job, nil
+}
+
+func (m *mockImportJobStore) OpenImportFile(ctx context.Context, _ *model.ImportJob) (io.ReadCloser, error) {
+	return m.open(ctx)
+}
+
+func (m *mockImportJobStore) CreateImportJob(
+	_ context.Context,
+	job *model.ImportJob,
+	_ io.Reader,
+) (*model.ImportJob, error) {
+	job.ID = "1"
+	m.job = job
+	return job, nil
+}
+
+func (m *mockImportJobStore) UpdateImportJob(
+	_ context.Context,
+	job *model.ImportJob,
+) (*model.ImportJob, error) {
+	m.job = job
+	return job, nil
+}
+
+func (m *mockImportJobStore) DeleteImportFile(context.Context, *model.ImportJob) error {
+	m.fileDeleted = true
+	return nil
+}
+
+type failingQueue struct{}
+
+func (failingQueue) Enqueue(context.Context, string, interface{}) error {
+	return errors.Str("queue unavailable")
+}
+
+func TestStartImport_EnqueueFailed(t *testing.T) {
+	store := &mockImportJobStore{}
+	u := User{
+		Importers:      map[string]bookmarks.Parser{"pocket": bookmarks.ParsePocket},
+		ImportJobStore: store,
+		Queue:          failingQueue{},
+	}
+
+	_, err := u.StartImport(context.Background(), &model.User{ID: "u"}, "pocket",
+		strings.NewReader("title,url,time_added,tags,status\nA,https://example.com/a,1,,unread\n"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if store.job.Status != model.ImportStatusFailed || store.job.Error == "" {
+		t.Errorf("expected the import to have failed: %+v", store.job)
+	}
+
+	if !store.fileDeleted {
+		t.Error("expected the import file to be deleted")
+	}
+}
+
+func TestRunImport_CanceledOnLastAttempt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &mockImportJobStore{
+		job: &model.ImportJob{ID: "1", UserID: "u", Format: "pocket", Status: model.ImportStatusRunning},
+		open: func(ctx context.Context) (io.ReadCloser, error) {
+			// The queue shuts down while the file is being read.
+			cancel()
+			return nil, ctx.Err()
+		},
+	}
+	u := User{
+		Importers:      map[string]bookmarks.Parser{"pocket": bookmarks.ParsePocket},
+		ImportJobStore: store,
+	}
+
+	job := newTestJob(t, &importJob{ImportID: "1"})
+	job.Attempts = job.MaxAttempts
+
+	if err := u.RunImport(ctx, job); err == nil {
+		t.Fatal("expected an error so that the job is run again")
+	}
+
+	if store.job.Status != model.ImportStatusRunning {
+		t.Errorf("expected the import to still be running, got %q", store.job.Status)
+	}
+
+	if store.fileDeleted {
+		t.Error("expected the import file to be kept")
+	}
+}
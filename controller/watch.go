@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+const (
+	// watchBatchSize is the most links a JobQueueWatches run queues.
+	watchBatchSize = 500
+	// watchInterval is how often the page of a watched link is checked.
+	watchInterval = 24 * time.Hour
+	// watchRetryInterval is how long to wait before checking a page again
+	// when it couldn't be fetched.
+	watchRetryInterval = 6 * time.Hour
+)
+
+// Watcher checks the pages of watched links for changes on a schedule, and
+// records each change it finds.
+type Watcher struct {
+	Store interface {
+		GetLinkByID(ctx context.Context, id string) (*model.Link, error)
+		GetLinksDueForWatch(ctx context.Context, limit int) ([]*model.Link, error)
+		UpdateLinkWatch(ctx context.Context, link *model.Link, watchAfter *time.Time) (bool, error)
+	}
+	ChangeStore interface {
+		CreateLinkChange(ctx context.Context, c *model.LinkChange) error
+	}
+	UserStore interface {
+		GetUserByID(ctx context.Context, id string) (*model.User, error)
+	}
+	Extractor interface {
+		ReadableText(ctx context.Context, url string) (string, error)
+	}
+	// Notifier, if set, is told about each change.
+	Notifier interface {
+		SendLinkChanged(context.Context, *model.User, *model.Link, *model.LinkChange) error
+	}
+	Queue interface {
+		EnqueueUnique(ctx context.Context, kind, key string, payload interface{}) error
+	}
+	// Limiter spaces out requests to the same domain.
+	Limiter *ratelimit.Limiter
+}
+
+// QueueWatches queues a JobWatch for each watched link whose page is due to
+// be checked. Links that already have one queued aren't queued again.
+func (w *Watcher) QueueWatches(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.QueueWatches(%q)", job.ID)
+
+	links, err := w.Store.GetLinksDueForWatch(ctx, watchBatchSize)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, link := range links {
+		err := w.Queue.EnqueueUnique(ctx, JobWatch, JobWatch+":"+link.ID, &linkJob{link.ID})
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// Watch runs a JobWatch. Links that are no longer watched or due, such as
+// ones that were checked since the job was queued, are left alone.
+func (w *Watcher) Watch(ctx context.Context, job *model.Job) error {
+	op := errors.Opf("controller.Watch(%q)", job.ID)
+
+	p := new(linkJob)
+	if err := job.Decode(p); err != nil {
+		return errors.E(op, queue.Permanent(err))
+	}
+
+	link, err := w.Store.GetLinkByID(ctx, p.LinkID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	if !link.IsWatched || link.IsTrashed || (link.WatchAfter != nil && link.WatchAfter.After(time.Now())) {
+		return nil
+	}
+
+	if err := waitForHost(ctx, w.Limiter, link.URL); err != nil {
+		return err
+	}
+
+	if err := w.CheckPage(ctx, link); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// CheckPage fetches the text of a watched link's page, which is due to be
+// checked, and compares it with the text from the last time. The first time,
+// the text is only kept to compare with later. Pages that can't be fetched
+// are tried again later without counting as a change.
+func (w *Watcher) CheckPage(ctx context.Context, link *model.Link) error {
+	op := errors.Opf("controller.CheckPage(%q)", link.ID)
+
+	watchAfter := link.WatchAfter
+
+	now := time.Now()
+
+	text, err := w.Extractor.ReadableText(ctx, link.URL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.E(op, ctx.Err())
+		}
+
+		next := now.Add(watchRetryInterval)
+		link.WatchAfter = &next
+
+		if _, err := w.Store.UpdateLinkWatch(ctx, link, watchAfter); err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	}
+
+	var change *model.LinkChange
+	if link.WatchedText != "" {
+		change = model.NewLinkChange(link, link.WatchedText, text)
+	}
+
+	next := now.Add(watchInterval)
+	link.WatchAfter = &next
+	link.WatchedText = text
+
+	if change != nil {
+		link.IsChanged = true
+		link.LastChangedAt = &change.DetectedAt
+	}
+
+	saved, err := w.Store.UpdateLinkWatch(ctx, link, watchAfter)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if !saved || change == nil {
+		// The link was changed, unwatched or deleted while its page was
+		// being fetched, or its page didn't change.
+		return nil
+	}
+
+	if err := w.ChangeStore.CreateLinkChange(ctx, change); err != nil {
+		return errors.E(op, err)
+	}
+
+	if w.Notifier == nil {
+		return nil
+	}
+
+	u, err := w.UserStore.GetUserByID(ctx, link.UserID)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := w.Notifier.SendLinkChanged(ctx, u, link, change); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
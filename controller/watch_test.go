@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/ratelimit"
+)
+
+type mockWatchStore struct {
+	updated    *model.Link
+	watchAfter *time.Time
+	matched    bool
+}
+
+func (m *mockWatchStore) GetLinkByID(context.Context, string) (*model.Link, error) {
+	return nil, errors.Str("not implemented")
+}
+func (m *mockWatchStore) GetLinksDueForWatch(context.Context, int) ([]*model.Link, error) {
+	return nil, errors.Str("not implemented")
+}
+func (m *mockWatchStore) UpdateLinkWatch(_ context.Context, l *model.Link, watchAfter *time.Time) (bool, error) {
+	m.updated = l
+	m.watchAfter = watchAfter
+	return m.matched, nil
+}
+
+type mockChangeStore struct {
+	changes []*model.LinkChange
+}
+
+func (m *mockChangeStore) CreateLinkChange(_ context.Context, c *model.LinkChange) error {
+	m.changes = append(m.changes, c)
+	return nil
+}
+
+type mockWatchUserStore struct{}
+
+func (m *mockWatchUserStore) GetUserByID(_ context.Context, id string) (*model.User, error) {
+	return &model.User{ID: id}, nil
+}
+
+type mockExtractor struct {
+	text string
+	err  error
+}
+
+func (m *mockExtractor) ReadableText(context.Context, string) (string, error) {
+	return m.text, m.err
+}
+
+type mockNotifier struct {
+	sent []*model.LinkChange
+}
+
+func (m *mockNotifier) SendLinkChanged(_ context.Context, _ *model.User, _ *model.Link, c *model.LinkChange) error {
+	m.sent = append(m.sent, c)
+	return nil
+}
+
+func TestCheckPage(t *testing.T) {
+	tests := []struct {
+		Name          string
+		GivenWatched  string
+		GivenText     string
+		GivenErr      error
+		GivenMatched  bool
+		ExpectText    string
+		ExpectChanged bool
+		ExpectChanges int
+		ExpectNextIn  time.Duration
+	}{
+		{
+			Name:         "first check",
+			GivenText:    "Pricing\nFree",
+			GivenMatched: true,
+			ExpectText:   "Pricing\nFree",
+			ExpectNextIn: watchInterval,
+		},
+		{
+			Name:         "unchanged",
+			GivenWatched: "Pricing\nFree",
+			GivenText:    "Pricing\nFree",
+			GivenMatched: true,
+			ExpectText:   "Pricing\nFree",
+			ExpectNextIn: watchInterval,
+		},
+		{
+			Name:          "changed",
+			GivenWatched:  "Pricing\nFree",
+			GivenText:     "Pricing\n$5 a month",
+			GivenMatched:  true,
+			ExpectText:    "Pricing\n$5 a month",
+			ExpectChanged: true,
+			ExpectChanges: 1,
+			ExpectNextIn:  watchInterval,
+		},
+		{
+			Name:          "changed while being checked",
+			GivenWatched:  "Pricing\nFree",
+			GivenText:     "Pricing\n$5 a month",
+			ExpectText:    "Pricing\n$5 a month",
+			ExpectChanged: true,
+			ExpectNextIn:  watchInterval,
+		},
+		{
+			Name:         "page can't be fetched",
+			GivenWatched: "Pricing\nFree",
+			GivenErr:     errors.Str("timeout"),
+			GivenMatched: true,
+			ExpectText:   "Pricing\nFree",
+			ExpectNextIn: watchRetryInterval,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			store := &mockWatchStore{matched: tcase.GivenMatched}
+			changes := &mockChangeStore{}
+			notifier := &mockNotifier{}
+			w := &Watcher{
+				Store:       store,
+				ChangeStore: changes,
+				UserStore:   &mockWatchUserStore{},
+				Extractor:   &mockExtractor{text: tcase.GivenText, err: tcase.GivenErr},
+				Notifier:    notifier,
+				Limiter:     ratelimit.New(time.Millisecond),
+			}
+
+			due := time.Now().Add(-time.Hour)
+			link := &model.Link{
+				ID:          "1",
+				URL:         "https://example.com/pricing",
+				IsWatched:   true,
+				WatchedText: tcase.GivenWatched,
+				WatchAfter:  &due,
+			}
+
+			start := time.Now()
+
+			if err := w.CheckPage(context.Background(), link); err != nil {
+				t.Fatal(err)
+			}
+
+			if store.updated == nil {
+				t.Fatal("expected the link to be saved")
+			}
+
+			if store.watchAfter == nil || !store.watchAfter.Equal(due) {
+				t.Errorf("expected the save to be conditional on the link still being due, got %v", store.watchAfter)
+			}
+
+			if store.updated.WatchedText != tcase.ExpectText {
+				t.Errorf("got watched text %q, want %q", store.updated.WatchedText, tcase.ExpectText)
+			}
+
+			if store.updated.IsChanged != tcase.ExpectChanged {
+				t.Errorf("got isChanged %v, want %v", store.updated.IsChanged, tcase.ExpectChanged)
+			}
+
+			if len(changes.changes) != tcase.ExpectChanges || len(notifier.sent) != tcase.ExpectChanges {
+				t.Errorf("got %d changes and %d notifications, want %d",
+					len(changes.changes), len(notifier.sent), tcase.ExpectChanges)
+			}
+
+			next := store.updated.WatchAfter.Sub(start)
+			if next < tcase.ExpectNextIn || next > tcase.ExpectNextIn+time.Minute {
+				t.Errorf("got next check in %v, want %v", next, tcase.ExpectNextIn)
+			}
+		})
+	}
+}
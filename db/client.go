@@ -8,9 +8,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/linksort/linksort/canonical"
 	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
 )
 
+const canonicalMigrationBatchSize = 500
+
+// linksTextIndex is the name of the text index on links. A collection can
+// only have one, so it's named to be able to replace it when the fields it
+// covers change.
+const linksTextIndex = "links_text"
+
 func NewMongoClient(
 	ctx context.Context,
 	uri string,
@@ -56,20 +65,75 @@ func SetupIndexes(ctx context.Context, client *mongo.Client) error {
 				SetUnique(true).
 				SetSparse(true),
 		},
+		{
+			// Users without feeds, whose feeds are an empty list, are left
+			// out so that they don't all have the same key.
+			Keys: bson.D{primitive.E{Key: "feeds.token", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"feeds.token": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "digest.nextsendat", Value: 1}},
+			Options: options.Index().
+				SetPartialFilterExpression(bson.M{"digest.isenabled": true}),
+		},
 	})
 	if err != nil {
 		return errors.Wrap(op, err)
 	}
 
+	if err := migrateLinksForTrash(ctx, client); err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	if err := migrateLinksForEnrichment(ctx, client); err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	if err := migrateLinksForCanonicalURLs(ctx, client); err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	if err := migrateLinksTextIndex(ctx, client); err != nil {
+		return errors.Wrap(op, err)
+	}
+
 	_, err = client.Database("test").
 		Collection("links").
 		Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
+			// Only links that aren't in the trash need unique URLs, so that a
+			// trashed URL can be saved again.
 			Keys: bson.D{
 				primitive.E{Key: "userid", Value: 1},
 				primitive.E{Key: "url", Value: 1},
 			},
-			Options: options.Index().SetUnique(true),
+			Options: options.Index().
+				SetName("userid_1_url_1_untrashed").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"istrashed": false}),
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "userid", Value: 1},
+				primitive.E{Key: "istrashed", Value: 1},
+				primitive.E{Key: "createdat", Value: -1},
+			},
+		},
+		{
+			// Not unique, since links saved before URLs were canonicalized
+			// can share a canonical URL until they are merged.
+			Keys: bson.D{
+				primitive.E{Key: "userid", Value: 1},
+				primitive.E{Key: "canonicalurl", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "istrashed", Value: 1},
+				primitive.E{Key: "trashedat", Value: 1},
+			},
 		},
 		{
 			Keys: bson.D{
@@ -77,7 +141,10 @@ func SetupIndexes(ctx context.Context, client *mongo.Client) error {
 				primitive.E{Key: "title", Value: "text"},
 				primitive.E{Key: "description", Value: "text"},
 				primitive.E{Key: "site", Value: "text"},
+				primitive.E{Key: "highlights.quote", Value: "text"},
+				primitive.E{Key: "highlights.note", Value: "text"},
 			},
+			Options: options.Index().SetName(linksTextIndex),
 		},
 		{
 			Keys: bson.D{primitive.E{Key: "isfavorite", Value: 1}},
@@ -112,6 +179,253 @@ func SetupIndexes(ctx context.Context, client *mongo.Client) error {
 			},
 		},
 	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("linkchanges").
+		Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "linkid", Value: 1},
+				primitive.E{Key: "detectedat", Value: -1},
+				primitive.E{Key: "_id", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "userid", Value: 1}},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("snapshots").
+		Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "linkid", Value: 1},
+				primitive.E{Key: "fetchedat", Value: -1},
+				primitive.E{Key: "_id", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "userid", Value: 1}},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("revisions").
+		Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "linkid", Value: 1},
+				primitive.E{Key: "createdat", Value: -1},
+				primitive.E{Key: "_id", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "userid", Value: 1}},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("links").
+		Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "needsenrichment", Value: 1},
+			primitive.E{Key: "_id", Value: 1},
+		},
+		Options: options.Index().
+			SetPartialFilterExpression(bson.M{"needsenrichment": true}),
+	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("links").
+		Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "istrashed", Value: 1},
+				primitive.E{Key: "healthcheckafter", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "watchafter", Value: 1}},
+			Options: options.Index().
+				SetPartialFilterExpression(bson.M{"iswatched": true}),
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "userid", Value: 1},
+				primitive.E{Key: "health.status", Value: 1},
+			},
+			Options: options.Index().
+				SetPartialFilterExpression(bson.M{"health.status": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("jobs").
+		Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "status", Value: 1},
+				primitive.E{Key: "runat", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "status", Value: 1},
+				primitive.E{Key: "leaseuntil", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{primitive.E{Key: "uniquekey", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"uniquekey": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(op, err)
+	}
+
+	_, err = client.Database("test").
+		Collection("imports").
+		Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "userid", Value: 1}},
+	})
 
 	return errors.Wrap(op, err)
 }
+
+// migrateLinksForTrash sets istrashed on links saved before the trash existed
+// and drops the old unique index on userid and url, which covered trashed
+// links too.
+func migrateLinksForTrash(ctx context.Context, client *mongo.Client) error {
+	op := errors.Op("db.migrateLinksForTrash()")
+	col := client.Database("test").Collection("links")
+
+	_, err := col.UpdateMany(ctx,
+		bson.M{"istrashed": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"istrashed": false}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	_, err = col.Indexes().DropOne(ctx, "userid_1_url_1")
+	if err != nil {
+		var ce mongo.CommandError
+		// The index is already gone, or the collection doesn't exist yet.
+		if errors.As(err, &ce) && (ce.Code == 27 || ce.Code == 26) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// migrateLinksTextIndex drops the text index on links that was made before it
+// covered highlights, so that it can be made again.
+func migrateLinksTextIndex(ctx context.Context, client *mongo.Client) error {
+	op := errors.Op("db.migrateLinksTextIndex()")
+	col := client.Database("test").Collection("links")
+
+	_, err := col.Indexes().DropOne(ctx, "corpus_text_title_text_description_text_site_text")
+	if err != nil {
+		var ce mongo.CommandError
+		// The index is already gone, or the collection doesn't exist yet.
+		if errors.As(err, &ce) && (ce.Code == 27 || ce.Code == 26) {
+			return nil
+		}
+
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// migrateLinksForEnrichment sets needsenrichment on links saved before links
+// were enriched in the background. Those without a favicon were saved without
+// fetching their page, so they are queued for enrichment.
+func migrateLinksForEnrichment(ctx context.Context, client *mongo.Client) error {
+	op := errors.Op("db.migrateLinksForEnrichment()")
+	col := client.Database("test").Collection("links")
+
+	_, err := col.UpdateMany(ctx,
+		bson.M{"needsenrichment": bson.M{"$exists": false}, "favicon": ""},
+		bson.M{"$set": bson.M{"needsenrichment": true}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	_, err = col.UpdateMany(ctx,
+		bson.M{"needsenrichment": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"needsenrichment": false}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// migrateLinksForCanonicalURLs sets canonicalurl on links saved before URLs
+// were canonicalized. Links whose URL can't be canonicalized get their URL as
+// it is.
+func migrateLinksForCanonicalURLs(ctx context.Context, client *mongo.Client) error {
+	op := errors.Op("db.migrateLinksForCanonicalURLs()")
+	col := client.Database("test").Collection("links")
+
+	for {
+		cur, err := col.Find(ctx,
+			bson.M{"canonicalurl": bson.M{"$exists": false}},
+			options.Find().
+				SetProjection(bson.M{"_id": 1, "url": 1}).
+				SetLimit(canonicalMigrationBatchSize))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		var links []*model.Link
+		if err := cur.All(ctx, &links); err != nil {
+			return errors.E(op, err)
+		}
+
+		if len(links) == 0 {
+			return nil
+		}
+
+		models := make([]mongo.WriteModel, len(links))
+
+		for i, l := range links {
+			curl, err := canonical.URL(l.URL)
+			if err != nil {
+				curl = l.URL
+			}
+
+			models[i] = mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": l.Key}).
+				SetUpdate(bson.M{"$set": bson.M{"canonicalurl": curl}})
+		}
+
+		if _, err := col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return errors.E(op, err)
+		}
+	}
+}
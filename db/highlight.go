@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+// CreateHighlight adds the highlight to the link. Highlights are kept on their
+// links, so that they are searched and listed along with them. They are
+// changed in place rather than by replacing the link, so that they aren't
+// lost to a background job that is updating the link at the same time.
+func (s *LinkStore) CreateHighlight(ctx context.Context, l *model.Link, h *model.Highlight) error {
+	op := errors.Opf("LinkStore.CreateHighlight(%q)", l.ID)
+
+	res, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": l.Key},
+		bson.M{
+			"$push": bson.M{"highlights": h},
+			"$set":  bson.M{"updatedat": time.Now()},
+		})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("no documents"), http.StatusNotFound)
+	}
+
+	l.Highlights = append(l.Highlights, h)
+
+	return nil
+}
+
+func (s *LinkStore) UpdateHighlight(ctx context.Context, l *model.Link, h *model.Highlight) error {
+	op := errors.Opf("LinkStore.UpdateHighlight(%q, %q)", l.ID, h.ID)
+
+	h.UpdatedAt = time.Now()
+
+	res, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": l.Key, "highlights.id": h.ID},
+		bson.M{"$set": bson.M{"highlights.$": h, "updatedat": h.UpdatedAt}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("no documents"), http.StatusNotFound)
+	}
+
+	return nil
+}
+
+func (s *LinkStore) DeleteHighlight(ctx context.Context, l *model.Link, id string) error {
+	op := errors.Opf("LinkStore.DeleteHighlight(%q, %q)", l.ID, id)
+
+	res, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": l.Key, "highlights.id": id},
+		bson.M{
+			"$pull": bson.M{"highlights": bson.M{"id": id}},
+			"$set":  bson.M{"updatedat": time.Now()},
+		})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("no documents"), http.StatusNotFound)
+	}
+
+	kept := l.Highlights[:0]
+	for _, h := range l.Highlights {
+		if h.ID != id {
+			kept = append(kept, h)
+		}
+	}
+
+	l.Highlights = kept
+
+	return nil
+}
+
+// GetHighlightsByUser returns the highlights on the user's links that aren't
+// in the trash, newest first, each with the link it was made on.
+func (s *LinkStore) GetHighlightsByUser(
+	ctx context.Context,
+	u *model.User,
+	p *model.Pagination,
+) ([]*model.LinkHighlight, error) {
+	op := errors.Opf("LinkStore.GetHighlightsByUser(u=%s)", u.Email)
+
+	cur, err := s.col.Aggregate(ctx, mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M{
+			"userid":       u.ID,
+			"istrashed":    false,
+			"highlights.0": bson.M{"$exists": true},
+		}}},
+		{primitive.E{Key: "$unwind", Value: "$highlights"}},
+		{primitive.E{Key: "$sort", Value: bson.D{
+			primitive.E{Key: "highlights.createdat", Value: -1},
+			primitive.E{Key: "highlights.id", Value: -1},
+		}}},
+		{primitive.E{Key: "$skip", Value: int64(p.Offset())}},
+		{primitive.E{Key: "$limit", Value: int64(p.Limit())}},
+		{primitive.E{Key: "$project", Value: bson.M{
+			"_id":       1,
+			"userid":    1,
+			"createdat": 1,
+			"folderid":  1,
+			"url":       1,
+			"title":     1,
+			"favicon":   1,
+			"site":      1,
+			"highlight": "$highlights",
+		}}},
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var docs []*struct {
+		model.Link `bson:",inline"`
+		Highlight  *model.Highlight `bson:"highlight"`
+	}
+
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	highlights := make([]*model.LinkHighlight, len(docs))
+	for i, d := range docs {
+		link := &d.Link
+		link.ID = link.Key.Hex()
+
+		highlights[i] = &model.LinkHighlight{Highlight: d.Highlight, Link: link}
+	}
+
+	return highlights, nil
+}
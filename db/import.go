@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+// ImportJobStore keeps import jobs in a collection and the files that were
+// uploaded for them in GridFS, since they can be larger than a document.
+type ImportJobStore struct {
+	client *mongo.Client
+	col    *mongo.Collection
+}
+
+func NewImportJobStore(client *mongo.Client) *ImportJobStore {
+	return &ImportJobStore{col: client.Database("test").Collection("imports"), client: client}
+}
+
+func (s *ImportJobStore) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(s.client.Database("test"), options.GridFSBucket().SetName("importfiles"))
+}
+
+func (s *ImportJobStore) CreateImportJob(
+	ctx context.Context,
+	j *model.ImportJob,
+	file io.Reader,
+) (*model.ImportJob, error) {
+	op := errors.Op("ImportJobStore.CreateImportJob")
+
+	bucket, err := s.bucket()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	j.FileID, err = bucket.UploadFromStream(j.Format, file)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	res, err := s.col.InsertOne(ctx, j)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	j.Key = res.InsertedID.(primitive.ObjectID)
+	j.ID = j.Key.Hex()
+
+	return j, nil
+}
+
+func (s *ImportJobStore) GetImportJobByID(ctx context.Context, id string) (*model.ImportJob, error) {
+	op := errors.Opf("ImportJobStore.GetImportJobByID(id=%s)", id)
+
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusNotFound)
+	}
+
+	j := new(model.ImportJob)
+
+	err = s.col.FindOne(ctx, bson.M{"_id": docID}).Decode(j)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.E(op, err, errors.Str("no documents"), http.StatusNotFound)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	j.ID = id
+	j.Key = docID
+
+	return j, nil
+}
+
+func (s *ImportJobStore) UpdateImportJob(ctx context.Context, j *model.ImportJob) (*model.ImportJob, error) {
+	op := errors.Opf("ImportJobStore.UpdateImportJob(%q)", j.ID)
+
+	j.UpdatedAt = time.Now()
+
+	res, err := s.col.ReplaceOne(ctx, bson.M{"_id": j.Key}, j)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return nil, errors.E(op, errors.Str("no document match"))
+	}
+
+	return j, nil
+}
+
+func (s *ImportJobStore) OpenImportFile(ctx context.Context, j *model.ImportJob) (io.ReadCloser, error) {
+	op := errors.Opf("ImportJobStore.OpenImportFile(%q)", j.ID)
+
+	bucket, err := s.bucket()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	stream, err := bucket.OpenDownloadStream(j.FileID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return stream, nil
+}
+
+func (s *ImportJobStore) DeleteImportFile(ctx context.Context, j *model.ImportJob) error {
+	op := errors.Opf("ImportJobStore.DeleteImportFile(%q)", j.ID)
+
+	bucket, err := s.bucket()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := bucket.Delete(j.FileID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *ImportJobStore) DeleteAllImportJobsByUser(ctx context.Context, u *model.User) error {
+	op := errors.Opf("ImportJobStore.DeleteAllImportJobsByUser(%q)", u.Email)
+
+	cur, err := s.col.Find(ctx, bson.M{"userid": u.ID})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	jobs := make([]*model.ImportJob, cur.RemainingBatchLength())
+	if err = cur.All(ctx, &jobs); err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, j := range jobs {
+		j.ID = j.Key.Hex()
+		if err := s.DeleteImportFile(ctx, j); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if _, err = s.col.DeleteMany(ctx, bson.M{"userid": u.ID}); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+type JobStore struct {
+	client *mongo.Client
+	col    *mongo.Collection
+}
+
+func NewJobStore(client *mongo.Client) *JobStore {
+	return &JobStore{col: client.Database("test").Collection("jobs"), client: client}
+}
+
+func (s *JobStore) CreateJob(ctx context.Context, j *model.Job) (*model.Job, error) {
+	op := errors.Opf("JobStore.CreateJob(%s)", j.Kind)
+
+	res, err := s.col.InsertOne(ctx, j)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, errors.E(op, err, errors.Str("duplicate job key"), http.StatusConflict)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	j.Key = res.InsertedID.(primitive.ObjectID)
+	j.ID = j.Key.Hex()
+
+	return j, nil
+}
+
+// ClaimJob returns a queued job that is due, or a running job whose lease has
+// run out and that has attempts left, and leases it. Its attempts are counted
+// up, which also fences off the worker that held it before. It returns a 404
+// error when there is no such job.
+func (s *JobStore) ClaimJob(ctx context.Context, kinds []string, lease time.Duration) (*model.Job, error) {
+	op := errors.Op("JobStore.ClaimJob")
+
+	now := time.Now()
+	j := new(model.Job)
+
+	err := s.col.FindOneAndUpdate(ctx,
+		bson.M{
+			"kind": bson.M{"$in": kinds},
+			"$or": bson.A{
+				bson.M{"status": model.JobStatusQueued, "runat": bson.M{"$lte": now}},
+				bson.M{
+					"status":     model.JobStatusRunning,
+					"leaseuntil": bson.M{"$lte": now},
+					"$expr":      bson.M{"$lt": bson.A{"$attempts", "$maxattempts"}},
+				},
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"status":     model.JobStatusRunning,
+				"leaseuntil": now.Add(lease),
+				"updatedat":  now,
+			},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.M{"runat": 1}).
+			SetReturnDocument(options.After),
+	).Decode(j)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.E(op, err, errors.Str("no documents"), http.StatusNotFound)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	j.ID = j.Key.Hex()
+
+	return j, nil
+}
+
+// DeadLetterExpiredJobs marks the running jobs whose lease ran out on their
+// last attempt as dead, since ClaimJob no longer picks them up. Like UpdateJob,
+// it frees their unique keys.
+func (s *JobStore) DeadLetterExpiredJobs(ctx context.Context, kinds []string) ([]*model.Job, error) {
+	op := errors.Op("JobStore.DeadLetterExpiredJobs")
+
+	now := time.Now()
+
+	cur, err := s.col.Find(ctx, bson.M{
+		"kind":       bson.M{"$in": kinds},
+		"status":     model.JobStatusRunning,
+		"leaseuntil": bson.M{"$lte": now},
+		"$expr":      bson.M{"$gte": bson.A{"$attempts", "$maxattempts"}},
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	expired := make([]*model.Job, 0)
+	if err := cur.All(ctx, &expired); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	dead := make([]*model.Job, 0, len(expired))
+
+	for _, j := range expired {
+		j.ID = j.Key.Hex()
+		j.Status = model.JobStatusDead
+		j.LeaseUntil = nil
+		j.LastError = "lease ran out on the last attempt"
+		j.UniqueKey = ""
+		j.UpdatedAt = now
+
+		// The lease is checked again, in case the worker renewed it meanwhile.
+		filter := leased(j)
+		filter["leaseuntil"] = bson.M{"$lte": now}
+
+		res, err := s.col.UpdateOne(ctx, filter, bson.M{
+			"$set": bson.M{
+				"status":    j.Status,
+				"lasterror": j.LastError,
+				"updatedat": j.UpdatedAt,
+			},
+			"$unset": bson.M{"leaseuntil": "", "uniquekey": ""},
+		})
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if res.MatchedCount > 0 {
+			dead = append(dead, j)
+		}
+	}
+
+	return dead, nil
+}
+
+func (s *JobStore) ExtendJobLease(ctx context.Context, j *model.Job, lease time.Duration) error {
+	op := errors.Opf("JobStore.ExtendJobLease(%q)", j.ID)
+
+	until := time.Now().Add(lease)
+
+	res, err := s.col.UpdateOne(ctx, leased(j), bson.M{"$set": bson.M{"leaseuntil": until}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("lease lost"))
+	}
+
+	return nil
+}
+
+func (s *JobStore) CompleteJob(ctx context.Context, j *model.Job) error {
+	op := errors.Opf("JobStore.CompleteJob(%q)", j.ID)
+
+	res, err := s.col.DeleteOne(ctx, leased(j))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.DeletedCount < 1 {
+		return errors.E(op, errors.Str("lease lost"))
+	}
+
+	return nil
+}
+
+func (s *JobStore) UpdateJob(ctx context.Context, j *model.Job) error {
+	op := errors.Opf("JobStore.UpdateJob(%q)", j.ID)
+
+	j.UpdatedAt = time.Now()
+
+	update := bson.M{"$set": bson.M{
+		"status":      j.Status,
+		"runat":       j.RunAt,
+		"maxattempts": j.MaxAttempts,
+		"leaseuntil":  j.LeaseUntil,
+		"lasterror":   j.LastError,
+		"updatedat":   j.UpdatedAt,
+	}}
+
+	// Dead jobs give up their key so that the work can be queued again.
+	if j.Status == model.JobStatusDead {
+		j.UniqueKey = ""
+		update["$unset"] = bson.M{"uniquekey": ""}
+	}
+
+	res, err := s.col.UpdateOne(ctx, leased(j), update)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("lease lost"))
+	}
+
+	return nil
+}
+
+// leased matches the job only while it is still held with the lease the
+// caller claimed it with.
+func leased(j *model.Job) bson.M {
+	return bson.M{
+		"_id":      j.Key,
+		"status":   model.JobStatusRunning,
+		"attempts": j.Attempts,
+	}
+}
+
+// isDuplicateKey reports whether err was caused by a unique index.
+func isDuplicateKey(err error) bool {
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) {
+		for _, e := range bwe.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
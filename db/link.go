@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"net/http"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 
 	"github.com/linksort/linksort/errors"
 	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/search"
 )
 
 type LinkStore struct {
@@ -49,6 +51,38 @@ func (s *LinkStore) GetLinkByID(ctx context.Context, id string) (*model.Link, er
 	return n, nil
 }
 
+// listProjection leaves out the large fields of links, which aren't needed
+// when links are listed.
+var listProjection = bson.D{
+	primitive.E{Key: "_id", Value: 1},
+	primitive.E{Key: "id", Value: 1},
+	primitive.E{Key: "userid", Value: 1},
+	primitive.E{Key: "createdat", Value: 1},
+	primitive.E{Key: "updatedat", Value: 1},
+	primitive.E{Key: "tagpaths", Value: 1},
+	primitive.E{Key: "tagdetails", Value: 1},
+	primitive.E{Key: "usertags", Value: 1},
+	primitive.E{Key: "isfavorite", Value: 1},
+	primitive.E{Key: "folderid", Value: 1},
+	primitive.E{Key: "url", Value: 1},
+	primitive.E{Key: "canonicalurl", Value: 1},
+	primitive.E{Key: "title", Value: 1},
+	primitive.E{Key: "description", Value: 1},
+	primitive.E{Key: "favicon", Value: 1},
+	primitive.E{Key: "image", Value: 1},
+	primitive.E{Key: "site", Value: 1},
+	primitive.E{Key: "istrashed", Value: 1},
+	primitive.E{Key: "trashedat", Value: 1},
+	primitive.E{Key: "highlights", Value: 1},
+	primitive.E{Key: "isread", Value: 1},
+	primitive.E{Key: "readprogress", Value: 1},
+	primitive.E{Key: "lastopenedat", Value: 1},
+	primitive.E{Key: "health", Value: 1},
+	primitive.E{Key: "iswatched", Value: 1},
+	primitive.E{Key: "ischanged", Value: 1},
+	primitive.E{Key: "lastchangedat", Value: 1},
+}
+
 func (s *LinkStore) GetLinksByUser(
 	ctx context.Context,
 	u *model.User,
@@ -57,55 +91,71 @@ func (s *LinkStore) GetLinksByUser(
 ) ([]*model.Link, error) {
 	op := errors.Opf("LinkStore.GetLinksByUser(u=%s)", u.Email)
 
-	m := map[string]interface{}{"userid": u.ID}
+	m := userLinksFilter(u, opts)
+	// The projection is copied since searches add the relevance score to it.
+	projection := append(bson.D{}, listProjection...)
 
-	for _, f := range opts {
-		f(m)
+	direction := int64(-1)
+	if val, ok := m["sort"]; ok {
+		direction = val.(int64)
+		delete(m, "sort")
 	}
 
-	projection := bson.D{
-		primitive.E{Key: "_id", Value: 1},
-		primitive.E{Key: "id", Value: 1},
-		primitive.E{Key: "userid", Value: 1},
-		primitive.E{Key: "createdat", Value: 1},
-		primitive.E{Key: "updatedat", Value: 1},
-		primitive.E{Key: "tagpaths", Value: 1},
-		primitive.E{Key: "tagdetails", Value: 1},
-		primitive.E{Key: "usertags", Value: 1},
-		primitive.E{Key: "isfavorite", Value: 1},
-		primitive.E{Key: "folderid", Value: 1},
-		primitive.E{Key: "url", Value: 1},
-		primitive.E{Key: "title", Value: 1},
-		primitive.E{Key: "description", Value: 1},
-		primitive.E{Key: "favicon", Value: 1},
-		primitive.E{Key: "image", Value: 1},
-		primitive.E{Key: "site", Value: 1},
-	}
-
-	var sort bson.M
-	if _, ok := m["$text"]; ok {
-		// if we're searching...
-		sort = bson.M{"score": bson.M{"$meta": "textScore"}}
-		projection = append(projection, primitive.E{
-			Key: "score",
-			Value: primitive.E{
-				Key:   "$meta",
-				Value: " textScore",
-			},
-		})
-		delete(m, "sort")
-	} else if val, ok := m["sort"]; ok {
-		sort = bson.M{"createdat": val.(int64)}
-		delete(m, "sort")
+	similarTo, isSimilar := m["similar"].([]float32)
+	delete(m, "similar")
+
+	var sort bson.D
+	_, isSearch := m["$text"]
+	if isSearch {
+		// Search results are ordered by relevance, regardless of the requested sort.
+		sort = bson.D{
+			primitive.E{Key: "score", Value: bson.M{"$meta": "textScore"}},
+			primitive.E{Key: "_id", Value: -1},
+		}
 	} else {
-		sort = bson.M{"createdat": -1}
+		// The _id breaks ties between links created at the same moment so that
+		// the order is stable for cursors.
+		sort = bson.D{
+			primitive.E{Key: "createdat", Value: direction},
+			primitive.E{Key: "_id", Value: direction},
+		}
 	}
 
-	cur, err := s.col.Find(ctx, bson.M(m), options.Find().
-		SetLimit(int64(p.Limit())).
-		SetSkip(int64(p.Offset())).
-		SetProjection(projection).
-		SetSort(sort))
+	var cur *mongo.Cursor
+	var err error
+
+	switch {
+	case isSimilar:
+		cur, err = s.getSimilarLinks(ctx, m, p, projection, similarTo)
+	case p.Cursor != nil && isSearch:
+		cur, err = s.searchAfter(ctx, m, p, projection)
+	case p.Cursor != nil:
+		var after bson.M
+		after, err = afterCursor(p.Cursor, direction)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		and(m, after)
+
+		cur, err = s.col.Find(ctx, bson.M(m), options.Find().
+			SetLimit(int64(p.Limit())).
+			SetProjection(projection).
+			SetSort(sort))
+	default:
+		if isSearch {
+			projection = append(projection, primitive.E{
+				Key:   "score",
+				Value: bson.M{"$meta": "textScore"},
+			})
+		}
+
+		cur, err = s.col.Find(ctx, bson.M(m), options.Find().
+			SetLimit(int64(p.Limit())).
+			SetSkip(int64(p.Offset())).
+			SetProjection(projection).
+			SetSort(sort))
+	}
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
@@ -124,15 +174,289 @@ func (s *LinkStore) GetLinksByUser(
 	return links, nil
 }
 
+// userLinksFilter returns the filter for the user's links with the options
+// applied.
+func userLinksFilter(u *model.User, opts []model.GetLinksOption) map[string]interface{} {
+	// Trashed links are left out unless they're asked for with GetLinksTrashed.
+	m := map[string]interface{}{"userid": u.ID, "istrashed": false}
+
+	for _, f := range opts {
+		f(m)
+	}
+
+	return m
+}
+
+// facetPipelines holds, for each facet, the stages that group the links by
+// the facet's value.
+var facetPipelines = map[string]bson.A{
+	model.FacetSite: {
+		bson.M{"$group": bson.M{
+			"_id": bson.M{"$let": bson.M{
+				"vars": bson.M{"m": bson.M{"$regexFind": bson.M{
+					"input":   "$url",
+					"regex":   `^[a-z]+://(?:www\.)?([^/:?#]+)`,
+					"options": "i",
+				}}},
+				"in": bson.M{"$toLower": bson.M{"$arrayElemAt": bson.A{"$$m.captures", 0}}},
+			}},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$match": bson.M{"_id": bson.M{"$nin": bson.A{nil, ""}}}},
+	},
+	model.FacetTag: {
+		bson.M{"$unwind": "$tagpaths"},
+		bson.M{"$group": bson.M{"_id": "$tagpaths", "count": bson.M{"$sum": 1}}},
+	},
+	model.FacetUserTag: {
+		bson.M{"$unwind": "$usertags"},
+		bson.M{"$group": bson.M{"_id": "$usertags", "count": bson.M{"$sum": 1}}},
+	},
+	model.FacetFolder: {
+		bson.M{"$group": bson.M{
+			"_id": bson.M{"$let": bson.M{
+				"vars": bson.M{"f": bson.M{"$ifNull": bson.A{"$folderid", ""}}},
+				"in":   bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$$f", ""}}, "root", "$$f"}},
+			}},
+			"count": bson.M{"$sum": 1},
+		}},
+	},
+	model.FacetFavorite: {
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$cond": bson.A{"$isfavorite", "true", "false"}},
+			"count": bson.M{"$sum": 1},
+		}},
+	},
+	model.FacetAnnotated: {
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$cond": bson.A{"$isannotated", "true", "false"}},
+			"count": bson.M{"$sum": 1},
+		}},
+	},
+}
+
+// GetLinkFacets counts the user's links that match the options, in total and
+// by each of the given facets, in a single aggregation.
+func (s *LinkStore) GetLinkFacets(
+	ctx context.Context,
+	u *model.User,
+	facets []string,
+	opts ...model.GetLinksOption,
+) (*model.FacetCounts, error) {
+	op := errors.Opf("LinkStore.GetLinkFacets(u=%s)", u.Email)
+
+	m := userLinksFilter(u, opts)
+	delete(m, "sort")
+	delete(m, "similar")
+
+	stages := bson.M{"total": bson.A{bson.M{"$count": "count"}}}
+
+	for _, f := range facets {
+		pipeline, ok := facetPipelines[f]
+		if !ok {
+			return nil, errors.E(op, errors.Strf("unknown facet %q", f))
+		}
+
+		stages[f] = append(append(bson.A{}, pipeline...),
+			bson.M{"$sort": bson.D{
+				primitive.E{Key: "count", Value: -1},
+				primitive.E{Key: "_id", Value: 1},
+			}},
+			bson.M{"$limit": model.MaxFacetValues})
+	}
+
+	cur, err := s.col.Aggregate(ctx, mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M(m)}},
+		{primitive.E{Key: "$facet", Value: stages}},
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var res []map[string][]struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+
+	if err := cur.All(ctx, &res); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	counts := &model.FacetCounts{Counts: make(map[string][]*model.FacetCount, len(facets))}
+	if len(res) == 0 {
+		return counts, nil
+	}
+
+	for k, buckets := range res[0] {
+		if k == "total" {
+			if len(buckets) > 0 {
+				counts.Total = buckets[0].Count
+			}
+
+			continue
+		}
+
+		counts.Counts[k] = make([]*model.FacetCount, len(buckets))
+		for i, b := range buckets {
+			counts.Counts[k][i] = &model.FacetCount{Value: b.Value, Count: b.Count}
+		}
+	}
+
+	return counts, nil
+}
+
+// searchAfter pages through text search results with a cursor. The relevance
+// score can only be filtered on once it has been added to the documents, so
+// this is done with an aggregation instead of a find.
+func (s *LinkStore) searchAfter(
+	ctx context.Context,
+	m map[string]interface{},
+	p *model.Pagination,
+	projection bson.D,
+) (*mongo.Cursor, error) {
+	op := errors.Op("LinkStore.searchAfter")
+
+	after, err := afterScore(p.Cursor)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	cur, err := s.col.Aggregate(ctx, mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M(m)}},
+		{primitive.E{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		{primitive.E{Key: "$match", Value: after}},
+		{primitive.E{Key: "$sort", Value: bson.D{
+			primitive.E{Key: "score", Value: -1},
+			primitive.E{Key: "_id", Value: -1},
+		}}},
+		{primitive.E{Key: "$limit", Value: int64(p.Limit())}},
+		{primitive.E{Key: "$project", Value: append(projection, primitive.E{Key: "score", Value: 1})}},
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return cur, nil
+}
+
+// getSimilarLinks ranks the links that match the filter by how similar their
+// embeddings are to the vector. Embeddings have unit length, so their dot
+// product is their cosine similarity.
+func (s *LinkStore) getSimilarLinks(
+	ctx context.Context,
+	m map[string]interface{},
+	p *model.Pagination,
+	projection bson.D,
+	vector []float32,
+) (*mongo.Cursor, error) {
+	op := errors.Op("LinkStore.getSimilarLinks")
+
+	pipeline := mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M(m)}},
+		{primitive.E{Key: "$addFields", Value: bson.M{"score": dotProduct("$embedding.vector", vector)}}},
+	}
+
+	if p.Cursor != nil {
+		after, err := afterScore(p.Cursor)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: after}})
+	}
+
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$sort", Value: bson.D{
+		primitive.E{Key: "score", Value: -1},
+		primitive.E{Key: "_id", Value: -1},
+	}}})
+
+	if p.Cursor == nil {
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$skip", Value: int64(p.Offset())}})
+	}
+
+	pipeline = append(pipeline,
+		bson.D{primitive.E{Key: "$limit", Value: int64(p.Limit())}},
+		bson.D{primitive.E{Key: "$project", Value: append(projection, primitive.E{Key: "score", Value: 1})}})
+
+	cur, err := s.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return cur, nil
+}
+
+// afterScore returns a condition that matches the links that come after the
+// cursor when sorting by score, highest first.
+func afterScore(c *model.Cursor) (bson.M, error) {
+	op := errors.Op("db.afterScore")
+
+	key, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest, errors.M{"cursor": "This is not valid."})
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{"score": bson.M{"$lt": c.Score}},
+		bson.M{"score": c.Score, "_id": bson.M{"$lt": key}},
+	}}, nil
+}
+
+// afterCursor returns a condition that matches the links that come after the
+// cursor when sorting by creation date in the given direction.
+func afterCursor(c *model.Cursor, direction int64) (bson.M, error) {
+	op := errors.Op("db.afterCursor")
+
+	key, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest, errors.M{"cursor": "This is not valid."})
+	}
+
+	cmp := "$lt"
+	if direction > 0 {
+		cmp = "$gt"
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{"createdat": bson.M{cmp: c.CreatedAt}},
+		bson.M{"createdat": c.CreatedAt, "_id": bson.M{cmp: key}},
+	}}, nil
+}
+
+// and adds the given condition to the filter's top-level $and so that it
+// can't clobber conditions set by other options.
+func and(m map[string]interface{}, cond bson.M) {
+	conds, _ := m["$and"].(bson.A)
+	m["$and"] = append(conds, cond)
+}
+
+// GetAllLinksByUser returns a page of the user's links with all of their
+// fields, including the ones left out of lists. Without options, the links in
+// the trash are returned too. With options, the links are filtered the same
+// way GetLinksByUser filters them.
 func (s *LinkStore) GetAllLinksByUser(
 	ctx context.Context,
 	u *model.User,
 	p *model.Pagination,
+	opts ...model.GetLinksOption,
 ) ([]*model.Link, error) {
 	op := errors.Opf("LinkStore.GetAllLinksByUser(u=%s)", u.Email)
 
 	m := map[string]interface{}{"userid": u.ID}
-	sort := bson.M{"createdat": -1}
+	if len(opts) > 0 {
+		m = userLinksFilter(u, opts)
+	}
+
+	direction := int64(-1)
+	if val, ok := m["sort"]; ok {
+		direction = val.(int64)
+		delete(m, "sort")
+	}
+
+	sort := bson.D{
+		primitive.E{Key: "createdat", Value: direction},
+		primitive.E{Key: "_id", Value: direction},
+	}
 
 	cur, err := s.col.Find(ctx, bson.M(m), options.Find().
 		SetLimit(int64(p.Limit())).
@@ -154,22 +478,167 @@ func (s *LinkStore) GetAllLinksByUser(
 	return links, nil
 }
 
+func (s *LinkStore) GetLinksByIDs(
+	ctx context.Context,
+	u *model.User,
+	ids []string,
+) ([]*model.Link, error) {
+	op := errors.Opf("LinkStore.GetLinksByIDs(u=%s)", u.Email)
+
+	keys := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		key, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			// Invalid IDs can't match anything, so they're left out of the query.
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return []*model.Link{}, nil
+	}
+
+	cur, err := s.col.Find(ctx, bson.M{"userid": u.ID, "_id": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.Link, cur.RemainingBatchLength())
+	err = cur.All(ctx, &links)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for i := range links {
+		links[i].ID = links[i].Key.Hex()
+	}
+
+	return links, nil
+}
+
+// GetLinksByURLs returns the user's links that aren't in the trash and have
+// one of the given URLs, either as they were saved or in canonical form. Only
+// the IDs, URLs and canonical URLs of the links are populated.
+func (s *LinkStore) GetLinksByURLs(
+	ctx context.Context,
+	u *model.User,
+	urls []string,
+) ([]*model.Link, error) {
+	op := errors.Opf("LinkStore.GetLinksByURLs(u=%s)", u.Email)
+
+	if len(urls) == 0 {
+		return []*model.Link{}, nil
+	}
+
+	cur, err := s.col.Find(ctx,
+		bson.M{
+			"userid":    u.ID,
+			"istrashed": false,
+			"$or": bson.A{
+				bson.M{"url": bson.M{"$in": urls}},
+				bson.M{"canonicalurl": bson.M{"$in": urls}},
+			},
+		},
+		options.Find().SetProjection(bson.M{"_id": 1, "url": 1, "canonicalurl": 1}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.Link, cur.RemainingBatchLength())
+	err = cur.All(ctx, &links)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for i := range links {
+		links[i].ID = links[i].Key.Hex()
+	}
+
+	return links, nil
+}
+
+// GetDuplicateLinks returns the user's links that aren't in the trash and
+// share a canonical URL with another one, grouped by canonical URL.
+func (s *LinkStore) GetDuplicateLinks(ctx context.Context, u *model.User) ([]*model.DuplicateLinks, error) {
+	op := errors.Opf("LinkStore.GetDuplicateLinks(u=%s)", u.Email)
+
+	cur, err := s.col.Aggregate(ctx, mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M{
+			"userid":       u.ID,
+			"istrashed":    false,
+			"canonicalurl": bson.M{"$gt": ""},
+		}}},
+		{primitive.E{Key: "$group", Value: bson.M{
+			"_id":   "$canonicalurl",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{primitive.E{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{primitive.E{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var groups []struct {
+		CanonicalURL string               `bson:"_id"`
+		IDs          []primitive.ObjectID `bson:"ids"`
+	}
+
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	dupes := make([]*model.DuplicateLinks, 0, len(groups))
+	keys := make([]primitive.ObjectID, 0)
+	byURL := make(map[string]*model.DuplicateLinks, len(groups))
+
+	for _, g := range groups {
+		d := &model.DuplicateLinks{CanonicalURL: g.CanonicalURL, Links: make([]*model.Link, 0, len(g.IDs))}
+		dupes = append(dupes, d)
+		byURL[g.CanonicalURL] = d
+		keys = append(keys, g.IDs...)
+	}
+
+	if len(keys) == 0 {
+		return dupes, nil
+	}
+
+	cur, err = s.col.Find(ctx, bson.M{"_id": bson.M{"$in": keys}},
+		options.Find().
+			SetProjection(listProjection).
+			SetSort(bson.D{
+				primitive.E{Key: "createdat", Value: 1},
+				primitive.E{Key: "_id", Value: 1},
+			}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.Link, cur.RemainingBatchLength())
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for _, l := range links {
+		l.ID = l.Key.Hex()
+		if d, ok := byURL[l.CanonicalURL]; ok {
+			d.Links = append(d.Links, l)
+		}
+	}
+
+	return dupes, nil
+}
+
 func (s *LinkStore) CreateLink(ctx context.Context, l *model.Link) (*model.Link, error) {
 	op := errors.Op("LinkStore.CreateLink")
 
 	res, err := s.col.InsertOne(ctx, l)
 	if err != nil {
-		var e mongo.WriteException
-		if errors.As(err, &e) {
-			for _, we := range e.WriteErrors {
-				if we.Code == 11000 {
-					return nil, errors.E(
-						op,
-						errors.M{"url": "This link has already been saved."},
-						errors.Str("duplicate link URL"),
-						http.StatusBadRequest)
-				}
-			}
+		if isDuplicateKey(err) {
+			return nil, errDuplicateURL(op)
 		}
 
 		return nil, errors.E(op, err)
@@ -181,6 +650,32 @@ func (s *LinkStore) CreateLink(ctx context.Context, l *model.Link) (*model.Link,
 	return l, nil
 }
 
+func (s *LinkStore) CreateLinks(ctx context.Context, ll []*model.Link) error {
+	op := errors.Opf("LinkStore.CreateLinks(n=%d)", len(ll))
+
+	if len(ll) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(ll))
+	for i, l := range ll {
+		l.Key = primitive.NewObjectID()
+		l.ID = l.Key.Hex()
+		docs[i] = l
+	}
+
+	_, err := s.col.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	if err != nil {
+		if isDuplicateKey(err) {
+			return errDuplicateURL(op)
+		}
+
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
 func (s *LinkStore) UpdateLink(ctx context.Context, l *model.Link) (*model.Link, error) {
 	op := errors.Opf("LinkStore.UpdateLink(%q)", l.ID)
 
@@ -189,6 +684,10 @@ func (s *LinkStore) UpdateLink(ctx context.Context, l *model.Link) (*model.Link,
 
 	res, err := s.col.ReplaceOne(ctx, bson.M{"_id": l.Key}, l)
 	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, errDuplicateURL(op)
+		}
+
 		return nil, errors.E(op, err)
 	}
 
@@ -199,6 +698,38 @@ func (s *LinkStore) UpdateLink(ctx context.Context, l *model.Link) (*model.Link,
 	return l, nil
 }
 
+func (s *LinkStore) UpdateLinks(ctx context.Context, ll []*model.Link) error {
+	op := errors.Opf("LinkStore.UpdateLinks(n=%d)", len(ll))
+
+	if len(ll) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, len(ll))
+
+	for i, l := range ll {
+		l.UpdatedAt = now
+		l.IsAnnotated = len(l.Annotation) > 0
+		models[i] = mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": l.Key}).SetReplacement(l)
+	}
+
+	res, err := s.col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+	if err != nil {
+		if isDuplicateKey(err) {
+			return errDuplicateURL(op)
+		}
+
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < int64(len(ll)) {
+		return errors.E(op, errors.Strf("matched %d of %d documents", res.MatchedCount, len(ll)))
+	}
+
+	return nil
+}
+
 func (s *LinkStore) DeleteLink(ctx context.Context, l *model.Link) error {
 	op := errors.Opf("LinkStore.DeleteLink(%q)", l.ID)
 
@@ -214,6 +745,57 @@ func (s *LinkStore) DeleteLink(ctx context.Context, l *model.Link) error {
 	return nil
 }
 
+func (s *LinkStore) DeleteLinks(ctx context.Context, ll []*model.Link) error {
+	op := errors.Opf("LinkStore.DeleteLinks(n=%d)", len(ll))
+
+	if len(ll) == 0 {
+		return nil
+	}
+
+	keys := make([]primitive.ObjectID, len(ll))
+	for i, l := range ll {
+		keys[i] = l.Key
+	}
+
+	res, err := s.col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.DeletedCount != int64(len(ll)) {
+		return errors.E(op, errors.Strf("deleted %d of %d documents", res.DeletedCount, len(ll)))
+	}
+
+	return nil
+}
+
+// GetTrashedLinksBefore returns up to limit links that were moved to the trash
+// before the given time, the longest trashed first.
+func (s *LinkStore) GetTrashedLinksBefore(ctx context.Context, before time.Time, limit int) ([]*model.Link, error) {
+	op := errors.Op("LinkStore.GetTrashedLinksBefore")
+
+	cur, err := s.col.Find(ctx,
+		bson.M{"istrashed": true, "trashedat": bson.M{"$lte": before}},
+		options.Find().
+			SetSort(bson.M{"trashedat": 1}).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"_id": 1, "userid": 1, "trashedat": 1}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.Link, 0)
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for _, l := range links {
+		l.ID = l.Key.Hex()
+	}
+
+	return links, nil
+}
+
 func (s *LinkStore) DeleteAllLinksByUser(ctx context.Context, u *model.User) error {
 	op := errors.Opf("LinkStore.DeleteAllLinks(%q)", u.Email)
 
@@ -225,6 +807,417 @@ func (s *LinkStore) DeleteAllLinksByUser(ctx context.Context, u *model.User) err
 	return nil
 }
 
+// GetLinksToEnrich returns up to limit links that are waiting for their
+// metadata, oldest first. Only their IDs are filled in.
+func (s *LinkStore) GetLinksToEnrich(ctx context.Context, limit int) ([]*model.Link, error) {
+	op := errors.Op("LinkStore.GetLinksToEnrich")
+
+	cur, err := s.col.Find(ctx,
+		bson.M{"needsenrichment": true, "istrashed": false},
+		options.Find().
+			SetSort(bson.M{"_id": 1}).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.Link, 0)
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for _, l := range links {
+		l.ID = l.Key.Hex()
+	}
+
+	return links, nil
+}
+
+// getDueLinks returns up to limit of the links matching the filter whose time
+// in the given field has passed or that don't have one, the longest overdue
+// first. Only their IDs are filled in.
+func (s *LinkStore) getDueLinks(ctx context.Context, filter bson.M, field string, limit int) ([]*model.Link, error) {
+	filter["$or"] = bson.A{
+		bson.M{field: nil},
+		bson.M{field: bson.M{"$lte": time.Now()}},
+	}
+
+	cur, err := s.col.Find(ctx, filter, options.Find().
+		SetSort(bson.M{field: 1}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*model.Link, 0)
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		l.ID = l.Key.Hex()
+	}
+
+	return links, nil
+}
+
+// UpdateLinkEnrichment saves only the fields that track fetching the link's
+// metadata, so that it doesn't overwrite changes the user made meanwhile.
+// Nothing is saved once the link has been enriched.
+func (s *LinkStore) UpdateLinkEnrichment(ctx context.Context, l *model.Link) error {
+	op := errors.Opf("LinkStore.UpdateLinkEnrichment(%q)", l.ID)
+
+	_, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": l.Key, "needsenrichment": true},
+		bson.M{"$set": bson.M{
+			"needsenrichment": l.NeedsEnrichment,
+			"enrichfailed":    l.EnrichFailed,
+		}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetLinksDueForHealthCheck returns up to limit links whose URLs are due to
+// be checked, the longest overdue first. Only their IDs are filled in.
+func (s *LinkStore) GetLinksDueForHealthCheck(ctx context.Context, limit int) ([]*model.Link, error) {
+	op := errors.Op("LinkStore.GetLinksDueForHealthCheck")
+
+	links, err := s.getDueLinks(ctx, bson.M{"istrashed": false}, "healthcheckafter", limit)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return links, nil
+}
+
+// UpdateLinkHealth saves only the link's health and when it is next due to be
+// checked. Nothing is saved unless the link still has the same URL and is
+// still due at checkAfter, so that the result of a check doesn't outlive a
+// change the user made meanwhile, or a check that finished first.
+func (s *LinkStore) UpdateLinkHealth(ctx context.Context, l *model.Link, checkAfter *time.Time) error {
+	op := errors.Opf("LinkStore.UpdateLinkHealth(%q)", l.ID)
+
+	_, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": l.Key, "url": l.URL, "healthcheckafter": checkAfter},
+		bson.M{"$set": bson.M{
+			"health":           l.Health,
+			"healthcheckafter": l.HealthCheckAfter,
+		}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetLinksDueForWatch returns up to limit watched links whose pages are due
+// to be checked for changes, the longest overdue first. Only their IDs are
+// filled in.
+func (s *LinkStore) GetLinksDueForWatch(ctx context.Context, limit int) ([]*model.Link, error) {
+	op := errors.Op("LinkStore.GetLinksDueForWatch")
+
+	links, err := s.getDueLinks(ctx, bson.M{"iswatched": true, "istrashed": false}, "watchafter", limit)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return links, nil
+}
+
+// UpdateLinkWatch saves only the fields that track watching the link's page.
+// Nothing is saved unless the link is still watched, still has the same URL
+// and is still due at watchAfter. It reports whether the link was saved.
+func (s *LinkStore) UpdateLinkWatch(ctx context.Context, l *model.Link, watchAfter *time.Time) (bool, error) {
+	op := errors.Opf("LinkStore.UpdateLinkWatch(%q)", l.ID)
+
+	res, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": l.Key, "iswatched": true, "url": l.URL, "watchafter": watchAfter},
+		bson.M{"$set": bson.M{
+			"ischanged":     l.IsChanged,
+			"lastchangedat": l.LastChangedAt,
+			"watchedtext":   l.WatchedText,
+			"watchafter":    l.WatchAfter,
+		}})
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+func (s *LinkStore) GetEnrichmentStatus(ctx context.Context, u *model.User) (*model.EnrichmentStatus, error) {
+	op := errors.Opf("LinkStore.GetEnrichmentStatus(%q)", u.Email)
+
+	count := func(filter bson.M) (int, error) {
+		filter["userid"] = u.ID
+		filter["istrashed"] = false
+
+		n, err := s.col.CountDocuments(ctx, filter)
+
+		return int(n), err
+	}
+
+	var (
+		status = new(model.EnrichmentStatus)
+		err    error
+	)
+
+	if status.Total, err = count(bson.M{}); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if status.Pending, err = count(bson.M{"needsenrichment": true}); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if status.Failed, err = count(bson.M{"enrichfailed": true}); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return status, nil
+}
+
+// How much each way in which links can be related counts towards ranking
+// them. Shared tags count by the share of the link's tags that are shared.
+const (
+	relatedContentWeight  = 1.0
+	relatedTagsWeight     = 0.5
+	relatedUserTagsWeight = 0.75
+	relatedSiteWeight     = 0.25
+)
+
+// GetRelatedLinks ranks the link owner's other links by how related they are
+// to it: by how similar their content is, the tags and user tags they share
+// and whether they are from the same site. Trashed links and links that
+// aren't related at all are left out.
+func (s *LinkStore) GetRelatedLinks(
+	ctx context.Context,
+	l *model.Link,
+	p *model.Pagination,
+) ([]*model.RelatedLink, error) {
+	op := errors.Opf("LinkStore.GetRelatedLinks(%q)", l.ID)
+
+	related := bson.A{}
+	score := bson.A{}
+	similarity := interface{}(0.0)
+
+	if l.Embedding != nil {
+		related = append(related, bson.M{"embedding.model": l.Embedding.Model})
+		similarity = bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$embedding.model", l.Embedding.Model}},
+			dotProduct("$embedding.vector", l.Embedding.Vector),
+			0.0,
+		}}
+		score = append(score, bson.M{"$cond": bson.A{
+			bson.M{"$gte": bson.A{"$similarity", model.MinRelatedSimilarity}},
+			bson.M{"$multiply": bson.A{"$similarity", relatedContentWeight}},
+			0.0,
+		}})
+	}
+
+	if len(l.TagPaths) > 0 {
+		related = append(related, bson.M{"tagpaths": bson.M{"$in": l.TagPaths}})
+		score = append(score, sharedScore("$tagpaths", l.TagPaths, relatedTagsWeight))
+	}
+
+	if len(l.UserTags) > 0 {
+		related = append(related, bson.M{"usertags": bson.M{"$in": l.UserTags}})
+		score = append(score, sharedScore("$usertags", l.UserTags, relatedUserTagsWeight))
+	}
+
+	if host := model.Host(l.URL); host != "" {
+		site := `^[a-z]+://(www\.)?` + regexp.QuoteMeta(host) + `(:[0-9]+)?([/?#]|$)`
+		related = append(related, bson.M{"url": primitive.Regex{Pattern: site, Options: "i"}})
+		score = append(score, bson.M{"$cond": bson.A{
+			bson.M{"$regexMatch": bson.M{"input": "$url", "regex": site, "options": "i"}},
+			relatedSiteWeight,
+			0.0,
+		}})
+	}
+
+	if len(related) == 0 {
+		return []*model.RelatedLink{}, nil
+	}
+
+	projection := append(bson.D{}, listProjection...)
+	projection = append(projection,
+		primitive.E{Key: "score", Value: 1},
+		primitive.E{Key: "similarity", Value: 1})
+
+	cur, err := s.col.Aggregate(ctx, mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M{
+			"userid":    l.UserID,
+			"istrashed": false,
+			"_id":       bson.M{"$ne": l.Key},
+			"$or":       related,
+		}}},
+		{primitive.E{Key: "$addFields", Value: bson.M{"similarity": similarity}}},
+		{primitive.E{Key: "$addFields", Value: bson.M{"score": bson.M{"$add": score}}}},
+		{primitive.E{Key: "$match", Value: bson.M{"score": bson.M{"$gt": 0}}}},
+		{primitive.E{Key: "$sort", Value: bson.D{
+			primitive.E{Key: "score", Value: -1},
+			primitive.E{Key: "_id", Value: -1},
+		}}},
+		{primitive.E{Key: "$skip", Value: int64(p.Offset())}},
+		{primitive.E{Key: "$limit", Value: int64(p.Limit())}},
+		{primitive.E{Key: "$project", Value: projection}},
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var docs []*struct {
+		model.Link `bson:",inline"`
+		Similarity float64 `bson:"similarity"`
+	}
+
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.RelatedLink, len(docs))
+	for i, d := range docs {
+		other := &d.Link
+		other.ID = other.Key.Hex()
+
+		links[i] = &model.RelatedLink{
+			Link:    other,
+			Score:   other.Score,
+			Reasons: model.RelatedReasons(l, other, d.Similarity),
+		}
+	}
+
+	return links, nil
+}
+
+// sharedScore is an expression for the share of the values that are also in
+// the field, times the weight.
+func sharedScore(field string, values []string, weight float64) bson.M {
+	return bson.M{"$multiply": bson.A{
+		weight,
+		bson.M{"$divide": bson.A{
+			bson.M{"$size": bson.M{"$setIntersection": bson.A{bson.M{"$ifNull": bson.A{field, bson.A{}}}, values}}},
+			len(values),
+		}},
+	}}
+}
+
+// dotProduct is an expression for the dot product of the vector field and the
+// vector.
+func dotProduct(field string, vector []float32) bson.M {
+	return bson.M{"$reduce": bson.M{
+		"input":        bson.M{"$zip": bson.M{"inputs": bson.A{field, vector}}},
+		"initialValue": 0.0,
+		"in": bson.M{"$add": bson.A{"$$value", bson.M{"$multiply": bson.A{
+			bson.M{"$arrayElemAt": bson.A{"$$this", 0}},
+			bson.M{"$arrayElemAt": bson.A{"$$this", 1}},
+		}}}},
+	}}
+}
+
+// GetLinksWithoutEmbedding returns links that have no embedding from the
+// given model, in the order they were saved, starting after the link with the
+// given ID, which can be empty. Only the fields that are embedded are
+// populated.
+func (s *LinkStore) GetLinksWithoutEmbedding(
+	ctx context.Context,
+	embeddingModel string,
+	after string,
+	limit int,
+) ([]*model.Link, error) {
+	op := errors.Opf("LinkStore.GetLinksWithoutEmbedding(%q)", after)
+
+	m := bson.M{"embedding.model": bson.M{"$ne": embeddingModel}}
+
+	if after != "" {
+		key, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		m["_id"] = bson.M{"$gt": key}
+	}
+
+	cur, err := s.col.Find(ctx, m, options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{
+			"_id":         1,
+			"userid":      1,
+			"title":       1,
+			"description": 1,
+			"annotation":  1,
+			"corpus":      1,
+		}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	links := make([]*model.Link, cur.RemainingBatchLength())
+	if err := cur.All(ctx, &links); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for i := range links {
+		links[i].ID = links[i].Key.Hex()
+	}
+
+	return links, nil
+}
+
+// SetLinkEmbedding saves the link's embedding without touching its other
+// fields, which the user may have changed while it was being made.
+func (s *LinkStore) SetLinkEmbedding(ctx context.Context, l *model.Link, e *model.Embedding) error {
+	op := errors.Opf("LinkStore.SetLinkEmbedding(%q)", l.ID)
+
+	res, err := s.col.UpdateOne(ctx, bson.M{"_id": l.Key}, bson.M{"$set": bson.M{"embedding": e}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("no documents"), http.StatusNotFound)
+	}
+
+	l.Embedding = e
+
+	return nil
+}
+
+// SetLinkOpened records that the link was just opened, without touching its
+// other fields.
+func (s *LinkStore) SetLinkOpened(ctx context.Context, l *model.Link) error {
+	op := errors.Opf("LinkStore.SetLinkOpened(%q)", l.ID)
+
+	now := time.Now()
+
+	res, err := s.col.UpdateOne(ctx, bson.M{"_id": l.Key}, bson.M{"$set": bson.M{"lastopenedat": now}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if res.MatchedCount < 1 {
+		return errors.E(op, errors.Str("no documents"), http.StatusNotFound)
+	}
+
+	l.LastOpenedAt = &now
+
+	return nil
+}
+
+func errDuplicateURL(op errors.Op) error {
+	return errors.E(
+		op,
+		errors.M{"url": "This link has already been saved."},
+		errors.Str("duplicate link URL"),
+		http.StatusBadRequest)
+}
+
 func GetLinksSort(val string) model.GetLinksOption {
 	return func(m map[string]interface{}) {
 		if len(val) > 0 && (val == "1" || val == "-1") {
@@ -242,6 +1235,96 @@ func GetLinksSearch(val string) model.GetLinksOption {
 	}
 }
 
+// GetLinksSimilar ranks links by how similar their embeddings are to the
+// vector, instead of sorting them, and leaves out links that have no
+// embedding from the given model.
+func GetLinksSimilar(embeddingModel string, vector []float32) model.GetLinksOption {
+	return func(m map[string]interface{}) {
+		if len(vector) > 0 {
+			m["similar"] = vector
+			m["embedding.model"] = embeddingModel
+		}
+	}
+}
+
+// GetLinksQuery filters links by a parsed search query. Its filters are
+// combined with the other options.
+func GetLinksQuery(q *search.Query) model.GetLinksOption {
+	return func(m map[string]interface{}) {
+		GetLinksSearch(q.Text)(m)
+
+		for _, clause := range q.Clauses {
+			conds := make([]bson.M, 0, len(clause))
+			for _, f := range clause {
+				conds = append(conds, filterCond(f))
+			}
+
+			if len(conds) == 1 {
+				and(m, conds[0])
+			} else {
+				and(m, bson.M{"$or": conds})
+			}
+		}
+	}
+}
+
+var isFields = map[string]string{
+	search.IsFavorite:  "isfavorite",
+	search.IsAnnotated: "isannotated",
+	search.IsArticle:   "isarticle",
+	search.IsRead:      "isread",
+}
+
+func filterCond(f *search.Filter) bson.M {
+	var cond bson.M
+
+	switch f.Field {
+	case search.FieldSite:
+		// The site matches the link's host and any of its subdomains.
+		cond = bson.M{"url": primitive.Regex{
+			Pattern: `^[a-z]+://([^/?#]*\.)?` + regexp.QuoteMeta(f.Value) + `(:[0-9]+)?([/?#]|$)`,
+			Options: "i",
+		}}
+	case search.FieldTag:
+		cond = bson.M{"tagpaths": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Value) + "$", Options: "i"}}
+	case search.FieldUserTag:
+		cond = bson.M{"usertags": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Value) + "$", Options: "i"}}
+	case search.FieldFolder:
+		cond = bson.M{}
+		if f.Value != "root" {
+			cond = bson.M{"folderid": f.Value}
+		}
+	case search.FieldTitle:
+		cond = bson.M{"title": primitive.Regex{Pattern: regexp.QuoteMeta(f.Value), Options: "i"}}
+	case search.FieldURL:
+		cond = bson.M{"url": primitive.Regex{Pattern: regexp.QuoteMeta(f.Value), Options: "i"}}
+	case search.FieldIs:
+		switch f.Value {
+		case search.IsUnread, search.IsInProgress:
+			cond = readStateCond(f.Value)
+		default:
+			cond = bson.M{isFields[f.Value]: true}
+		}
+	case search.FieldBefore, search.FieldAfter, search.FieldCreated:
+		r := bson.M{}
+		if !f.From.IsZero() {
+			r["$gte"] = f.From
+		}
+
+		if !f.To.IsZero() {
+			r["$lt"] = f.To
+		}
+
+		cond = bson.M{"createdat": r}
+	}
+
+	if f.Negate {
+		return bson.M{"$nor": []bson.M{cond}}
+	}
+
+	return cond
+}
+
 func GetLinksFavorites(val string) model.GetLinksOption {
 	return func(m map[string]interface{}) {
 		if val == "1" {
@@ -250,6 +1333,50 @@ func GetLinksFavorites(val string) model.GetLinksOption {
 	}
 }
 
+// GetLinksReadState keeps the links that are unread, in progress or read.
+// In progress links are unread ones that have been partly read.
+func GetLinksReadState(val string) model.GetLinksOption {
+	return func(m map[string]interface{}) {
+		if len(val) > 0 {
+			for k, v := range readStateCond(val) {
+				m[k] = v
+			}
+		}
+	}
+}
+
+func readStateCond(state string) bson.M {
+	switch state {
+	case model.ReadStateRead:
+		return bson.M{"isread": true}
+	case model.ReadStateInProgress:
+		return bson.M{"isread": bson.M{"$ne": true}, "readprogress": bson.M{"$gt": 0}}
+	default:
+		// Links saved before read state was tracked have no isread field.
+		return bson.M{"isread": bson.M{"$ne": true}}
+	}
+}
+
+// GetLinksChanged keeps the watched links whose page changed since the user
+// last cleared their IsChanged.
+func GetLinksChanged(val string) model.GetLinksOption {
+	return func(m map[string]interface{}) {
+		if val == "1" {
+			m["ischanged"] = true
+		}
+	}
+}
+
+// GetLinksHealth keeps the links whose URL was found to have the given
+// health status the last time it was checked.
+func GetLinksHealth(val string) model.GetLinksOption {
+	return func(m map[string]interface{}) {
+		if len(val) > 0 {
+			m["health.status"] = val
+		}
+	}
+}
+
 func GetLinksFolder(val string) model.GetLinksOption {
 	return func(m map[string]interface{}) {
 		if len(val) > 0 && val != "root" {
@@ -274,6 +1401,14 @@ func GetLinksUserTag(val string) model.GetLinksOption {
 	}
 }
 
+func GetLinksTrashed(val string) model.GetLinksOption {
+	return func(m map[string]interface{}) {
+		if val == "1" {
+			m["istrashed"] = true
+		}
+	}
+}
+
 func GetLinksAnnotated(val string) model.GetLinksOption {
 	return func(m map[string]interface{}) {
 		if val == "1" {
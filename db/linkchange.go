@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+type LinkChangeStore struct {
+	client *mongo.Client
+	col    *mongo.Collection
+}
+
+func NewLinkChangeStore(client *mongo.Client) *LinkChangeStore {
+	return &LinkChangeStore{col: client.Database("test").Collection("linkchanges"), client: client}
+}
+
+func (s *LinkChangeStore) CreateLinkChange(ctx context.Context, c *model.LinkChange) error {
+	op := errors.Opf("LinkChangeStore.CreateLinkChange(linkID=%s)", c.LinkID)
+
+	c.Key = primitive.NewObjectID()
+	c.ID = c.Key.Hex()
+
+	if _, err := s.col.InsertOne(ctx, c); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *LinkChangeStore) GetLinkChangesByLink(
+	ctx context.Context,
+	linkID string,
+	p *model.Pagination,
+) ([]*model.LinkChange, error) {
+	op := errors.Opf("LinkChangeStore.GetLinkChangesByLink(linkID=%s)", linkID)
+
+	cur, err := s.col.Find(ctx, bson.M{"linkid": linkID}, options.Find().
+		SetSort(bson.D{
+			primitive.E{Key: "detectedat", Value: -1},
+			primitive.E{Key: "_id", Value: -1},
+		}).
+		SetLimit(int64(p.Limit())).
+		SetSkip(int64(p.Offset())))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	changes := make([]*model.LinkChange, cur.RemainingBatchLength())
+	if err := cur.All(ctx, &changes); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for _, c := range changes {
+		c.ID = c.Key.Hex()
+	}
+
+	return changes, nil
+}
+
+func (s *LinkChangeStore) DeleteLinkChangesByLinks(ctx context.Context, linkIDs []string) error {
+	op := errors.Opf("LinkChangeStore.DeleteLinkChangesByLinks(n=%d)", len(linkIDs))
+
+	if len(linkIDs) == 0 {
+		return nil
+	}
+
+	_, err := s.col.DeleteMany(ctx, bson.M{"linkid": bson.M{"$in": linkIDs}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *LinkChangeStore) DeleteAllLinkChangesByUser(ctx context.Context, u *model.User) error {
+	op := errors.Opf("LinkChangeStore.DeleteAllLinkChangesByUser(%q)", u.Email)
+
+	_, err := s.col.DeleteMany(ctx, bson.M{"userid": u.ID})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
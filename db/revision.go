@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+type RevisionStore struct {
+	client *mongo.Client
+	col    *mongo.Collection
+}
+
+func NewRevisionStore(client *mongo.Client) *RevisionStore {
+	return &RevisionStore{col: client.Database("test").Collection("revisions"), client: client}
+}
+
+func (s *RevisionStore) CreateRevisions(ctx context.Context, revs []*model.LinkRevision) error {
+	op := errors.Opf("RevisionStore.CreateRevisions(n=%d)", len(revs))
+
+	if len(revs) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(revs))
+	for i, r := range revs {
+		r.Key = primitive.NewObjectID()
+		r.ID = r.Key.Hex()
+		docs[i] = r
+	}
+
+	_, err := s.col.InsertMany(ctx, docs)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *RevisionStore) GetRevisionByID(ctx context.Context, id string) (*model.LinkRevision, error) {
+	op := errors.Opf("RevisionStore.GetRevisionByID(id=%s)", id)
+
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusNotFound)
+	}
+
+	r := new(model.LinkRevision)
+
+	err = s.col.FindOne(ctx, bson.M{"_id": docID}).Decode(r)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.E(op, err, errors.Str("no documents"), http.StatusNotFound)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	r.ID = id
+	r.Key = docID
+
+	return r, nil
+}
+
+func (s *RevisionStore) GetRevisionsByLink(
+	ctx context.Context,
+	linkID string,
+	p *model.Pagination,
+) ([]*model.LinkRevision, error) {
+	op := errors.Opf("RevisionStore.GetRevisionsByLink(linkID=%s)", linkID)
+
+	return s.find(ctx, op, bson.M{"linkid": linkID}, options.Find().
+		SetLimit(int64(p.Limit())).
+		SetSkip(int64(p.Offset())))
+}
+
+func (s *RevisionStore) GetRevisionsSince(
+	ctx context.Context,
+	r *model.LinkRevision,
+) ([]*model.LinkRevision, error) {
+	op := errors.Opf("RevisionStore.GetRevisionsSince(id=%s)", r.ID)
+
+	return s.find(ctx, op, bson.M{
+		"linkid": r.LinkID,
+		"$or": bson.A{
+			bson.M{"createdat": bson.M{"$gt": r.CreatedAt}},
+			bson.M{"createdat": r.CreatedAt, "_id": bson.M{"$gte": r.Key}},
+		},
+	}, options.Find())
+}
+
+func (s *RevisionStore) find(
+	ctx context.Context,
+	op errors.Op,
+	filter bson.M,
+	opts *options.FindOptions,
+) ([]*model.LinkRevision, error) {
+	cur, err := s.col.Find(ctx, filter, opts.SetSort(bson.D{
+		primitive.E{Key: "createdat", Value: -1},
+		primitive.E{Key: "_id", Value: -1},
+	}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	revs := make([]*model.LinkRevision, cur.RemainingBatchLength())
+	err = cur.All(ctx, &revs)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for i := range revs {
+		revs[i].ID = revs[i].Key.Hex()
+	}
+
+	return revs, nil
+}
+
+func (s *RevisionStore) DeleteRevisionsByLinks(ctx context.Context, linkIDs []string) error {
+	op := errors.Opf("RevisionStore.DeleteRevisionsByLinks(n=%d)", len(linkIDs))
+
+	if len(linkIDs) == 0 {
+		return nil
+	}
+
+	_, err := s.col.DeleteMany(ctx, bson.M{"linkid": bson.M{"$in": linkIDs}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *RevisionStore) DeleteAllRevisionsByUser(ctx context.Context, u *model.User) error {
+	op := errors.Opf("RevisionStore.DeleteAllRevisionsByUser(%q)", u.Email)
+
+	_, err := s.col.DeleteMany(ctx, bson.M{"userid": u.ID})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
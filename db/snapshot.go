@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+// newestFirst sorts snapshots from the newest to the oldest.
+var newestFirst = bson.D{
+	primitive.E{Key: "fetchedat", Value: -1},
+	primitive.E{Key: "_id", Value: -1},
+}
+
+type SnapshotStore struct {
+	client *mongo.Client
+	col    *mongo.Collection
+}
+
+func NewSnapshotStore(client *mongo.Client) *SnapshotStore {
+	return &SnapshotStore{col: client.Database("test").Collection("snapshots"), client: client}
+}
+
+func (s *SnapshotStore) CreateSnapshot(ctx context.Context, snap *model.Snapshot) (*model.Snapshot, error) {
+	op := errors.Opf("SnapshotStore.CreateSnapshot(linkID=%s)", snap.LinkID)
+
+	snap.Key = primitive.NewObjectID()
+	snap.ID = snap.Key.Hex()
+
+	if _, err := s.col.InsertOne(ctx, snap); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return snap, nil
+}
+
+func (s *SnapshotStore) GetSnapshotByID(ctx context.Context, id string) (*model.Snapshot, error) {
+	op := errors.Opf("SnapshotStore.GetSnapshotByID(id=%s)", id)
+
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusNotFound)
+	}
+
+	snap := new(model.Snapshot)
+
+	err = s.col.FindOne(ctx, bson.M{"_id": docID}).Decode(snap)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.E(op, err, errors.Str("no documents"), http.StatusNotFound)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	snap.ID = id
+
+	return snap, nil
+}
+
+func (s *SnapshotStore) GetSnapshotsByLink(
+	ctx context.Context,
+	l *model.Link,
+	p *model.Pagination,
+) ([]*model.Snapshot, error) {
+	op := errors.Opf("SnapshotStore.GetSnapshotsByLink(linkID=%s)", l.ID)
+
+	cur, err := s.col.Find(ctx, bson.M{"linkid": l.ID}, options.Find().
+		SetSort(newestFirst).
+		SetProjection(bson.M{"html": 0, "text": 0}).
+		SetLimit(int64(p.Limit())).
+		SetSkip(int64(p.Offset())))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	snaps := make([]*model.Snapshot, cur.RemainingBatchLength())
+	if err := cur.All(ctx, &snaps); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for _, snap := range snaps {
+		snap.ID = snap.Key.Hex()
+	}
+
+	return snaps, nil
+}
+
+func (s *SnapshotStore) DeleteOldSnapshots(ctx context.Context, l *model.Link, keep int) error {
+	op := errors.Opf("SnapshotStore.DeleteOldSnapshots(linkID=%s, keep=%d)", l.ID, keep)
+
+	cur, err := s.col.Find(ctx, bson.M{"linkid": l.ID}, options.Find().
+		SetSort(newestFirst).
+		SetProjection(bson.M{"_id": 1}).
+		SetSkip(int64(keep)))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	var old []struct {
+		Key primitive.ObjectID `bson:"_id"`
+	}
+
+	if err := cur.All(ctx, &old); err != nil {
+		return errors.E(op, err)
+	}
+
+	if len(old) == 0 {
+		return nil
+	}
+
+	keys := make(bson.A, len(old))
+	for i, o := range old {
+		keys[i] = o.Key
+	}
+
+	if _, err := s.col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}}); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *SnapshotStore) DeleteSnapshotsByLinks(ctx context.Context, linkIDs []string) error {
+	op := errors.Opf("SnapshotStore.DeleteSnapshotsByLinks(n=%d)", len(linkIDs))
+
+	if len(linkIDs) == 0 {
+		return nil
+	}
+
+	_, err := s.col.DeleteMany(ctx, bson.M{"linkid": bson.M{"$in": linkIDs}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (s *SnapshotStore) DeleteAllSnapshotsByUser(ctx context.Context, u *model.User) error {
+	op := errors.Opf("SnapshotStore.DeleteAllSnapshotsByUser(%q)", u.Email)
+
+	_, err := s.col.DeleteMany(ctx, bson.M{"userid": u.ID})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
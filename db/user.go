@@ -8,6 +8,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/linksort/linksort/errors"
 	"github.com/linksort/linksort/model"
@@ -95,6 +96,54 @@ func (s *UserStore) GetUserByToken(ctx context.Context, token string) (*model.Us
 	return usr, nil
 }
 
+// GetUserByFeedToken gets the user who has a feed with the given token.
+func (s *UserStore) GetUserByFeedToken(ctx context.Context, token string) (*model.User, error) {
+	op := errors.Op("UserStore.GetUserByFeedToken()")
+
+	usr := new(model.User)
+
+	err := s.col.FindOne(ctx, bson.M{"feeds.token": token}).Decode(usr)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.E(op, err, ErrNoDocuments, http.StatusNotFound)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	usr.ID = usr.Key.Hex()
+
+	handleNullValues(usr)
+
+	return usr, nil
+}
+
+func (s *UserStore) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	op := errors.Op("UserStore.GetUserByID()")
+
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.E(op, err, ErrNoDocuments, http.StatusNotFound)
+	}
+
+	usr := new(model.User)
+
+	err = s.col.FindOne(ctx, bson.M{"_id": docID}).Decode(usr)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.E(op, err, ErrNoDocuments, http.StatusNotFound)
+		}
+
+		return nil, errors.E(op, err)
+	}
+
+	usr.ID = usr.Key.Hex()
+
+	handleNullValues(usr)
+
+	return usr, nil
+}
+
 func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	op := errors.Op("UserStore.GetUserByEmail()")
 
@@ -148,6 +197,55 @@ func (s *UserStore) UpdateUser(ctx context.Context, u *model.User) (*model.User,
 	return u, nil
 }
 
+// GetUsersDueForDigest returns up to limit users whose digest is due, the
+// longest overdue first. Only their IDs are filled in.
+func (s *UserStore) GetUsersDueForDigest(ctx context.Context, limit int) ([]*model.User, error) {
+	op := errors.Op("UserStore.GetUsersDueForDigest()")
+
+	cur, err := s.col.Find(ctx,
+		bson.M{
+			"digest.isenabled":  true,
+			"digest.nextsendat": bson.M{"$lte": time.Now()},
+		},
+		options.Find().
+			SetSort(bson.M{"digest.nextsendat": 1}).
+			SetLimit(int64(limit)).
+			SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	users := make([]*model.User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	for _, u := range users {
+		u.ID = u.Key.Hex()
+	}
+
+	return users, nil
+}
+
+// UpdateUserDigest saves when the user's digest was last sent and is next
+// due, without overwriting other changes to the user. Nothing is saved if the
+// user turned the digest off meanwhile.
+func (s *UserStore) UpdateUserDigest(ctx context.Context, u *model.User) error {
+	op := errors.Opf("UserStore.UpdateUserDigest(%q)", u.ID)
+
+	_, err := s.col.UpdateOne(ctx,
+		bson.M{"_id": u.Key, "digest.isenabled": true},
+		bson.M{"$set": bson.M{
+			"digest.lastsentat": u.Digest.LastSentAt,
+			"digest.nextsendat": u.Digest.NextSendAt,
+		}})
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
 func (s *UserStore) DeleteUser(ctx context.Context, u *model.User) error {
 	op := errors.Opf("UserStore.DeleteUser(%q)", u.ID)
 
@@ -172,6 +270,14 @@ func handleNullValues(usr *model.User) {
 		}
 	}
 
+	if usr.SavedSearches == nil {
+		usr.SavedSearches = make(model.SavedSearches, 0)
+	}
+
+	if usr.Feeds == nil {
+		usr.Feeds = make(model.Feeds, 0)
+	}
+
 	if usr.TagTree == nil {
 		usr.TagTree = &model.TagNode{
 			Name:     "root",
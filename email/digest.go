@@ -0,0 +1,163 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/log"
+	"github.com/linksort/linksort/model"
+)
+
+func (c *Client) SendDigest(ctx context.Context, usr *model.User, d *model.Digest) error {
+	op := errors.Opf("SendDigest(UserID=%s)", usr.ID)
+
+	html, text, err := renderDigest(usr, d)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	m := c.mg.NewMessage("Linksort <noreply@linksort.com>", "Your weekly Linksort digest", text, usr.Email)
+	m.SetHtml(html)
+	m.AddHeader("List-Unsubscribe", fmt.Sprintf("<%s>", d.UnsubscribeURL))
+	m.AddHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+
+	_, id, err := c.mg.Send(ctx, m)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	log.FromContext(ctx).Printf("SentEmailID=%s", id)
+
+	return nil
+}
+
+func (l *Logger) SendDigest(ctx context.Context, usr *model.User, d *model.Digest) error {
+	op := errors.Opf("Logger.SendDigest(UserID=%s)", usr.ID)
+
+	_, text, err := renderDigest(usr, d)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	log.FromContext(ctx).Printf("email=%s, digest=%q", usr.Email, text)
+
+	return nil
+}
+
+type digestData struct {
+	FirstName string
+	*model.Digest
+}
+
+// renderDigest returns the HTML and plain-text bodies of the digest email.
+func renderDigest(usr *model.User, d *model.Digest) (string, string, error) {
+	data := &digestData{FirstName: usr.FirstName, Digest: d}
+
+	var html, text bytes.Buffer
+
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return "", "", err
+	}
+
+	if err := digestText.Execute(&text, data); err != nil {
+		return "", "", err
+	}
+
+	return html.String(), text.String(), nil
+}
+
+// linkTitle is what a link is called in the digest. Links without a title go
+// by their URL.
+func linkTitle(l *model.Link) string {
+	if l.Title != "" {
+		return l.Title
+	}
+
+	return l.URL
+}
+
+var digestFuncs = map[string]interface{}{"title": linkTitle}
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Funcs(digestFuncs).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; color: #1f2937; max-width: 600px;">
+<p>Hi {{.FirstName}},</p>
+<p>Here are some things from your Linksort that are worth another look.</p>
+{{- define "links"}}
+<ul>
+{{- range .}}
+<li><a href="{{.URL}}">{{title .}}</a>{{with .Site}} <span style="color: #6b7280;">{{.}}</span>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Unread}}
+<h2>Still unread</h2>
+{{- template "links" .}}
+{{- end}}
+{{- with .Favorites}}
+<h2>Favorites you haven't opened in a while</h2>
+{{- template "links" .}}
+{{- end}}
+{{- with .OnThisDay}}
+<h2>On this day</h2>
+{{- template "links" .}}
+{{- end}}
+{{- with .Highlights}}
+<h2>Your recent highlights</h2>
+{{- range .}}
+<blockquote style="border-left: 3px solid #d1d5db; margin: 1em 0; padding-left: 1em;">
+<p>{{.Highlight.Quote}}</p>
+{{- with .Highlight.Note}}
+<p style="color: #4b5563;">{{.}}</p>
+{{- end}}
+<p><a href="{{.Link.URL}}">{{title .Link}}</a></p>
+</blockquote>
+{{- end}}
+{{- end}}
+<p style="color: #6b7280; font-size: 12px;">You're getting this email because you turned on the Linksort digest. <a href="{{.UnsubscribeURL}}">Unsubscribe</a>.</p>
+</body>
+</html>
+`))
+
+var digestText = template.Must(template.New("digest").Funcs(digestFuncs).Parse(`Hi {{.FirstName}},
+
+Here are some things from your Linksort that are worth another look.
+{{- define "links"}}{{range .}}
+- {{title .}}
+  {{.URL}}
+{{- end}}{{end}}
+{{- with .Unread}}
+
+STILL UNREAD
+{{template "links" .}}
+{{- end}}
+{{- with .Favorites}}
+
+FAVORITES YOU HAVEN'T OPENED IN A WHILE
+{{template "links" .}}
+{{- end}}
+{{- with .OnThisDay}}
+
+ON THIS DAY
+{{template "links" .}}
+{{- end}}
+{{- with .Highlights}}
+
+YOUR RECENT HIGHLIGHTS
+{{- range .}}
+
+"{{.Highlight.Quote}}"
+{{- with .Highlight.Note}}
+{{.}}
+{{- end}}
+- {{title .Link}}, {{.Link.URL}}
+{{- end}}
+{{- end}}
+
+You're getting this email because you turned on the Linksort digest. To unsubscribe, go to:
+{{.UnsubscribeURL}}
+`))
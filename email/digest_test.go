@@ -0,0 +1,60 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/linksort/linksort/model"
+)
+
+func TestRenderDigest(t *testing.T) {
+	usr := &model.User{FirstName: "Ada"}
+	rust := &model.Link{URL: "https://doc.rust-lang.org/book/", Title: "The <Rust> Book", Site: "rust-lang.org"}
+	untitled := &model.Link{URL: "https://example.com/untitled"}
+	d := &model.Digest{
+		Unread:    []*model.Link{rust, untitled},
+		OnThisDay: []*model.Link{{URL: "javascript:alert(1)", Title: "Sneaky"}},
+		Highlights: []*model.LinkHighlight{{
+			Highlight: &model.Highlight{Quote: "Ownership is Rust's most unique feature", Note: "Reread"},
+			Link:      rust,
+		}},
+		UnsubscribeURL: "https://linksort.com/unsubscribe?t=a&u=ada%40example.com&s=b",
+	}
+
+	html, text, err := renderDigest(usr, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Hi Ada,",
+		"<h2>Still unread</h2>",
+		`<a href="https://doc.rust-lang.org/book/">The &lt;Rust&gt; Book</a>`,
+		`<a href="https://example.com/untitled">https://example.com/untitled</a>`,
+		"<h2>On this day</h2>",
+		"Ownership is Rust&#39;s most unique feature",
+		`href="https://linksort.com/unsubscribe?t=a&amp;u=ada%40example.com&amp;s=b"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected the HTML to contain %q:\n%s", want, html)
+		}
+	}
+
+	if strings.Contains(html, "javascript:") {
+		t.Errorf("expected unsafe URLs to be filtered out:\n%s", html)
+	}
+
+	if strings.Contains(html, "Favorites") || strings.Contains(text, "FAVORITES") {
+		t.Error("expected empty sections to be left out")
+	}
+
+	for _, want := range []string{
+		"STILL UNREAD\n\n- The <Rust> Book\n  https://doc.rust-lang.org/book/\n",
+		"\"Ownership is Rust's most unique feature\"\nReread\n- The <Rust> Book, https://doc.rust-lang.org/book/",
+		d.UnsubscribeURL,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected the text to contain %q:\n%s", want, text)
+		}
+	}
+}
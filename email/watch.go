@@ -0,0 +1,75 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/log"
+	"github.com/linksort/linksort/model"
+)
+
+func (c *Client) SendLinkChanged(ctx context.Context, usr *model.User, l *model.Link, ch *model.LinkChange) error {
+	op := errors.Opf("SendLinkChanged(UserID=%s, LinkID=%s)", usr.ID, l.ID)
+
+	text, err := renderLinkChanged(usr, l, ch)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	m := c.mg.NewMessage(
+		"Linksort <noreply@linksort.com>",
+		fmt.Sprintf("A page you're watching changed: %s", linkTitle(l)),
+		text,
+		usr.Email)
+
+	_, id, err := c.mg.Send(ctx, m)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	log.FromContext(ctx).Printf("SentEmailID=%s", id)
+
+	return nil
+}
+
+func (l *Logger) SendLinkChanged(ctx context.Context, usr *model.User, link *model.Link, ch *model.LinkChange) error {
+	log.FromContext(ctx).Printf("email=%s, changed=%s, added=%d, removed=%d",
+		usr.Email, link.URL, ch.Added, ch.Removed)
+
+	return nil
+}
+
+type linkChangedData struct {
+	FirstName string
+	Link      *model.Link
+	Change    *model.LinkChange
+}
+
+// renderLinkChanged returns the plain-text body of the email telling the user
+// that the page of a link they watch changed.
+func renderLinkChanged(usr *model.User, l *model.Link, ch *model.LinkChange) (string, error) {
+	var text bytes.Buffer
+
+	err := linkChangedText.Execute(&text, &linkChangedData{FirstName: usr.FirstName, Link: l, Change: ch})
+	if err != nil {
+		return "", err
+	}
+
+	return text.String(), nil
+}
+
+var linkChangedText = template.Must(template.New("linkChanged").Funcs(digestFuncs).Parse(`Hi {{.FirstName}},
+
+The page of a link you're watching changed.
+
+{{title .Link}}
+{{.Link.URL}}
+
+{{.Change.Added}} lines were added and {{.Change.Removed}} were removed:
+
+{{.Change.Diff}}
+You're getting this email because you're watching this link. To stop, turn off watching for it in Linksort.
+`))
@@ -0,0 +1,73 @@
+package embed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+const (
+	titanModelID    = "amazon.titan-embed-text-v2:0"
+	titanDimensions = 512
+)
+
+// InvokeModelProvider is the part of the Bedrock runtime client that Bedrock
+// uses.
+type InvokeModelProvider interface {
+	InvokeModel(
+		ctx context.Context,
+		params *bedrockruntime.InvokeModelInput,
+		optFns ...func(*bedrockruntime.Options),
+	) (*bedrockruntime.InvokeModelOutput, error)
+}
+
+// Bedrock embeds texts with Amazon Titan Text Embeddings on Bedrock.
+type Bedrock struct {
+	client InvokeModelProvider
+}
+
+func NewBedrock(client InvokeModelProvider) *Bedrock {
+	return &Bedrock{client: client}
+}
+
+func (b *Bedrock) Model() string {
+	return fmt.Sprintf("%s/%d", titanModelID, titanDimensions)
+}
+
+func (b *Bedrock) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"inputText":  Truncate(text),
+		"dimensions": titanDimensions,
+		"normalize":  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	out, err := b.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(titanModelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke embedding model: %w", err)
+	}
+
+	var res struct {
+		Embedding []float32 `json:"embedding"`
+	}
+
+	if err := json.Unmarshal(out.Body, &res); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+
+	if len(res.Embedding) != titanDimensions {
+		return nil, fmt.Errorf("got an embedding of %d dimensions, want %d", len(res.Embedding), titanDimensions)
+	}
+
+	return Normalize(res.Embedding), nil
+}
@@ -0,0 +1,69 @@
+// Package embed turns text into vectors that are close together when the
+// texts mean similar things, so that links can be found by what they are
+// about rather than by the words they use.
+package embed
+
+import (
+	"context"
+	"math"
+)
+
+// MaxInputLength is how much of a text is embedded, in characters. The rest
+// is cut off.
+const MaxInputLength = 20000
+
+// Embedder embeds texts. Its vectors have unit length, so that their dot
+// product is their cosine similarity.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// Model names the model and its settings. Vectors are only comparable
+	// when they come from the same model.
+	Model() string
+}
+
+// Truncate cuts the text down to MaxInputLength characters.
+func Truncate(text string) string {
+	if len(text) <= MaxInputLength {
+		return text
+	}
+
+	runes := []rune(text)
+	if len(runes) <= MaxInputLength {
+		return text
+	}
+
+	return string(runes[:MaxInputLength])
+}
+
+// Normalize scales the vector to unit length in place and returns it.
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+
+	if sum == 0 {
+		return v
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+
+	return v
+}
+
+// Similarity is the cosine similarity of two vectors of unit length.
+func Similarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+
+	return dot
+}
@@ -0,0 +1,57 @@
+package embed
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	e := NewLocal()
+
+	embed := func(text string) []float32 {
+		v, err := e.Embed(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return v
+	}
+
+	query := embed("sourdough bread baking")
+	near := embed("A beginner's guide to baking sourdough bread at home")
+	far := embed("Kubernetes operators for managing stateful databases")
+
+	if got, again := query, embed("sourdough bread baking"); Similarity(got, again) < 0.9999 {
+		t.Error("embedding the same text twice gave different vectors")
+	}
+
+	if Similarity(query, near) <= Similarity(query, far) {
+		t.Errorf("related text scored %f, unrelated text %f", Similarity(query, near), Similarity(query, far))
+	}
+
+	var sum float64
+	for _, x := range near {
+		sum += float64(x) * float64(x)
+	}
+
+	if math.Abs(sum-1) > 1e-5 {
+		t.Errorf("vector has squared length %f, want 1", sum)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("é", MaxInputLength+10)
+
+	got := Truncate(long)
+	if n := utf8.RuneCountInString(got); n != MaxInputLength {
+		t.Errorf("got %d characters, want %d", n, MaxInputLength)
+	}
+
+	if short := "short"; Truncate(short) != short {
+		t.Error("short text was changed")
+	}
+}
@@ -0,0 +1,52 @@
+package embed
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const localDimensions = 256
+
+// Local embeds texts without calling out to a model, by hashing their words
+// into a vector. Texts are only close when they share words, so it's a
+// deterministic stand-in for a real model in tests and development.
+type Local struct{}
+
+func NewLocal() *Local {
+	return &Local{}
+}
+
+func (l *Local) Model() string {
+	return fmt.Sprintf("local-hash/%d", localDimensions)
+}
+
+func (l *Local) Embed(_ context.Context, text string) ([]float32, error) {
+	v := make([]float32, localDimensions)
+
+	words := strings.FieldsFunc(strings.ToLower(Truncate(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, w := range words {
+		if len(w) < 3 {
+			continue
+		}
+
+		h := fnv.New32a()
+		h.Write([]byte(w))
+		sum := h.Sum32()
+
+		// One bit of the hash picks the sign so that collisions tend to
+		// cancel out rather than add up.
+		if sum&(1<<31) != 0 {
+			v[sum%localDimensions]--
+		} else {
+			v[sum%localDimensions]++
+		}
+	}
+
+	return Normalize(v), nil
+}
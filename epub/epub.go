@@ -0,0 +1,475 @@
+// Package epub writes EPUB 3 books out of saved pages, so that they can be
+// read offline on e-readers.
+//
+// A book is streamed as it is written: each page becomes a chapter as soon as
+// it is added, and the table of contents and package document, which list
+// all of the chapters, are written last.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/linksort/linksort/random"
+)
+
+const (
+	mimetype = "application/epub+zip"
+	// contentDir is the directory in the book that holds its content.
+	contentDir = "OEBPS/"
+	// maxImagesSize is the most image data a book embeds, in bytes. Images
+	// past it are left out, and replaced by their alt text.
+	maxImagesSize = 64 << 20
+	// imageTime is the longest a book spends fetching images, so that a book
+	// with many images is still written in good time. Images that aren't
+	// fetched by then are replaced by their alt text.
+	imageTime = 45 * time.Second
+	// imageWorkers is how many of a chapter's images are fetched at once.
+	imageWorkers = 8
+)
+
+// Chapter is a saved page, as it is put in a book.
+type Chapter struct {
+	Title   string
+	Site    string
+	URL     string
+	SavedAt time.Time
+	// HTML is the page's sanitized HTML, with absolute links and images.
+	HTML       string
+	Annotation string
+	Highlights []*Highlight
+}
+
+// Highlight is a quote from a page, with the reader's note on it. Highlights
+// and annotations are put at the end of their chapter as endnotes.
+type Highlight struct {
+	Quote string
+	Note  string
+}
+
+type chapterEntry struct {
+	ID    string
+	Path  string
+	Title string
+}
+
+type imageEntry struct {
+	ID        string
+	Path      string
+	MediaType string
+}
+
+// Writer writes a book.
+type Writer struct {
+	zw       *zip.Writer
+	title    string
+	images   ImageFetcher
+	chapters []*chapterEntry
+	// embedded maps the URLs of the images that were embedded to their path
+	// in the book, or to "" for those that couldn't be.
+	embedded  map[string]string
+	manifest  []*imageEntry
+	imageSize int
+	// imagesUntil is when the book stops fetching images.
+	imagesUntil time.Time
+}
+
+// NewWriter starts writing a book with the given title to w. The images the
+// chapters refer to are fetched with the fetcher and embedded in the book.
+// If the fetcher is nil, images are replaced by their alt text.
+func NewWriter(w io.Writer, title string, images ImageFetcher) (*Writer, error) {
+	bw := &Writer{
+		zw:          zip.NewWriter(w),
+		title:       title,
+		images:      images,
+		embedded:    make(map[string]string),
+		imagesUntil: time.Now().Add(imageTime),
+	}
+
+	// The mimetype file comes first and is stored as it is, with no data
+	// descriptor, so that the book can be recognized by its first bytes.
+	mw, err := bw.zw.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(mimetype)),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+		Modified:           time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+
+	if _, err := io.WriteString(mw, mimetype); err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+
+	if err := bw.writeFile("META-INF/container.xml", []byte(containerXML)); err != nil {
+		return nil, err
+	}
+
+	if err := bw.writeFile(contentDir+"style.css", []byte(styleCSS)); err != nil {
+		return nil, err
+	}
+
+	return bw, nil
+}
+
+// AddChapter adds the chapter to the book.
+func (w *Writer) AddChapter(ctx context.Context, ch *Chapter) error {
+	content, err := w.xhtml(ctx, ch.HTML)
+	if err != nil {
+		return err
+	}
+
+	n := len(w.chapters) + 1
+	entry := &chapterEntry{
+		ID:    fmt.Sprintf("chapter-%04d", n),
+		Path:  fmt.Sprintf("chapter-%04d.xhtml", n),
+		Title: chapterTitle(ch),
+	}
+
+	var buf bytes.Buffer
+
+	err = chapterTemplate.Execute(&buf, &chapterData{
+		Chapter:    ch,
+		Title:      entry.Title,
+		Content:    content,
+		Annotation: paragraphs(ch.Annotation),
+	})
+	if err != nil {
+		return fmt.Errorf("epub: %w", err)
+	}
+
+	if err := w.writeFile(contentDir+entry.Path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	w.chapters = append(w.chapters, entry)
+
+	return nil
+}
+
+// Close writes the table of contents and the package document, which list
+// the chapters and images, and finishes the book. A book needs at least one
+// chapter.
+func (w *Writer) Close() error {
+	if len(w.chapters) == 0 {
+		return fmt.Errorf("epub: a book needs at least one chapter")
+	}
+
+	data := &packageData{
+		ID:       "urn:uuid:" + random.UUID(),
+		Title:    w.title,
+		Modified: time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Chapters: w.chapters,
+		Images:   w.manifest,
+	}
+
+	for _, f := range []struct {
+		name string
+		tmpl *template.Template
+	}{
+		{"nav.xhtml", navTemplate},
+		{"toc.ncx", ncxTemplate},
+		{"content.opf", packageTemplate},
+	} {
+		var buf bytes.Buffer
+
+		if err := f.tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("epub: %w", err)
+		}
+
+		if err := w.writeFile(contentDir+f.name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := w.zw.Close(); err != nil {
+		return fmt.Errorf("epub: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Writer) writeFile(name string, data []byte) error {
+	fw, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("epub: %w", err)
+	}
+
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("epub: %w", err)
+	}
+
+	return nil
+}
+
+// embedImages fetches the images at the URLs, a few at a time, and embeds
+// those that can be in the book, once each. Their paths relative to the
+// chapters are kept in embedded, or "" for those that couldn't be embedded.
+func (w *Writer) embedImages(ctx context.Context, urls []string) {
+	fetch := make([]string, 0, len(urls))
+
+	for _, url := range urls {
+		if _, ok := w.embedded[url]; !ok {
+			w.embedded[url] = ""
+			fetch = append(fetch, url)
+		}
+	}
+
+	if w.images == nil || len(fetch) == 0 || w.imageSize >= maxImagesSize {
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, w.imagesUntil)
+	defer cancel()
+
+	imgs := make([]*Image, len(fetch))
+	sem := make(chan struct{}, imageWorkers)
+
+	var wg sync.WaitGroup
+
+	for i, url := range fetch {
+		wg.Add(1)
+
+		go func(i int, url string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if img, err := w.images.FetchImage(ctx, url); err == nil {
+				imgs[i] = img
+			}
+		}(i, url)
+	}
+
+	wg.Wait()
+
+	// The images are written one at a time, in the order they're in.
+	for i, img := range imgs {
+		if img != nil {
+			w.addImage(fetch[i], img)
+		}
+	}
+}
+
+// addImage writes the image at the URL to the book, unless it would take the
+// book past maxImagesSize.
+func (w *Writer) addImage(url string, img *Image) {
+	if w.imageSize+len(img.Data) > maxImagesSize {
+		return
+	}
+
+	n := len(w.manifest) + 1
+	entry := &imageEntry{
+		ID:        fmt.Sprintf("image-%04d", n),
+		Path:      fmt.Sprintf("images/image-%04d%s", n, imageExtensions[img.MediaType]),
+		MediaType: img.MediaType,
+	}
+
+	if err := w.writeFile(contentDir+entry.Path, img.Data); err != nil {
+		return
+	}
+
+	w.imageSize += len(img.Data)
+	w.manifest = append(w.manifest, entry)
+	w.embedded[url] = entry.Path
+}
+
+// chapterTitle is what the chapter is called in the table of contents.
+// Pages without a title go by their URL.
+func chapterTitle(ch *Chapter) string {
+	if t := strings.TrimSpace(ch.Title); t != "" {
+		return t
+	}
+
+	return ch.URL
+}
+
+// paragraphs splits the text into paragraphs at its blank lines.
+func paragraphs(s string) []string {
+	out := make([]string, 0)
+
+	for _, p := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+
+	return out
+}
+
+type chapterData struct {
+	*Chapter
+	Title      string
+	Content    string
+	Annotation []string
+}
+
+type packageData struct {
+	ID       string
+	Title    string
+	Modified string
+	Chapters []*chapterEntry
+	Images   []*imageEntry
+}
+
+var funcs = template.FuncMap{
+	"esc": html.EscapeString,
+	// lines escapes the text and keeps its line breaks.
+	"lines": func(s string) string {
+		return strings.ReplaceAll(html.EscapeString(strings.TrimSpace(s)), "\n", "<br/>")
+	},
+	"date": func(t time.Time) string { return t.Format("January 2, 2006") },
+	"inc":  func(i int) int { return i + 1 },
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const styleCSS = `body { font-family: serif; line-height: 1.5; }
+img { max-width: 100%; height: auto; }
+pre { white-space: pre-wrap; }
+blockquote { margin: 1em 0; padding-left: 1em; border-left: 3px solid #ccc; }
+.meta { color: #555; font-size: 0.9em; margin: 0.2em 0; }
+.notes { margin-top: 2em; border-top: 1px solid #ccc; }
+`
+
+var chapterTemplate = template.Must(template.New("chapter").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+<title>{{esc .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<section epub:type="chapter">
+<header>
+<h1>{{esc .Title}}</h1>
+{{- with .Site}}
+<p class="meta">{{esc .}}</p>
+{{- end}}
+<p class="meta"><a href="{{esc .URL}}">{{esc .URL}}</a></p>
+{{- if not .SavedAt.IsZero}}
+<p class="meta">Saved {{date .SavedAt}}</p>
+{{- end}}
+</header>
+{{.Content}}
+</section>
+{{- if or .Annotation .Highlights}}
+<section epub:type="endnotes" class="notes">
+<h2>Notes</h2>
+{{- with .Annotation}}
+<aside epub:type="endnote" id="annotation">
+{{- range .}}
+<p>{{lines .}}</p>
+{{- end}}
+</aside>
+{{- end}}
+{{- with .Highlights}}
+<ol>
+{{- range $i, $h := .}}
+<li epub:type="endnote" id="highlight-{{inc $i}}">
+<blockquote><p>{{lines $h.Quote}}</p></blockquote>
+{{- with $h.Note}}
+<p>{{lines .}}</p>
+{{- end}}
+</li>
+{{- end}}
+</ol>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+var navTemplate = template.Must(template.New("nav").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+<title>{{esc .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>{{esc .Title}}</h1>
+<ol>
+{{- range .Chapters}}
+<li><a href="{{.Path}}">{{esc .Title}}</a></li>
+{{- end}}
+</ol>
+</nav>
+</body>
+</html>
+`))
+
+var ncxTemplate = template.Must(template.New("ncx").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head>
+<meta name="dtb:uid" content="{{esc .ID}}"/>
+</head>
+<docTitle><text>{{esc .Title}}</text></docTitle>
+<navMap>
+{{- range $i, $c := .Chapters}}
+<navPoint id="nav-{{$c.ID}}" playOrder="{{inc $i}}">
+<navLabel><text>{{esc $c.Title}}</text></navLabel>
+<content src="{{$c.Path}}"/>
+</navPoint>
+{{- end}}
+</navMap>
+</ncx>
+`))
+
+var packageTemplate = template.Must(template.New("package").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="en">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">{{esc .ID}}</dc:identifier>
+<dc:title>{{esc .Title}}</dc:title>
+<dc:language>en</dc:language>
+<dc:creator>Linksort</dc:creator>
+<meta property="dcterms:modified">{{.Modified}}</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+<item id="style" href="style.css" media-type="text/css"/>
+{{- range .Chapters}}
+<item id="{{.ID}}" href="{{.Path}}" media-type="application/xhtml+xml"/>
+{{- end}}
+{{- range .Images}}
+<item id="{{.ID}}" href="{{.Path}}" media-type="{{.MediaType}}"/>
+{{- end}}
+</manifest>
+<spine toc="ncx">
+<itemref idref="nav"/>
+{{- range .Chapters}}
+<itemref idref="{{.ID}}"/>
+{{- end}}
+</spine>
+</package>
+`))
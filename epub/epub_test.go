@@ -0,0 +1,280 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readBook(t *testing.T, book []byte) map[string]string {
+	t.Helper()
+
+	if !bytes.HasPrefix(book[30:], []byte("mimetype"+mimetype)) {
+		t.Errorf("expected the book to start with its mimetype, got %q", book[30:68])
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[f.Name] = string(data)
+
+		if strings.HasSuffix(f.Name, ".xhtml") || strings.HasSuffix(f.Name, ".opf") || strings.HasSuffix(f.Name, ".ncx") {
+			d := xml.NewDecoder(bytes.NewReader(data))
+			for {
+				if _, err := d.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("expected %s to be well-formed XML: %v\n%s", f.Name, err, data)
+				}
+			}
+		}
+	}
+
+	return files
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, "Rust & Go", NewTestImageFetcher())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.AddChapter(ctx, &Chapter{
+		Title:   "The <Rust> Book",
+		Site:    "rust-lang.org",
+		URL:     "https://doc.rust-lang.org/book/?a=1&b=2",
+		SavedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		HTML: `<p>Ownership&nbsp;rules<br/>and <a href="https://example.com/?a=1&amp;b=2">links</a></p>` +
+			`<img src="https://example.com/a.png" alt="A"/><img src="https://example.com/a.png" alt="Again"/>`,
+		Annotation: "Read this\n\nagain",
+		Highlights: []*Highlight{{Quote: "Ownership rules", Note: "Key idea"}, {Quote: "and links"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.AddChapter(ctx, &Chapter{URL: "https://example.com/untitled", HTML: "<p>Text</p>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readBook(t, buf.Bytes())
+
+	for _, name := range []string{
+		"META-INF/container.xml",
+		"OEBPS/content.opf",
+		"OEBPS/nav.xhtml",
+		"OEBPS/toc.ncx",
+		"OEBPS/style.css",
+		"OEBPS/chapter-0001.xhtml",
+		"OEBPS/chapter-0002.xhtml",
+		"OEBPS/images/image-0001.png",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected the book to contain %s", name)
+		}
+	}
+
+	if _, ok := files["OEBPS/images/image-0002.png"]; ok {
+		t.Error("expected the same image to be embedded once")
+	}
+
+	chapter := files["OEBPS/chapter-0001.xhtml"]
+	for _, want := range []string{
+		"<h1>The &lt;Rust&gt; Book</h1>",
+		`<p class="meta">rust-lang.org</p>`,
+		`<a href="https://doc.rust-lang.org/book/?a=1&amp;b=2">`,
+		"Saved March 1, 2024",
+		"Ownership\u00a0rules<br/>",
+		`<img src="images/image-0001.png" alt="A"/>`,
+		`<img src="images/image-0001.png" alt="Again"/>`,
+		`<section epub:type="endnotes" class="notes">`,
+		"<p>Read this</p>\n<p>again</p>",
+		`<li epub:type="endnote" id="highlight-1">`,
+		"<p>Key idea</p>",
+	} {
+		if !strings.Contains(chapter, want) {
+			t.Errorf("expected the chapter to contain %q:\n%s", want, chapter)
+		}
+	}
+
+	if untitled := files["OEBPS/chapter-0002.xhtml"]; strings.Contains(untitled, "endnotes") {
+		t.Errorf("expected no notes for a chapter without any:\n%s", untitled)
+	}
+
+	nav := files["OEBPS/nav.xhtml"]
+	for _, want := range []string{
+		`<a href="chapter-0001.xhtml">The &lt;Rust&gt; Book</a>`,
+		`<a href="chapter-0002.xhtml">https://example.com/untitled</a>`,
+	} {
+		if !strings.Contains(nav, want) {
+			t.Errorf("expected the table of contents to contain %q:\n%s", want, nav)
+		}
+	}
+
+	opf := files["OEBPS/content.opf"]
+	for _, want := range []string{
+		"<dc:title>Rust &amp; Go</dc:title>",
+		`<item id="image-0001" href="images/image-0001.png" media-type="image/png"/>`,
+		`<itemref idref="chapter-0002"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("expected the package document to contain %q:\n%s", want, opf)
+		}
+	}
+}
+
+func TestWriter_NoImages(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, "Offline", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.AddChapter(context.Background(), &Chapter{
+		URL:  "https://example.com",
+		HTML: `<p>Before <img src="https://example.com/a.png" alt="A diagram"/> after</p>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chapter := readBook(t, buf.Bytes())["OEBPS/chapter-0001.xhtml"]
+	if !strings.Contains(chapter, "<p>Before A diagram after</p>") {
+		t.Errorf("expected the image to be replaced by its alt text:\n%s", chapter)
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	w, err := NewWriter(io.Discard, "Empty", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err == nil {
+		t.Error("expected a book without chapters to be an error")
+	}
+}
+
+// slowImageFetcher returns the test image for URLs that end in "fast.png",
+// and waits for the context to be done for any other.
+type slowImageFetcher struct {
+	*TestImageFetcher
+}
+
+func (f slowImageFetcher) FetchImage(ctx context.Context, url string) (*Image, error) {
+	if strings.HasSuffix(url, "fast.png") {
+		return f.TestImageFetcher.FetchImage(ctx, url)
+	}
+
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func TestWriter_ImageTime(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, "Slow", slowImageFetcher{NewTestImageFetcher()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.imagesUntil = time.Now().Add(50 * time.Millisecond)
+
+	var html strings.Builder
+	for i := 0; i < 3*imageWorkers; i++ {
+		fmt.Fprintf(&html, `<img src="https://example.com/%d.png" alt="Slow %d"/>`, i, i)
+	}
+
+	html.WriteString(`<img src="https://example.com/fast.png" alt="Fast"/>`)
+
+	start := time.Now()
+
+	if err := w.AddChapter(context.Background(), &Chapter{URL: "https://example.com", HTML: html.String()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected the images to stop being fetched once the time was up, took %v", took)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chapter := readBook(t, buf.Bytes())["OEBPS/chapter-0001.xhtml"]
+	if !strings.Contains(chapter, "Slow 0") || !strings.Contains(chapter, `<img src="images/image-0001.png" alt="Fast"/>`) {
+		t.Errorf("expected only the fast image to be embedded:\n%s", chapter)
+	}
+}
+
+func TestHTTPImageFetcher_PublicOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(NewTestImageFetcher().data)
+	}))
+	defer srv.Close()
+
+	_, err := NewImageFetcher().FetchImage(context.Background(), srv.URL+"/a.png")
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("expected a loopback address to be refused, got %v", err)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::6810": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"224.0.0.1":       false,
+	} {
+		if got := isPublic(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublic(%s): got %v, want %v", addr, got, want)
+		}
+	}
+}
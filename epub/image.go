@@ -0,0 +1,159 @@
+package epub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	imageTimeout = 15 * time.Second
+	// maxImageSize is the largest image that is embedded, in bytes.
+	maxImageSize = 5 << 20
+)
+
+// imageExtensions are the kinds of image that are embedded, by media type.
+// They are the raster images that every EPUB reader has to show.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Image is an image to embed in a book.
+type Image struct {
+	MediaType string
+	Data      []byte
+}
+
+// ImageFetcher fetches the images that are embedded in books.
+type ImageFetcher interface {
+	FetchImage(ctx context.Context, url string) (*Image, error)
+}
+
+// HTTPImageFetcher fetches images from the web. Since the URLs come from
+// saved pages, it only connects to public addresses, so that a page can't get
+// the server to fetch from itself or the network it's in.
+type HTTPImageFetcher struct {
+	client *http.Client
+}
+
+func NewImageFetcher() *HTTPImageFetcher {
+	dialer := &net.Dialer{Timeout: imageTimeout, Control: publicOnly}
+
+	return &HTTPImageFetcher{client: &http.Client{
+		Timeout: imageTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: imageTimeout,
+			MaxIdleConnsPerHost: imageWorkers,
+		},
+	}}
+}
+
+// publicOnly refuses connections to addresses that aren't public, such as
+// loopback, private and link-local ones. It is checked for each connection,
+// after the host is resolved, so it also holds for redirects and for hosts
+// that resolve to such addresses.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("epub: %w", err)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublic(ip) {
+		return fmt.Errorf("epub: %s is not a public address", host)
+	}
+
+	return nil
+}
+
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// FetchImage fetches the image at the URL. Only web images of the kinds that
+// can be embedded, no larger than the largest that is embedded, are fetched.
+func (f *HTTPImageFetcher) FetchImage(ctx context.Context, rawURL string) (*Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("epub: %q is not a web URL", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+
+	req.Header.Set("Accept", "image/*")
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("epub: got status %d for %q", res.StatusCode, rawURL)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("epub: %w", err)
+	}
+
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("epub: %q is too large", rawURL)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if _, ok := imageExtensions[mediaType]; !ok {
+		// The header is often missing or wrong, so the data is checked too.
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	if _, ok := imageExtensions[mediaType]; !ok {
+		return nil, fmt.Errorf("epub: %q is not an image that can be embedded", rawURL)
+	}
+
+	return &Image{MediaType: mediaType, Data: data}, nil
+}
+
+// TestImageFetcher is an ImageFetcher that returns the same small image for
+// every URL, without fetching anything.
+type TestImageFetcher struct {
+	data []byte
+}
+
+func NewTestImageFetcher() *TestImageFetcher {
+	var buf bytes.Buffer
+
+	_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+
+	return &TestImageFetcher{data: buf.Bytes()}
+}
+
+func (f *TestImageFetcher) FetchImage(context.Context, string) (*Image, error) {
+	return &Image{MediaType: "image/png", Data: f.data}, nil
+}
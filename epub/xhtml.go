@@ -0,0 +1,104 @@
+package epub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// xhtml turns the sanitized HTML of a page into XHTML that can go in a
+// chapter. Its images are embedded in the book, or replaced by their alt
+// text when they can't be.
+func (w *Writer) xhtml(ctx context.Context, s string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("epub: failed to parse html: %w", err)
+	}
+
+	var urls []string
+	for _, n := range nodes {
+		urls = imageURLs(n, urls)
+	}
+
+	w.embedImages(ctx, urls)
+
+	var buf bytes.Buffer
+
+	for _, n := range nodes {
+		replaceImages(n, w.embedded)
+
+		// Void elements are rendered as self-closing tags and text is
+		// escaped with references that XML knows, so the output is XHTML.
+		if err := html.Render(&buf, n); err != nil {
+			return "", fmt.Errorf("epub: failed to render html: %w", err)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// imageURLs adds the URLs of the images in n to urls.
+func imageURLs(n *html.Node, urls []string) []string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+		return append(urls, attr(n, "src"))
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		urls = imageURLs(c, urls)
+	}
+
+	return urls
+}
+
+// replaceImages points the images in n to where they were embedded in the
+// book, by their URLs.
+func replaceImages(n *html.Node, embedded map[string]string) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+		if path := embedded[attr(n, "src")]; path != "" {
+			setAttr(n, "src", path)
+			return
+		}
+
+		// Images that aren't in the book would have to be loaded from the
+		// web, which e-readers can't do offline.
+		n.Type = html.TextNode
+		n.Data = attr(n, "alt")
+		n.DataAtom = 0
+		n.Attr = nil
+
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		replaceImages(c, embedded)
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func setAttr(n *html.Node, name, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == name {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+
+	n.Attr = append(n.Attr, html.Attribute{Key: name, Val: val})
+}
@@ -1,5 +1,5 @@
 import React from "react";
-import { BrowserRouter, Switch } from "react-router-dom";
+import { BrowserRouter, Route, Switch } from "react-router-dom";
 import { QueryClient, QueryClientProvider } from "react-query";
 import { ChakraProvider } from "@chakra-ui/react";
 import { extendTheme } from "@chakra-ui/react";
@@ -12,6 +12,7 @@ import "../theme/shepherd.css";
 import theme from "../theme/theme";
 
 import AuthRoute from "./AuthRoute";
+import AuthLayout from "./AuthLayout";
 import SignIn from "../pages/SignIn";
 import SignUp from "../pages/SignUp";
 import ForgotPassword from "../pages/ForgotPassword";
@@ -23,6 +24,7 @@ import LinkView from "../pages/LinkView";
 import Extensions from "../pages/Extensions";
 import Account from "../pages/Account";
 import Graph from "../pages/Graph";
+import Unsubscribe from "../pages/Unsubscribe";
 import { ViewSettingProvider } from "../hooks/views";
 import { GlobalFiltersProvider } from "../hooks/filters";
 import { getScrollbarWidth } from "../utils/styles";
@@ -95,6 +97,11 @@ export default function App() {
                     path="/change-password"
                     component={ChangePassword}
                   />
+                  <Route path="/unsubscribe">
+                    <AuthLayout>
+                      <Unsubscribe />
+                    </AuthLayout>
+                  </Route>
                   <AuthRoute
                     isAuthRequired={true}
                     redirectTo="/sign-in"
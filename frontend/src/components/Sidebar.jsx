@@ -23,6 +23,7 @@ import SidebarButton from "./SidebarButton";
 import SidebarCollapsableSection from "./SidebarCollapsableSection";
 import SidebarSearchButton from "./SidebarSearchButton";
 import SidebarFolderTree from "./SidebarFolderTree";
+import SidebarSavedSearches from "./SidebarSavedSearches";
 import SidebarTagTree from "./SidebarTagTree";
 import { useFilters } from "../hooks/filters";
 import { GraphIcon, StarBorderIcon } from "./CustomIcons";
@@ -153,6 +154,11 @@ export default function Sidebar({ width = "18rem", isMobile = false }) {
                   <SidebarFolderTree />
                 </SidebarCollapsableSection>
               </ListItem>
+              <ListItem marginBottom={8}>
+                <SidebarCollapsableSection title="Saved Searches">
+                  <SidebarSavedSearches />
+                </SidebarCollapsableSection>
+              </ListItem>
               <ListItem
                 marginBottom={8}
               >
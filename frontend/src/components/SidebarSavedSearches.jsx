@@ -0,0 +1,39 @@
+import React from "react";
+import { Link as RouterLink } from "react-router-dom";
+import { List, ListItem, Text } from "@chakra-ui/react";
+import { SearchIcon } from "@chakra-ui/icons";
+
+import SidebarButton from "./SidebarButton";
+import { useUser } from "../hooks/auth";
+import { useFilterParams } from "../hooks/filters";
+
+export default function SidebarSavedSearches() {
+  const user = useUser();
+  const { saved } = useFilterParams();
+  const savedSearches = user?.savedSearches || [];
+
+  if (savedSearches.length === 0) {
+    return (
+      <Text fontSize="sm" color="gray.600">
+        Ask the assistant to save a search and it will appear here.
+      </Text>
+    );
+  }
+
+  return (
+    <List spacing={1}>
+      {savedSearches.map((search) => (
+        <ListItem key={search.id}>
+          <SidebarButton
+            leftIcon={<SearchIcon />}
+            as={RouterLink}
+            to={`/?saved=${encodeURIComponent(search.id)}`}
+            variant={saved === search.id ? "solid" : "ghost"}
+          >
+            {search.name}
+          </SidebarButton>
+        </ListItem>
+      ))}
+    </List>
+  );
+}
@@ -170,3 +170,43 @@ export function useDeleteUser() {
     }
   );
 }
+
+/*
+ * Follows an import until it is done or has failed.
+ *
+ * @param {string} id
+ */
+export function useImport(id) {
+  return useQuery(
+    ["import", id],
+    () => apiFetch(`/api/users/imports/${id}`).then((data) => data.job),
+    {
+      enabled: !!id,
+      refetchInterval: (job) =>
+        job?.status === "done" || job?.status === "failed" ? false : 2000,
+    }
+  );
+}
+
+/*
+ * @param {Object} payload
+ * @param {string} payload.email
+ * @param {string} payload.signature
+ * @param {string} payload.timestamp
+ */
+export function useUnsubscribe() {
+  const queryClient = useQueryClient();
+
+  return useMutation(
+    (payload) =>
+      apiFetch(`/api/users/unsubscribe`, {
+        body: payload,
+        method: "POST",
+      }),
+    {
+      onSuccess: () => {
+        queryClient.invalidateQueries("user");
+      },
+    }
+  );
+}
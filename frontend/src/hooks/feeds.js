@@ -0,0 +1,82 @@
+import { useMutation, useQueryClient } from "react-query";
+import { useToast } from "@chakra-ui/react";
+
+import apiFetch from "../utils/apiFetch";
+
+/*
+ * @param {Object} payload
+ * @param {string} payload.name
+ * @param {boolean} payload.favorites
+ * @param {string} payload.folderId
+ * @param {string} payload.userTag
+ */
+export function useCreateFeed() {
+  const queryClient = useQueryClient();
+  const toast = useToast();
+
+  return useMutation(
+    (payload) =>
+      apiFetch(`/api/feeds`, {
+        body: payload,
+        method: "POST",
+      }),
+    {
+      onSuccess: (data, payload) => {
+        queryClient.setQueryData("user", data?.user);
+        toast({
+          title: `Feed "${payload.name}" created`,
+          status: "success",
+          duration: 9000,
+          isClosable: true,
+        });
+      },
+    }
+  );
+}
+
+export function useResetFeedToken(feed) {
+  const queryClient = useQueryClient();
+  const toast = useToast();
+
+  return useMutation(
+    () =>
+      apiFetch(`/api/feeds/${feed.id}/token`, {
+        method: "POST",
+      }),
+    {
+      onSuccess: (data) => {
+        queryClient.setQueryData("user", data?.user);
+        toast({
+          title: `Feed "${feed.name}" has new URLs`,
+          description: "The old URLs no longer work.",
+          status: "success",
+          duration: 9000,
+          isClosable: true,
+        });
+      },
+    }
+  );
+}
+
+export function useDeleteFeed(feed) {
+  const queryClient = useQueryClient();
+  const toast = useToast();
+
+  return useMutation(
+    () =>
+      apiFetch(`/api/feeds/${feed.id}`, {
+        method: "DELETE",
+      }),
+    {
+      onSuccess: (data) => {
+        queryClient.setQueryData("user", data?.user);
+        toast({
+          title: `Feed "${feed.name}" deleted`,
+          status: "success",
+          duration: 9000,
+          isClosable: true,
+        });
+      },
+    }
+  );
+}
@@ -17,6 +17,7 @@ export const FILTER_KEY_FOLDER = "folder";
 export const FILTER_KEY_TAG = "tag";
 export const FILTER_KEY_USER_TAG = "usertag";
 export const FILTER_KEY_ANNOTATED = "annotated";
+export const FILTER_KEY_SAVED = "saved";
 
 const LOCALSTORAGE_FILTER_KEYS = [FILTER_KEY_SORT, FILTER_KEY_GROUP];
 const QUERY_FILTER_KEYS = [
@@ -27,6 +28,7 @@ const QUERY_FILTER_KEYS = [
   FILTER_KEY_TAG,
   FILTER_KEY_USER_TAG,
   FILTER_KEY_ANNOTATED,
+  FILTER_KEY_SAVED,
 ];
 
 const DEFAULT_FILTER_PARAMS = Object.freeze({
@@ -39,6 +41,7 @@ const DEFAULT_FILTER_PARAMS = Object.freeze({
   [FILTER_KEY_FOLDER]: "root",
   [FILTER_KEY_TAG]: "",
   [FILTER_KEY_USER_TAG]: "",
+  [FILTER_KEY_SAVED]: "",
 });
 
 export const GROUP_BY_OPTION_NONE = "none";
@@ -167,6 +170,7 @@ export function useFilters() {
         folder: encodeURIComponent(folder),
         tag: "",
         usertag: "",
+        saved: "",
         page: "0",
       });
     }
@@ -175,6 +179,7 @@ export function useFilters() {
       return mergeParamAndStringify({
         tag: encodeURIComponent(tagPath),
         folder: "root",
+        saved: "",
       });
     }
 
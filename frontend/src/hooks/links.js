@@ -15,6 +15,7 @@ import {
   FILTER_KEY_TAG,
   FILTER_KEY_ANNOTATED,
   FILTER_KEY_USER_TAG,
+  FILTER_KEY_SAVED,
 } from "./filters";
 import { omit } from "lodash";
 import { useHistory } from "react-router-dom";
@@ -28,6 +29,7 @@ const REFETCH_FILTERS = [
   FILTER_KEY_TAG,
   FILTER_KEY_USER_TAG,
   FILTER_KEY_ANNOTATED,
+  FILTER_KEY_SAVED,
 ];
 
 function useForceRefetchFilterParams() {
@@ -20,12 +20,27 @@ import {
   ModalOverlay,
   ModalContent,
   HStack,
+  Select,
+  Checkbox,
+  InputGroup,
+  InputRightElement,
+  useClipboard,
 } from "@chakra-ui/react";
 import { ArrowForwardIcon } from "@chakra-ui/icons";
 import { csrfStore } from "../utils/apiFetch";
 
 import { suppressMutationErrors } from "../utils/mutations";
-import { useUpdateUser, useDeleteUser, useUser } from "../hooks/auth";
+import {
+  useUpdateUser,
+  useDeleteUser,
+  useUser,
+  useImport,
+} from "../hooks/auth";
+import {
+  useCreateFeed,
+  useResetFeedToken,
+  useDeleteFeed,
+} from "../hooks/feeds";
 
 function Profile() {
   const user = useUser();
@@ -156,6 +171,150 @@ function APIAccess() {
   );
 }
 
+function flattenFolders(folder, depth = 0) {
+  return folder.children.flatMap((child) => [
+    { id: child.id, name: child.name, depth },
+    ...flattenFolders(child, depth + 1),
+  ]);
+}
+
+function FeedURL({ url }) {
+  const { hasCopied, onCopy } = useClipboard(url);
+
+  return (
+    <InputGroup size="sm">
+      <Input value={url} isReadOnly fontFamily={"mono"} paddingRight="4.5rem" />
+      <InputRightElement width="4.5rem">
+        <Button size="xs" onClick={onCopy}>
+          {hasCopied ? "Copied" : "Copy"}
+        </Button>
+      </InputRightElement>
+    </InputGroup>
+  );
+}
+
+function FeedItem({ feed }) {
+  const resetMutation = useResetFeedToken(feed);
+  const deleteMutation = useDeleteFeed(feed);
+  const base = `${window.location.origin}/api/feeds/${feed.token}`;
+
+  return (
+    <VStack spacing={2} align="left">
+      <Text fontWeight="semibold">{feed.name}</Text>
+      <FeedURL url={`${base}/atom`} />
+      <FeedURL url={`${base}/rss`} />
+      <HStack>
+        <Button
+          size="sm"
+          onClick={() => resetMutation.mutate()}
+          isLoading={resetMutation.isLoading}
+        >
+          Reset URLs
+        </Button>
+        <Button
+          size="sm"
+          colorScheme="red"
+          variant="outline"
+          onClick={() => deleteMutation.mutate()}
+          isLoading={deleteMutation.isLoading}
+        >
+          Delete
+        </Button>
+      </HStack>
+    </VStack>
+  );
+}
+
+function Feeds() {
+  const user = useUser();
+  const mutation = useCreateFeed();
+  const folders = flattenFolders(user.folderTree);
+  const feeds = user.feeds || [];
+  const formik = useFormik({
+    initialValues: { name: "", folderId: "", userTag: "", favorites: false },
+    onSubmit: suppressMutationErrors(async (values, { resetForm }) => {
+      await mutation.mutateAsync(values);
+      resetForm();
+    }),
+  });
+
+  return (
+    <VStack maxWidth="40ch" spacing={4} align="left">
+      <Heading as="h2" size="md">
+        Feeds
+      </Heading>
+
+      <Text>
+        Follow your links in a feed reader. Anyone with a feed's URL can read
+        it, so reset its URLs if they are shared by mistake.
+      </Text>
+
+      {feeds.map((feed) => (
+        <FeedItem key={feed.id} feed={feed} />
+      ))}
+
+      <VStack as="form" spacing={4} align="left" onSubmit={formik.handleSubmit}>
+        <FormControl id="feedName" isInvalid={mutation.error?.name}>
+          <FormLabel>Name</FormLabel>
+          <Input
+            type="text"
+            name="name"
+            onChange={formik.handleChange}
+            value={formik.values.name}
+          />
+          <FormErrorMessage>{mutation.error?.name}</FormErrorMessage>
+        </FormControl>
+
+        <FormControl id="feedFolderId" isInvalid={mutation.error?.folderId}>
+          <FormLabel>Folder</FormLabel>
+          <Select
+            name="folderId"
+            onChange={formik.handleChange}
+            value={formik.values.folderId}
+          >
+            <option value="">All</option>
+            {folders.map((folder) => (
+              <option key={folder.id} value={folder.id}>
+                {"\u00a0".repeat(folder.depth * 2)}
+                {folder.name}
+              </option>
+            ))}
+          </Select>
+          <FormErrorMessage>{mutation.error?.folderId}</FormErrorMessage>
+        </FormControl>
+
+        <FormControl id="feedUserTag" isInvalid={mutation.error?.userTag}>
+          <FormLabel>Tag</FormLabel>
+          <Input
+            type="text"
+            name="userTag"
+            onChange={formik.handleChange}
+            value={formik.values.userTag}
+          />
+          <FormErrorMessage>{mutation.error?.userTag}</FormErrorMessage>
+        </FormControl>
+
+        <FormControl id="feedFavorites" isInvalid={mutation.error?.message}>
+          <Checkbox
+            name="favorites"
+            onChange={formik.handleChange}
+            isChecked={formik.values.favorites}
+          >
+            Only favorites
+          </Checkbox>
+          <FormErrorMessage>{mutation.error?.message}</FormErrorMessage>
+        </FormControl>
+
+        <Flex justifyContent="left">
+          <Button type="submit" isLoading={formik.isSubmitting}>
+            Create Feed
+          </Button>
+        </Flex>
+      </VStack>
+    </VStack>
+  );
+}
+
 function DownloadData() {
   return (
     <VStack maxWidth="40ch" spacing={4} align="left">
@@ -177,10 +336,50 @@ function DownloadData() {
   );
 }
 
+function ImportProgress({ id }) {
+  const { data: job } = useImport(id);
+
+  if (!job) {
+    return null;
+  }
+
+  return (
+    <VStack spacing={2} align="left">
+      <Text>
+        {job.status === "done"
+          ? "Import finished."
+          : job.status === "failed"
+          ? job.error || "The import could not be finished."
+          : `Importing ${job.processed} of ${job.total} links...`}
+      </Text>
+      <Text fontSize="sm" color="gray.500">
+        {job.imported} imported, {job.skipped} skipped, {job.failed} failed
+      </Text>
+      {job.errors?.length > 0 && (
+        <VStack
+          spacing={1}
+          align="left"
+          fontSize="sm"
+          maxHeight="12rem"
+          overflowY="auto"
+        >
+          {job.errors.map((e) => (
+            <Text key={e.row} wordBreak="break-all">
+              Row {e.row}: {e.url ? `${e.url}: ` : ""}
+              {e.message}
+            </Text>
+          ))}
+        </VStack>
+      )}
+    </VStack>
+  );
+}
+
 function ImportPocket() {
   const inputRef = useRef();
   const toast = useToast();
   const [isUploading, setIsUploading] = useState(false);
+  const [jobID, setJobID] = useState(null);
 
   async function handleChange(e) {
     const file = e.target.files[0];
@@ -189,21 +388,21 @@ function ImportPocket() {
     form.append("file", file);
     setIsUploading(true);
     try {
-      await fetch("/api/users/import-pocket", {
+      const res = await fetch("/api/users/import/pocket", {
         method: "POST",
         body: form,
         headers: { "X-Csrf-Token": csrfStore.get() },
         credentials: "same-origin",
       });
-      toast({
-        title: "Import complete",
-        status: "success",
-        duration: 9000,
-        isClosable: true,
-      });
+      const data = await res.json();
+      if (!res.ok) {
+        throw new Error(data.file || data.message);
+      }
+      setJobID(data.job.id);
     } catch (e) {
       toast({
         title: "Import failed",
+        description: e.message,
         status: "error",
         duration: 9000,
         isClosable: true,
@@ -236,6 +435,8 @@ function ImportPocket() {
           Upload CSV
         </Button>
       </Box>
+
+      {jobID && <ImportProgress id={jobID} />}
     </VStack>
   );
 }
@@ -317,6 +518,7 @@ export default function Account() {
     >
       <Profile />
       <APIAccess />
+      <Feeds />
       <ImportPocket />
       <DownloadData />
       <Danger />
@@ -0,0 +1,54 @@
+import React from "react";
+import { Heading, Text, Button } from "@chakra-ui/react";
+
+import FloatingPill from "../components/FloatingPill";
+import { useUnsubscribe } from "../hooks/auth";
+import useQueryString from "../hooks/queryString";
+
+export default function Unsubscribe() {
+  const queryValues = useQueryString();
+  const mutation = useUnsubscribe();
+
+  if (mutation.isSuccess) {
+    return (
+      <FloatingPill width="100%" maxWidth="36ch" margin="auto">
+        <Heading fontSize="3xl" mb={6}>
+          Unsubscribed
+        </Heading>
+        <Text>
+          You won't get the Linksort digest anymore. You can turn it back on
+          from your account settings.
+        </Text>
+      </FloatingPill>
+    );
+  }
+
+  return (
+    <FloatingPill width="100%" maxWidth="36ch" margin="auto">
+      <Heading fontSize="3xl" mb={6}>
+        Unsubscribe
+      </Heading>
+      <Text mb={6}>Stop sending the Linksort digest to {queryValues.u}?</Text>
+      {mutation.isError && (
+        <Text color="red.500" mb={6}>
+          This link doesn't work anymore. You can turn off the digest from your
+          account settings.
+        </Text>
+      )}
+      <Button
+        onClick={() =>
+          mutation.mutate({
+            email: queryValues.u,
+            signature: queryValues.s,
+            timestamp: queryValues.t,
+          })
+        }
+        isLoading={mutation.isLoading}
+        colorScheme="brand"
+        w="100%"
+      >
+        Unsubscribe
+      </Button>
+    </FloatingPill>
+  );
+}
@@ -0,0 +1,230 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/handler/middleware"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/payload"
+	"github.com/linksort/linksort/syndication"
+)
+
+type Config struct {
+	FeedController interface {
+		CreateFeed(context.Context, *model.User, *CreateFeedRequest) (*model.User, error)
+		DeleteFeed(context.Context, *model.User, string) (*model.User, error)
+		ResetFeedToken(context.Context, *model.User, string) (*model.User, error)
+		GetFeed(context.Context, string) (*syndication.Feed, error)
+	}
+	AuthController interface {
+		WithCookie(context.Context, string) (*model.User, error)
+		WithToken(context.Context, string) (*model.User, error)
+	}
+	CSRF interface {
+		VerifyUserCSRF(token string, sessionID string, expiry time.Duration) error
+	}
+}
+
+type config struct{ *Config }
+
+func Handler(c *Config) *mux.Router {
+	cc := config{Config: c}
+	r := mux.NewRouter()
+
+	// Feed readers can't sign in, so feeds are read with their token.
+	r.HandleFunc("/api/feeds/{token}/atom", cc.GetAtomFeed).Methods("GET", "HEAD")
+	r.HandleFunc("/api/feeds/{token}/rss", cc.GetRSSFeed).Methods("GET", "HEAD")
+
+	s := r.NewRoute().Subrouter()
+	s.Use(middleware.WithUser(c.AuthController, c.CSRF))
+
+	s.HandleFunc("/api/feeds", cc.CreateFeed).Methods("POST")
+	s.HandleFunc("/api/feeds/{feedID}", cc.DeleteFeed).Methods("DELETE")
+	s.HandleFunc("/api/feeds/{feedID}/token", cc.ResetFeedToken).Methods("POST")
+
+	return r
+}
+
+type CreateFeedRequest struct {
+	Name      string `json:"name" validate:"required,max=128"`
+	Favorites bool   `json:"favorites"`
+	FolderID  string `json:"folderId" validate:"omitempty,uuid"`
+	TagPath   string `json:"tagPath" validate:"omitempty,max=512"`
+	UserTag   string `json:"userTag" validate:"omitempty,max=64"`
+}
+
+type CreateFeedResponse struct {
+	User *model.User `json:"user"`
+}
+
+// CreateFeed godoc
+//
+//	@Summary		CreateFeed
+//	@Description	Makes a feed of the links in a folder, with a tag path or user tag, or that are favorites. The feed is listed in the user's 'feeds', and is read without signing in from GET /feeds/{token}/atom or GET /feeds/{token}/rss.
+//	@Param		CreateFeedRequest	body		CreateFeedRequest	true	"Only 'name' is required. A feed without any filters has all of the user's links."
+//	@Success		201					{object}	CreateFeedResponse
+//	@Failure		400					{object}	payload.Error
+//	@Failure		401					{object}	payload.Error
+//	@Failure		500					{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/feeds				[post]
+func (s *config) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.CreateFeed")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(CreateFeedRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	u, err := s.FeedController.CreateFeed(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &CreateFeedResponse{u}, http.StatusCreated)
+}
+
+type DeleteFeedResponse struct {
+	User *model.User `json:"user"`
+}
+
+// DeleteFeed godoc
+//
+//	@Summary	DeleteFeed
+//	@Param	id				path		string	true	"FeedID"
+//	@Success	200				{object}	DeleteFeedResponse
+//	@Failure	401				{object}	payload.Error
+//	@Failure	404				{object}	payload.Error
+//	@Failure	500				{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/feeds/{id}		[delete]
+func (s *config) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.DeleteFeed")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	u, err := s.FeedController.DeleteFeed(ctx, u, vars["feedID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &DeleteFeedResponse{u}, http.StatusOK)
+}
+
+type ResetFeedTokenResponse struct {
+	User *model.User `json:"user"`
+}
+
+// ResetFeedToken godoc
+//
+//	@Summary		ResetFeedToken
+//	@Description	Gives the feed a new token. The feed can no longer be read with its old one.
+//	@Param		id					path		string	true	"FeedID"
+//	@Success		200					{object}	ResetFeedTokenResponse
+//	@Failure		401					{object}	payload.Error
+//	@Failure		404					{object}	payload.Error
+//	@Failure		500					{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/feeds/{id}/token	[post]
+func (s *config) ResetFeedToken(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.ResetFeedToken")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	u, err := s.FeedController.ResetFeedToken(ctx, u, vars["feedID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &ResetFeedTokenResponse{u}, http.StatusOK)
+}
+
+// GetAtomFeed godoc
+//
+//	@Summary		GetAtomFeed
+//	@Description	Gets the newest links of a feed as an Atom feed. The response has an ETag and a Last-Modified header, and is 304 Not Modified for a conditional request when the feed hasn't changed.
+//	@Param		token				path		string	true	"Feed token"
+//	@Produce		application/atom+xml
+//	@Success		200
+//	@Success		304
+//	@Failure		404					{object}	payload.Error
+//	@Failure		500					{object}	payload.Error
+//	@Router		/feeds/{token}/atom	[get]
+func (s *config) GetAtomFeed(w http.ResponseWriter, r *http.Request) {
+	s.getFeed(w, r, errors.Op("handler.GetAtomFeed"),
+		"application/atom+xml; charset=utf-8", syndication.WriteAtom)
+}
+
+// GetRSSFeed godoc
+//
+//	@Summary		GetRSSFeed
+//	@Description	Gets the newest links of a feed as an RSS 2.0 feed. The response has an ETag and a Last-Modified header, and is 304 Not Modified for a conditional request when the feed hasn't changed.
+//	@Param		token				path		string	true	"Feed token"
+//	@Produce		application/rss+xml
+//	@Success		200
+//	@Success		304
+//	@Failure		404					{object}	payload.Error
+//	@Failure		500					{object}	payload.Error
+//	@Router		/feeds/{token}/rss	[get]
+func (s *config) GetRSSFeed(w http.ResponseWriter, r *http.Request) {
+	s.getFeed(w, r, errors.Op("handler.GetRSSFeed"),
+		"application/rss+xml; charset=utf-8", syndication.WriteRSS)
+}
+
+func (s *config) getFeed(
+	w http.ResponseWriter,
+	r *http.Request,
+	op errors.Op,
+	contentType string,
+	write func(io.Writer, *syndication.Feed) error,
+) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	f, err := s.FeedController.GetFeed(ctx, vars["token"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	// The feed is made in full first so that its ETag can be worked out from
+	// it, since a feed also changes when links leave it.
+	buf := new(bytes.Buffer)
+	if err := write(buf, f); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	// ServeContent answers conditional requests from the ETag and the time
+	// the feed was last updated.
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(buf.Bytes()))
+}
@@ -33,6 +33,7 @@ var applicationRoutes = map[string]bool{
 	"extensions":                 true,
 	"graph":                      true,
 	"account":                    true,
+	"unsubscribe":                true,
 }
 
 var staticExts = []string{".js", ".css", ".png", ".jpg", ".jpeg"}
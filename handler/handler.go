@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -11,37 +12,62 @@ import (
 	"github.com/linksort/linksort/agent"
 	"github.com/linksort/linksort/analyze"
 	"github.com/linksort/linksort/assistant"
+	"github.com/linksort/linksort/bookmarks"
 	"github.com/linksort/linksort/controller"
 	"github.com/linksort/linksort/db"
+	"github.com/linksort/linksort/embed"
+	"github.com/linksort/linksort/epub"
 	"github.com/linksort/linksort/handler/conversation"
 	"github.com/linksort/linksort/handler/docs"
+	"github.com/linksort/linksort/handler/feed"
 	"github.com/linksort/linksort/handler/folder"
 	"github.com/linksort/linksort/handler/frontend"
+	"github.com/linksort/linksort/handler/highlight"
 	"github.com/linksort/linksort/handler/link"
 	"github.com/linksort/linksort/handler/middleware"
 	"github.com/linksort/linksort/handler/oauth"
+	"github.com/linksort/linksort/handler/readingqueue"
+	"github.com/linksort/linksort/handler/savedsearch"
+	"github.com/linksort/linksort/handler/snapshot"
 	"github.com/linksort/linksort/handler/user"
 	"github.com/linksort/linksort/log"
 	"github.com/linksort/linksort/magic"
 	"github.com/linksort/linksort/model"
 	"github.com/linksort/linksort/payload"
+	"github.com/linksort/linksort/queue"
+	"github.com/linksort/linksort/ratelimit"
 )
 
 type Config struct {
 	Transactor        db.Transactor
 	UserStore         model.UserStore
 	LinkStore         model.LinkStore
+	RevisionStore     model.RevisionStore
+	SnapshotStore     model.SnapshotStore
+	LinkChangeStore   model.LinkChangeStore
+	ImportJobStore    model.ImportJobStore
 	ConversationStore model.ConversationStore
 	Magic             *magic.Client
 	Email             interface {
 		SendForgotPassword(context.Context, *model.User, string) error
+		SendDigest(context.Context, *model.User, *model.Digest) error
+		SendLinkChanged(context.Context, *model.User, *model.Link, *model.LinkChange) error
 	}
 	Analyzer interface {
 		Do(context.Context, *analyze.Request) (*analyze.Response, error)
 		GatherCorpus(context.Context, string) (*analyze.Response, error)
 		Summarize(context.Context, string) (string, error)
+		FetchPage(context.Context, string) (*analyze.Page, error)
+		ReadableText(context.Context, string) (string, error)
 	}
+	HealthChecker interface {
+		Check(context.Context, string) *model.LinkHealth
+	}
+	ImageFetcher          epub.ImageFetcher
+	Queue                 *queue.Queue
+	Embedder              embed.Embedder
 	BedrockClient         agent.ConverseStreamProvider
+	ImageProxyURL         string
 	FrontendProxyHostname string
 	FrontendProxyPort     string
 	IsProd                bool
@@ -53,40 +79,140 @@ func New(c *Config) http.Handler {
 
 	// Controllers
 	userC := &controller.User{
-		Store:     c.UserStore,
-		LinkStore: c.LinkStore,
-		Magic:     c.Magic,
-		Email:     c.Email,
+		Store:          c.UserStore,
+		LinkStore:      c.LinkStore,
+		RevisionStore:  c.RevisionStore,
+		SnapshotStore:  c.SnapshotStore,
+		ChangeStore:    c.LinkChangeStore,
+		Magic:          c.Magic,
+		Email:          c.Email,
+		Importers:      bookmarks.Parsers(),
+		ImportJobStore: c.ImportJobStore,
+		Transactor:     c.Transactor,
+		Queue:          c.Queue,
 	}
 	authC := &controller.Auth{Store: c.UserStore}
 	linkC := &controller.Link{
-		Store:      c.LinkStore,
-		Analyzer:   c.Analyzer,
-		UserStore:  c.UserStore,
-		Transactor: c.Transactor,
+		Store:         c.LinkStore,
+		Analyzer:      c.Analyzer,
+		UserStore:     c.UserStore,
+		RevisionStore: c.RevisionStore,
+		ChangeStore:   c.LinkChangeStore,
+		SnapshotStore: c.SnapshotStore,
+		Transactor:    c.Transactor,
+		Queue:         c.Queue,
+		Embedder:      c.Embedder,
+		ImageProxyURL: c.ImageProxyURL,
+		Images:        c.ImageFetcher,
 	}
 	folderC := &controller.Folder{Store: c.UserStore}
+	highlightC := &controller.Highlight{Store: c.LinkStore}
+	snapshotC := &controller.Snapshot{
+		Store:         c.LinkStore,
+		SnapshotStore: c.SnapshotStore,
+		Fetcher:       c.Analyzer,
+	}
+	savedSearchC := &controller.SavedSearch{Store: c.UserStore}
+	feedC := &controller.Feed{Store: c.UserStore, LinkStore: c.LinkStore}
+	readingQueueC := &controller.ReadingQueue{Store: c.LinkStore, UserStore: c.UserStore}
 	oauthC := &controller.OAuth{Store: c.UserStore}
 	sessionC := &controller.Session{Store: c.UserStore}
 	conversationC := &controller.Conversation{
 		UserStore:         c.UserStore,
 		ConversationStore: c.ConversationStore,
 		AssistantClient: &assistant.Client{
-			LinkController:   linkC,
-			FolderController: folderC,
-			BedrockClient:    c.BedrockClient,
+			LinkController:        linkC,
+			FolderController:      folderC,
+			SavedSearchController: savedSearchC,
+			BedrockClient:         c.BedrockClient,
 		},
 	}
 
+	enricher := &controller.Enricher{
+		Store:      c.LinkStore,
+		UserStore:  c.UserStore,
+		Analyzer:   c.Analyzer,
+		Transactor: c.Transactor,
+		Queue:      c.Queue,
+		Limiter:    ratelimit.New(2 * time.Second),
+	}
+
+	healthChecker := &controller.HealthChecker{
+		Store:   c.LinkStore,
+		Checker: c.HealthChecker,
+		Queue:   c.Queue,
+		Limiter: ratelimit.New(5 * time.Second),
+	}
+
+	watcher := &controller.Watcher{
+		Store:       c.LinkStore,
+		ChangeStore: c.LinkChangeStore,
+		UserStore:   c.UserStore,
+		Extractor:   c.Analyzer,
+		Notifier:    c.Email,
+		Queue:       c.Queue,
+		Limiter:     ratelimit.New(5 * time.Second),
+	}
+
+	digester := &controller.Digester{
+		Store:     c.LinkStore,
+		UserStore: c.UserStore,
+		Email:     c.Email,
+		Magic:     c.Magic,
+		Queue:     c.Queue,
+	}
+
+	// Background work runs as jobs, so that it is retried, set aside when it
+	// keeps failing and finished before the server stops. The queue is started
+	// and drained by the caller.
+	c.Queue.Register(controller.JobGatherCorpus, linkC.GatherCorpus)
+	c.Queue.Register(controller.JobSummarize, linkC.Summarize)
+	c.Queue.Register(controller.JobImport, userC.RunImport)
+	c.Queue.Register(controller.JobEmbed, linkC.Embed)
+	c.Queue.Register(controller.JobSnapshot, snapshotC.SnapshotLink)
+	c.Queue.Register(controller.JobPurgeTrash, linkC.PurgeTrash)
+	c.Queue.Schedule(controller.JobPurgeTrash, time.Hour)
+	c.Queue.Register(controller.JobEnrich, enricher.EnrichLink)
+	c.Queue.Register(controller.JobQueueEnrichments, enricher.QueueEnrichments)
+	c.Queue.Schedule(controller.JobQueueEnrichments, time.Minute)
+	c.Queue.Register(controller.JobCheckHealth, healthChecker.CheckHealth)
+	c.Queue.Register(controller.JobQueueHealthChecks, healthChecker.QueueHealthChecks)
+	c.Queue.Schedule(controller.JobQueueHealthChecks, 5*time.Minute)
+	c.Queue.Register(controller.JobWatch, watcher.Watch)
+	c.Queue.Register(controller.JobQueueWatches, watcher.QueueWatches)
+	c.Queue.Schedule(controller.JobQueueWatches, time.Minute)
+	c.Queue.Register(controller.JobSendDigest, digester.SendDigest)
+	c.Queue.Register(controller.JobQueueDigests, digester.QueueDigests)
+	c.Queue.Schedule(controller.JobQueueDigests, time.Minute)
+	c.Queue.Register(controller.JobBackfillEmbeddings, linkC.BackfillEmbeddings)
+
+	// Links saved before semantic search, or before the embedding model
+	// changed, are embedded in the background.
+	c.Queue.RunAtStart(controller.JobBackfillEmbeddings)
+
 	// API Routes
 	api := router.PathPrefix("/api").Subrouter()
 	api.NotFoundHandler = http.HandlerFunc(notFound)
 
-	api.PathPrefix("/users").Handler(wrap(user.Handler(&user.Config{
+	userH := wrap(user.Handler(&user.Config{
 		AuthController:    authC,
 		UserController:    userC,
 		SessionController: sessionC,
 		CSRF:              c.Magic,
+	}))
+	api.PathPrefix("/users").Handler(userH)
+	highlightH := wrap(highlight.Handler(&highlight.Config{
+		AuthController:      authC,
+		HighlightController: highlightC,
+		CSRF:                c.Magic,
+	}))
+	// Highlights are under their links, so they are routed before links.
+	api.PathPrefix("/links/{linkID}/highlights").Handler(highlightH)
+	api.PathPrefix("/highlights").Handler(highlightH)
+	api.PathPrefix("/links/{linkID}/snapshots").Handler(wrap(snapshot.Handler(&snapshot.Config{
+		AuthController:     authC,
+		SnapshotController: snapshotC,
+		CSRF:               c.Magic,
 	})))
 	api.PathPrefix("/links").Handler(wrap(link.Handler(&link.Config{
 		AuthController: authC,
@@ -98,12 +224,31 @@ func New(c *Config) http.Handler {
 		FolderController: folderC,
 		CSRF:             c.Magic,
 	})))
+	api.PathPrefix("/searches").Handler(wrap(savedsearch.Handler(&savedsearch.Config{
+		AuthController:        authC,
+		SavedSearchController: savedSearchC,
+		CSRF:                  c.Magic,
+	})))
+	api.PathPrefix("/feeds").Handler(wrap(feed.Handler(&feed.Config{
+		AuthController: authC,
+		FeedController: feedC,
+		CSRF:           c.Magic,
+	})))
+	api.PathPrefix("/reading-queue").Handler(wrap(readingqueue.Handler(&readingqueue.Config{
+		AuthController:         authC,
+		ReadingQueueController: readingQueueC,
+		CSRF:                   c.Magic,
+	})))
 	api.PathPrefix("/conversations").Handler(wrap(conversation.Handler(&conversation.Config{
 		AuthController:         authC,
 		ConversationController: conversationC,
 		CSRF:                   c.Magic,
 	})))
 
+	// The unsubscribe links in emails are for a page of the frontend, and
+	// mail clients post to them to unsubscribe with one click.
+	router.Path("/unsubscribe").Methods("POST").Handler(userH)
+
 	router.PathPrefix("/oauth").Handler(oauth.Handler(&oauth.Config{
 		AuthController:  authC,
 		OAuthController: oauthC,
@@ -0,0 +1,236 @@
+package highlight
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/handler/middleware"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/payload"
+)
+
+type Config struct {
+	HighlightController interface {
+		CreateHighlight(context.Context, *model.User, *CreateHighlightRequest) (*model.Highlight, error)
+		GetHighlights(context.Context, *model.User, string) ([]*model.Highlight, error)
+		UpdateHighlight(context.Context, *model.User, *UpdateHighlightRequest) (*model.Highlight, error)
+		DeleteHighlight(ctx context.Context, u *model.User, linkID string, id string) error
+		GetHighlightFeed(context.Context, *model.User, *model.Pagination) ([]*model.LinkHighlight, error)
+	}
+	AuthController interface {
+		WithCookie(context.Context, string) (*model.User, error)
+		WithToken(context.Context, string) (*model.User, error)
+	}
+	CSRF interface {
+		VerifyUserCSRF(token string, sessionID string, expiry time.Duration) error
+	}
+}
+
+type config struct{ *Config }
+
+func Handler(c *Config) *mux.Router {
+	cc := config{Config: c}
+	r := mux.NewRouter()
+
+	r.Use(middleware.WithUser(c.AuthController, c.CSRF))
+
+	r.HandleFunc("/api/highlights", cc.GetHighlightFeed).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/highlights", cc.CreateHighlight).Methods("POST")
+	r.HandleFunc("/api/links/{linkID}/highlights", cc.GetHighlights).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/highlights/{highlightID}", cc.UpdateHighlight).Methods("PATCH")
+	r.HandleFunc("/api/links/{linkID}/highlights/{highlightID}", cc.DeleteHighlight).Methods("DELETE")
+
+	return r
+}
+
+type CreateHighlightRequest struct {
+	LinkID string `json:"-"`
+	Quote  string `json:"quote" validate:"required,max=5000"`
+	Start  int    `json:"start" validate:"min=0"`
+	Note   string `json:"note" validate:"max=10000"`
+	Color  string `json:"color" validate:"omitempty,oneof=yellow green blue pink purple"`
+}
+
+type CreateHighlightResponse struct {
+	Highlight *model.Highlight `json:"highlight"`
+}
+
+// CreateHighlight godoc
+//
+//	@Summary		CreateHighlight
+//	@Description	Highlights a passage of a link's text. The text is the link's corpus with its markup removed and runs of whitespace collapsed into single spaces, and 'start' is where the quote begins in it, in characters. If the quote isn't at 'start', the nearest place it is in the text is used. Highlights are found again when the corpus changes, and are marked 'isOrphaned' when their quote is gone.
+//	@Param		id						path		string					true	"LinkID"
+//	@Param		CreateHighlightRequest	body		CreateHighlightRequest	true	"Only 'quote' is required. 'color' is one of yellow, green, blue, pink and purple, and defaults to yellow."
+//	@Success		201						{object}	CreateHighlightResponse
+//	@Failure		400						{object}	payload.Error
+//	@Failure		401						{object}	payload.Error
+//	@Failure		404						{object}	payload.Error
+//	@Failure		500						{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/highlights	[post]
+func (s *config) CreateHighlight(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.CreateHighlight")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	req := new(CreateHighlightRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	req.LinkID = vars["linkID"]
+
+	h, err := s.HighlightController.CreateHighlight(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &CreateHighlightResponse{h}, http.StatusCreated)
+}
+
+type GetHighlightsResponse struct {
+	Highlights []*model.Highlight `json:"highlights"`
+}
+
+// GetHighlights godoc
+//
+//	@Summary		GetHighlights
+//	@Description	Gets the highlights of a link in the order they appear in its text. Orphaned highlights come last.
+//	@Param		id			path		string	true	"LinkID"
+//	@Success		200			{object}	GetHighlightsResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/highlights	[get]
+func (s *config) GetHighlights(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetHighlights")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	hh, err := s.HighlightController.GetHighlights(ctx, u, vars["linkID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetHighlightsResponse{hh}, http.StatusOK)
+}
+
+type UpdateHighlightRequest struct {
+	LinkID string  `json:"-"`
+	ID     string  `json:"-"`
+	Note   *string `json:"note" validate:"omitempty,max=10000"`
+	Color  *string `json:"color" validate:"omitempty,oneof=yellow green blue pink purple"`
+}
+
+type UpdateHighlightResponse struct {
+	Highlight *model.Highlight `json:"highlight"`
+}
+
+// UpdateHighlight godoc
+//
+//	@Summary	UpdateHighlight
+//	@Param	id						path		string					true	"LinkID"
+//	@Param	highlightId				path		string					true	"HighlightID"
+//	@Param	UpdateHighlightRequest	body		UpdateHighlightRequest	true	"All fields are optional. The quote can't be changed."
+//	@Success	200						{object}	UpdateHighlightResponse
+//	@Failure	400						{object}	payload.Error
+//	@Failure	401						{object}	payload.Error
+//	@Failure	404						{object}	payload.Error
+//	@Failure	500						{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/links/{id}/highlights/{highlightId}	[patch]
+func (s *config) UpdateHighlight(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.UpdateHighlight")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	req := new(UpdateHighlightRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	req.LinkID = vars["linkID"]
+	req.ID = vars["highlightID"]
+
+	h, err := s.HighlightController.UpdateHighlight(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &UpdateHighlightResponse{h}, http.StatusOK)
+}
+
+// DeleteHighlight godoc
+//
+//	@Summary	DeleteHighlight
+//	@Param	id			path	string	true	"LinkID"
+//	@Param	highlightId	path	string	true	"HighlightID"
+//	@Success	204
+//	@Failure	401	{object}	payload.Error
+//	@Failure	404	{object}	payload.Error
+//	@Failure	500	{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/links/{id}/highlights/{highlightId}	[delete]
+func (s *config) DeleteHighlight(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.DeleteHighlight")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	err := s.HighlightController.DeleteHighlight(ctx, u, vars["linkID"], vars["highlightID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, nil, http.StatusNoContent)
+}
+
+type GetHighlightFeedResponse struct {
+	Highlights []*model.LinkHighlight `json:"highlights"`
+}
+
+// GetHighlightFeed godoc
+//
+//	@Summary		GetHighlightFeed
+//	@Description	Gets the highlights on all of the user's links, newest first, each with the link it was made on. Highlights on links in the trash are left out.
+//	@Param		page		query		int		false	"Page"
+//	@Param		size		query		int		false	"Page size"	maximum(1000)
+//	@Success		200		{object}	GetHighlightFeedResponse
+//	@Failure		401		{object}	payload.Error
+//	@Failure		500		{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/highlights	[get]
+func (s *config) GetHighlightFeed(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetHighlightFeed")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	hh, err := s.HighlightController.GetHighlightFeed(ctx, u, model.GetPagination(r))
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetHighlightFeedResponse{hh}, http.StatusOK)
+}
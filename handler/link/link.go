@@ -3,17 +3,21 @@ package link
 import (
 	"context"
 	"html"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/linksort/linksort/errors"
 	"github.com/linksort/linksort/handler/middleware"
+	"github.com/linksort/linksort/log"
 	"github.com/linksort/linksort/model"
 	"github.com/linksort/linksort/payload"
+	"github.com/linksort/linksort/reader"
 )
 
 type Config struct {
@@ -21,9 +25,22 @@ type Config struct {
 		CreateLink(context.Context, *model.User, *CreateLinkRequest) (*model.Link, *model.User, error)
 		GetLink(context.Context, *model.User, string) (*model.Link, error)
 		GetLinks(context.Context, *model.User, *GetLinksRequest) ([]*model.Link, error)
+		GetLinkFacets(context.Context, *model.User, *GetLinksRequest) (*model.FacetCounts, error)
 		UpdateLink(context.Context, *model.User, *UpdateLinkRequest) (*model.Link, *model.User, error)
 		DeleteLink(context.Context, *model.User, string) (*model.User, error)
+		RestoreLink(context.Context, *model.User, string) (*model.Link, *model.User, error)
 		SummarizeLink(context.Context, *model.User, string) (*model.Link, error)
+		OpenLink(context.Context, *model.User, string) (*model.Link, error)
+		BatchLinks(context.Context, *model.User, *BatchLinksRequest) ([]*BatchLinkResult, *model.User, error)
+		GetLinkHistory(context.Context, *model.User, string, *model.Pagination) ([]*model.LinkRevision, error)
+		GetLinkChanges(context.Context, *model.User, string, *model.Pagination) ([]*model.LinkChange, error)
+		GetLinkReader(context.Context, *model.User, *GetLinkReaderRequest) (*reader.Article, error)
+		ExportEPUB(context.Context, *model.User, *GetLinksRequest, io.Writer) error
+		GetRelatedLinks(context.Context, *model.User, string, *model.Pagination) ([]*model.RelatedLink, error)
+		RevertLink(context.Context, *model.User, string, string) (*model.Link, *model.User, error)
+		GetEnrichmentStatus(context.Context, *model.User) (*model.EnrichmentStatus, error)
+		GetDuplicateLinks(context.Context, *model.User) ([]*model.DuplicateLinks, error)
+		MergeDuplicateLinks(context.Context, *model.User, *MergeDuplicateLinksRequest) ([]*model.Link, *model.User, error)
 	}
 	AuthController interface {
 		WithCookie(context.Context, string) (*model.User, error)
@@ -43,9 +60,21 @@ func Handler(c *Config) *mux.Router {
 	r.Use(middleware.WithUser(c.AuthController, c.CSRF))
 
 	r.HandleFunc("/api/links", cc.CreateLink).Methods("POST")
+	r.HandleFunc("/api/links/batch", cc.BatchLinks).Methods("POST")
+	r.HandleFunc("/api/links/enrichment", cc.GetEnrichmentStatus).Methods("GET")
+	r.HandleFunc("/api/links/duplicates", cc.GetDuplicateLinks).Methods("GET")
+	r.HandleFunc("/api/links/duplicates/merge", cc.MergeDuplicateLinks).Methods("POST")
+	r.HandleFunc("/api/links/epub", cc.ExportEPUB).Methods("GET")
 	r.HandleFunc("/api/links/{linkID}", cc.GetLink).Methods("GET")
 	r.HandleFunc("/api/links", cc.GetLinks).Methods("GET")
 	r.HandleFunc("/api/links/{linkID}/summarize", cc.SummarizeLink).Methods("POST")
+	r.HandleFunc("/api/links/{linkID}/restore", cc.RestoreLink).Methods("POST")
+	r.HandleFunc("/api/links/{linkID}/open", cc.OpenLink).Methods("POST")
+	r.HandleFunc("/api/links/{linkID}/history", cc.GetLinkHistory).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/changes", cc.GetLinkChanges).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/reader", cc.GetLinkReader).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/related", cc.GetRelatedLinks).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/history/{revisionID}/revert", cc.RevertLink).Methods("POST")
 	r.HandleFunc("/api/links/{linkID}", cc.UpdateLink).Methods("PATCH")
 	r.HandleFunc("/api/links/{linkID}", cc.DeleteLink).Methods("DELETE")
 
@@ -70,7 +99,7 @@ type CreateLinkResponse struct {
 // CreateLink godoc
 //
 //	@Summary		CreateLink
-//	@Description	Creates a link. Both the new link and the user are returned so that newly created tags can be seen.
+//	@Description	Creates a link. Both the new link and the user are returned so that newly created tags can be seen. If the user already has a link for the same page, such as the same URL with tracking parameters, the 400 error's 'linkId' is the ID of that link.
 //	@Param		CreateLinkRequest	body		CreateLinkRequest	true	"All fields are optional except 'url'."
 //	@Success		201					{object}	CreateLinkResponse
 //	@Failure		400					{object}	payload.Error
@@ -137,16 +166,67 @@ func (s *config) GetLink(w http.ResponseWriter, r *http.Request) {
 type GetLinksRequest struct {
 	Sort        string
 	Search      string
+	Semantic    string
 	Favorites   string
 	Annotations string
 	FolderID    string
 	TagPath     string
 	UserTag     string
-	Pagination  *model.Pagination
+	Trashed     string
+	Read        string
+	Health      string
+	Changed     string
+	// SavedSearchID is the ID of one of the user's saved searches. Its
+	// filters are used instead of the other filters.
+	SavedSearchID string
+	// Facets are the facets to count the matching links by.
+	Facets     []string
+	Pagination *model.Pagination
 }
 
 type GetLinksResponse struct {
 	Links []*model.Link `json:"links"`
+	// NextCursor is set when there may be more links after this page. Pass it
+	// back as the 'cursor' query parameter to get them.
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Facets counts all of the links that match the filters. It is only set
+	// when the 'facets' query parameter is given.
+	Facets *model.FacetCounts `json:"facets,omitempty"`
+}
+
+// linksRequest reads the filters of a request for links from its query.
+func linksRequest(q url.Values) (*GetLinksRequest, error) {
+	op := errors.Op("handler.linksRequest")
+
+	tagPath, err := url.PathUnescape(q.Get("tag"))
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest, errors.M{
+			"message": "Malformed tagPath",
+		})
+	}
+
+	userTag, err := url.PathUnescape(q.Get("usertag"))
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest, errors.M{
+			"message": "Malformed usertag",
+		})
+	}
+
+	return &GetLinksRequest{
+		Sort:          q.Get("sort"),
+		Search:        q.Get("search"),
+		Semantic:      q.Get("semantic"),
+		Favorites:     q.Get("favorite"),
+		Annotations:   q.Get("annotated"),
+		FolderID:      q.Get("folder"),
+		TagPath:       tagPath,
+		UserTag:       userTag,
+		Trashed:       q.Get("trashed"),
+		Read:          q.Get("read"),
+		Health:        q.Get("health"),
+		Changed:       q.Get("changed"),
+		SavedSearchID: q.Get("saved"),
+	}, nil
 }
 
 // GetLinks godoc
@@ -154,13 +234,21 @@ type GetLinksResponse struct {
 //	@Summary		GetLinks
 //	@Description	Gets a list of links with filters applied through the available query parameters.
 //	@Param		sort		query		string	false	"Sort, descending or ascending"		Enums(1, -1)
-//	@Param		search	query		string	false	"Search"
+//	@Param		search	query		string	false	"Search query. Words and \"quoted phrases\" are searched for in the text of links and their highlights. Operators: site:, tag:, usertag:, folder:, title:, url:, is:favorite, is:annotated, is:article, is:read, is:unread, is:inprogress, before:2024-01-01, after:2024-01-01 and created:2024-01-01..2024-03-01. Prefix a term with - to exclude it and join terms with OR to match either. Invalid queries return a 400 with the 'position' of the problem."
+//	@Param		semantic	query		string	false	"Rank links by how close they are in meaning to this text, most similar first, instead of sorting them. Combines with the other filters. Links that haven't been indexed yet are left out."
 //	@Param		favorite	query		string	false	"Only return favorites"				Enums(0, 1)
 //	@Param		annotated	query		string	false	"Only return links with annotations"	Enums(0, 1)
 //	@Param		folder	query		string	false	"Only return links from the given folder ID"
 //	@Param		tag		query		string	false	"Only return links with the given tag path"
 //	@Param		usertag	query		string	false	"Only return links with the given user tag"
-//	@Param		page		query		int		false	"Page"
+//	@Param		trashed	query		string	false	"Only return links in the trash"		Enums(0, 1)
+//	@Param		read		query		string	false	"Only return links that are unread, in progress or read. In progress links are unread ones that have been partly read."	Enums(unread, inprogress, read)
+//	@Param		health	query		string	false	"Only return links whose URL was found to be in the given state the last time it was checked. Links are checked in the background, and the result is in their 'health'."	Enums(ok, redirected, broken, unknown)
+//	@Param		changed	query		string	false	"Only return watched links whose page changed since they were last marked seen by setting 'isChanged' to false"	Enums(0, 1)
+//	@Param		saved		query		string	false	"Only return links matching the saved search with the given ID. Other filters are ignored."
+//	@Param		facets	query		string	false	"Comma-separated facets to count the matching links by: site, tag, usertag, folder, favorite and annotated. The counts are returned in 'facets'."
+//	@Param		page		query		int		false	"Page. Ignored when a cursor is given."
+//	@Param		cursor	query		string	false	"Opaque cursor from the 'nextCursor' of a previous response"
 //	@Param		size		query		int		false	"Page size"						maximum(1000)
 //	@Success		200		{object}	GetLinksResponse
 //	@Failure		400		{object}	payload.Error
@@ -174,55 +262,72 @@ func (s *config) GetLinks(w http.ResponseWriter, r *http.Request) {
 	u := middleware.UserFromContext(ctx)
 	q := r.URL.Query()
 
-	tagPath, err := url.PathUnescape(q.Get("tag"))
+	req, err := linksRequest(q)
 	if err != nil {
-		payload.WriteError(w, r, errors.E(op, err, http.StatusBadRequest, errors.M{
-			"message": "Malformed tagPath",
-		}))
+		payload.WriteError(w, r, errors.E(op, err))
 
 		return
 	}
 
-	userTag, err := url.PathUnescape(q.Get("usertag"))
-	if err != nil {
-		payload.WriteError(w, r, errors.E(op, err, http.StatusBadRequest, errors.M{
-			"message": "Malformed usertag",
-		}))
+	pagination := model.GetPagination(r)
 
-		return
+	if c := q.Get("cursor"); c != "" {
+		pagination.Cursor, err = model.ParseCursor(c)
+		if err != nil {
+			payload.WriteError(w, r, errors.E(op, err, http.StatusBadRequest, errors.M{
+				"cursor": "This is not valid.",
+			}))
+
+			return
+		}
 	}
 
-	l, err := s.LinkController.GetLinks(ctx, u, &GetLinksRequest{
-		Sort:        q.Get("sort"),
-		Search:      q.Get("search"),
-		Favorites:   q.Get("favorite"),
-		Annotations: q.Get("annotated"),
-		FolderID:    q.Get("folder"),
-		TagPath:     tagPath,
-		UserTag:     userTag,
-		Pagination:  model.GetPagination(r),
-	})
+	req.Pagination = pagination
+
+	if f := q.Get("facets"); f != "" {
+		req.Facets = strings.Split(f, ",")
+	}
+
+	l, err := s.LinkController.GetLinks(ctx, u, req)
 	if err != nil {
 		payload.WriteError(w, r, errors.E(op, err))
 
 		return
 	}
 
-	payload.Write(w, r, &GetLinksResponse{l}, http.StatusOK)
+	res := &GetLinksResponse{Links: l}
+	if len(l) > 0 && len(l) == pagination.Limit() {
+		res.NextCursor = model.NewCursor(l[len(l)-1]).Encode()
+	}
+
+	if len(req.Facets) > 0 {
+		res.Facets, err = s.LinkController.GetLinkFacets(ctx, u, req)
+		if err != nil {
+			payload.WriteError(w, r, errors.E(op, err))
+
+			return
+		}
+	}
+
+	payload.Write(w, r, res, http.StatusOK)
 }
 
 type UpdateLinkRequest struct {
-	ID          string    `json:"-"`
-	Title       *string   `json:"title" validate:"omitempty,max=512"`
-	URL         *string   `json:"url" validate:"omitempty,url,max=2048"`
-	Favicon     *string   `json:"favicon" validate:"omitempty,len=0|url,max=512"`
-	IsFavorite  *bool     `json:"isFavorite"`
-	FolderID    *string   `json:"folderId" validate:"omitempty,uuid|eq=root"`
-	Description *string   `json:"description" validate:"omitempty,max=2048"`
-	Image       *string   `json:"image" validate:"omitempty,len=0|url,max=512"`
-	Site        *string   `json:"site" validate:"omitempty,max=512"`
-	Annotation  *string   `json:"annotation"`
-	UserTags    *[]string `json:"userTags" validate:"omitempty,dive,max=64"`
+	ID           string    `json:"-"`
+	Title        *string   `json:"title" validate:"omitempty,max=512"`
+	URL          *string   `json:"url" validate:"omitempty,url,max=2048"`
+	Favicon      *string   `json:"favicon" validate:"omitempty,len=0|url,max=512"`
+	IsFavorite   *bool     `json:"isFavorite"`
+	FolderID     *string   `json:"folderId" validate:"omitempty,uuid|eq=root"`
+	Description  *string   `json:"description" validate:"omitempty,max=2048"`
+	Image        *string   `json:"image" validate:"omitempty,len=0|url,max=512"`
+	Site         *string   `json:"site" validate:"omitempty,max=512"`
+	Annotation   *string   `json:"annotation"`
+	UserTags     *[]string `json:"userTags" validate:"omitempty,dive,max=64"`
+	IsRead       *bool     `json:"isRead"`
+	ReadProgress *int      `json:"readProgress" validate:"omitempty,min=0,max=100"`
+	IsWatched    *bool     `json:"isWatched"`
+	IsChanged    *bool     `json:"isChanged"`
 }
 
 type UpdateLinkResponse struct {
@@ -234,7 +339,7 @@ type UpdateLinkResponse struct {
 //
 //	@Summary	UpdateLink
 //	@Param	id			path		string		true	"LinkID"
-//	@Param	UpdateLinkRequest	body		UpdateLinkRequest	true	"All fields are optional."
+//	@Param	UpdateLinkRequest	body		UpdateLinkRequest	true	"All fields are optional. 'readProgress' is a percentage and marks the link read at 100. Marking a link read takes it out of the reading queue. 'isWatched' turns on checking the link's page for changes once a day. Set 'isChanged' to false once a change has been seen."
 //	@Success	200					{object}	UpdateLinkResponse
 //	@Failure	400					{object}	payload.Error
 //	@Failure	401					{object}	payload.Error
@@ -290,6 +395,7 @@ type DeleteLinkResponse struct {
 // DeleteLink godoc
 //
 //	@Summary	DeleteLink
+//	@Description	Moves a link to the trash. Deleting a link that is already in the trash deletes it for good, along with its history, snapshots and page changes. Trashed links are deleted for good after 30 days.
 //	@Param	id			path		string	true	"LinkID"
 //	@Success	200					{object}	DeleteLinkResponse
 //	@Failure	400					{object}	payload.Error
@@ -315,6 +421,40 @@ func (s *config) DeleteLink(w http.ResponseWriter, r *http.Request) {
 	payload.Write(w, r, &DeleteLinkResponse{user}, http.StatusOK)
 }
 
+type RestoreLinkResponse struct {
+	Link *model.Link `json:"link"`
+	User *model.User `json:"user"`
+}
+
+// RestoreLink godoc
+//
+//	@Summary		RestoreLink
+//	@Description	Takes a link out of the trash. The user is returned so that the restored tags can be seen.
+//	@Param		id			path		string	true	"LinkID"
+//	@Success		200			{object}	RestoreLinkResponse
+//	@Failure		400			{object}	payload.Error
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/restore	[post]
+func (s *config) RestoreLink(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.RestoreLink")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+	id := vars["linkID"]
+
+	link, user, err := s.LinkController.RestoreLink(ctx, u, id)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &RestoreLinkResponse{link, user}, http.StatusOK)
+}
+
 type SummarizeLinkResponse struct {
 	Link *model.Link `json:"link"`
 }
@@ -346,6 +486,465 @@ func (s *config) SummarizeLink(w http.ResponseWriter, r *http.Request) {
 	payload.Write(w, r, &SummarizeLinkResponse{Link: link}, http.StatusOK)
 }
 
+type OpenLinkResponse struct {
+	Link *model.Link `json:"link"`
+}
+
+// OpenLink godoc
+//
+//	@Summary		OpenLink
+//	@Description	Records that the user opened the link to read it, which sets its 'lastOpenedAt'. Reading progress is set with PATCH /links/{id}.
+//	@Param		id			path		string	true	"LinkID"
+//	@Success		200			{object}	OpenLinkResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/open	[post]
+func (s *config) OpenLink(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.OpenLink")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	link, err := s.LinkController.OpenLink(ctx, u, vars["linkID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &OpenLinkResponse{link}, http.StatusOK)
+}
+
+type GetEnrichmentStatusResponse struct {
+	Status *model.EnrichmentStatus `json:"status"`
+}
+
+// GetEnrichmentStatus godoc
+//
+//	@Summary		GetEnrichmentStatus
+//	@Description	Counts the links whose metadata, such as the favicon, image, description and auto-tags, is still being fetched in the background, and those for which fetching it failed. Imported links are fetched this way.
+//	@Success		200			{object}	GetEnrichmentStatusResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/enrichment	[get]
+func (s *config) GetEnrichmentStatus(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetEnrichmentStatus")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	status, err := s.LinkController.GetEnrichmentStatus(ctx, u)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetEnrichmentStatusResponse{status}, http.StatusOK)
+}
+
+type GetDuplicateLinksResponse struct {
+	Duplicates []*model.DuplicateLinks `json:"duplicates"`
+}
+
+// GetDuplicateLinks godoc
+//
+//	@Summary		GetDuplicateLinks
+//	@Description	Lists the links that are for the same page as another link, such as ones saved before URLs were canonicalized. Links are grouped by their canonical URL, oldest first. Links in the trash are left out.
+//	@Success		200			{object}	GetDuplicateLinksResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/duplicates	[get]
+func (s *config) GetDuplicateLinks(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetDuplicateLinks")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	dupes, err := s.LinkController.GetDuplicateLinks(ctx, u)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetDuplicateLinksResponse{dupes}, http.StatusOK)
+}
+
+type MergeDuplicateLinksRequest struct {
+	CanonicalURLs []string `json:"canonicalUrls" validate:"omitempty,max=100,dive,required"`
+}
+
+type MergeDuplicateLinksResponse struct {
+	Links []*model.Link `json:"links"`
+	User  *model.User   `json:"user"`
+}
+
+// MergeDuplicateLinks godoc
+//
+//	@Summary		MergeDuplicateLinks
+//	@Description	Merges each group of duplicate links into its oldest link, which gets the user tags and annotations of the others, is a favorite if any of them is, and is moved to their folder if it isn't in one. The other links are moved to the trash. The merged links are returned. At most 100 groups are merged at a time.
+//	@Param		MergeDuplicateLinksRequest	body		MergeDuplicateLinksRequest	true	"The canonical URLs of the groups to merge. If none are given, the first 100 groups are merged."
+//	@Success		200							{object}	MergeDuplicateLinksResponse
+//	@Failure		400							{object}	payload.Error
+//	@Failure		401							{object}	payload.Error
+//	@Failure		500							{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/duplicates/merge			[post]
+func (s *config) MergeDuplicateLinks(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.MergeDuplicateLinks")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(MergeDuplicateLinksRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	links, u, err := s.LinkController.MergeDuplicateLinks(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &MergeDuplicateLinksResponse{links, u}, http.StatusOK)
+}
+
+type GetLinkHistoryResponse struct {
+	Revisions []*model.LinkRevision `json:"revisions"`
+}
+
+// GetLinkHistory godoc
+//
+//	@Summary		GetLinkHistory
+//	@Description	Gets the changes made to a link, newest first. Each revision says who made the change and the old and new value of every field that changed.
+//	@Param		id			path		string	true	"LinkID"
+//	@Param		page			query		int		false	"Page"
+//	@Param		size			query		int		false	"Page size"	maximum(1000)
+//	@Success		200			{object}	GetLinkHistoryResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/history	[get]
+func (s *config) GetLinkHistory(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetLinkHistory")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+	id := vars["linkID"]
+
+	revs, err := s.LinkController.GetLinkHistory(ctx, u, id, model.GetPagination(r))
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetLinkHistoryResponse{revs}, http.StatusOK)
+}
+
+// ExportEPUB godoc
+//
+//	@Summary		ExportEPUB
+//	@Description	Exports the links that match the filters as an EPUB book to read offline, one chapter per link with its title, site, URL and text, and its highlights and annotation as endnotes. Images in the text are embedded in the book, as many as can be fetched in 45 seconds; the rest are replaced by their alt text. The filters are the same as for listing links, except 'semantic'. At most the first 500 links are exported.
+//	@Produce		application/epub+zip
+//	@Param		sort		query		string	false	"Sort, descending or ascending"		Enums(1, -1)
+//	@Param		search	query		string	false	"Search query, as for listing links"
+//	@Param		favorite	query		string	false	"Only export favorites"				Enums(0, 1)
+//	@Param		annotated	query		string	false	"Only export links with annotations"	Enums(0, 1)
+//	@Param		folder	query		string	false	"Only export links from the given folder ID"
+//	@Param		tag		query		string	false	"Only export links with the given tag path"
+//	@Param		usertag	query		string	false	"Only export links with the given user tag"
+//	@Param		read		query		string	false	"Only export links that are unread, in progress or read"	Enums(unread, inprogress, read)
+//	@Param		saved		query		string	false	"Only export links matching the saved search with the given ID. Other filters are ignored."
+//	@Success		200
+//	@Failure		400		{object}	payload.Error
+//	@Failure		401		{object}	payload.Error
+//	@Failure		404		{object}	payload.Error
+//	@Failure		500		{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/epub	[get]
+func (s *config) ExportEPUB(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.ExportEPUB")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req, err := linksRequest(r.URL.Query())
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	book := payload.NewAttachment(w, "application/epub+zip", "linksort.epub")
+
+	if err := s.LinkController.ExportEPUB(ctx, u, req, book); err != nil {
+		if book.Started() {
+			// The book was cut short, which the client can only notice by
+			// it being unreadable.
+			log.AlarmWithContext(ctx, errors.E(op, err))
+
+			return
+		}
+
+		payload.WriteError(w, r, errors.E(op, err))
+	}
+}
+
+type GetLinkChangesResponse struct {
+	Changes []*model.LinkChange `json:"changes"`
+}
+
+// GetLinkChanges godoc
+//
+//	@Summary		GetLinkChanges
+//	@Description	Gets the changes found to the page of a watched link, newest first. Each change has a unified diff of the page's text, line by line.
+//	@Param		id			path		string	true	"LinkID"
+//	@Param		page			query		int		false	"Page"
+//	@Param		size			query		int		false	"Page size"	maximum(1000)
+//	@Success		200			{object}	GetLinkChangesResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/changes	[get]
+func (s *config) GetLinkChanges(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetLinkChanges")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+	id := vars["linkID"]
+
+	changes, err := s.LinkController.GetLinkChanges(ctx, u, id, model.GetPagination(r))
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetLinkChangesResponse{changes}, http.StatusOK)
+}
+
+type GetLinkReaderRequest struct {
+	ID     string
+	Format string
+	// ProxyImages makes the images load through the image proxy.
+	ProxyImages bool
+}
+
+type GetLinkReaderResponse struct {
+	Article *reader.Article `json:"article"`
+}
+
+// GetLinkReader godoc
+//
+//	@Summary		GetLinkReader
+//	@Description	Gets the saved text of a link's page ready to be shown to a reader, with its word count and about how many minutes it takes to read. HTML is sanitized down to an allowlist of tags and attributes, and its links and images are made absolute.
+//	@Param		id			path		string	true	"LinkID"
+//	@Param		format		query		string	false	"Format of the content. Defaults to html."	Enums(html, text, markdown)
+//	@Param		proxyImages	query		string	false	"Load images through the image proxy, where one is set up"	Enums(0, 1)
+//	@Success		200			{object}	GetLinkReaderResponse
+//	@Failure		400			{object}	payload.Error
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/reader	[get]
+func (s *config) GetLinkReader(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetLinkReader")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+	q := r.URL.Query()
+
+	article, err := s.LinkController.GetLinkReader(ctx, u, &GetLinkReaderRequest{
+		ID:          vars["linkID"],
+		Format:      q.Get("format"),
+		ProxyImages: q.Get("proxyImages") == "1",
+	})
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetLinkReaderResponse{article}, http.StatusOK)
+}
+
+type GetRelatedLinksResponse struct {
+	Links []*model.RelatedLink `json:"links"`
+}
+
+// GetRelatedLinks godoc
+//
+//	@Summary		GetRelatedLinks
+//	@Description	Gets the user's other links that are most related to a link, most related first. Links are related by similar content, shared tags and user tags, and being from the same site, and each one comes with the reasons it matched. Trashed links are left out.
+//	@Param		id			path		string	true	"LinkID"
+//	@Param		page			query		int		false	"Page"
+//	@Param		size			query		int		false	"Page size"	maximum(1000)
+//	@Success		200			{object}	GetRelatedLinksResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/related	[get]
+func (s *config) GetRelatedLinks(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetRelatedLinks")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+	id := vars["linkID"]
+
+	links, err := s.LinkController.GetRelatedLinks(ctx, u, id, model.GetPagination(r))
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetRelatedLinksResponse{links}, http.StatusOK)
+}
+
+type RevertLinkResponse struct {
+	Link *model.Link `json:"link"`
+	User *model.User `json:"user"`
+}
+
+// RevertLink godoc
+//
+//	@Summary		RevertLink
+//	@Description	Puts a link back into the state it was in before the given revision. The revert is recorded as a new revision.
+//	@Param		id			path		string	true	"LinkID"
+//	@Param		revisionId	path		string	true	"RevisionID"
+//	@Success		200			{object}	RevertLinkResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/history/{revisionId}/revert	[post]
+func (s *config) RevertLink(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.RevertLink")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	link, user, err := s.LinkController.RevertLink(ctx, u, vars["linkID"], vars["revisionID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &RevertLinkResponse{link, user}, http.StatusOK)
+}
+
+const (
+	BatchActionMove       = "move"
+	BatchActionFavorite   = "favorite"
+	BatchActionUnfavorite = "unfavorite"
+	BatchActionAddTags    = "addTags"
+	BatchActionRemoveTags = "removeTags"
+	BatchActionDelete     = "delete"
+	// BatchActionFollowRedirect moves links to the URL they were last found
+	// to redirect to.
+	BatchActionFollowRedirect = "followRedirect"
+)
+
+type BatchLinksRequest struct {
+	IDs      []string `json:"ids" validate:"required,min=1,max=500,dive,required"`
+	Action   string   `json:"action" validate:"required,oneof=move favorite unfavorite addTags removeTags delete followRedirect"`
+	FolderID string   `json:"folderId" validate:"omitempty,uuid|eq=root"`
+	UserTags []string `json:"userTags" validate:"omitempty,dive,max=64"`
+}
+
+type BatchLinkResult struct {
+	ID      string `json:"id"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type BatchLinksResponse struct {
+	Results []*BatchLinkResult `json:"results"`
+	User    *model.User        `json:"user"`
+}
+
+// BatchLinks godoc
+//
+//	@Summary		BatchLinks
+//	@Description	Applies one action to many links at once. The action is one of 'move' (requires 'folderId'), 'favorite', 'unfavorite', 'addTags' and 'removeTags' (both require 'userTags'), 'delete', or 'followRedirect', which updates the URL of links whose health is 'redirected' to the URL they redirect to. All changes are made in a single transaction. A result is returned for every given ID so that links that could not be found can be told apart.
+//	@Param		BatchLinksRequest	body		BatchLinksRequest	true	"At most 500 IDs may be given."
+//	@Success		200					{object}	BatchLinksResponse
+//	@Failure		400					{object}	payload.Error
+//	@Failure		401					{object}	payload.Error
+//	@Failure		500					{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/batch			[post]
+func (s *config) BatchLinks(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.BatchLinks")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(BatchLinksRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	switch req.Action {
+	case BatchActionMove:
+		if req.FolderID == "" {
+			payload.WriteError(w, r, errors.E(
+				op,
+				errors.Str("missing folder"),
+				http.StatusBadRequest,
+				errors.M{"folderId": "This field is required."},
+			))
+
+			return
+		}
+	case BatchActionAddTags, BatchActionRemoveTags:
+		if len(req.UserTags) == 0 {
+			payload.WriteError(w, r, errors.E(
+				op,
+				errors.Str("missing tags"),
+				http.StatusBadRequest,
+				errors.M{"userTags": "This field is required."},
+			))
+
+			return
+		}
+
+		for _, t := range req.UserTags {
+			if !tagRegex.MatchString(t) {
+				payload.WriteError(w, r, errors.E(
+					op,
+					errors.Str("invalid tag"),
+					http.StatusBadRequest,
+					errors.M{"message": "Invalid tag."},
+				))
+
+				return
+			}
+		}
+	}
+
+	res, u, err := s.LinkController.BatchLinks(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &BatchLinksResponse{res, u}, http.StatusOK)
+}
+
 // Define a regex to check if the tag is valid.
 // It must be lowercase and only have dashes as separators.
 var tagRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
@@ -0,0 +1,189 @@
+package readingqueue
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/handler/middleware"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/payload"
+)
+
+type Config struct {
+	ReadingQueueController interface {
+		GetReadingQueue(context.Context, *model.User, *model.Pagination) ([]*model.Link, error)
+		AddToReadingQueue(context.Context, *model.User, *AddToReadingQueueRequest) (*model.User, error)
+		ReorderReadingQueue(context.Context, *model.User, *ReorderReadingQueueRequest) (*model.User, error)
+		RemoveFromReadingQueue(context.Context, *model.User, string) (*model.User, error)
+	}
+	AuthController interface {
+		WithCookie(context.Context, string) (*model.User, error)
+		WithToken(context.Context, string) (*model.User, error)
+	}
+	CSRF interface {
+		VerifyUserCSRF(token string, sessionID string, expiry time.Duration) error
+	}
+}
+
+type config struct{ *Config }
+
+func Handler(c *Config) *mux.Router {
+	cc := config{Config: c}
+	r := mux.NewRouter()
+
+	r.Use(middleware.WithUser(c.AuthController, c.CSRF))
+
+	r.HandleFunc("/api/reading-queue", cc.GetReadingQueue).Methods("GET")
+	r.HandleFunc("/api/reading-queue", cc.AddToReadingQueue).Methods("POST")
+	r.HandleFunc("/api/reading-queue", cc.ReorderReadingQueue).Methods("PUT")
+	r.HandleFunc("/api/reading-queue/{linkID}", cc.RemoveFromReadingQueue).Methods("DELETE")
+
+	return r
+}
+
+type GetReadingQueueResponse struct {
+	Links []*model.Link `json:"links"`
+}
+
+// GetReadingQueue godoc
+//
+//	@Summary		GetReadingQueue
+//	@Description	Gets the links in the user's reading queue, in the order they are queued. The IDs of all of the queued links are in the user's 'readingQueue'.
+//	@Param		page		query		int		false	"Page"
+//	@Param		size		query		int		false	"Page size"	maximum(1000)
+//	@Success		200		{object}	GetReadingQueueResponse
+//	@Failure		401		{object}	payload.Error
+//	@Failure		500		{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/reading-queue	[get]
+func (s *config) GetReadingQueue(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetReadingQueue")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	links, err := s.ReadingQueueController.GetReadingQueue(ctx, u, model.GetPagination(r))
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetReadingQueueResponse{links}, http.StatusOK)
+}
+
+type AddToReadingQueueRequest struct {
+	LinkID   string `json:"linkId" validate:"required"`
+	Position *int   `json:"position" validate:"omitempty,min=0"`
+}
+
+type AddToReadingQueueResponse struct {
+	User *model.User `json:"user"`
+}
+
+// AddToReadingQueue godoc
+//
+//	@Summary		AddToReadingQueue
+//	@Description	Puts a link in the user's reading queue. Links that are already queued are moved. Links leave the queue when they are marked read or moved to the trash.
+//	@Param		AddToReadingQueueRequest	body		AddToReadingQueueRequest	true	"'position' is where in the queue to put the link, counting from 0. The link goes last when it's left out."
+//	@Success		200						{object}	AddToReadingQueueResponse
+//	@Failure		400						{object}	payload.Error
+//	@Failure		401						{object}	payload.Error
+//	@Failure		404						{object}	payload.Error
+//	@Failure		500						{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/reading-queue				[post]
+func (s *config) AddToReadingQueue(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.AddToReadingQueue")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(AddToReadingQueueRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	u, err := s.ReadingQueueController.AddToReadingQueue(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &AddToReadingQueueResponse{u}, http.StatusOK)
+}
+
+type ReorderReadingQueueRequest struct {
+	LinkIDs []string `json:"linkIds" validate:"required,max=1000"`
+}
+
+type ReorderReadingQueueResponse struct {
+	User *model.User `json:"user"`
+}
+
+// ReorderReadingQueue godoc
+//
+//	@Summary	ReorderReadingQueue
+//	@Param	ReorderReadingQueueRequest	body		ReorderReadingQueueRequest	true	"The IDs of the links in the reading queue, in their new order. Every queued link must be given exactly once."
+//	@Success	200							{object}	ReorderReadingQueueResponse
+//	@Failure	400							{object}	payload.Error
+//	@Failure	401							{object}	payload.Error
+//	@Failure	500							{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/reading-queue					[put]
+func (s *config) ReorderReadingQueue(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.ReorderReadingQueue")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(ReorderReadingQueueRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	u, err := s.ReadingQueueController.ReorderReadingQueue(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &ReorderReadingQueueResponse{u}, http.StatusOK)
+}
+
+type RemoveFromReadingQueueResponse struct {
+	User *model.User `json:"user"`
+}
+
+// RemoveFromReadingQueue godoc
+//
+//	@Summary	RemoveFromReadingQueue
+//	@Param	id				path		string	true	"LinkID"
+//	@Success	200				{object}	RemoveFromReadingQueueResponse
+//	@Failure	401				{object}	payload.Error
+//	@Failure	404				{object}	payload.Error
+//	@Failure	500				{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/reading-queue/{id}	[delete]
+func (s *config) RemoveFromReadingQueue(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.RemoveFromReadingQueue")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	u, err := s.ReadingQueueController.RemoveFromReadingQueue(ctx, u, vars["linkID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &RemoveFromReadingQueueResponse{u}, http.StatusOK)
+}
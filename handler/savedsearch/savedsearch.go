@@ -0,0 +1,168 @@
+package savedsearch
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/handler/middleware"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/payload"
+)
+
+type Config struct {
+	SavedSearchController interface {
+		CreateSavedSearch(context.Context, *model.User, *CreateSavedSearchRequest) (*model.User, error)
+		UpdateSavedSearch(context.Context, *model.User, *UpdateSavedSearchRequest) (*model.User, error)
+		DeleteSavedSearch(context.Context, *model.User, string) (*model.User, error)
+	}
+	AuthController interface {
+		WithCookie(context.Context, string) (*model.User, error)
+		WithToken(context.Context, string) (*model.User, error)
+	}
+	CSRF interface {
+		VerifyUserCSRF(token string, sessionID string, expiry time.Duration) error
+	}
+}
+
+type config struct{ *Config }
+
+func Handler(c *Config) *mux.Router {
+	cc := config{Config: c}
+	r := mux.NewRouter()
+
+	r.Use(middleware.WithUser(c.AuthController, c.CSRF))
+
+	r.HandleFunc("/api/searches", cc.CreateSavedSearch).Methods("POST")
+	r.HandleFunc("/api/searches/{searchID}", cc.UpdateSavedSearch).Methods("PUT")
+	r.HandleFunc("/api/searches/{searchID}", cc.DeleteSavedSearch).Methods("DELETE")
+
+	return r
+}
+
+type CreateSavedSearchRequest struct {
+	Name      string `json:"name" validate:"required,max=128"`
+	Search    string `json:"search" validate:"omitempty,max=512"`
+	Sort      string `json:"sort" validate:"omitempty,oneof=1 -1"`
+	Favorites bool   `json:"favorites"`
+	Annotated bool   `json:"annotated"`
+	FolderID  string `json:"folderId" validate:"omitempty,uuid"`
+	TagPath   string `json:"tagPath" validate:"omitempty,max=512"`
+	UserTag   string `json:"userTag" validate:"omitempty,max=64"`
+}
+
+type CreateSavedSearchResponse struct {
+	User *model.User `json:"user"`
+}
+
+// CreateSavedSearch godoc
+//
+//	@Summary		CreateSavedSearch
+//	@Description	Saves a set of link filters under a name. The saved search is listed in the user's 'savedSearches' and its links are returned by GET /links?saved={id}.
+//	@Param		CreateSavedSearchRequest	body		CreateSavedSearchRequest	true	"Only 'name' is required. The other fields are the same filters that GET /links takes."
+//	@Success		201						{object}	CreateSavedSearchResponse
+//	@Failure		400						{object}	payload.Error
+//	@Failure		401						{object}	payload.Error
+//	@Failure		500						{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/searches					[post]
+func (s *config) CreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.CreateSavedSearch")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(CreateSavedSearchRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	u, err := s.SavedSearchController.CreateSavedSearch(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &CreateSavedSearchResponse{u}, http.StatusCreated)
+}
+
+type UpdateSavedSearchRequest struct {
+	CreateSavedSearchRequest
+	ID string `json:"-"`
+}
+
+type UpdateSavedSearchResponse struct {
+	User *model.User `json:"user"`
+}
+
+// UpdateSavedSearch godoc
+//
+//	@Summary	UpdateSavedSearch
+//	@Param	id						path		string					true	"SavedSearchID"
+//	@Param	UpdateSavedSearchRequest	body		UpdateSavedSearchRequest	true	"Replaces the saved search's name and filters."
+//	@Success	200						{object}	UpdateSavedSearchResponse
+//	@Failure	400						{object}	payload.Error
+//	@Failure	401						{object}	payload.Error
+//	@Failure	404						{object}	payload.Error
+//	@Failure	500						{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/searches/{id}				[put]
+func (s *config) UpdateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.UpdateSavedSearch")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	req := new(UpdateSavedSearchRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	req.ID = vars["searchID"]
+
+	u, err := s.SavedSearchController.UpdateSavedSearch(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &UpdateSavedSearchResponse{u}, http.StatusOK)
+}
+
+type DeleteSavedSearchResponse struct {
+	User *model.User `json:"user"`
+}
+
+// DeleteSavedSearch godoc
+//
+//	@Summary	DeleteSavedSearch
+//	@Param	id				path		string	true	"SavedSearchID"
+//	@Success	200				{object}	DeleteSavedSearchResponse
+//	@Failure	401				{object}	payload.Error
+//	@Failure	404				{object}	payload.Error
+//	@Failure	500				{object}	payload.Error
+//	@Security	ApiKeyAuth
+//	@Router	/searches/{id}		[delete]
+func (s *config) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.DeleteSavedSearch")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	u, err := s.SavedSearchController.DeleteSavedSearch(ctx, u, vars["searchID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &DeleteSavedSearchResponse{u}, http.StatusOK)
+}
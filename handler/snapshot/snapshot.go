@@ -0,0 +1,148 @@
+package snapshot
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/handler/middleware"
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/payload"
+)
+
+type Config struct {
+	SnapshotController interface {
+		GetSnapshots(context.Context, *model.User, string, *model.Pagination) ([]*model.Snapshot, error)
+		GetSnapshot(ctx context.Context, u *model.User, linkID string, id string) (*model.Snapshot, error)
+		CreateSnapshot(context.Context, *model.User, string) (*model.Snapshot, bool, error)
+	}
+	AuthController interface {
+		WithCookie(context.Context, string) (*model.User, error)
+		WithToken(context.Context, string) (*model.User, error)
+	}
+	CSRF interface {
+		VerifyUserCSRF(token string, sessionID string, expiry time.Duration) error
+	}
+}
+
+type config struct{ *Config }
+
+func Handler(c *Config) *mux.Router {
+	cc := config{Config: c}
+	r := mux.NewRouter()
+
+	r.Use(middleware.WithUser(c.AuthController, c.CSRF))
+
+	r.HandleFunc("/api/links/{linkID}/snapshots", cc.GetSnapshots).Methods("GET")
+	r.HandleFunc("/api/links/{linkID}/snapshots", cc.CreateSnapshot).Methods("POST")
+	r.HandleFunc("/api/links/{linkID}/snapshots/{snapshotID}", cc.GetSnapshot).Methods("GET")
+
+	return r
+}
+
+type GetSnapshotsResponse struct {
+	Snapshots []*model.Snapshot `json:"snapshots"`
+}
+
+// GetSnapshots godoc
+//
+//	@Summary		GetSnapshots
+//	@Description	Gets the snapshots of a link's page, newest first, without their HTML and text. A snapshot is taken when a link is saved, and more can be taken on demand.
+//	@Param		id		path		string	true	"LinkID"
+//	@Param		page		query		int		false	"Page"
+//	@Param		size		query		int		false	"Page size"	maximum(1000)
+//	@Success		200		{object}	GetSnapshotsResponse
+//	@Failure		401		{object}	payload.Error
+//	@Failure		404		{object}	payload.Error
+//	@Failure		500		{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/snapshots	[get]
+func (s *config) GetSnapshots(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetSnapshots")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	snaps, err := s.SnapshotController.GetSnapshots(ctx, u, vars["linkID"], model.GetPagination(r))
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetSnapshotsResponse{snaps}, http.StatusOK)
+}
+
+type GetSnapshotResponse struct {
+	Snapshot *model.Snapshot `json:"snapshot"`
+}
+
+// GetSnapshot godoc
+//
+//	@Summary		GetSnapshot
+//	@Description	Gets a snapshot of a link's page with its HTML and text. The HTML has had its scripts, styles and anything else that could run or track removed, so it is safe to show.
+//	@Param		id			path		string	true	"LinkID"
+//	@Param		snapshotId	path		string	true	"SnapshotID"
+//	@Success		200			{object}	GetSnapshotResponse
+//	@Failure		401			{object}	payload.Error
+//	@Failure		404			{object}	payload.Error
+//	@Failure		500			{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/snapshots/{snapshotId}	[get]
+func (s *config) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetSnapshot")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	snap, err := s.SnapshotController.GetSnapshot(ctx, u, vars["linkID"], vars["snapshotID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &GetSnapshotResponse{snap}, http.StatusOK)
+}
+
+type CreateSnapshotResponse struct {
+	Snapshot *model.Snapshot `json:"snapshot"`
+}
+
+// CreateSnapshot godoc
+//
+//	@Summary		CreateSnapshot
+//	@Description	Takes a snapshot of a link's page now. If the page hasn't changed since the last snapshot, no new one is taken and the last one is returned with a 200 instead, without its HTML and text. Only the newest 20 snapshots of a link are kept.
+//	@Param		id		path		string	true	"LinkID"
+//	@Success		201		{object}	CreateSnapshotResponse
+//	@Success		200		{object}	CreateSnapshotResponse
+//	@Failure		400		{object}	payload.Error
+//	@Failure		401		{object}	payload.Error
+//	@Failure		404		{object}	payload.Error
+//	@Failure		500		{object}	payload.Error
+//	@Failure		502		{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/links/{id}/snapshots	[post]
+func (s *config) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.CreateSnapshot")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	vars := mux.Vars(r)
+
+	snap, created, err := s.SnapshotController.CreateSnapshot(ctx, u, vars["linkID"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	payload.Write(w, r, &CreateSnapshotResponse{snap}, status)
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
@@ -25,8 +26,14 @@ type Config struct {
 		DeleteUser(context.Context, *model.User) error
 		ForgotPassword(context.Context, *ForgotPasswordRequest) error
 		ChangePassword(context.Context, *ChangePasswordRequest) (*model.User, error)
+		UpdateDigestSchedule(context.Context, *model.User, *UpdateDigestScheduleRequest) (*model.User, error)
+		Unsubscribe(context.Context, *UnsubscribeRequest) error
 		DownloadUserData(context.Context, *model.User, io.Writer) error
 		ImportPocket(context.Context, *model.User, io.Reader) (int, error)
+		StartImport(context.Context, *model.User, string, io.Reader) (*model.ImportJob, error)
+		GetImport(context.Context, *model.User, string) (*model.ImportJob, error)
+		ExportBookmarks(context.Context, *model.User, io.Writer) error
+		ExportMarkdown(context.Context, *model.User, io.Writer) error
 	}
 	SessionController interface {
 		CreateSession(context.Context, *CreateSessionRequest) (*model.User, error)
@@ -54,6 +61,11 @@ func Handler(c *Config) *mux.Router {
 	r.HandleFunc("/api/users/sessions", cc.DeleteSession).Methods("DELETE")
 	// Allow authentication from the Safari extension
 	r.HandleFunc("/api/users/sessions", cc.CreateSession).Methods("POST")
+	// Unsubscribing uses the signed link in the email rather than a session,
+	// so it needs no CSRF token, which mail clients that unsubscribe with one
+	// click couldn't send anyway.
+	r.HandleFunc("/api/users/unsubscribe", cc.Unsubscribe).Methods("POST")
+	r.HandleFunc("/unsubscribe", cc.OneClickUnsubscribe).Methods("POST")
 
 	s := r.NewRoute().Subrouter()
 	s.Use(middleware.WithCSRF(c.CSRF))
@@ -67,8 +79,12 @@ func Handler(c *Config) *mux.Router {
 	t.HandleFunc("/api/users", cc.GetUser).Methods("GET")
 	t.HandleFunc("/api/users", cc.UpdateUser).Methods("PATCH")
 	t.HandleFunc("/api/users", cc.DeleteUser).Methods("DELETE")
+	t.HandleFunc("/api/users/digest", cc.UpdateDigestSchedule).Methods("PUT")
 	t.HandleFunc("/api/users/download", cc.DownloadUserData).Methods("GET")
 	t.HandleFunc("/api/users/import-pocket", cc.ImportPocket).Methods("POST")
+	t.HandleFunc("/api/users/import/{format}", cc.Import).Methods("POST")
+	t.HandleFunc("/api/users/imports/{id}", cc.GetImport).Methods("GET")
+	t.HandleFunc("/api/users/export-bookmarks", cc.ExportBookmarks).Methods("GET")
 
 	return r
 }
@@ -166,6 +182,91 @@ func (s *config) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	payload.Write(w, r, &ChangePasswordResponse{u}, http.StatusOK)
 }
 
+type UnsubscribeRequest struct {
+	Signature string `json:"signature" validate:"required"`
+	Timestamp string `json:"timestamp" validate:"required"`
+	Email     string `json:"email" validate:"required,email"`
+}
+
+// Unsubscribe godoc
+//
+//	@Summary		Unsubscribe
+//	@Description	Turns off the digest email without signing in. The signature, timestamp and email are the 's', 't' and 'u' query parameters of the unsubscribe link in the email.
+//	@Param		UnsubscribeRequest	body	UnsubscribeRequest	true	"All fields are required."
+//	@Success		204
+//	@Failure		400	{object}	payload.Error
+//	@Failure		401	{object}	payload.Error
+//	@Failure		500	{object}	payload.Error
+//	@Router		/users/unsubscribe	[post]
+func (s *config) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.Unsubscribe")
+	ctx := r.Context()
+
+	req := new(UnsubscribeRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	if err := s.UserController.Unsubscribe(ctx, req); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, nil, http.StatusNoContent)
+}
+
+// OneClickUnsubscribe godoc
+//
+//	@Summary		OneClickUnsubscribe
+//	@Description	Turns off the digest email when a mail client's unsubscribe button is used, as in RFC 8058. It is the unsubscribe link in the email, posted with the form body List-Unsubscribe=One-Click. It is served at /unsubscribe, outside of /api.
+//	@Accept		x-www-form-urlencoded
+//	@Param		t	query	string	true	"Timestamp"
+//	@Param		u	query	string	true	"Email"
+//	@Param		s	query	string	true	"Signature"
+//	@Param		List-Unsubscribe	formData	string	true	"One-Click"
+//	@Success		204
+//	@Failure		400	{object}	payload.Error
+//	@Failure		401	{object}	payload.Error
+//	@Failure		500	{object}	payload.Error
+//	@Router		/unsubscribe	[post]
+func (s *config) OneClickUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.OneClickUnsubscribe")
+	ctx := r.Context()
+
+	if r.PostFormValue("List-Unsubscribe") != "One-Click" {
+		payload.WriteError(w, r, errors.E(op,
+			errors.Str("not a one-click unsubscribe"),
+			errors.M{"message": "This request is not a one-click unsubscribe."},
+			http.StatusBadRequest))
+
+		return
+	}
+
+	q := r.URL.Query()
+	req := &UnsubscribeRequest{
+		Signature: q.Get("s"),
+		Timestamp: q.Get("t"),
+		Email:     q.Get("u"),
+	}
+
+	if err := payload.Valid(req); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	if err := s.UserController.Unsubscribe(ctx, req); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, nil, http.StatusNoContent)
+}
+
 type CreateSessionRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6,max=128"`
@@ -248,6 +349,50 @@ func (s *config) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	payload.Write(w, r, &UpdateUserResponse{u}, http.StatusOK)
 }
 
+type UpdateDigestScheduleRequest struct {
+	IsEnabled bool   `json:"isEnabled"`
+	Weekday   int    `json:"weekday" validate:"min=0,max=6"`
+	Hour      int    `json:"hour" validate:"min=0,max=23"`
+	TimeZone  string `json:"timeZone" validate:"max=64"`
+}
+
+type UpdateDigestScheduleResponse struct {
+	User *model.User `json:"user"`
+}
+
+// UpdateDigestSchedule godoc
+//
+//	@Summary		UpdateDigestSchedule
+//	@Description	Turns the weekly digest email on or off and sets when it is sent. The digest resurfaces unread links, old favorites, links saved on the same day in earlier years and recent highlights. It isn't sent when there is nothing in it.
+//	@Param		UpdateDigestScheduleRequest	body		UpdateDigestScheduleRequest	true	"'weekday' is 0 for Sunday to 6 for Saturday, 'hour' is 0 to 23 and 'timeZone' is an IANA time zone name such as Europe/Berlin, defaulting to UTC."
+//	@Success		200							{object}	UpdateDigestScheduleResponse
+//	@Failure		400							{object}	payload.Error
+//	@Failure		401							{object}	payload.Error
+//	@Failure		500							{object}	payload.Error
+//	@Security		ApiKeyAuth
+//	@Router		/users/digest					[put]
+func (s *config) UpdateDigestSchedule(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.UpdateDigestSchedule")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	req := new(UpdateDigestScheduleRequest)
+	if err := payload.ReadValid(req, r); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	u, err := s.UserController.UpdateDigestSchedule(ctx, u, req)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+
+		return
+	}
+
+	payload.Write(w, r, &UpdateDigestScheduleResponse{u}, http.StatusOK)
+}
+
 func (s *config) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	op := errors.Op("handler.DeleteUser")
 	ctx := r.Context()
@@ -306,11 +451,48 @@ func (s *config) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	payload.Write(w, r, nil, http.StatusNoContent)
 }
 
+// DownloadUserData godoc
+//
+//	@Summary        Download all of the user's data as a zip file
+//	@Description    The json format has the user, their links and their highlights as JSON. The markdown format has a note for each link, with YAML front matter, in directories that mirror the user's folders. Notes keep their names from one download to the next, so a download can be unzipped over an earlier one.
+//	@Param  format  query           string  false   "Format"    Enums(json, markdown)
+//	@Produce        application/zip
+//	@Success        200
+//	@Failure        400     {object}        payload.Error
+//	@Failure        401     {object}        payload.Error
+//	@Failure        500     {object}        payload.Error
+//	@Security       ApiKeyAuth
+//	@Router /users/download  [get]
 func (s *config) DownloadUserData(w http.ResponseWriter, r *http.Request) {
 	op := errors.Op("handler.DownloadUserData")
 	ctx := r.Context()
 	u := middleware.UserFromContext(ctx)
 
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+	case "markdown":
+		vault := payload.NewAttachment(w, "application/zip", "linksort-vault.zip")
+
+		if err := s.UserController.ExportMarkdown(ctx, u, vault); err != nil {
+			if vault.Started() {
+				log.AlarmWithContext(ctx, errors.E(op, err))
+
+				return
+			}
+
+			payload.WriteError(w, r, errors.E(op, err))
+		}
+
+		return
+	default:
+		payload.WriteError(w, r, errors.E(op,
+			errors.Str("unknown format"),
+			errors.M{"format": "This must be one of json and markdown."},
+			http.StatusBadRequest))
+
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", "attachment; filename=\"linksort-data.zip\"")
@@ -358,3 +540,92 @@ func (s *config) ImportPocket(w http.ResponseWriter, r *http.Request) {
 
 	payload.Write(w, r, &ImportPocketResponse{Imported: n}, http.StatusOK)
 }
+
+type ImportResponse struct {
+	Job *model.ImportJob `json:"job"`
+}
+
+// Import godoc
+//
+//	@Summary        Import links from another service's export file
+//	@Description    Folders, tags, favorites, notes and dates in the file are kept where the format has them. Links that have already been saved are skipped. The import runs in the background. Poll the returned job to follow its progress.
+//	@Param  format  path            string  true    "Export format"    Enums(netscape, raindrop, pinboard, instapaper, omnivore, pocket)
+//	@Param  file    formData        file    true    "Export file"
+//	@Success        202     {object}        ImportResponse
+//	@Failure        400     {object}        payload.Error
+//	@Failure        401     {object}        payload.Error
+//	@Failure        500     {object}        payload.Error
+//	@Security       ApiKeyAuth
+//	@Router /users/import/{format}  [post]
+func (s *config) Import(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.Import")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+	format := mux.Vars(r)["format"]
+
+	f, _, err := r.FormFile("file")
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err, http.StatusBadRequest))
+		return
+	}
+	defer f.Close()
+
+	job, err := s.UserController.StartImport(ctx, u, format, f)
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+		return
+	}
+
+	payload.Write(w, r, &ImportResponse{job}, http.StatusAccepted)
+}
+
+// GetImport godoc
+//
+//	@Summary        Get the progress of an import
+//	@Param  id      path            string  true    "Import job ID"
+//	@Success        200     {object}        ImportResponse
+//	@Failure        401     {object}        payload.Error
+//	@Failure        404     {object}        payload.Error
+//	@Failure        500     {object}        payload.Error
+//	@Security       ApiKeyAuth
+//	@Router /users/imports/{id}  [get]
+func (s *config) GetImport(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.GetImport")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	job, err := s.UserController.GetImport(ctx, u, mux.Vars(r)["id"])
+	if err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+		return
+	}
+
+	payload.Write(w, r, &ImportResponse{job}, http.StatusOK)
+}
+
+// ExportBookmarks godoc
+//
+//	@Summary        Export links as a bookmarks.html file that browsers can import
+//	@Produce        html
+//	@Success        200
+//	@Failure        401     {object}        payload.Error
+//	@Failure        500     {object}        payload.Error
+//	@Security       ApiKeyAuth
+//	@Router /users/export-bookmarks  [get]
+func (s *config) ExportBookmarks(w http.ResponseWriter, r *http.Request) {
+	op := errors.Op("handler.ExportBookmarks")
+	ctx := r.Context()
+	u := middleware.UserFromContext(ctx)
+
+	// The file is built up front so that an error can still be reported.
+	buf := new(bytes.Buffer)
+	if err := s.UserController.ExportBookmarks(ctx, u, buf); err != nil {
+		payload.WriteError(w, r, errors.E(op, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"linksort-bookmarks.html\"")
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
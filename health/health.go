@@ -0,0 +1,181 @@
+// Package health checks whether the pages that links point to are still
+// there, and whether they have moved.
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/linksort/linksort/canonical"
+	"github.com/linksort/linksort/model"
+)
+
+const (
+	defaultTimeout = 20 * time.Second
+	// userAgent is the same one the analyzer fetches pages with, since sites
+	// are more willing to answer link preview bots.
+	userAgent = "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"
+	// maxDrain is how much of a response body is read before it is closed,
+	// so that the connection can be reused.
+	maxDrain = 4 << 10
+)
+
+// parkingHosts are the sites that parked domains redirect to. A link that
+// ends up at one of them is broken, even though the page answers.
+var parkingHosts = []string{
+	"sedoparking.com",
+	"sedo.com",
+	"parkingcrew.net",
+	"bodis.com",
+	"hugedomains.com",
+	"dan.com",
+	"afternic.com",
+	"undeveloped.com",
+	"domainmarket.com",
+	"buydomains.com",
+	"above.com",
+}
+
+// Checker checks links by requesting their URLs.
+type Checker struct {
+	client *http.Client
+}
+
+func New() *Checker {
+	return &Checker{client: &http.Client{Timeout: defaultTimeout}}
+}
+
+// Check requests the URL, following redirects, and reports what it found. It
+// asks with HEAD first, and again with GET when that fails, since some
+// servers don't answer HEAD requests properly.
+func (c *Checker) Check(ctx context.Context, rawURL string) *model.LinkHealth {
+	code, final, err := c.fetch(ctx, http.MethodHead, rawURL)
+	if (err != nil && !isNoSuchHost(err)) || code >= 400 {
+		code, final, err = c.fetch(ctx, http.MethodGet, rawURL)
+	}
+
+	return classify(rawURL, code, final, err, time.Now())
+}
+
+// fetch makes the request and returns the status of the last response and
+// the URL it came from.
+func (c *Checker) fetch(ctx context.Context, method, rawURL string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxDrain))
+
+	return res.StatusCode, res.Request.URL.String(), nil
+}
+
+// classify turns the outcome of requesting a link's URL into its health.
+// Only answers that say the page is gone count as broken. Anything that may
+// pass, such as the site being down or limiting requests, is unknown.
+func classify(rawURL string, code int, final string, err error, now time.Time) *model.LinkHealth {
+	h := &model.LinkHealth{CheckedAt: now}
+
+	if err != nil {
+		h.Error = err.Error()
+		h.Status = model.HealthUnknown
+
+		if isNoSuchHost(err) {
+			h.Status = model.HealthBroken
+		}
+
+		return h
+	}
+
+	h.StatusCode = code
+	h.FinalURL = final
+
+	switch {
+	case isParked(final):
+		h.Status = model.HealthBroken
+	case code == http.StatusNotFound || code == http.StatusGone:
+		h.Status = model.HealthBroken
+	case code >= 400:
+		h.Status = model.HealthUnknown
+	case moved(rawURL, final):
+		h.Status = model.HealthRedirected
+	default:
+		h.Status = model.HealthOK
+	}
+
+	return h
+}
+
+// moved reports whether the URLs are for different pages. Redirects that only
+// change what canonicalizing a URL ignores, such as going from http to https,
+// don't count.
+func moved(from, to string) bool {
+	a, err := canonical.URL(from)
+	if err != nil {
+		return from != to
+	}
+
+	b, err := canonical.URL(to)
+	if err != nil {
+		return from != to
+	}
+
+	return a != b
+}
+
+func isParked(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	for _, p := range parkingHosts {
+		if host == p || strings.HasSuffix(host, "."+p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNoSuchHost reports whether the request failed because the URL's domain
+// doesn't exist.
+func isNoSuchHost(err error) bool {
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// TestChecker reports every link as fine, without making any requests.
+type TestChecker struct{}
+
+func NewTestChecker() *TestChecker {
+	return &TestChecker{}
+}
+
+func (c *TestChecker) Check(ctx context.Context, rawURL string) *model.LinkHealth {
+	return &model.LinkHealth{
+		Status:     model.HealthOK,
+		StatusCode: http.StatusOK,
+		FinalURL:   rawURL,
+		CheckedAt:  time.Now(),
+	}
+}
@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/linksort/linksort/model"
+)
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/slash/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		Name         string
+		GivenPath    string
+		ExpectStatus string
+		ExpectCode   int
+		ExpectFinal  string
+	}{
+		{
+			Name:         "ok",
+			GivenPath:    "/ok",
+			ExpectStatus: model.HealthOK,
+			ExpectCode:   http.StatusOK,
+			ExpectFinal:  "/ok",
+		},
+		{
+			Name:         "redirected",
+			GivenPath:    "/old",
+			ExpectStatus: model.HealthRedirected,
+			ExpectCode:   http.StatusOK,
+			ExpectFinal:  "/new",
+		},
+		{
+			Name:         "redirected to the same page",
+			GivenPath:    "/slash",
+			ExpectStatus: model.HealthOK,
+			ExpectCode:   http.StatusOK,
+			ExpectFinal:  "/slash/",
+		},
+		{
+			Name:         "not found",
+			GivenPath:    "/missing",
+			ExpectStatus: model.HealthBroken,
+			ExpectCode:   http.StatusNotFound,
+			ExpectFinal:  "/missing",
+		},
+		{
+			Name:         "gone",
+			GivenPath:    "/gone",
+			ExpectStatus: model.HealthBroken,
+			ExpectCode:   http.StatusGone,
+			ExpectFinal:  "/gone",
+		},
+		{
+			Name:         "down",
+			GivenPath:    "/down",
+			ExpectStatus: model.HealthUnknown,
+			ExpectCode:   http.StatusServiceUnavailable,
+			ExpectFinal:  "/down",
+		},
+		{
+			Name:         "HEAD not allowed",
+			GivenPath:    "/nohead",
+			ExpectStatus: model.HealthOK,
+			ExpectCode:   http.StatusOK,
+			ExpectFinal:  "/nohead",
+		},
+	}
+
+	c := New()
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			h := c.Check(context.Background(), srv.URL+tcase.GivenPath)
+
+			if h.Status != tcase.ExpectStatus {
+				t.Errorf("status: got %q, want %q", h.Status, tcase.ExpectStatus)
+			}
+
+			if h.StatusCode != tcase.ExpectCode {
+				t.Errorf("status code: got %d, want %d", h.StatusCode, tcase.ExpectCode)
+			}
+
+			if h.FinalURL != srv.URL+tcase.ExpectFinal {
+				t.Errorf("final URL: got %q, want %q", h.FinalURL, srv.URL+tcase.ExpectFinal)
+			}
+
+			if h.CheckedAt.IsZero() {
+				t.Error("expected the time of the check")
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	now := time.Now()
+	noSuchHost := &url.Error{
+		Op:  "Head",
+		URL: "https://gone.example",
+		Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "gone.example", IsNotFound: true}},
+	}
+
+	tests := []struct {
+		Name         string
+		GivenFinal   string
+		GivenCode    int
+		GivenErr     error
+		ExpectStatus string
+	}{
+		{
+			Name:         "no such host",
+			GivenErr:     noSuchHost,
+			ExpectStatus: model.HealthBroken,
+		},
+		{
+			Name:         "timeout",
+			GivenErr:     context.DeadlineExceeded,
+			ExpectStatus: model.HealthUnknown,
+		},
+		{
+			Name:         "parked",
+			GivenFinal:   "https://www.hugedomains.com/domain_profile.cfm?d=example",
+			GivenCode:    http.StatusOK,
+			ExpectStatus: model.HealthBroken,
+		},
+		{
+			Name:         "rate limited",
+			GivenFinal:   "https://example.com/posts/1",
+			GivenCode:    http.StatusTooManyRequests,
+			ExpectStatus: model.HealthUnknown,
+		},
+		{
+			Name:         "moved to https",
+			GivenFinal:   "https://example.com/posts/1",
+			GivenCode:    http.StatusOK,
+			ExpectStatus: model.HealthOK,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			h := classify("http://example.com/posts/1", tcase.GivenCode, tcase.GivenFinal, tcase.GivenErr, now)
+
+			if h.Status != tcase.ExpectStatus {
+				t.Errorf("got %q, want %q", h.Status, tcase.ExpectStatus)
+			}
+
+			if tcase.GivenErr != nil && h.Error == "" {
+				t.Error("expected the error to be recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package integ_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestExportEPUB(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	emptyUsr, _ := testutil.NewUser(t, ctx)
+	favorite := testutil.NewLink(t, ctx, usr)
+	testutil.NewLink(t, ctx, usr)
+
+	apitest.New("favorite").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", favorite.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"isFavorite": true, "annotation": "Worth rereading"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	apitest.New("export favorites").
+		Handler(testutil.Handler()).
+		Get("/api/links/epub").
+		Query("favorite", "1").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/epub+zip").
+		Assert(func(res *http.Response, _ *http.Request) error {
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				return err
+			}
+
+			files := make(map[string]string)
+			for _, f := range zr.File {
+				r, err := f.Open()
+				if err != nil {
+					return err
+				}
+
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+
+				files[f.Name] = string(data)
+			}
+
+			if zr.File[0].Name != "mimetype" || files["mimetype"] != "application/epub+zip" {
+				return fmt.Errorf("expected the book to start with its mimetype")
+			}
+
+			if _, ok := files["OEBPS/chapter-0002.xhtml"]; ok {
+				return fmt.Errorf("expected only the favorite to be exported")
+			}
+
+			if !strings.Contains(files["OEBPS/chapter-0001.xhtml"], "Worth rereading") {
+				return fmt.Errorf("expected the annotation to be in the chapter")
+			}
+
+			if !strings.Contains(files["OEBPS/content.opf"], "<dc:title>Favorites</dc:title>") {
+				return fmt.Errorf("expected the book to be named after the filter")
+			}
+
+			return nil
+		}).
+		End()
+
+	apitest.New("semantic").
+		Handler(testutil.Handler()).
+		Get("/api/links/epub").
+		Query("semantic", "rust").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"semantic":"Exports can't be ranked by meaning."}`).
+		End()
+
+	apitest.New("no links").
+		Handler(testutil.Handler()).
+		Get("/api/links/epub").
+		Cookie("session_id", emptyUsr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Body(`{"message":"There are no links to export."}`).
+		End()
+}
@@ -0,0 +1,129 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestFeeds(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	other := testutil.NewLink(t, ctx, usr)
+	favorite := testutil.NewLink(t, ctx, usr)
+
+	apitest.New("favorite a link").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", favorite.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"isFavorite": true, "annotation": "Worth rereading"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	var created struct {
+		User *model.User `json:"user"`
+	}
+
+	apitest.New("create").
+		Handler(testutil.Handler()).
+		Post("/api/feeds").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"name": "Favorites", "favorites": true}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusCreated).
+		Assert(jsonpath.Equal("$.user.feeds[0].name", "Favorites")).
+		End().
+		JSON(&created)
+
+	feed := created.User.Feeds[0]
+
+	var etag string
+
+	apitest.New("atom").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/feeds/%s/atom", feed.Token)).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/atom+xml; charset=utf-8").
+		HeaderPresent("Last-Modified").
+		Assert(func(res *http.Response, _ *http.Request) error {
+			etag = res.Header.Get("ETag")
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+
+			if !strings.Contains(string(body), favorite.URL) || !strings.Contains(string(body), "Worth rereading") {
+				return fmt.Errorf("expected the favorite and its annotation in the feed:\n%s", body)
+			}
+
+			if strings.Contains(string(body), other.URL) {
+				return fmt.Errorf("expected only favorites in the feed:\n%s", body)
+			}
+
+			return nil
+		}).
+		End()
+
+	if etag == "" {
+		t.Fatal("expected the feed to have an ETag")
+	}
+
+	apitest.New("atom not modified").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/feeds/%s/atom", feed.Token)).
+		Header("If-None-Match", etag).
+		Expect(t).
+		Status(http.StatusNotModified).
+		End()
+
+	apitest.New("rss").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/feeds/%s/rss", feed.Token)).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/rss+xml; charset=utf-8").
+		HeaderPresent("ETag").
+		End()
+
+	apitest.New("reset token").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/feeds/%s/token", feed.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.NotEqual("$.user.feeds[0].token", feed.Token)).
+		End()
+
+	apitest.New("old token").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/feeds/%s/rss", feed.Token)).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Body(`{"message": "The given feed was not found."}`).
+		End()
+
+	apitest.New("delete").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/feeds/%s", feed.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.user.feeds", 0)).
+		End()
+}
@@ -0,0 +1,120 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestLinkHealth(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	healthy := testutil.NewLink(t, ctx, usr)
+	broken := testutil.NewLink(t, ctx, usr)
+	moved := testutil.NewLink(t, ctx, usr)
+	movedToSaved := testutil.NewLink(t, ctx, usr)
+
+	testutil.SetLinkHealth(t, ctx, healthy, &model.LinkHealth{
+		Status:     model.HealthOK,
+		StatusCode: http.StatusOK,
+		FinalURL:   healthy.URL,
+		CheckedAt:  time.Now(),
+	})
+	testutil.SetLinkHealth(t, ctx, broken, &model.LinkHealth{
+		Status:     model.HealthBroken,
+		StatusCode: http.StatusNotFound,
+		FinalURL:   broken.URL,
+		CheckedAt:  time.Now(),
+	})
+	testutil.SetLinkHealth(t, ctx, moved, &model.LinkHealth{
+		Status:     model.HealthRedirected,
+		StatusCode: http.StatusOK,
+		FinalURL:   moved.URL + "/moved",
+		CheckedAt:  time.Now(),
+	})
+	testutil.SetLinkHealth(t, ctx, movedToSaved, &model.LinkHealth{
+		Status:     model.HealthRedirected,
+		StatusCode: http.StatusOK,
+		FinalURL:   healthy.URL,
+		CheckedAt:  time.Now(),
+	})
+
+	apitest.New("broken").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Query("health", "broken").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 1)).
+		Assert(jsonpath.Equal("$.links[0].id", broken.ID)).
+		Assert(jsonpath.Equal("$.links[0].health.statusCode", float64(http.StatusNotFound))).
+		End()
+
+	apitest.New("redirected").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Query("health", "redirected").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 2)).
+		End()
+
+	apitest.New("unknown status").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Query("health", "dead").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"health":"This must be one of ok, redirected, broken and unknown."}`).
+		End()
+
+	apitest.New("follow redirects").
+		Handler(testutil.Handler()).
+		Post("/api/links/batch").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{
+			"action": "followRedirect",
+			"ids":    []string{moved.ID, movedToSaved.ID, broken.ID},
+		}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.results[0].ok", true)).
+		Assert(jsonpath.Equal("$.results[1].ok", false)).
+		Assert(jsonpath.Equal("$.results[1].message", "The page the link redirects to has already been saved")).
+		Assert(jsonpath.Equal("$.results[2].ok", false)).
+		Assert(jsonpath.Equal("$.results[2].message", "The link isn't known to redirect")).
+		End()
+
+	apitest.New("moved link").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s", moved.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.url", moved.URL+"/moved")).
+		Assert(jsonpath.NotPresent("$.link.health")).
+		End()
+
+	apitest.New("history of the moved link").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/history", moved.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.revisions", 1)).
+		Assert(jsonpath.Equal("$.revisions[0].changes[0].field", "url")).
+		Assert(jsonpath.Equal("$.revisions[0].changes[0].old", moved.URL)).
+		End()
+}
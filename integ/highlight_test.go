@@ -0,0 +1,181 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestHighlights(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+	lnk := testutil.NewLink(t, ctx, usr)
+
+	// The quote is the third to fifth words of the link's text.
+	words := strings.Fields(model.CorpusText(lnk.Corpus))
+	quote := strings.Join(words[2:5], " ")
+	start := len([]rune(strings.Join(words[:2], " "))) + 1
+
+	var created struct {
+		Highlight *model.Highlight `json:"highlight"`
+	}
+
+	apitest.New("create").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/highlights", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"quote": quote, "start": start, "note": "Remember this"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusCreated).
+		Assert(jsonpath.Equal("$.highlight.quote", quote)).
+		Assert(jsonpath.Equal("$.highlight.start", float64(start))).
+		Assert(jsonpath.Equal("$.highlight.color", "yellow")).
+		Assert(jsonpath.Equal("$.highlight.isOrphaned", false)).
+		End().
+		JSON(&created)
+
+	id := created.Highlight.ID
+
+	createTests := []struct {
+		Name           string
+		GivenSessionID string
+		GivenLinkID    string
+		GivenBody      map[string]interface{}
+		ExpectStatus   int
+		ExpectBody     string
+	}{
+		{
+			Name:           "not in the text",
+			GivenSessionID: usr.SessionID,
+			GivenLinkID:    lnk.ID,
+			GivenBody:      map[string]interface{}{"quote": "not a passage of the text at all"},
+			ExpectStatus:   http.StatusBadRequest,
+			ExpectBody:     `{"quote":"This passage isn't in the link's text."}`,
+		},
+		{
+			Name:           "bad color",
+			GivenSessionID: usr.SessionID,
+			GivenLinkID:    lnk.ID,
+			GivenBody:      map[string]interface{}{"quote": quote, "color": "orange"},
+			ExpectStatus:   http.StatusBadRequest,
+		},
+		{
+			Name:           "someone else's link",
+			GivenSessionID: otherUsr.SessionID,
+			GivenLinkID:    lnk.ID,
+			GivenBody:      map[string]interface{}{"quote": quote},
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message":"The requested resource was not found"}`,
+		},
+	}
+
+	for _, tcase := range createTests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Post(fmt.Sprintf("/api/links/%s/highlights", tcase.GivenLinkID)).
+				Header("X-Csrf-Token", testutil.UserCSRF(tcase.GivenSessionID)).
+				JSON(tcase.GivenBody).
+				Cookie("session_id", tcase.GivenSessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectBody != "" {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+
+	apitest.New("update").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s/highlights/%s", lnk.ID, id)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"color": "green"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.highlight.color", "green")).
+		Assert(jsonpath.Equal("$.highlight.note", "Remember this")).
+		End()
+
+	apitest.New("list").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/highlights", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.highlights", 1)).
+		Assert(jsonpath.Equal("$.highlights[0].id", id)).
+		End()
+
+	apitest.New("on the link").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.highlights[0].id", id)).
+		End()
+
+	apitest.New("feed").
+		Handler(testutil.Handler()).
+		Get("/api/highlights").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.highlights", 1)).
+		Assert(jsonpath.Equal("$.highlights[0].highlight.id", id)).
+		Assert(jsonpath.Equal("$.highlights[0].link.id", lnk.ID)).
+		End()
+
+	apitest.New("feed of someone else").
+		Handler(testutil.Handler()).
+		Get("/api/highlights").
+		Cookie("session_id", otherUsr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.highlights", 0)).
+		End()
+
+	apitest.New("notes are searched").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Query("search", "remember").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 1)).
+		Assert(jsonpath.Equal("$.links[0].highlights[0].id", id)).
+		End()
+
+	apitest.New("delete").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/links/%s/highlights/%s", lnk.ID, id)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusNoContent).
+		End()
+
+	apitest.New("delete again").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/links/%s/highlights/%s", lnk.ID, id)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Body(`{"message":"The given highlight was not found."}`).
+		End()
+}
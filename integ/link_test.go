@@ -7,6 +7,8 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -220,6 +222,438 @@ func TestGetLinks(t *testing.T) {
 	}
 }
 
+func TestGetLinksWithCursor(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	links := make([]*model.Link, 5)
+	for i := range links {
+		links[i] = testutil.NewLink(t, ctx, usr)
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	for _, sort := range []string{"-1", "1"} {
+		t.Run(fmt.Sprintf("sort %s", sort), func(t *testing.T) {
+			expect := make([]string, 0, len(links))
+			for i := range links {
+				if sort == "-1" {
+					expect = append(expect, links[len(links)-1-i].ID)
+				} else {
+					expect = append(expect, links[i].ID)
+				}
+			}
+
+			got := make([]string, 0, len(links))
+			cursor := ""
+
+			for page := 0; page < 3; page++ {
+				query := fmt.Sprintf("/api/links?size=2&sort=%s&cursor=%s", sort, cursor)
+
+				var res struct {
+					Links      []*model.Link `json:"links"`
+					NextCursor string        `json:"nextCursor"`
+				}
+
+				apitest.New(fmt.Sprintf("page %d", page)).
+					Handler(testutil.Handler()).
+					Get(query).
+					Cookie("session_id", usr.SessionID).
+					Expect(t).
+					Status(http.StatusOK).
+					End().
+					JSON(&res)
+
+				for _, l := range res.Links {
+					got = append(got, l.ID)
+				}
+
+				cursor = res.NextCursor
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(expect) {
+				t.Fatalf("unexpected order: got %v want %v", got, expect)
+			}
+
+			if cursor != "" {
+				t.Fatalf("expected no cursor after the last page, got %q", cursor)
+			}
+		})
+	}
+
+	apitest.New("invalid cursor").
+		Handler(testutil.Handler()).
+		Get("/api/links?cursor=nonsense").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"cursor": "This is not valid."}`).
+		End()
+}
+
+func TestGetLinksWithQuery(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	lnk1 := testutil.NewLink(t, ctx, usr)
+	lnk2 := testutil.NewLink(t, ctx, usr)
+
+	host, err := url.Parse(lnk1.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apitest.New("favorite a link").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", lnk2.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]bool{"isFavorite": true}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	tests := []struct {
+		Name         string
+		GivenQuery   string
+		ExpectStatus int
+		ExpectIDs    []string
+		ExpectBody   string
+	}{
+		{
+			Name:         "site",
+			GivenQuery:   fmt.Sprintf("site:%s", host.Hostname()),
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{lnk1.ID},
+		},
+		{
+			Name:         "negated is",
+			GivenQuery:   "-is:favorite",
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{lnk1.ID},
+		},
+		{
+			Name:         "or",
+			GivenQuery:   fmt.Sprintf("is:favorite OR site:%s", host.Hostname()),
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{lnk2.ID, lnk1.ID},
+		},
+		{
+			Name:         "date range",
+			GivenQuery:   "before:2000-01-01",
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{},
+		},
+		{
+			Name:         "syntax error",
+			GivenQuery:   `is:favorite "unclosed`,
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"search": "Missing closing quote.", "position": "12"}`,
+		},
+		{
+			Name:         "unknown folder",
+			GivenQuery:   `folder:"No such folder"`,
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"search": "There is no folder \"No such folder\".", "position": "0"}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Get("/api/links").
+				Query("search", tcase.GivenQuery).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus < http.StatusBadRequest {
+				tt.Assert(jsonpath.Len("$.links", len(tcase.ExpectIDs)))
+				for i, id := range tcase.ExpectIDs {
+					tt.Assert(jsonpath.Equal(fmt.Sprintf("$.links[%d].id", i), id))
+				}
+			} else {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+}
+
+func TestGetLinksWithFacets(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	testutil.NewLink(t, ctx, usr)
+	testutil.NewLink(t, ctx, usr)
+	lnk := testutil.NewLink(t, ctx, usr)
+
+	apitest.New("favorite and tag a link").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"isFavorite": true, "userTags": []string{"reading"}}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	tests := []struct {
+		Name         string
+		GivenQuery   map[string]string
+		ExpectStatus int
+		ExpectBody   string
+		Expect       []apitest.Assert
+	}{
+		{
+			Name:         "no facets",
+			GivenQuery:   map[string]string{},
+			ExpectStatus: http.StatusOK,
+			Expect:       []apitest.Assert{jsonpath.NotPresent("$.facets")},
+		},
+		{
+			Name:         "facets",
+			GivenQuery:   map[string]string{"facets": "favorite,usertag,site,folder"},
+			ExpectStatus: http.StatusOK,
+			Expect: []apitest.Assert{
+				jsonpath.Equal("$.facets.total", float64(3)),
+				jsonpath.Equal("$.facets.counts.favorite[0].value", "false"),
+				jsonpath.Equal("$.facets.counts.favorite[0].count", float64(2)),
+				jsonpath.Equal("$.facets.counts.favorite[1].value", "true"),
+				jsonpath.Equal("$.facets.counts.usertag[0].value", "reading"),
+				jsonpath.Len("$.facets.counts.site", 3),
+				jsonpath.Equal("$.facets.counts.folder[0].value", "root"),
+				jsonpath.Equal("$.facets.counts.folder[0].count", float64(3)),
+			},
+		},
+		{
+			Name:         "facets with a filter",
+			GivenQuery:   map[string]string{"facets": "favorite", "favorite": "1"},
+			ExpectStatus: http.StatusOK,
+			Expect: []apitest.Assert{
+				jsonpath.Equal("$.facets.total", float64(1)),
+				jsonpath.Len("$.facets.counts.favorite", 1),
+			},
+		},
+		{
+			Name:         "unknown facet",
+			GivenQuery:   map[string]string{"facets": "colour"},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"facets": "\"colour\" is not a facet. Use site, tag, usertag, folder, favorite, annotated."}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Get("/api/links").
+				QueryParams(tcase.GivenQuery).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus < http.StatusBadRequest {
+				for _, assert := range tcase.Expect {
+					tt.Assert(assert)
+				}
+			} else {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+}
+
+func TestGetLinksSemantic(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+
+	// The links are saved through the API so that their text, which is what's
+	// embedded, doesn't depend on fake data.
+	ids := make([]string, 2)
+	for i, tcase := range []struct {
+		URL   string
+		Patch map[string]interface{}
+	}{
+		{"https://example.com/sourdough", map[string]interface{}{
+			"annotation": "My sourdough starter and bread recipe",
+		}},
+		{"https://example.com/kubernetes", map[string]interface{}{
+			"annotation": "Kubernetes operators for stateful databases",
+			"isFavorite": true,
+		}},
+	} {
+		var created struct {
+			Link *model.Link `json:"link"`
+		}
+
+		apitest.New("create link").
+			Handler(testutil.Handler()).
+			Post("/api/links").
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(map[string]interface{}{"url": tcase.URL}).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusCreated).
+			End().
+			JSON(&created)
+
+		apitest.New("annotate link").
+			Handler(testutil.Handler()).
+			Patch(fmt.Sprintf("/api/links/%s", created.Link.ID)).
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(tcase.Patch).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+
+		ids[i] = created.Link.ID
+	}
+
+	waitForRanking(t, usr, "sourdough bread", ids)
+
+	tests := []struct {
+		Name         string
+		GivenQuery   map[string]string
+		ExpectStatus int
+		ExpectIDs    []string
+		ExpectBody   string
+	}{
+		{
+			Name:         "ranked",
+			GivenQuery:   map[string]string{"semantic": "sourdough bread"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{ids[0], ids[1]},
+		},
+		{
+			Name:         "ranked the other way",
+			GivenQuery:   map[string]string{"semantic": "kubernetes databases"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{ids[1], ids[0]},
+		},
+		{
+			Name:         "with a filter",
+			GivenQuery:   map[string]string{"semantic": "sourdough bread", "favorite": "1"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{ids[1]},
+		},
+		{
+			Name:         "too long",
+			GivenQuery:   map[string]string{"semantic": strings.Repeat("a", 1001)},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"semantic": "This must be at most 1000 characters."}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Get("/api/links").
+				QueryParams(tcase.GivenQuery).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus < http.StatusBadRequest {
+				tt.Assert(jsonpath.Len("$.links", len(tcase.ExpectIDs)))
+				for i, id := range tcase.ExpectIDs {
+					tt.Assert(jsonpath.Equal(fmt.Sprintf("$.links[%d].id", i), id))
+				}
+			} else {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+}
+
+func TestGetRelatedLinks(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	other, _ := testutil.NewUser(t, ctx)
+	lnk := testutil.NewLinkWithURL(t, ctx, usr, "https://related.example.com/a")
+	sameSite := testutil.NewLinkWithURL(t, ctx, usr, "https://www.related.example.com/b")
+	sameTag := testutil.NewLink(t, ctx, usr)
+	unrelated := testutil.NewLink(t, ctx, usr)
+	otherLnk := testutil.NewLink(t, ctx, other)
+
+	for _, id := range []string{lnk.ID, sameTag.ID} {
+		apitest.New("tag link").
+			Handler(testutil.Handler()).
+			Patch(fmt.Sprintf("/api/links/%s", id)).
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(map[string]interface{}{"userTags": []string{"physics"}}).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+
+	tests := []struct {
+		Name         string
+		GivenLinkID  string
+		ExpectStatus int
+		ExpectIDs    []string
+		ExpectBody   string
+	}{
+		{
+			Name:         "success",
+			GivenLinkID:  lnk.ID,
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{sameTag.ID, sameSite.ID},
+		},
+		{
+			Name:         "nothing related",
+			GivenLinkID:  unrelated.ID,
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{},
+		},
+		{
+			Name:         "not found",
+			GivenLinkID:  otherLnk.ID,
+			ExpectStatus: http.StatusNotFound,
+			ExpectBody:   `{"message":"The requested resource was not found"}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Get(fmt.Sprintf("/api/links/%s/related", tcase.GivenLinkID)).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus < http.StatusBadRequest {
+				tt.Assert(jsonpath.Len("$.links", len(tcase.ExpectIDs)))
+				for i, id := range tcase.ExpectIDs {
+					tt.Assert(jsonpath.Equal(fmt.Sprintf("$.links[%d].link.id", i), id))
+				}
+			} else {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+
+	apitest.New("reasons").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/related", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.links[0].reasons[0].kind", "userTags")).
+		Assert(jsonpath.Equal("$.links[0].reasons[0].values[0]", "physics")).
+		Assert(jsonpath.Equal("$.links[1].reasons[0].kind", "site")).
+		Assert(jsonpath.Equal("$.links[1].reasons[0].values[0]", "related.example.com")).
+		End()
+}
+
 func TestUpdateLink(t *testing.T) {
 	ctx := context.Background()
 	usr, _ := testutil.NewUser(t, ctx)
@@ -415,7 +849,13 @@ func TestDeleteLink(t *testing.T) {
 			ExpectStatus:   http.StatusNotFound,
 		},
 		{
-			Name:           "success",
+			Name:           "move to trash",
+			GivenSessionID: usr1.SessionID,
+			GivenLinkID:    lnk1.ID,
+			ExpectStatus:   http.StatusOK,
+		},
+		{
+			Name:           "delete for good",
 			GivenSessionID: usr1.SessionID,
 			GivenLinkID:    lnk1.ID,
 			ExpectStatus:   http.StatusOK,
@@ -447,43 +887,574 @@ func TestDeleteLink(t *testing.T) {
 	}
 }
 
-func TestSummarizeLink(t *testing.T) {
+func TestTrash(t *testing.T) {
 	ctx := context.Background()
 	usr, _ := testutil.NewUser(t, ctx)
 	lnk := testutil.NewLink(t, ctx, usr)
 
-	tests := []struct {
-		Name           string
-		GivenSessionID string
-		GivenLinkID    string
-		ExpectStatus   int
-		ExpectBody     string
-	}{
-		{
-			Name:           "success",
-			GivenSessionID: usr.SessionID,
-			GivenLinkID:    lnk.ID,
-			ExpectStatus:   http.StatusOK,
-		},
-		{
-			Name:           "not found",
-			GivenSessionID: usr.SessionID,
-			GivenLinkID:    "non-existent-id",
-			ExpectStatus:   http.StatusNotFound,
-			ExpectBody:     `{"message":"The requested resource was not found"}`,
-		},
-		{
-			Name:           "unauthorized",
-			GivenSessionID: "invalid-session-id",
-			GivenLinkID:    lnk.ID,
-			ExpectStatus:   http.StatusUnauthorized,
-			ExpectBody:     `{"message":"Unauthorized"}`,
-		},
-	}
-
-	for _, tcase := range tests {
-		t.Run(tcase.Name, func(t *testing.T) {
-			tt := apitest.New(tcase.Name).
+	apitest.New("tag link").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"userTags": []string{"trash"}}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.user.userTags.trash", float64(1))).
+		End()
+
+	apitest.New("move to trash").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.user.userTags", 0)).
+		End()
+
+	apitest.New("left out of listings").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 0)).
+		End()
+
+	apitest.New("listed in trash").
+		Handler(testutil.Handler()).
+		Get("/api/links?trashed=1").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 1)).
+		Assert(jsonpath.Equal("$.links[0].id", lnk.ID)).
+		Assert(jsonpath.Equal("$.links[0].isTrashed", true)).
+		End()
+
+	apitest.New("can't update while trashed").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"title": "New"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+
+	var resaved struct {
+		Link *model.Link `json:"link"`
+	}
+
+	apitest.New("save the same URL again").
+		Handler(testutil.Handler()).
+		Post("/api/links").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"url": lnk.URL}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusCreated).
+		End().
+		JSON(&resaved)
+
+	apitest.New("restore conflicts with saved URL").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/restore", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(fmt.Sprintf(`{"url": "This link has already been saved.", "linkId": %q}`, resaved.Link.ID)).
+		End()
+
+	apitest.New("trash resaved link").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/links/%s", resaved.Link.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	apitest.New("restore").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/restore", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.isTrashed", false)).
+		Assert(jsonpath.Equal("$.user.userTags.trash", float64(1))).
+		End()
+
+	apitest.New("listed again").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 1)).
+		End()
+}
+
+func TestPurgeLink(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	lnk := testutil.NewLink(t, ctx, usr)
+	testutil.SetLinkChanged(t, ctx, lnk, "Pricing\nFree", "Pricing\n$5 a month")
+
+	apitest.New("move to trash").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	if len(testutil.LinkRevisions(t, ctx, lnk.ID)) == 0 {
+		t.Fatal("expected moving the link to the trash to be recorded")
+	}
+
+	apitest.New("delete for good").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	apitest.New("gone").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+
+	if revs := testutil.LinkRevisions(t, ctx, lnk.ID); len(revs) != 0 {
+		t.Errorf("expected the link's revisions to be deleted, got %d", len(revs))
+	}
+
+	if changes := testutil.LinkChanges(t, ctx, lnk.ID); len(changes) != 0 {
+		t.Errorf("expected the link's page changes to be deleted, got %d", len(changes))
+	}
+}
+
+func TestCreateDuplicateLink(t *testing.T) {
+	usr, _ := testutil.NewUser(t, context.Background())
+
+	var created struct {
+		Link *model.Link `json:"link"`
+	}
+
+	apitest.New("create").
+		Handler(testutil.Handler()).
+		Post("/api/links").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]string{"url": "https://www.example.org/dupes/story?utm_source=newsletter"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusCreated).
+		Assert(jsonpath.Equal("$.link.canonicalUrl", "https://example.org/dupes/story")).
+		End().
+		JSON(&created)
+
+	for _, given := range []string{
+		"https://www.example.org/dupes/story?utm_source=newsletter",
+		"http://example.org/dupes/story/#comments",
+		"https://example-org.cdn.ampproject.org/c/s/example.org/dupes/story/amp",
+	} {
+		apitest.New(given).
+			Handler(testutil.Handler()).
+			Post("/api/links").
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(map[string]string{"url": given}).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			Body(fmt.Sprintf(`{"url": "This link has already been saved.", "linkId": %q}`, created.Link.ID)).
+			End()
+	}
+
+	other := testutil.NewLink(t, context.Background(), usr)
+
+	apitest.New("update to a duplicate").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", other.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]string{"url": "https://example.org/dupes/story/"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(fmt.Sprintf(`{"url": "This link has already been saved.", "linkId": %q}`, created.Link.ID)).
+		End()
+}
+
+func TestMergeDuplicateLinks(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+
+	oldest := testutil.NewLinkWithURL(t, ctx, usr, "https://example.com/merge/story")
+	dupe := testutil.NewLinkWithURL(t, ctx, usr, "https://www.example.com/merge/story?utm_campaign=spring")
+	testutil.NewLink(t, ctx, usr)
+
+	apitest.New("annotate duplicate").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", dupe.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{
+			"annotation": "Read the second half.",
+			"userTags":   []string{"essays"},
+			"isFavorite": true,
+		}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	apitest.New("report").
+		Handler(testutil.Handler()).
+		Get("/api/links/duplicates").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.duplicates", 1)).
+		Assert(jsonpath.Equal("$.duplicates[0].canonicalUrl", "https://example.com/merge/story")).
+		Assert(jsonpath.Len("$.duplicates[0].links", 2)).
+		Assert(jsonpath.Equal("$.duplicates[0].links[0].id", oldest.ID)).
+		Assert(jsonpath.Equal("$.duplicates[0].links[1].id", dupe.ID)).
+		End()
+
+	apitest.New("merge").
+		Handler(testutil.Handler()).
+		Post("/api/links/duplicates/merge").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 1)).
+		Assert(jsonpath.Equal("$.links[0].id", oldest.ID)).
+		Assert(jsonpath.Equal("$.links[0].annotation", "Read the second half.")).
+		Assert(jsonpath.Equal("$.links[0].userTags", []interface{}{"essays"})).
+		Assert(jsonpath.Equal("$.links[0].isFavorite", true)).
+		Assert(jsonpath.Equal("$.user.userTags.essays", float64(1))).
+		End()
+
+	apitest.New("duplicate is trashed").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s", dupe.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.isTrashed", true)).
+		End()
+
+	apitest.New("no more duplicates").
+		Handler(testutil.Handler()).
+		Get("/api/links/duplicates").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.duplicates", 0)).
+		End()
+}
+
+func TestBatchLinks(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	other, _ := testutil.NewUser(t, ctx)
+	lnk1 := testutil.NewLink(t, ctx, usr)
+	lnk2 := testutil.NewLink(t, ctx, usr)
+	lnk3 := testutil.NewLink(t, ctx, other)
+	folder := testutil.NewFolder(t, ctx, usr, "root")
+
+	tests := []struct {
+		Name         string
+		GivenBody    map[string]interface{}
+		ExpectStatus int
+		ExpectBody   string
+		ExpectOK     []bool
+		ExpectTags   map[string]int
+	}{
+		{
+			Name: "move to folder",
+			GivenBody: map[string]interface{}{
+				"ids":      []string{lnk1.ID, lnk2.ID, lnk3.ID},
+				"action":   "move",
+				"folderId": folder.ID,
+			},
+			ExpectStatus: http.StatusOK,
+			ExpectOK:     []bool{true, true, false},
+		},
+		{
+			Name: "move to non-existent folder",
+			GivenBody: map[string]interface{}{
+				"ids":      []string{lnk1.ID},
+				"action":   "move",
+				"folderId": "3d5d1b6e-5b6c-4a3f-9bd8-6c1c0fa3b5a1",
+			},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"folderId": "This folder does not exist."}`,
+		},
+		{
+			Name: "move without folder",
+			GivenBody: map[string]interface{}{
+				"ids":    []string{lnk1.ID},
+				"action": "move",
+			},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"folderId": "This field is required."}`,
+		},
+		{
+			Name: "favorite",
+			GivenBody: map[string]interface{}{
+				"ids":    []string{lnk1.ID, lnk2.ID},
+				"action": "favorite",
+			},
+			ExpectStatus: http.StatusOK,
+			ExpectOK:     []bool{true, true},
+		},
+		{
+			Name: "add tags",
+			GivenBody: map[string]interface{}{
+				"ids":      []string{lnk1.ID, lnk2.ID},
+				"action":   "addTags",
+				"userTags": []string{"tag1", "tag2"},
+			},
+			ExpectStatus: http.StatusOK,
+			ExpectOK:     []bool{true, true},
+			ExpectTags:   map[string]int{"tag1": 2, "tag2": 2},
+		},
+		{
+			Name: "remove tags",
+			GivenBody: map[string]interface{}{
+				"ids":      []string{lnk1.ID},
+				"action":   "removeTags",
+				"userTags": []string{"tag1"},
+			},
+			ExpectStatus: http.StatusOK,
+			ExpectOK:     []bool{true},
+			ExpectTags:   map[string]int{"tag1": 1, "tag2": 2},
+		},
+		{
+			Name: "invalid tags",
+			GivenBody: map[string]interface{}{
+				"ids":      []string{lnk1.ID},
+				"action":   "addTags",
+				"userTags": []string{"$%^&**&&*--"},
+			},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"message": "Invalid tag."}`,
+		},
+		{
+			Name: "unknown action",
+			GivenBody: map[string]interface{}{
+				"ids":    []string{lnk1.ID},
+				"action": "explode",
+			},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"action": "This is not valid."}`,
+		},
+		{
+			Name: "delete",
+			GivenBody: map[string]interface{}{
+				"ids":    []string{lnk1.ID, lnk2.ID},
+				"action": "delete",
+			},
+			ExpectStatus: http.StatusOK,
+			ExpectOK:     []bool{true, true},
+			ExpectTags:   map[string]int{},
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Post("/api/links/batch").
+				Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+				JSON(tcase.GivenBody).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus < http.StatusBadRequest {
+				tt.Assert(jsonpath.Len("$.results", len(tcase.ExpectOK)))
+				for i, ok := range tcase.ExpectOK {
+					tt.Assert(jsonpath.Equal(fmt.Sprintf("$.results[%d].ok", i), ok))
+				}
+
+				if tcase.ExpectTags != nil {
+					tt.Assert(jsonpath.Len("$.user.userTags", len(tcase.ExpectTags)))
+					for tag, count := range tcase.ExpectTags {
+						tt.Assert(jsonpath.Equal(fmt.Sprintf("$.user.userTags.%s", tag), float64(count)))
+					}
+				}
+			} else {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+
+	apitest.New("links are gone").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 0)).
+		End()
+}
+
+func TestLinkHistory(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	lnk := testutil.NewLink(t, ctx, usr)
+	other := testutil.NewLink(t, ctx, usr)
+
+	patch := func(id string, body map[string]interface{}) {
+		apitest.New("update").
+			Handler(testutil.Handler()).
+			Patch(fmt.Sprintf("/api/links/%s", id)).
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(body).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+
+	patch(lnk.ID, map[string]interface{}{"title": "First"})
+	time.Sleep(time.Millisecond * 5)
+	patch(lnk.ID, map[string]interface{}{"title": "Second", "userTags": []string{"history"}})
+	patch(other.ID, map[string]interface{}{"title": "Other"})
+
+	var history struct {
+		Revisions []*model.LinkRevision `json:"revisions"`
+	}
+
+	apitest.New("get history").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/history", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.revisions", 2)).
+		Assert(jsonpath.Equal("$.revisions[0].actor", "user")).
+		Assert(jsonpath.Equal("$.revisions[0].action", "update")).
+		Assert(jsonpath.Len("$.revisions[0].changes", 2)).
+		Assert(jsonpath.Equal("$.revisions[1].changes[0].field", "title")).
+		Assert(jsonpath.Equal("$.revisions[1].changes[0].old", lnk.Title)).
+		Assert(jsonpath.Equal("$.revisions[1].changes[0].new", "First")).
+		End().
+		JSON(&history)
+
+	var otherHistory struct {
+		Revisions []*model.LinkRevision `json:"revisions"`
+	}
+
+	apitest.New("get other history").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/history", other.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End().
+		JSON(&otherHistory)
+
+	apitest.New("revert to another link's revision").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/history/%s/revert", lnk.ID, otherHistory.Revisions[0].ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+
+	apitest.New("revert").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/history/%s/revert", lnk.ID, history.Revisions[1].ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.title", lnk.Title)).
+		Assert(jsonpath.Len("$.link.userTags", 0)).
+		Assert(jsonpath.Len("$.user.userTags", 0)).
+		End()
+
+	apitest.New("revert is recorded").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/history", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.revisions", 3)).
+		Assert(jsonpath.Equal("$.revisions[0].action", "revert")).
+		Assert(jsonpath.Equal("$.revisions[0].revertedTo", history.Revisions[1].ID)).
+		End()
+}
+
+func TestGetEnrichmentStatus(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	testutil.NewLink(t, ctx, usr)
+
+	apitest.New("enrichment status").
+		Handler(testutil.Handler()).
+		Get("/api/links/enrichment").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"status": {"total": 1, "pending": 0, "failed": 0}}`).
+		End()
+}
+
+func TestSummarizeLink(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	lnk := testutil.NewLink(t, ctx, usr)
+
+	tests := []struct {
+		Name           string
+		GivenSessionID string
+		GivenLinkID    string
+		ExpectStatus   int
+		ExpectBody     string
+	}{
+		{
+			Name:           "success",
+			GivenSessionID: usr.SessionID,
+			GivenLinkID:    lnk.ID,
+			ExpectStatus:   http.StatusOK,
+		},
+		{
+			Name:           "not found",
+			GivenSessionID: usr.SessionID,
+			GivenLinkID:    "non-existent-id",
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message":"The requested resource was not found"}`,
+		},
+		{
+			Name:           "unauthorized",
+			GivenSessionID: "invalid-session-id",
+			GivenLinkID:    lnk.ID,
+			ExpectStatus:   http.StatusUnauthorized,
+			ExpectBody:     `{"message":"Unauthorized"}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
 				Handler(testutil.Handler()).
 				Post(fmt.Sprintf("/api/links/%s/summarize", tcase.GivenLinkID)).
 				Header("X-Csrf-Token", testutil.UserCSRF(tcase.GivenSessionID)).
@@ -580,3 +1551,270 @@ func TestImportPocketVariableFields(t *testing.T) {
 		Assert(jsonpath.Len("$.links", 5)).
 		End()
 }
+
+func TestImportInBackground(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+
+	csvData := "title,url,time_added,cursor,tags,status\n" +
+		"Example,https://example.com,1728576752,1,tag1|tag2,unread\n" +
+		"Second,https://second.com,1728576753,2,,unread\n"
+
+	var started struct {
+		Job *model.ImportJob `json:"job"`
+	}
+
+	apitest.New("import").
+		Handler(testutil.Handler()).
+		Intercept(func(req *http.Request) {
+			buf := new(bytes.Buffer)
+			w := multipart.NewWriter(buf)
+			part, _ := w.CreateFormFile("file", "links.csv")
+			part.Write([]byte(csvData))
+			w.Close()
+			req.Body = io.NopCloser(buf)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			req.ContentLength = int64(buf.Len())
+		}).
+		Post("/api/users/import/pocket").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusAccepted).
+		Assert(jsonpath.Equal("$.job.format", "pocket")).
+		Assert(jsonpath.Equal("$.job.total", float64(2))).
+		End().
+		JSON(&started)
+
+	job := waitForImport(t, usr, started.Job.ID)
+	if job.Status != model.ImportStatusDone || job.Imported != 2 || job.Processed != 2 {
+		t.Errorf("unexpected import job: %+v", job)
+	}
+
+	apitest.New("other users can't see the import").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/users/imports/%s", job.ID)).
+		Cookie("session_id", otherUsr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+
+	apitest.New("list").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 2)).
+		End()
+}
+
+func TestImportExportBookmarks(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+
+	html := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><H3>Reading</H3>
+    <DL><p>
+        <DT><A HREF="https://example.com/nested" ADD_DATE="1700000000" TAGS="Go,Web Dev">Nested</A>
+    </DL><p>
+    <DT><A HREF="https://example.com/root">Root</A>
+    <DT><A HREF="place:sort=8">Not a web page</A>
+</DL><p>
+`
+
+	var started struct {
+		Job *model.ImportJob `json:"job"`
+	}
+
+	apitest.New("import").
+		Handler(testutil.Handler()).
+		Intercept(func(req *http.Request) {
+			buf := new(bytes.Buffer)
+			w := multipart.NewWriter(buf)
+			part, _ := w.CreateFormFile("file", "bookmarks.html")
+			part.Write([]byte(html))
+			w.Close()
+			req.Body = io.NopCloser(buf)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			req.ContentLength = int64(buf.Len())
+		}).
+		Post("/api/users/import/netscape").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusAccepted).
+		End().
+		JSON(&started)
+
+	job := waitForImport(t, usr, started.Job.ID)
+	if job.Imported != 2 || job.Skipped != 0 || job.Failed != 1 {
+		t.Errorf("unexpected import job: %+v", job)
+	}
+
+	if len(job.Errors) != 1 || job.Errors[0].Row != 3 || job.Errors[0].URL != "place:sort=8" {
+		t.Errorf("unexpected import errors: %+v", job.Errors)
+	}
+
+	apitest.New("import again").
+		Handler(testutil.Handler()).
+		Intercept(func(req *http.Request) {
+			buf := new(bytes.Buffer)
+			w := multipart.NewWriter(buf)
+			part, _ := w.CreateFormFile("file", "bookmarks.html")
+			part.Write([]byte(html))
+			w.Close()
+			req.Body = io.NopCloser(buf)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			req.ContentLength = int64(buf.Len())
+		}).
+		Post("/api/users/import/netscape").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusAccepted).
+		End().
+		JSON(&started)
+
+	job = waitForImport(t, usr, started.Job.ID)
+	if job.Imported != 0 || job.Skipped != 2 {
+		t.Errorf("unexpected import job: %+v", job)
+	}
+
+	apitest.New("unknown format").
+		Handler(testutil.Handler()).
+		Intercept(func(req *http.Request) {
+			buf := new(bytes.Buffer)
+			w := multipart.NewWriter(buf)
+			part, _ := w.CreateFormFile("file", "bookmarks.html")
+			part.Write([]byte(html))
+			w.Close()
+			req.Body = io.NopCloser(buf)
+			req.Header.Set("Content-Type", w.FormDataContentType())
+			req.ContentLength = int64(buf.Len())
+		}).
+		Post("/api/users/import/delicious").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"format": "This format is not supported."}`).
+		End()
+
+	apitest.New("folder and tags are created").
+		Handler(testutil.Handler()).
+		Get("/api/users").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.user.folderTree.children[0].name", "Reading")).
+		Assert(jsonpath.Equal("$.user.userTags.go", float64(1))).
+		Assert(jsonpath.Equal("$.user.userTags.web-dev", float64(1))).
+		End()
+
+	apitest.New("list").
+		Handler(testutil.Handler()).
+		Get("/api/links?sort=1").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 2)).
+		Assert(jsonpath.Equal("$.links[0].url", "https://example.com/nested")).
+		Assert(jsonpath.Equal("$.links[0].createdAt", "2023-11-14T22:13:20Z")).
+		End()
+
+	apitest.New("export").
+		Handler(testutil.Handler()).
+		Get("/api/users/export-bookmarks").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "text/html; charset=utf-8").
+		Assert(func(res *http.Response, _ *http.Request) error {
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+
+			for _, want := range []string{
+				"<DT><H3>Reading</H3>",
+				`HREF="https://example.com/nested"`,
+				`TAGS="go,web-dev"`,
+				`HREF="https://example.com/root"`,
+			} {
+				if !bytes.Contains(body, []byte(want)) {
+					return fmt.Errorf("export is missing %q", want)
+				}
+			}
+
+			return nil
+		}).
+		End()
+}
+
+// waitForImport polls an import job until it has finished.
+func waitForImport(t *testing.T, usr *model.User, id string) *model.ImportJob {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		var res struct {
+			Job *model.ImportJob `json:"job"`
+		}
+
+		apitest.New("get import").
+			Handler(testutil.Handler()).
+			Get(fmt.Sprintf("/api/users/imports/%s", id)).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End().
+			JSON(&res)
+
+		if res.Job.Status == model.ImportStatusDone || res.Job.Status == model.ImportStatusFailed {
+			return res.Job
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("import %s did not finish", id)
+
+	return nil
+}
+
+// waitForRanking polls until semantic search for the query ranks the user's
+// links in the given order, which it does once they have been embedded.
+func waitForRanking(t *testing.T, usr *model.User, query string, ids []string) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		var res struct {
+			Links []*model.Link `json:"links"`
+		}
+
+		apitest.New("get links").
+			Handler(testutil.Handler()).
+			Get("/api/links").
+			Query("semantic", query).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End().
+			JSON(&res)
+
+		got := make([]string, len(res.Links))
+		for i, l := range res.Links {
+			got[i] = l.ID
+		}
+
+		if strings.Join(got, ",") == strings.Join(ids, ",") {
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("links were not ranked %v for %q", ids, query)
+}
@@ -0,0 +1,71 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestLinkReader(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+	link := testutil.NewLink(t, ctx, usr)
+	path := fmt.Sprintf("/api/links/%s/reader", link.ID)
+
+	apitest.New("html").
+		Handler(testutil.Handler()).
+		Get(path).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.article.format", "html")).
+		Assert(jsonpath.Present("$.article.content")).
+		Assert(jsonpath.Present("$.article.wordCount")).
+		Assert(jsonpath.Present("$.article.readingTime")).
+		End()
+
+	apitest.New("markdown").
+		Handler(testutil.Handler()).
+		Get(path).
+		Query("format", "markdown").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.article.format", "markdown")).
+		End()
+
+	apitest.New("unknown format").
+		Handler(testutil.Handler()).
+		Get(path).
+		Query("format", "pdf").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"format":"This must be one of html, text and markdown."}`).
+		End()
+
+	apitest.New("no image proxy").
+		Handler(testutil.Handler()).
+		Get(path).
+		Query("proxyImages", "1").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"proxyImages":"Images can't be proxied."}`).
+		End()
+
+	apitest.New("someone else's link").
+		Handler(testutil.Handler()).
+		Get(path).
+		Cookie("session_id", otherUsr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+}
@@ -0,0 +1,213 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestReadState(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	started := testutil.NewLink(t, ctx, usr)
+	finished := testutil.NewLink(t, ctx, usr)
+	untouched := testutil.NewLink(t, ctx, usr)
+
+	apitest.New("progress").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", started.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"readProgress": 40}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.readProgress", float64(40))).
+		Assert(jsonpath.Equal("$.link.isRead", false)).
+		Assert(jsonpath.Present("$.link.lastOpenedAt")).
+		End()
+
+	apitest.New("finished").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", finished.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"readProgress": 100}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.isRead", true)).
+		End()
+
+	apitest.New("opened").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/open", untouched.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Present("$.link.lastOpenedAt")).
+		Assert(jsonpath.Equal("$.link.readProgress", float64(0))).
+		End()
+
+	tests := []struct {
+		Name         string
+		GivenQuery   map[string]string
+		ExpectStatus int
+		ExpectIDs    []string
+	}{
+		{
+			Name:         "unread",
+			GivenQuery:   map[string]string{"read": "unread"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{untouched.ID, started.ID},
+		},
+		{
+			Name:         "in progress",
+			GivenQuery:   map[string]string{"read": "inprogress"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{started.ID},
+		},
+		{
+			Name:         "read",
+			GivenQuery:   map[string]string{"read": "read"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{finished.ID},
+		},
+		{
+			Name:         "search operator",
+			GivenQuery:   map[string]string{"search": "-is:read"},
+			ExpectStatus: http.StatusOK,
+			ExpectIDs:    []string{untouched.ID, started.ID},
+		},
+		{
+			Name:         "unknown read state",
+			GivenQuery:   map[string]string{"read": "skimmed"},
+			ExpectStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Get("/api/links").
+				QueryParams(tcase.GivenQuery).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus == http.StatusOK {
+				tt.Assert(jsonpath.Len("$.links", len(tcase.ExpectIDs)))
+
+				for i, id := range tcase.ExpectIDs {
+					tt.Assert(jsonpath.Equal(fmt.Sprintf("$.links[%d].id", i), id))
+				}
+			}
+
+			tt.End()
+		})
+	}
+}
+
+func TestReadingQueue(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+	first := testutil.NewLink(t, ctx, usr)
+	second := testutil.NewLink(t, ctx, usr)
+	others := testutil.NewLink(t, ctx, otherUsr)
+
+	for _, id := range []string{first.ID, second.ID} {
+		apitest.New("add").
+			Handler(testutil.Handler()).
+			Post("/api/reading-queue").
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(map[string]interface{}{"linkId": id}).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+
+	apitest.New("add someone else's link").
+		Handler(testutil.Handler()).
+		Post("/api/reading-queue").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"linkId": others.ID}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+
+	apitest.New("reorder").
+		Handler(testutil.Handler()).
+		Put("/api/reading-queue").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"linkIds": []string{second.ID, first.ID}}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.user.readingQueue[0]", second.ID)).
+		Assert(jsonpath.Equal("$.user.readingQueue[1]", first.ID)).
+		End()
+
+	apitest.New("reorder with a link left out").
+		Handler(testutil.Handler()).
+		Put("/api/reading-queue").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"linkIds": []string{first.ID}}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"linkIds":"These must be the links in your reading queue, each given once."}`).
+		End()
+
+	apitest.New("list").
+		Handler(testutil.Handler()).
+		Get("/api/reading-queue").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 2)).
+		Assert(jsonpath.Equal("$.links[0].id", second.ID)).
+		Assert(jsonpath.Equal("$.links[1].id", first.ID)).
+		End()
+
+	apitest.New("mark read").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", second.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"isRead": true}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.readProgress", float64(100))).
+		Assert(jsonpath.Len("$.user.readingQueue", 1)).
+		Assert(jsonpath.Equal("$.user.readingQueue[0]", first.ID)).
+		End()
+
+	apitest.New("remove").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/reading-queue/%s", first.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.user.readingQueue", 0)).
+		End()
+
+	apitest.New("remove again").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/reading-queue/%s", first.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		Body(`{"message":"The given link is not in the reading queue."}`).
+		End()
+}
@@ -0,0 +1,111 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestSavedSearches(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+	testutil.NewLink(t, ctx, usr)
+	favorite := testutil.NewLink(t, ctx, usr)
+
+	apitest.New("favorite a link").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", favorite.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]bool{"isFavorite": true}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	var created struct {
+		User *model.User `json:"user"`
+	}
+
+	apitest.New("create").
+		Handler(testutil.Handler()).
+		Post("/api/searches").
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"name": "Favorites", "favorites": true}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusCreated).
+		Assert(jsonpath.Equal("$.user.savedSearches[0].name", "Favorites")).
+		End().
+		JSON(&created)
+
+	searchID := created.User.SavedSearches[0].ID
+
+	tests := []struct {
+		Name           string
+		GivenSessionID string
+		GivenSearchID  string
+		ExpectStatus   int
+		ExpectBody     string
+	}{
+		{
+			Name:           "success",
+			GivenSessionID: usr.SessionID,
+			GivenSearchID:  searchID,
+			ExpectStatus:   http.StatusOK,
+		},
+		{
+			Name:           "not found",
+			GivenSessionID: usr.SessionID,
+			GivenSearchID:  "nope",
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message": "The given saved search was not found."}`,
+		},
+		{
+			Name:           "other user",
+			GivenSessionID: otherUsr.SessionID,
+			GivenSearchID:  searchID,
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message": "The given saved search was not found."}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Get("/api/links").
+				Query("saved", tcase.GivenSearchID).
+				Query("favorite", "0").
+				Cookie("session_id", tcase.GivenSessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectStatus < http.StatusBadRequest {
+				tt.Assert(jsonpath.Len("$.links", 1))
+				tt.Assert(jsonpath.Equal("$.links[0].id", favorite.ID))
+			} else {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			tt.End()
+		})
+	}
+
+	apitest.New("delete").
+		Handler(testutil.Handler()).
+		Delete(fmt.Sprintf("/api/searches/%s", searchID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.user.savedSearches", 0)).
+		End()
+}
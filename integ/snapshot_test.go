@@ -0,0 +1,121 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+	lnk := testutil.NewLink(t, ctx, usr)
+
+	var created struct {
+		Snapshot *model.Snapshot `json:"snapshot"`
+	}
+
+	apitest.New("create").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/snapshots", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusCreated).
+		Assert(jsonpath.Equal("$.snapshot.linkId", lnk.ID)).
+		Assert(jsonpath.Equal("$.snapshot.url", lnk.URL)).
+		Assert(jsonpath.Present("$.snapshot.contentHash")).
+		Assert(jsonpath.Present("$.snapshot.html")).
+		End().
+		JSON(&created)
+
+	id := created.Snapshot.ID
+
+	apitest.New("create unchanged").
+		Handler(testutil.Handler()).
+		Post(fmt.Sprintf("/api/links/%s/snapshots", lnk.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.snapshot.id", id)).
+		End()
+
+	apitest.New("list").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/snapshots", lnk.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.snapshots", 1)).
+		Assert(jsonpath.Equal("$.snapshots[0].id", id)).
+		Assert(jsonpath.NotPresent("$.snapshots[0].html")).
+		End()
+
+	apitest.New("get").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/snapshots/%s", lnk.ID, id)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.snapshot.html", created.Snapshot.HTML)).
+		Assert(jsonpath.Equal("$.snapshot.text", created.Snapshot.Text)).
+		End()
+
+	tests := []struct {
+		Name           string
+		GivenMethod    string
+		GivenPath      string
+		GivenSessionID string
+		ExpectStatus   int
+		ExpectBody     string
+	}{
+		{
+			Name:           "list someone else's",
+			GivenMethod:    http.MethodGet,
+			GivenPath:      fmt.Sprintf("/api/links/%s/snapshots", lnk.ID),
+			GivenSessionID: otherUsr.SessionID,
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message":"The requested resource was not found"}`,
+		},
+		{
+			Name:           "create someone else's",
+			GivenMethod:    http.MethodPost,
+			GivenPath:      fmt.Sprintf("/api/links/%s/snapshots", lnk.ID),
+			GivenSessionID: otherUsr.SessionID,
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message":"The requested resource was not found"}`,
+		},
+		{
+			Name:           "unknown snapshot",
+			GivenMethod:    http.MethodGet,
+			GivenPath:      fmt.Sprintf("/api/links/%s/snapshots/%s", lnk.ID, "000000000000000000000000"),
+			GivenSessionID: usr.SessionID,
+			ExpectStatus:   http.StatusNotFound,
+			ExpectBody:     `{"message":"The given snapshot was not found."}`,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Method(tcase.GivenMethod).
+				URL(tcase.GivenPath).
+				Header("X-Csrf-Token", testutil.UserCSRF(tcase.GivenSessionID)).
+				Cookie("session_id", tcase.GivenSessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus).
+				Body(tcase.ExpectBody).
+				End()
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package integ_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -415,3 +421,291 @@ func TestDownloadUserData(t *testing.T) {
 		})
 	}
 }
+
+func TestDownloadMarkdown(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	f := testutil.NewFolder(t, ctx, usr, "root")
+	inFolder := testutil.NewLink(t, ctx, usr)
+	testutil.NewLink(t, ctx, usr)
+
+	apitest.New("move to folder").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", inFolder.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"folderId": f.ID, "annotation": "Worth rereading"}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	download := func() map[string]string {
+		files := make(map[string]string)
+
+		apitest.New("download markdown").
+			Handler(testutil.Handler()).
+			Get("/api/users/download").
+			Query("format", "markdown").
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			Header("Content-Type", "application/zip").
+			Assert(func(res *http.Response, _ *http.Request) error {
+				body, err := io.ReadAll(res.Body)
+				if err != nil {
+					return err
+				}
+
+				zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+				if err != nil {
+					return err
+				}
+
+				for _, zf := range zr.File {
+					r, err := zf.Open()
+					if err != nil {
+						return err
+					}
+
+					data, err := io.ReadAll(r)
+					if err != nil {
+						return err
+					}
+
+					files[zf.Name] = string(data)
+				}
+
+				return nil
+			}).
+			End()
+
+		return files
+	}
+
+	files := download()
+	if len(files) != 2 {
+		t.Fatalf("expected a note for each link, got %d", len(files))
+	}
+
+	var note string
+	for name, data := range files {
+		if strings.HasPrefix(name, f.Name+"/") && strings.HasSuffix(name, ".md") {
+			note = data
+		}
+	}
+
+	for _, want := range []string{fmt.Sprintf("url: %q", inFolder.URL), "## Annotation\n\nWorth rereading"} {
+		if !strings.Contains(note, want) {
+			t.Errorf("expected the note in the folder's directory to contain %q:\n%s", want, note)
+		}
+	}
+
+	names := func(files map[string]string) string {
+		out := make([]string, 0, len(files))
+		for name := range files {
+			out = append(out, name)
+		}
+
+		sort.Strings(out)
+
+		return strings.Join(out, "\n")
+	}
+
+	if first, again := names(files), names(download()); first != again {
+		t.Errorf("expected the same names when downloading again, got\n%s\nthen\n%s", first, again)
+	}
+
+	apitest.New("unknown format").
+		Handler(testutil.Handler()).
+		Get("/api/users/download").
+		Query("format", "pdf").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		Body(`{"format":"This must be one of json and markdown."}`).
+		End()
+}
+
+func TestDigestSchedule(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+
+	tests := []struct {
+		Name         string
+		GivenBody    map[string]interface{}
+		ExpectStatus int
+		ExpectBody   string
+	}{
+		{
+			Name:         "unknown time zone",
+			GivenBody:    map[string]interface{}{"isEnabled": true, "weekday": 1, "hour": 9, "timeZone": "Mars/Olympus"},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectBody:   `{"timeZone":"This is not a known time zone."}`,
+		},
+		{
+			Name:         "bad hour",
+			GivenBody:    map[string]interface{}{"isEnabled": true, "weekday": 1, "hour": 24},
+			ExpectStatus: http.StatusBadRequest,
+		},
+		{
+			Name:         "success",
+			GivenBody:    map[string]interface{}{"isEnabled": true, "weekday": 1, "hour": 9, "timeZone": "Europe/Berlin"},
+			ExpectStatus: http.StatusOK,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			tt := apitest.New(tcase.Name).
+				Handler(testutil.Handler()).
+				Put("/api/users/digest").
+				Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+				JSON(tcase.GivenBody).
+				Cookie("session_id", usr.SessionID).
+				Expect(t).
+				Status(tcase.ExpectStatus)
+
+			if tcase.ExpectBody != "" {
+				tt.Body(tcase.ExpectBody)
+			}
+
+			if tcase.ExpectStatus == http.StatusOK {
+				tt.Assert(jsonpath.Equal("$.user.digest.isEnabled", true)).
+					Assert(jsonpath.Equal("$.user.digest.timeZone", "Europe/Berlin")).
+					Assert(jsonpath.Present("$.user.digest.nextSendAt"))
+			}
+
+			tt.End()
+		})
+	}
+
+	link := testutil.Magic(t).Link("unsubscribe", usr.Email, "digest-unsubscribe")
+
+	mlink, err := url.Parse(link)
+	if err != nil {
+		t.Error(err)
+	}
+
+	apitest.New("unsubscribe with a bad signature").
+		Handler(testutil.Handler()).
+		Post("/api/users/unsubscribe").
+		Header("X-Csrf-Token", testutil.CSRF()).
+		JSON(map[string]string{
+			"signature": "abcdefghijklmnopqustuvxxyz",
+			"timestamp": mlink.Query().Get("t"),
+			"email":     mlink.Query().Get("u"),
+		}).
+		Expect(t).
+		Status(http.StatusUnauthorized).
+		End()
+
+	apitest.New("unsubscribe").
+		Handler(testutil.Handler()).
+		Post("/api/users/unsubscribe").
+		Header("X-Csrf-Token", testutil.CSRF()).
+		JSON(map[string]string{
+			"signature": mlink.Query().Get("s"),
+			"timestamp": mlink.Query().Get("t"),
+			"email":     mlink.Query().Get("u"),
+		}).
+		Expect(t).
+		Status(http.StatusNoContent).
+		End()
+
+	apitest.New("unsubscribed").
+		Handler(testutil.Handler()).
+		Get("/api/users").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.user.digest.isEnabled", false)).
+		Assert(jsonpath.NotPresent("$.user.digest.nextSendAt")).
+		End()
+}
+
+func TestUnsubscribeFromEmail(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+
+	enable := func(t *testing.T) {
+		apitest.New("enable digest").
+			Handler(testutil.Handler()).
+			Put("/api/users/digest").
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(map[string]interface{}{"isEnabled": true, "weekday": 1, "hour": 9}).
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+
+	disabled := func(t *testing.T) {
+		apitest.New("unsubscribed").
+			Handler(testutil.Handler()).
+			Get("/api/users").
+			Cookie("session_id", usr.SessionID).
+			Expect(t).
+			Status(http.StatusOK).
+			Assert(jsonpath.Equal("$.user.digest.isEnabled", false)).
+			End()
+	}
+
+	link, err := url.Parse(testutil.Digest(t, ctx, usr).UnsubscribeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if link.Path != "/unsubscribe" {
+		t.Fatalf("expected the link to go to /unsubscribe, got %q", link)
+	}
+
+	query := map[string]string{
+		"t": link.Query().Get("t"),
+		"u": link.Query().Get("u"),
+		"s": link.Query().Get("s"),
+	}
+
+	t.Run("one click", func(t *testing.T) {
+		enable(t)
+
+		apitest.New("without the one-click body").
+			Handler(testutil.Handler()).
+			Post(link.Path).
+			QueryParams(query).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			End()
+
+		apitest.New("one click").
+			Handler(testutil.Handler()).
+			Post(link.Path).
+			QueryParams(query).
+			FormData("List-Unsubscribe", "One-Click").
+			Expect(t).
+			Status(http.StatusNoContent).
+			End()
+
+		disabled(t)
+	})
+
+	t.Run("unsubscribe page", func(t *testing.T) {
+		enable(t)
+
+		// The page posts the link's parameters with the CSRF token of whoever
+		// is signed in, which the endpoint doesn't need.
+		apitest.New("unsubscribe").
+			Handler(testutil.Handler()).
+			Post("/api/users/unsubscribe").
+			Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+			JSON(map[string]string{
+				"signature": query["s"],
+				"timestamp": query["t"],
+				"email":     query["u"],
+			}).
+			Expect(t).
+			Status(http.StatusNoContent).
+			End()
+
+		disabled(t)
+	})
+}
@@ -0,0 +1,89 @@
+package integ_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/steinfletcher/apitest"
+	jsonpath "github.com/steinfletcher/apitest-jsonpath"
+
+	"github.com/linksort/linksort/testutil"
+)
+
+func TestWatchLink(t *testing.T) {
+	ctx := context.Background()
+	usr, _ := testutil.NewUser(t, ctx)
+	watched := testutil.NewLink(t, ctx, usr)
+	changed := testutil.NewLink(t, ctx, usr)
+	otherUsr, _ := testutil.NewUser(t, ctx)
+
+	apitest.New("watch").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", watched.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"isWatched": true}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.isWatched", true)).
+		Assert(jsonpath.Equal("$.link.isChanged", false)).
+		End()
+
+	c := testutil.SetLinkChanged(t, ctx, changed, "Pricing\nFree\n", "Pricing\n$5 a month\n")
+
+	apitest.New("changed").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Query("changed", "1").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 1)).
+		Assert(jsonpath.Equal("$.links[0].id", changed.ID)).
+		Assert(jsonpath.Present("$.links[0].lastChangedAt")).
+		End()
+
+	apitest.New("changes").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/changes", changed.ID)).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.changes", 1)).
+		Assert(jsonpath.Equal("$.changes[0].diff", c.Diff)).
+		Assert(jsonpath.Equal("$.changes[0].added", float64(1))).
+		Assert(jsonpath.Equal("$.changes[0].removed", float64(1))).
+		End()
+
+	apitest.New("someone else's changes").
+		Handler(testutil.Handler()).
+		Get(fmt.Sprintf("/api/links/%s/changes", changed.ID)).
+		Cookie("session_id", otherUsr.SessionID).
+		Expect(t).
+		Status(http.StatusNotFound).
+		End()
+
+	apitest.New("mark seen").
+		Handler(testutil.Handler()).
+		Patch(fmt.Sprintf("/api/links/%s", changed.ID)).
+		Header("X-Csrf-Token", testutil.UserCSRF(usr.SessionID)).
+		JSON(map[string]interface{}{"isChanged": false}).
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Equal("$.link.isChanged", false)).
+		Assert(jsonpath.Equal("$.link.isWatched", true)).
+		End()
+
+	apitest.New("none changed").
+		Handler(testutil.Handler()).
+		Get("/api/links").
+		Query("changed", "1").
+		Cookie("session_id", usr.SessionID).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(jsonpath.Len("$.links", 0)).
+		End()
+}
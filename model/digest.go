@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// DigestSchedule is when the user is sent the digest email, which resurfaces
+// links they saved and forgot about. Users opt in to it.
+type DigestSchedule struct {
+	IsEnabled bool         `json:"isEnabled"`
+	Weekday   time.Weekday `json:"weekday"`
+	// Hour is the hour of the day the digest is sent, in TimeZone.
+	Hour int `json:"hour"`
+	// TimeZone is an IANA time zone name, such as Europe/Berlin. UTC is used
+	// when it's empty.
+	TimeZone   string     `json:"timeZone"`
+	LastSentAt *time.Time `json:"lastSentAt,omitempty" bson:"lastsentat,omitempty"`
+	// NextSendAt is when the digest is due. It is only set while the digest
+	// is enabled.
+	NextSendAt *time.Time `json:"nextSendAt,omitempty" bson:"nextsendat,omitempty"`
+}
+
+// Next returns the first time after t that the digest is scheduled for.
+func (s *DigestSchedule) Next(t time.Time) time.Time {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		// Time zones are checked when the schedule is set, so this only
+		// happens if one is removed from the time zone database.
+		loc = time.UTC
+	}
+
+	local := t.In(loc)
+	days := (int(s.Weekday) - int(local.Weekday()) + 7) % 7
+	next := time.Date(local.Year(), local.Month(), local.Day()+days, s.Hour, 0, 0, 0, loc)
+
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 7)
+	}
+
+	return next
+}
+
+// Digest is what a digest email resurfaces.
+type Digest struct {
+	// Unread links have been waiting the longest to be read.
+	Unread []*Link
+	// Favorites are links the user favorited a long time ago.
+	Favorites []*Link
+	// OnThisDay are links saved around this time of year in earlier years.
+	OnThisDay []*Link
+	// Highlights are the ones made since the last digest.
+	Highlights []*LinkHighlight
+	// UnsubscribeURL turns the digest off without signing in.
+	UnsubscribeURL string
+}
+
+func (d *Digest) IsEmpty() bool {
+	return len(d.Unread) == 0 && len(d.Favorites) == 0 && len(d.OnThisDay) == 0 && len(d.Highlights) == 0
+}
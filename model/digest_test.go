@@ -0,0 +1,56 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	// 2024-03-06 was a Wednesday.
+	wednesday := time.Date(2024, 3, 6, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name   string
+		Given  DigestSchedule
+		Expect time.Time
+	}{
+		{
+			Name:   "later this week",
+			Given:  DigestSchedule{Weekday: time.Friday, Hour: 8},
+			Expect: time.Date(2024, 3, 8, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:   "later today",
+			Given:  DigestSchedule{Weekday: time.Wednesday, Hour: 18},
+			Expect: time.Date(2024, 3, 6, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:   "earlier today",
+			Given:  DigestSchedule{Weekday: time.Wednesday, Hour: 9},
+			Expect: time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:   "next week",
+			Given:  DigestSchedule{Weekday: time.Monday, Hour: 0},
+			Expect: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:   "time zone",
+			Given:  DigestSchedule{Weekday: time.Wednesday, Hour: 11, TimeZone: "Europe/Berlin"},
+			Expect: time.Date(2024, 3, 13, 11, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			if got := tcase.Given.Next(wednesday); !got.Equal(tcase.Expect) {
+				t.Errorf("got %s, want %s", got, tcase.Expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/linksort/linksort/random"
+)
+
+// Feed is an Atom and RSS feed of the links in a folder, with a tag or user
+// tag, or that are favorites. Feed readers can't sign in, so a feed is read
+// with its Token instead, which anyone who has it can use until it is reset.
+type Feed struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token"`
+	Favorites bool      `json:"favorites"`
+	FolderID  string    `json:"folderId"`
+	TagPath   string    `json:"tagPath"`
+	UserTag   string    `json:"userTag"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Feeds []*Feed
+
+func NewFeed(name string) *Feed {
+	return &Feed{
+		ID:        random.UUID(),
+		Name:      name,
+		Token:     random.URLToken(),
+		CreatedAt: time.Now(),
+	}
+}
+
+// ResetToken gives the feed a new token, so that the old one no longer reads
+// it.
+func (f *Feed) ResetToken() {
+	f.Token = random.URLToken()
+}
+
+func (s Feeds) Find(id string) *Feed {
+	for _, feed := range s {
+		if feed.ID == id {
+			return feed
+		}
+	}
+
+	return nil
+}
+
+func (s Feeds) FindByToken(token string) *Feed {
+	for _, feed := range s {
+		if feed.Token == token {
+			return feed
+		}
+	}
+
+	return nil
+}
+
+// Remove returns the feeds without the one with the given ID, and the one
+// that was removed or nil if there wasn't one.
+func (s Feeds) Remove(id string) (Feeds, *Feed) {
+	for i, feed := range s {
+		if feed.ID == id {
+			return append(s[:i:i], s[i+1:]...), feed
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// The health statuses of a link, which say what was found the last time its
+// URL was checked.
+const (
+	// HealthOK is for links whose page was found where they point.
+	HealthOK = "ok"
+	// HealthRedirected is for links whose page was found after being
+	// redirected to a different URL.
+	HealthRedirected = "redirected"
+	// HealthBroken is for links whose page is gone, whose site no longer
+	// exists or whose domain has been parked.
+	HealthBroken = "broken"
+	// HealthUnknown is for links that couldn't be checked, such as when
+	// their site was down or refused to answer.
+	HealthUnknown = "unknown"
+)
+
+// HealthStatuses lists the health statuses that links can be filtered by.
+var HealthStatuses = []string{HealthOK, HealthRedirected, HealthBroken, HealthUnknown}
+
+// LinkHealth is what was found the last time a link's URL was checked.
+type LinkHealth struct {
+	Status string `json:"status"`
+	// StatusCode is the HTTP status of the last response, after following
+	// redirects. It is 0 when there was no response.
+	StatusCode int `json:"statusCode"`
+	// FinalURL is the URL that was reached after following redirects.
+	FinalURL string `json:"finalUrl"`
+	// Error says why there was no response, if there wasn't one.
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// IsHealthStatus reports whether s is one of the health statuses.
+func IsHealthStatus(s string) bool {
+	for _, status := range HealthStatuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
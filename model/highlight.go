@@ -0,0 +1,206 @@
+package model
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+
+	"github.com/linksort/linksort/random"
+)
+
+// HighlightColors are the colors a highlight can be. The first is the default.
+var HighlightColors = []string{"yellow", "green", "blue", "pink", "purple"}
+
+// highlightContextLength is how much of the text on either side of a
+// highlight's quote is kept, in characters.
+const highlightContextLength = 32
+
+// Highlight is a passage of a link's text that the user marked, with an
+// optional note.
+type Highlight struct {
+	ID    string `json:"id"`
+	Quote string `json:"quote"`
+	// Start and End are where the quote is in the link's text, as offsets in
+	// characters. The text is the link's corpus with its markup removed and
+	// its whitespace collapsed.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Prefix and Suffix are the text just before and after the quote. They
+	// tell apart the places a quote could be when the text changes.
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+	// IsOrphaned is set when the quote is no longer in the link's text.
+	IsOrphaned bool      `json:"isOrphaned"`
+	Note       string    `json:"note"`
+	Color      string    `json:"color"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func NewHighlight(quote string, start int) *Highlight {
+	now := time.Now()
+
+	return &Highlight{
+		ID:        random.UUID(),
+		Quote:     strings.Join(strings.Fields(quote), " "),
+		Start:     start,
+		Color:     HighlightColors[0],
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Anchor finds the highlight's quote in the link's text and updates where it
+// is. When the quote is in the text more than once, the place whose
+// surroundings best match the highlight's prefix and suffix wins, and then
+// the one nearest to where the quote was. It reports whether the quote was
+// found, and marks the highlight as orphaned if it wasn't.
+func (h *Highlight) Anchor(text string) bool {
+	if h.Quote == "" {
+		h.IsOrphaned = true
+		return false
+	}
+
+	// best is the byte offset of the best place so far and bestPos its
+	// offset in characters.
+	best, bestPos, bestScore, bestDistance := -1, 0, -1, 0
+
+	// pos is the offset in characters of the byte offset from.
+	pos, from := 0, 0
+
+	for {
+		i := strings.Index(text[from:], h.Quote)
+		if i < 0 {
+			break
+		}
+
+		pos += utf8.RuneCountInString(text[from : from+i])
+		from += i
+
+		score := commonSuffixLength(text[:from], h.Prefix) +
+			commonPrefixLength(text[from+len(h.Quote):], h.Suffix)
+
+		distance := pos - h.Start
+		if distance < 0 {
+			distance = -distance
+		}
+
+		if score > bestScore || (score == bestScore && distance < bestDistance) {
+			best, bestPos, bestScore, bestDistance = from, pos, score, distance
+		}
+
+		// Overlapping places are looked at too.
+		_, size := utf8.DecodeRuneInString(text[from:])
+		from += size
+		pos++
+	}
+
+	if best < 0 {
+		h.IsOrphaned = true
+		return false
+	}
+
+	h.Start = bestPos
+	h.End = bestPos + utf8.RuneCountInString(h.Quote)
+	h.Prefix = lastRunes(text[:best], highlightContextLength)
+	h.Suffix = firstRunes(text[best+len(h.Quote):], highlightContextLength)
+	h.IsOrphaned = false
+
+	return true
+}
+
+func commonPrefixLength(a, b string) int {
+	n := 0
+
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+func commonSuffixLength(a, b string) int {
+	n := 0
+
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+
+	return n
+}
+
+func firstRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+
+		n--
+	}
+
+	return s
+}
+
+func lastRunes(s string, n int) string {
+	for i := len(s); i > 0; {
+		if n == 0 {
+			return s[i:]
+		}
+
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+		n--
+	}
+
+	return s
+}
+
+// CorpusText returns the text of a link's corpus with its markup removed and
+// its whitespace collapsed, which is the text that highlights are anchored in.
+func CorpusText(corpus string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(bluemonday.StrictPolicy().Sanitize(corpus))), " ")
+}
+
+// ReanchorHighlights finds the link's highlights again after its corpus
+// changed.
+func (l *Link) ReanchorHighlights() {
+	if len(l.Highlights) == 0 {
+		return
+	}
+
+	text := CorpusText(l.Corpus)
+	for _, h := range l.Highlights {
+		h.Anchor(text)
+	}
+}
+
+// HighlightList is the highlights of a link, in the order they were made.
+type HighlightList []*Highlight
+
+func (l *HighlightList) MarshalJSON() ([]byte, error) {
+	if len(*l) == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]*Highlight(*l))
+}
+
+func (l HighlightList) Find(id string) *Highlight {
+	for _, h := range l {
+		if h.ID == id {
+			return h
+		}
+	}
+
+	return nil
+}
+
+// LinkHighlight is a highlight together with the link it was made on.
+type LinkHighlight struct {
+	Highlight *Highlight `json:"highlight"`
+	Link      *Link      `json:"link"`
+}
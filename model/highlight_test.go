@@ -0,0 +1,78 @@
+package model
+
+import "testing"
+
+func TestHighlightAnchor(t *testing.T) {
+	const text = "The cat sat. Then the cat ran. Later the cat slept."
+
+	tests := []struct {
+		Name        string
+		GivenStart  int
+		GivenPrefix string
+		GivenText   string
+		ExpectFound bool
+		ExpectStart int
+	}{
+		{
+			Name:        "nearest to the position",
+			GivenStart:  20,
+			GivenText:   text,
+			ExpectFound: true,
+			ExpectStart: 22,
+		},
+		{
+			Name:        "context wins over position",
+			GivenStart:  0,
+			GivenPrefix: "Later the ",
+			GivenText:   text,
+			ExpectFound: true,
+			ExpectStart: 41,
+		},
+		{
+			Name:        "survives a change before the quote",
+			GivenStart:  41,
+			GivenPrefix: "Later the ",
+			GivenText:   "Intro. " + text,
+			ExpectFound: true,
+			ExpectStart: 48,
+		},
+		{
+			Name:        "counts characters, not bytes",
+			GivenStart:  0,
+			GivenText:   "Café: the cat",
+			ExpectFound: true,
+			ExpectStart: 10,
+		},
+		{
+			Name:        "gone",
+			GivenText:   "The dog sat.",
+			ExpectFound: false,
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			h := NewHighlight("cat", tcase.GivenStart)
+			h.Prefix = tcase.GivenPrefix
+
+			if found := h.Anchor(tcase.GivenText); found != tcase.ExpectFound {
+				t.Fatalf("found %v, want %v", found, tcase.ExpectFound)
+			}
+
+			if h.IsOrphaned == tcase.ExpectFound {
+				t.Errorf("orphaned is %v", h.IsOrphaned)
+			}
+
+			if tcase.ExpectFound && (h.Start != tcase.ExpectStart || h.End != tcase.ExpectStart+3) {
+				t.Errorf("got %d-%d, want %d-%d", h.Start, h.End, tcase.ExpectStart, tcase.ExpectStart+3)
+			}
+		})
+	}
+}
+
+func TestNewHighlight(t *testing.T) {
+	h := NewHighlight("  a\n\tquote  ", 0)
+	if h.Quote != "a quote" || h.Color != HighlightColors[0] {
+		t.Errorf("unexpected highlight: %+v", h)
+	}
+}
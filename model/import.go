@@ -0,0 +1,81 @@
+package model
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportResult counts what happened to the bookmarks in an imported file.
+type ImportResult struct {
+	Imported int `json:"imported"`
+	// Skipped counts bookmarks that had already been saved or appeared more
+	// than once in the file.
+	Skipped int `json:"skipped"`
+	// Failed counts bookmarks that couldn't be saved, such as those without
+	// a web URL.
+	Failed int `json:"failed"`
+}
+
+type ImportStatus string
+
+const (
+	ImportStatusPending ImportStatus = "pending"
+	ImportStatusRunning ImportStatus = "running"
+	ImportStatusDone    ImportStatus = "done"
+	ImportStatusFailed  ImportStatus = "failed"
+)
+
+// MaxImportErrors is the most row errors kept for an import job. Failures
+// after that are still counted.
+const MaxImportErrors = 1000
+
+// ImportJob is an import that runs in the background through the job queue.
+// The uploaded file is kept until the import finishes so that it can be picked
+// up again after a restart.
+type ImportJob struct {
+	Key       primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ID        string             `json:"id"`
+	UserID    string             `json:"userId"`
+	FileID    primitive.ObjectID `json:"-"`
+	Format    string             `json:"format"`
+	Status    ImportStatus       `json:"status"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	// Total is the number of bookmarks in the file and Processed is how many
+	// of them have been handled so far.
+	Total        int `json:"total"`
+	Processed    int `json:"processed"`
+	ImportResult `bson:",inline"`
+	Errors       []*ImportError `json:"errors"`
+	// Error says why the job failed as a whole.
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// ImportError says why one bookmark in the file couldn't be imported.
+type ImportError struct {
+	// Row is the position of the bookmark in the file, starting at 1.
+	Row     int    `json:"row"`
+	URL     string `json:"url"`
+	Message string `json:"message"`
+}
+
+type ImportJobStore interface {
+	CreateImportJob(context.Context, *ImportJob, io.Reader) (*ImportJob, error)
+	GetImportJobByID(context.Context, string) (*ImportJob, error)
+	UpdateImportJob(context.Context, *ImportJob) (*ImportJob, error)
+	OpenImportFile(context.Context, *ImportJob) (io.ReadCloser, error)
+	DeleteImportFile(context.Context, *ImportJob) error
+	DeleteAllImportJobsByUser(context.Context, *User) error
+}
+
+// AddError records a bookmark that failed to import.
+func (j *ImportJob) AddError(row int, url, message string) {
+	j.Failed++
+
+	if len(j.Errors) < MaxImportErrors {
+		j.Errors = append(j.Errors, &ImportError{Row: row, URL: url, Message: message})
+	}
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JobStatus string
+
+const (
+	// JobStatusQueued jobs are waiting to run, or to be retried, at RunAt.
+	JobStatusQueued JobStatus = "queued"
+	// JobStatusRunning jobs are held by a worker until LeaseUntil. If the
+	// worker dies the job is picked up again once the lease runs out, or is
+	// dead if it has no attempts left.
+	JobStatusRunning JobStatus = "running"
+	// JobStatusDead jobs failed too many times and are kept for inspection.
+	JobStatusDead JobStatus = "dead"
+)
+
+// Job is a unit of background work in the job queue. Jobs that finish are
+// removed.
+type Job struct {
+	Key         primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ID          string             `json:"id"`
+	Kind        string             `json:"kind"`
+	Payload     bson.Raw           `json:"-"`
+	Status      JobStatus          `json:"status"`
+	Attempts    int                `json:"attempts"`
+	MaxAttempts int                `json:"maxAttempts"`
+	RunAt       time.Time          `json:"runAt"`
+	LeaseUntil  *time.Time         `json:"leaseUntil,omitempty" bson:"leaseuntil,omitempty"`
+	LastError   string             `json:"lastError,omitempty" bson:"lasterror,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	// UniqueKey, if set, is unique among the jobs that are queued or running.
+	UniqueKey string `json:"-" bson:"uniquekey,omitempty"`
+}
+
+type JobStore interface {
+	CreateJob(context.Context, *Job) (*Job, error)
+	// ClaimJob leases the next job of one of the given kinds that is due.
+	ClaimJob(ctx context.Context, kinds []string, lease time.Duration) (*Job, error)
+	// DeadLetterExpiredJobs marks the running jobs of the given kinds whose
+	// lease ran out on their last attempt as dead, and returns them.
+	DeadLetterExpiredJobs(ctx context.Context, kinds []string) ([]*Job, error)
+	// ExtendJobLease, CompleteJob and UpdateJob only apply while the caller
+	// still holds the lease it got from ClaimJob.
+	ExtendJobLease(ctx context.Context, job *Job, lease time.Duration) error
+	CompleteJob(context.Context, *Job) error
+	UpdateJob(context.Context, *Job) error
+}
+
+// Decode reads the job's payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return bson.Unmarshal(j.Payload, v)
+}
+
+// IsLastAttempt reports whether the job won't be retried if this attempt
+// fails.
+func (j *Job) IsLastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
@@ -20,6 +20,7 @@ type Link struct {
 	FolderID     string             `json:"folderId"`
 	Corpus       string             `json:"corpus"`
 	URL          string             `json:"url"`
+	CanonicalURL string             `json:"canonicalUrl"`
 	Title        string             `json:"title"`
 	Description  string             `json:"description"`
 	Favicon      string             `json:"favicon"`
@@ -27,19 +28,193 @@ type Link struct {
 	Site         string             `json:"site"`
 	Annotation   string             `json:"annotation"`
 	IsAnnotated  bool               `json:"isAnnotated"`
+	Highlights   HighlightList      `json:"highlights" bson:"highlights,omitempty"`
 	Summary      string             `json:"summary"`
 	IsSummarized bool               `json:"isSummarized"`
 	IsArticle    bool               `json:"isArticle"`
+	IsTrashed    bool               `json:"isTrashed"`
+	IsRead       bool               `json:"isRead"`
+	// ReadProgress is how far through the link the user has read, as a
+	// percentage. A link is read once it reaches 100.
+	ReadProgress int `json:"readProgress"`
+	// LastOpenedAt is when the user last opened the link to read it.
+	LastOpenedAt *time.Time `json:"lastOpenedAt,omitempty" bson:"lastopenedat,omitempty"`
+	// TrashedAt is when the link was moved to the trash. Trashed links are
+	// purged once TrashRetention has passed.
+	TrashedAt *time.Time `json:"trashedAt,omitempty" bson:"trashedat,omitempty"`
+	// NeedsEnrichment marks a link that was saved without fetching its page,
+	// such as one that was imported, so its metadata is still to be filled in.
+	NeedsEnrichment bool `json:"-" bson:"needsenrichment"`
+	// EnrichFailed is set once fetching the link's metadata has been given up.
+	EnrichFailed bool `json:"-" bson:"enrichfailed,omitempty"`
+	// Health is what was found the last time the link's URL was checked. It
+	// is nil until the link is first checked, and again after its URL
+	// changes.
+	Health *LinkHealth `json:"health,omitempty" bson:"health,omitempty"`
+	// HealthCheckAfter is when the link's URL is next due to be checked.
+	// Links without it are checked as soon as possible.
+	HealthCheckAfter *time.Time `json:"-" bson:"healthcheckafter,omitempty"`
+	// IsWatched marks a link whose page is checked for changes. IsChanged is
+	// set when the page of a watched link changes, until the user clears it.
+	IsWatched     bool       `json:"isWatched"`
+	IsChanged     bool       `json:"isChanged"`
+	LastChangedAt *time.Time `json:"lastChangedAt,omitempty" bson:"lastchangedat,omitempty"`
+	// WatchedText is the text of a watched link's page from the last time it
+	// was checked, and WatchAfter is when it is next due to be checked.
+	WatchedText string     `json:"-" bson:"watchedtext,omitempty"`
+	WatchAfter  *time.Time `json:"-" bson:"watchafter,omitempty"`
+	// Embedding is what semantic search compares the link by. It is left
+	// out of listings.
+	Embedding *Embedding `json:"-" bson:"embedding,omitempty"`
+	// Score is the text search relevance of the link, or its similarity to
+	// the query of a semantic search. It is only populated when listing links
+	// with a search query and is never stored.
+	Score float64 `json:"-" bson:"score,omitempty"`
+}
+
+// Embedding is a vector that stands for the meaning of a link's title,
+// description, annotation and text.
+type Embedding struct {
+	// Model is the model that made the vector. Vectors are only compared to
+	// ones from the same model.
+	Model     string    `bson:"model"`
+	Vector    []float32 `bson:"vector"`
+	CreatedAt time.Time `bson:"createdat"`
+}
+
+// The read states that links can be filtered by.
+const (
+	ReadStateUnread     = "unread"
+	ReadStateInProgress = "inprogress"
+	ReadStateRead       = "read"
+)
+
+// MarkRead sets whether the link has been read. Marking it read finishes its
+// progress, and marking a finished link unread starts it over.
+func (l *Link) MarkRead(read bool) {
+	l.IsRead = read
+
+	switch {
+	case read:
+		l.ReadProgress = 100
+	case l.ReadProgress >= 100:
+		l.ReadProgress = 0
+	}
+}
+
+// SetReadProgress records how far through the link the user has read, as a
+// percentage, and marks it read once they reach the end.
+func (l *Link) SetReadProgress(progress int) {
+	if progress > 100 {
+		progress = 100
+	}
+
+	l.ReadProgress = progress
+
+	if progress == 100 {
+		l.IsRead = true
+	}
+}
+
+// Watch stops watching the link's page for changes, or starts watching it,
+// in which case it is checked as soon as possible.
+func (l *Link) Watch(watch bool) {
+	if watch == l.IsWatched {
+		return
+	}
+
+	l.IsWatched = watch
+	l.IsChanged = false
+	l.WatchedText = ""
+	l.WatchAfter = nil
+}
+
+// TrashRetention is how long links stay in the trash before they are deleted
+// for good.
+const TrashRetention = 30 * 24 * time.Hour
+
+// EnrichmentStatus counts a user's links by whether their metadata has been
+// fetched.
+type EnrichmentStatus struct {
+	Total int `json:"total"`
+	// Pending counts the links still waiting for their metadata.
+	Pending int `json:"pending"`
+	// Failed counts the links whose metadata couldn't be fetched.
+	Failed int `json:"failed"`
+}
+
+// The facets that links can be counted by.
+const (
+	FacetSite      = "site"
+	FacetTag       = "tag"
+	FacetUserTag   = "usertag"
+	FacetFolder    = "folder"
+	FacetFavorite  = "favorite"
+	FacetAnnotated = "annotated"
+)
+
+// Facets lists the facets that links can be counted by.
+var Facets = []string{FacetSite, FacetTag, FacetUserTag, FacetFolder, FacetFavorite, FacetAnnotated}
+
+// MaxFacetValues is how many of the most common values of a facet are
+// counted.
+const MaxFacetValues = 20
+
+// FacetCounts counts the links that match a filter, in total and by the
+// values of each of the requested facets.
+type FacetCounts struct {
+	Total int `json:"total"`
+	// Counts maps each facet to its most common values, most common first.
+	// The values of favorite and annotated are "true" and "false", and links
+	// that aren't in a folder are counted under "root".
+	Counts map[string][]*FacetCount `json:"counts"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// DuplicateLinks are links of a user's that are for the same page, oldest
+// first.
+type DuplicateLinks struct {
+	CanonicalURL string  `json:"canonicalUrl"`
+	Links        []*Link `json:"links"`
 }
 
 type GetLinksOption func(map[string]interface{})
 
 type LinkStore interface {
 	GetLinksByUser(context.Context, *User, *Pagination, ...GetLinksOption) ([]*Link, error)
-	GetAllLinksByUser(context.Context, *User, *Pagination) ([]*Link, error)
+	GetLinkFacets(context.Context, *User, []string, ...GetLinksOption) (*FacetCounts, error)
+	GetAllLinksByUser(context.Context, *User, *Pagination, ...GetLinksOption) ([]*Link, error)
 	GetLinkByID(context.Context, string) (*Link, error)
+	GetLinksByIDs(context.Context, *User, []string) ([]*Link, error)
+	GetLinksByURLs(context.Context, *User, []string) ([]*Link, error)
+	GetDuplicateLinks(context.Context, *User) ([]*DuplicateLinks, error)
+	GetRelatedLinks(context.Context, *Link, *Pagination) ([]*RelatedLink, error)
+	CreateHighlight(context.Context, *Link, *Highlight) error
+	UpdateHighlight(context.Context, *Link, *Highlight) error
+	DeleteHighlight(ctx context.Context, l *Link, id string) error
+	GetHighlightsByUser(context.Context, *User, *Pagination) ([]*LinkHighlight, error)
 	CreateLink(context.Context, *Link) (*Link, error)
+	CreateLinks(context.Context, []*Link) error
 	UpdateLink(context.Context, *Link) (*Link, error)
+	UpdateLinks(context.Context, []*Link) error
 	DeleteLink(context.Context, *Link) error
+	DeleteLinks(context.Context, []*Link) error
+	// GetTrashedLinksBefore returns up to limit links that were moved to the
+	// trash before the given time.
+	GetTrashedLinksBefore(ctx context.Context, before time.Time, limit int) ([]*Link, error)
 	DeleteAllLinksByUser(ctx context.Context, u *User) error
+	GetLinksToEnrich(ctx context.Context, limit int) ([]*Link, error)
+	UpdateLinkEnrichment(context.Context, *Link) error
+	GetEnrichmentStatus(context.Context, *User) (*EnrichmentStatus, error)
+	GetLinksDueForHealthCheck(ctx context.Context, limit int) ([]*Link, error)
+	UpdateLinkHealth(ctx context.Context, l *Link, checkAfter *time.Time) error
+	GetLinksDueForWatch(ctx context.Context, limit int) ([]*Link, error)
+	UpdateLinkWatch(ctx context.Context, l *Link, watchAfter *time.Time) (bool, error)
+	GetLinksWithoutEmbedding(ctx context.Context, model string, after string, limit int) ([]*Link, error)
+	SetLinkEmbedding(context.Context, *Link, *Embedding) error
+	SetLinkOpened(context.Context, *Link) error
 }
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxChangeDiffLength is the most of a change's diff that is kept, in bytes.
+const maxChangeDiffLength = 64 << 10
+
+// LinkChange records that the text of a watched link's page changed.
+type LinkChange struct {
+	Key        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ID         string             `json:"id"`
+	LinkID     string             `json:"linkId"`
+	UserID     string             `json:"userId"`
+	DetectedAt time.Time          `json:"detectedAt"`
+	// Diff is a unified diff of the text of the page, line by line, from
+	// before the change to after it. Long diffs are cut short.
+	Diff string `json:"diff"`
+	// Added and Removed count the lines of text that were added and removed.
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// NewLinkChange returns the change from the text of the link's page before to
+// the text after, or nil if they are the same.
+func NewLinkChange(l *Link, before, after string) *LinkChange {
+	if before == after {
+		return nil
+	}
+
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: "before",
+		ToFile:   "after",
+		Context:  2,
+	})
+
+	c := &LinkChange{
+		LinkID:     l.ID,
+		UserID:     l.UserID,
+		DetectedAt: time.Now(),
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			c.Added++
+		case strings.HasPrefix(line, "-"):
+			c.Removed++
+		}
+	}
+
+	if len(diff) > maxChangeDiffLength {
+		cut := strings.LastIndex(diff[:maxChangeDiffLength], "\n")
+		diff = diff[:cut+1] + "...\n"
+	}
+
+	c.Diff = diff
+
+	return c
+}
+
+// splitLines splits the text into lines that each end with a newline, as
+// difflib expects.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return difflib.SplitLines(s)
+}
+
+type LinkChangeStore interface {
+	CreateLinkChange(context.Context, *LinkChange) error
+	// GetLinkChangesByLink returns the changes to the link's page, newest
+	// first.
+	GetLinkChangesByLink(context.Context, string, *Pagination) ([]*LinkChange, error)
+	// DeleteLinkChangesByLinks deletes the changes to the pages of the links
+	// with the given IDs.
+	DeleteLinkChangesByLinks(ctx context.Context, linkIDs []string) error
+	DeleteAllLinkChangesByUser(context.Context, *User) error
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewLinkChange(t *testing.T) {
+	l := &Link{ID: "link", UserID: "user"}
+
+	if c := NewLinkChange(l, "Same\nText", "Same\nText"); c != nil {
+		t.Errorf("expected no change for the same text, got %+v", c)
+	}
+
+	c := NewLinkChange(l, "Pricing\nFree\nContact us", "Pricing\n$5 a month\nTeams\nContact us")
+	if c == nil {
+		t.Fatal("expected a change")
+	}
+
+	if c.LinkID != "link" || c.UserID != "user" {
+		t.Errorf("expected the change to belong to the link, got %+v", c)
+	}
+
+	if c.Added != 2 || c.Removed != 1 {
+		t.Errorf("got %d added and %d removed, want 2 and 1", c.Added, c.Removed)
+	}
+
+	for _, want := range []string{"-Free\n", "+$5 a month\n", "+Teams\n", " Pricing\n"} {
+		if !strings.Contains(c.Diff, want) {
+			t.Errorf("expected the diff to contain %q:\n%s", want, c.Diff)
+		}
+	}
+}
+
+func TestNewLinkChange_Long(t *testing.T) {
+	before := strings.Repeat("old line\n", 10000)
+	after := strings.Repeat("new line\n", 10000)
+
+	c := NewLinkChange(&Link{}, before, after)
+
+	if len(c.Diff) > maxChangeDiffLength+len("...\n") || !strings.HasSuffix(c.Diff, "\n...\n") {
+		t.Errorf("expected the diff to be cut short, got %d bytes", len(c.Diff))
+	}
+
+	if c.Added != 10000 || c.Removed != 10000 {
+		t.Errorf("expected the counts to cover the whole diff, got %d and %d", c.Added, c.Removed)
+	}
+}
@@ -1,8 +1,13 @@
 package model
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/linksort/linksort/errors"
 )
 
 const (
@@ -13,6 +18,9 @@ const (
 type Pagination struct {
 	Page int
 	Size int
+	// Cursor, if set, takes precedence over Page. Results start immediately
+	// after the position it marks.
+	Cursor *Cursor
 }
 
 func (p *Pagination) Offset() int {
@@ -43,3 +51,50 @@ func GetPagination(r *http.Request) *Pagination {
 	}
 	return &Pagination{Page: pageNum, Size: pageSize}
 }
+
+// Cursor marks a position in a listing of links so that the next page can be
+// fetched without skipping over documents. Clients only ever see it as the
+// opaque string returned by Encode.
+type Cursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+	// Score is the text search relevance of the link at the cursor. It is
+	// only set when paging through search results.
+	Score float64 `json:"s,omitempty"`
+}
+
+// NewCursor returns a cursor that points at the given link.
+func NewCursor(l *Link) *Cursor {
+	return &Cursor{CreatedAt: l.CreatedAt, ID: l.ID, Score: l.Score}
+}
+
+func (c *Cursor) Encode() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		// Marshalling a time, a string and a float can't fail.
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor decodes a cursor that was previously returned by Encode.
+func ParseCursor(s string) (*Cursor, error) {
+	op := errors.Op("model.ParseCursor")
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest)
+	}
+
+	c := new(Cursor)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest)
+	}
+
+	if c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, errors.E(op, errors.Str("incomplete cursor"), http.StatusBadRequest)
+	}
+
+	return c, nil
+}
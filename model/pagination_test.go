@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	l := &Link{
+		ID:        "60d5ec49f1a2c8b1f8e4e1a1",
+		CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Score:     1.25,
+	}
+
+	got, err := ParseCursor(NewCursor(l).Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.ID != l.ID || !got.CreatedAt.Equal(l.CreatedAt) || got.Score != l.Score {
+		t.Fatalf("unexpected cursor: got %+v", got)
+	}
+}
+
+func TestParseCursor_Invalid(t *testing.T) {
+	for _, given := range []string{"", "not base64!", "e30", "eyJpIjoiYWJjIn0"} {
+		if _, err := ParseCursor(given); err == nil {
+			t.Fatalf("expected an error for %q", given)
+		}
+	}
+}
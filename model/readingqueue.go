@@ -0,0 +1,74 @@
+package model
+
+import "encoding/json"
+
+// MaxReadingQueueLength is how many links a reading queue can hold.
+const MaxReadingQueueLength = 1000
+
+// ReadingQueue is the IDs of the links the user means to read, in the order
+// they mean to read them.
+type ReadingQueue []string
+
+func (q *ReadingQueue) MarshalJSON() ([]byte, error) {
+	if len(*q) == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]string(*q))
+}
+
+func (q ReadingQueue) Contains(id string) bool {
+	for _, queued := range q {
+		if queued == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Insert returns the queue with the link put at the given position, moving it
+// there if it was already queued. Positions past the end put it last.
+func (q ReadingQueue) Insert(id string, pos int) ReadingQueue {
+	q, _ = q.Remove(id)
+
+	if pos < 0 || pos > len(q) {
+		pos = len(q)
+	}
+
+	out := make(ReadingQueue, 0, len(q)+1)
+	out = append(out, q[:pos]...)
+	out = append(out, id)
+
+	return append(out, q[pos:]...)
+}
+
+// Remove returns the queue without the link, and whether it was queued.
+func (q ReadingQueue) Remove(id string) (ReadingQueue, bool) {
+	for i, queued := range q {
+		if queued == id {
+			return append(q[:i:i], q[i+1:]...), true
+		}
+	}
+
+	return q, false
+}
+
+// IsReorderOf reports whether ids holds exactly the links in the queue, in
+// any order.
+func (q ReadingQueue) IsReorderOf(ids []string) bool {
+	if len(ids) != len(q) {
+		return false
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] || !q.Contains(id) {
+			return false
+		}
+
+		seen[id] = true
+	}
+
+	return true
+}
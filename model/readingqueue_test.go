@@ -0,0 +1,107 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestReadingQueueInsert(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Given  ReadingQueue
+		ID     string
+		Pos    int
+		Expect ReadingQueue
+	}{
+		{Name: "empty", Given: nil, ID: "a", Pos: 0, Expect: ReadingQueue{"a"}},
+		{Name: "first", Given: ReadingQueue{"a", "b"}, ID: "c", Pos: 0, Expect: ReadingQueue{"c", "a", "b"}},
+		{Name: "middle", Given: ReadingQueue{"a", "b"}, ID: "c", Pos: 1, Expect: ReadingQueue{"a", "c", "b"}},
+		{Name: "past the end", Given: ReadingQueue{"a", "b"}, ID: "c", Pos: 10, Expect: ReadingQueue{"a", "b", "c"}},
+		{Name: "moved", Given: ReadingQueue{"a", "b", "c"}, ID: "c", Pos: 0, Expect: ReadingQueue{"c", "a", "b"}},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			got := tcase.Given.Insert(tcase.ID, tcase.Pos)
+			if !reflect.DeepEqual(got, tcase.Expect) {
+				t.Errorf("got %v, want %v", got, tcase.Expect)
+			}
+		})
+	}
+}
+
+func TestReadingQueueRemove(t *testing.T) {
+	q := ReadingQueue{"a", "b", "c"}
+
+	got, ok := q.Remove("b")
+	if !ok || !reflect.DeepEqual(got, ReadingQueue{"a", "c"}) {
+		t.Errorf("got %v, %v", got, ok)
+	}
+
+	if !reflect.DeepEqual(q, ReadingQueue{"a", "b", "c"}) {
+		t.Errorf("the original queue was changed: %v", q)
+	}
+
+	if _, ok := q.Remove("d"); ok {
+		t.Error("removed a link that wasn't queued")
+	}
+}
+
+func TestReadingQueueIsReorderOf(t *testing.T) {
+	q := ReadingQueue{"a", "b", "c"}
+
+	tests := []struct {
+		Given  []string
+		Expect bool
+	}{
+		{Given: []string{"c", "a", "b"}, Expect: true},
+		{Given: []string{"a", "b"}, Expect: false},
+		{Given: []string{"a", "b", "b"}, Expect: false},
+		{Given: []string{"a", "b", "d"}, Expect: false},
+	}
+
+	for _, tcase := range tests {
+		if got := q.IsReorderOf(tcase.Given); got != tcase.Expect {
+			t.Errorf("IsReorderOf(%v) = %v, want %v", tcase.Given, got, tcase.Expect)
+		}
+	}
+}
+
+func TestReadingQueueMarshalJSON(t *testing.T) {
+	u := &User{}
+
+	b, err := json.Marshal(&u.ReadingQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "[]" {
+		t.Errorf("got %s, want []", b)
+	}
+}
+
+func TestLinkReadState(t *testing.T) {
+	l := &Link{}
+
+	l.SetReadProgress(40)
+	if l.IsRead || l.ReadProgress != 40 {
+		t.Errorf("unexpected read state: %v %d", l.IsRead, l.ReadProgress)
+	}
+
+	l.SetReadProgress(100)
+	if !l.IsRead {
+		t.Error("expected the link to be read once finished")
+	}
+
+	l.MarkRead(false)
+	if l.IsRead || l.ReadProgress != 0 {
+		t.Errorf("expected a finished link to start over: %v %d", l.IsRead, l.ReadProgress)
+	}
+
+	l.SetReadProgress(30)
+	l.MarkRead(true)
+	if !l.IsRead || l.ReadProgress != 100 {
+		t.Errorf("unexpected read state: %v %d", l.IsRead, l.ReadProgress)
+	}
+}
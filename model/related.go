@@ -0,0 +1,115 @@
+package model
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MinRelatedSimilarity is how similar the content of two links has to be for
+// it to count towards them being related. Links below it can still be related
+// in other ways.
+const MinRelatedSimilarity = 0.5
+
+// Kinds of ways in which links can be related.
+const (
+	RelatedByContent  = "content"
+	RelatedByTags     = "tags"
+	RelatedByUserTags = "userTags"
+	RelatedBySite     = "site"
+)
+
+// RelatedLink is a link that is related to another one, with why.
+type RelatedLink struct {
+	Link *Link `json:"link"`
+	// Score ranks related links. It has no meaning of its own.
+	Score   float64          `json:"score"`
+	Reasons []*RelatedReason `json:"reasons"`
+}
+
+// RelatedReason is one of the ways in which two links are related.
+type RelatedReason struct {
+	Kind string `json:"kind"`
+	// Values are the tag paths or user tags the links share, or the site they
+	// are both from.
+	Values []string `json:"values,omitempty"`
+	// Similarity is how similar the content of the links is, up to 1. It is
+	// only set for content.
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// RelatedReasons returns the ways in which the other link is related to the
+// link, given how similar their content is.
+func RelatedReasons(l, other *Link, similarity float64) []*RelatedReason {
+	reasons := make([]*RelatedReason, 0)
+
+	if similarity >= MinRelatedSimilarity {
+		reasons = append(reasons, &RelatedReason{Kind: RelatedByContent, Similarity: similarity})
+	}
+
+	// A tag's ancestors are in the tag paths too. Only the most specific of
+	// the shared tags are given.
+	tags := intersect(l.TagPaths, other.TagPaths)
+	specific := make([]string, 0, len(tags))
+
+	for _, t := range tags {
+		isAncestor := false
+
+		for _, u := range tags {
+			if strings.HasPrefix(u, t+"/") {
+				isAncestor = true
+				break
+			}
+		}
+
+		if !isAncestor {
+			specific = append(specific, t)
+		}
+	}
+
+	if len(specific) > 0 {
+		reasons = append(reasons, &RelatedReason{Kind: RelatedByTags, Values: specific})
+	}
+
+	if userTags := intersect(l.UserTags, other.UserTags); len(userTags) > 0 {
+		reasons = append(reasons, &RelatedReason{Kind: RelatedByUserTags, Values: userTags})
+	}
+
+	if host := Host(l.URL); host != "" && host == Host(other.URL) {
+		reasons = append(reasons, &RelatedReason{Kind: RelatedBySite, Values: []string{host}})
+	}
+
+	return reasons
+}
+
+// Host returns the host of the link's URL without a leading "www.", or the
+// empty string if the URL can't be parsed.
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// intersect returns the strings that are in both lists, sorted.
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(a))
+	for _, s := range a {
+		in[s] = true
+	}
+
+	out := make([]string, 0)
+
+	for _, s := range b {
+		if in[s] {
+			out = append(out, s)
+			delete(in, s)
+		}
+	}
+
+	sort.Strings(out)
+
+	return out
+}
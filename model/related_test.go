@@ -0,0 +1,36 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRelatedReasons(t *testing.T) {
+	l := &Link{
+		URL:      "https://www.example.com/a",
+		TagPaths: []string{"/Science", "/Science/Physics", "/Arts"},
+		UserTags: []string{"reading", "physics"},
+	}
+	other := &Link{
+		URL:      "https://example.com/b",
+		TagPaths: []string{"/Science", "/Science/Physics", "/Science/Physics/Optics"},
+		UserTags: []string{"physics"},
+	}
+
+	got := RelatedReasons(l, other, 0.8)
+	want := []*RelatedReason{
+		{Kind: RelatedByContent, Similarity: 0.8},
+		{Kind: RelatedByTags, Values: []string{"/Science/Physics"}},
+		{Kind: RelatedByUserTags, Values: []string{"physics"}},
+		{Kind: RelatedBySite, Values: []string{"example.com"}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	unrelated := &Link{URL: "https://other.com", TagPaths: []string{"/Arts/Music"}}
+	if got := RelatedReasons(other, unrelated, MinRelatedSimilarity/2); len(got) != 0 {
+		t.Errorf("expected no reasons, got %+v", got)
+	}
+}
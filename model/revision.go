@@ -0,0 +1,210 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actor says who made a change.
+type Actor string
+
+const (
+	ActorUser      Actor = "user"
+	ActorAssistant Actor = "assistant"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes changes made with it to the
+// given actor.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFromContext returns the actor set with WithActor. Changes are
+// attributed to the user unless something else was set.
+func ActorFromContext(ctx context.Context) Actor {
+	if a, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return a
+	}
+
+	return ActorUser
+}
+
+type RevisionAction string
+
+const (
+	RevisionActionUpdate  RevisionAction = "update"
+	RevisionActionTrash   RevisionAction = "trash"
+	RevisionActionRestore RevisionAction = "restore"
+	RevisionActionRevert  RevisionAction = "revert"
+)
+
+// LinkRevision records one change made to a link.
+type LinkRevision struct {
+	Key       primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ID        string             `json:"id"`
+	LinkID    string             `json:"linkId"`
+	UserID    string             `json:"userId"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Actor     Actor              `json:"actor"`
+	Action    RevisionAction     `json:"action"`
+	// RevertedTo is the ID of the revision that a revert went back to.
+	RevertedTo string         `json:"revertedTo,omitempty" bson:"revertedto,omitempty"`
+	Changes    []*FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+type RevisionStore interface {
+	CreateRevisions(context.Context, []*LinkRevision) error
+	GetRevisionByID(context.Context, string) (*LinkRevision, error)
+	GetRevisionsByLink(context.Context, string, *Pagination) ([]*LinkRevision, error)
+	// GetRevisionsSince returns the given revision and every later revision
+	// of the same link, newest first.
+	GetRevisionsSince(context.Context, *LinkRevision) ([]*LinkRevision, error)
+	// DeleteRevisionsByLinks deletes the revisions of the links with the
+	// given IDs.
+	DeleteRevisionsByLinks(ctx context.Context, linkIDs []string) error
+	DeleteAllRevisionsByUser(context.Context, *User) error
+}
+
+// NewLinkRevision returns a revision describing how the link changed from
+// before to after, or nil if nothing that is tracked changed.
+func NewLinkRevision(ctx context.Context, action RevisionAction, before, after *Link) *LinkRevision {
+	changes := make([]*FieldChange, 0)
+
+	for _, f := range revisionFields {
+		old, new := f.get(before), f.get(after)
+		if !equalFieldValues(old, new) {
+			changes = append(changes, &FieldChange{Field: f.name, Old: old, New: new})
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return &LinkRevision{
+		LinkID:    before.ID,
+		UserID:    before.UserID,
+		CreatedAt: time.Now(),
+		Actor:     ActorFromContext(ctx),
+		Action:    action,
+		Changes:   changes,
+	}
+}
+
+// Undo sets every field changed by the revision back to its old value.
+func (r *LinkRevision) Undo(l *Link) {
+	for _, c := range r.Changes {
+		for _, f := range revisionFields {
+			if f.name == c.Field {
+				f.set(l, c.Old)
+			}
+		}
+	}
+}
+
+type revisionField struct {
+	name string
+	get  func(*Link) interface{}
+	set  func(*Link, interface{})
+}
+
+// revisionFields are the fields of a link that users and the assistant can
+// change and which are therefore tracked by revisions.
+var revisionFields = []revisionField{
+	stringField("title", func(l *Link) *string { return &l.Title }),
+	stringField("url", func(l *Link) *string { return &l.URL }),
+	stringField("favicon", func(l *Link) *string { return &l.Favicon }),
+	stringField("description", func(l *Link) *string { return &l.Description }),
+	stringField("image", func(l *Link) *string { return &l.Image }),
+	stringField("site", func(l *Link) *string { return &l.Site }),
+	stringField("annotation", func(l *Link) *string { return &l.Annotation }),
+	stringField("folderId", func(l *Link) *string { return &l.FolderID }),
+	boolField("isFavorite", func(l *Link) *bool { return &l.IsFavorite }),
+	boolField("isTrashed", func(l *Link) *bool { return &l.IsTrashed }),
+	{
+		name: "userTags",
+		get: func(l *Link) interface{} {
+			return append(make([]string, 0, len(l.UserTags)), l.UserTags...)
+		},
+		set: func(l *Link, v interface{}) {
+			l.UserTags = toStrings(v)
+		},
+	},
+}
+
+func stringField(name string, field func(*Link) *string) revisionField {
+	return revisionField{
+		name: name,
+		get:  func(l *Link) interface{} { return *field(l) },
+		set: func(l *Link, v interface{}) {
+			s, _ := v.(string)
+			*field(l) = s
+		},
+	}
+}
+
+func boolField(name string, field func(*Link) *bool) revisionField {
+	return revisionField{
+		name: name,
+		get:  func(l *Link) interface{} { return *field(l) },
+		set: func(l *Link, v interface{}) {
+			b, _ := v.(bool)
+			*field(l) = b
+		},
+	}
+}
+
+func equalFieldValues(a, b interface{}) bool {
+	as, aok := a.([]string)
+	bs, bok := b.([]string)
+	if aok || bok {
+		if len(as) != len(bs) {
+			return false
+		}
+
+		for i := range as {
+			if as[i] != bs[i] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return a == b
+}
+
+// toStrings converts a value that was stored as an array back to a slice of
+// strings. Arrays come back from the database as primitive.A.
+func toStrings(v interface{}) []string {
+	out := make([]string, 0)
+
+	switch t := v.(type) {
+	case []string:
+		out = append(out, t...)
+	case primitive.A:
+		for _, s := range t {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+	case []interface{}:
+		for _, s := range t {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+	}
+
+	return out
+}
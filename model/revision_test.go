@@ -0,0 +1,49 @@
+package model
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewLinkRevision(t *testing.T) {
+	before := &Link{ID: "a", UserID: "u", Title: "Old", UserTags: []string{"x"}}
+	after := &Link{ID: "a", UserID: "u", Title: "New", UserTags: []string{"x"}, IsFavorite: true}
+
+	rev := NewLinkRevision(WithActor(context.Background(), ActorAssistant), RevisionActionUpdate, before, after)
+	if rev == nil {
+		t.Fatal("expected a revision")
+	}
+
+	if rev.Actor != ActorAssistant {
+		t.Errorf("unexpected actor: %s", rev.Actor)
+	}
+
+	if len(rev.Changes) != 2 || rev.Changes[0].Field != "title" || rev.Changes[1].Field != "isFavorite" {
+		t.Fatalf("unexpected changes: %+v", rev.Changes)
+	}
+
+	if NewLinkRevision(context.Background(), RevisionActionUpdate, before, before) != nil {
+		t.Error("expected no revision when nothing changed")
+	}
+}
+
+func TestLinkRevisionUndo(t *testing.T) {
+	l := &Link{Title: "New", FolderID: "f", UserTags: []string{"b"}}
+
+	// Old values come back from the database as primitive.A rather than
+	// []string.
+	rev := &LinkRevision{Changes: []*FieldChange{
+		{Field: "title", Old: "Old", New: "New"},
+		{Field: "folderId", Old: "root", New: "f"},
+		{Field: "userTags", Old: primitive.A{"a"}, New: primitive.A{"b"}},
+	}}
+
+	rev.Undo(l)
+
+	if l.Title != "Old" || l.FolderID != "root" || !reflect.DeepEqual(l.UserTags, JSONStringArray{"a"}) {
+		t.Fatalf("unexpected link after undo: %+v", l)
+	}
+}
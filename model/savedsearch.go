@@ -0,0 +1,45 @@
+package model
+
+import "github.com/linksort/linksort/random"
+
+// SavedSearch is a named set of link filters. It's opened like a folder whose
+// links are the ones that match the filters.
+type SavedSearch struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Search    string `json:"search"`
+	Sort      string `json:"sort"`
+	Favorites bool   `json:"favorites"`
+	Annotated bool   `json:"annotated"`
+	FolderID  string `json:"folderId"`
+	TagPath   string `json:"tagPath"`
+	UserTag   string `json:"userTag"`
+}
+
+type SavedSearches []*SavedSearch
+
+func NewSavedSearch(name string) *SavedSearch {
+	return &SavedSearch{ID: random.UUID(), Name: name}
+}
+
+func (s SavedSearches) Find(id string) *SavedSearch {
+	for _, search := range s {
+		if search.ID == id {
+			return search
+		}
+	}
+
+	return nil
+}
+
+// Remove returns the saved searches without the one with the given ID, and
+// the one that was removed or nil if there wasn't one.
+func (s SavedSearches) Remove(id string) (SavedSearches, *SavedSearch) {
+	for i, search := range s {
+		if search.ID == id {
+			return append(s[:i:i], s[i+1:]...), search
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxSnapshotsPerLink is how many snapshots are kept of each link. The oldest
+// ones are deleted to make room for new ones.
+const MaxSnapshotsPerLink = 20
+
+// Snapshot is a copy of a link's page as it was when it was fetched.
+// Snapshots are kept apart from links, since they are large and links are
+// listed often.
+type Snapshot struct {
+	Key    primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ID     string             `json:"id"`
+	LinkID string             `json:"linkId"`
+	UserID string             `json:"userId"`
+	// URL is where the page was found, after following redirects.
+	URL string `json:"url"`
+	// HTML is the page made safe to show, and Text the text of its main
+	// content. Both are left out when snapshots are listed.
+	HTML string `json:"html,omitempty"`
+	Text string `json:"text,omitempty"`
+	// ContentHash is the SHA-256 of the HTML, in hex. It is the same for
+	// snapshots of a page that didn't change.
+	ContentHash string `json:"contentHash"`
+	// Size is the length of the HTML in bytes.
+	Size      int       `json:"size"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+func NewSnapshot(l *Link, url, html, text string) *Snapshot {
+	sum := sha256.Sum256([]byte(html))
+
+	return &Snapshot{
+		LinkID:      l.ID,
+		UserID:      l.UserID,
+		URL:         url,
+		HTML:        html,
+		Text:        text,
+		ContentHash: hex.EncodeToString(sum[:]),
+		Size:        len(html),
+		FetchedAt:   time.Now(),
+	}
+}
+
+type SnapshotStore interface {
+	CreateSnapshot(context.Context, *Snapshot) (*Snapshot, error)
+	GetSnapshotByID(context.Context, string) (*Snapshot, error)
+	// GetSnapshotsByLink returns the link's snapshots, newest first, without
+	// their HTML and text.
+	GetSnapshotsByLink(context.Context, *Link, *Pagination) ([]*Snapshot, error)
+	// DeleteOldSnapshots deletes all but the newest keep snapshots of the link.
+	DeleteOldSnapshots(ctx context.Context, l *Link, keep int) error
+	// DeleteSnapshotsByLinks deletes the snapshots of the links with the given
+	// IDs.
+	DeleteSnapshotsByLinks(ctx context.Context, linkIDs []string) error
+	DeleteAllSnapshotsByUser(context.Context, *User) error
+}
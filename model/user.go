@@ -24,18 +24,26 @@ type User struct {
 	PasswordDigest     string             `json:"-" bson:"passwordDigest"`
 	Token              string             `json:"token"`
 	FolderTree         *Folder            `json:"folderTree"`
+	SavedSearches      SavedSearches      `json:"savedSearches"`
+	Feeds              Feeds              `json:"feeds"`
 	TagTree            *TagNode           `json:"tagTree"`
 	UserTags           UserTags           `json:"userTags"`
 	HasSeenWelcomeTour bool               `json:"hasSeenWelcomeTour"`
+	ReadingQueue       ReadingQueue       `json:"readingQueue"`
+	Digest             DigestSchedule     `json:"digest"`
 }
 
 type UserStore interface {
 	GetUserBySessionID(context.Context, string) (*User, error)
 	GetUserByToken(context.Context, string) (*User, error)
+	GetUserByFeedToken(context.Context, string) (*User, error)
 	GetUserByEmail(context.Context, string) (*User, error)
+	GetUserByID(context.Context, string) (*User, error)
 	CreateUser(context.Context, *User) (*User, error)
 	UpdateUser(context.Context, *User) (*User, error)
 	DeleteUser(context.Context, *User) error
+	GetUsersDueForDigest(ctx context.Context, limit int) ([]*User, error)
+	UpdateUserDigest(context.Context, *User) error
 }
 
 func NewPasswordDigest(passwd string) (string, error) {
@@ -1,6 +1,9 @@
 package model
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
 type UserTags map[string]int
 
@@ -74,6 +77,34 @@ func ReconcileUserTags(u *User, oldTags, newTags []string) {
 	u.UserTags.UpdateWithRemovedTags(removed)
 }
 
+var tagSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// NormalizeTag turns a tag from another service into the lowercase,
+// hyphen-separated form that user tags use. It returns an empty string if
+// nothing is left.
+func NormalizeTag(tag string) string {
+	tag = strings.Trim(tagSeparators.ReplaceAllString(strings.ToLower(tag), "-"), "-")
+	if len(tag) > 64 {
+		tag = strings.TrimRight(tag[:64], "-")
+	}
+
+	return tag
+}
+
+// NormalizeTags normalizes each tag with NormalizeTag, dropping empty and
+// repeated tags.
+func NormalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		if t := NormalizeTag(tag); t != "" && !contains(out, t) {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
 func contains(tags []string, tag string) bool {
 	for _, t := range tags {
 		if t == tag {
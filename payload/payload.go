@@ -117,6 +117,44 @@ func Write(w http.ResponseWriter, r *http.Request, payload interface{}, status i
 	}
 }
 
+// Attachment is a file download that is written straight to the response as
+// it is made. Its headers are only sent once the first of it is written, so
+// that an error found before then can still be written with WriteError.
+type Attachment struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func NewAttachment(w http.ResponseWriter, contentType, filename string) *Attachment {
+	return &Attachment{w: w, contentType: contentType, filename: filename}
+}
+
+func (a *Attachment) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.w.Header().Set("Content-Type", a.contentType)
+		a.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.filename))
+		a.w.WriteHeader(http.StatusOK)
+	}
+
+	return a.w.Write(p)
+}
+
+// Flush sends what has been written so far to the client.
+func (a *Attachment) Flush() {
+	if f, ok := a.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Started reports whether any of the file has been written, after which an
+// error can no longer be written to the response.
+func (a *Attachment) Started() bool {
+	return a.started
+}
+
 // Valid validates the given struct.
 func Valid(dst interface{}) error {
 	rv := reflect.ValueOf(dst).Elem()
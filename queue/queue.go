@@ -0,0 +1,461 @@
+// Package queue runs background work as jobs that are kept in the database,
+// so that they survive restarts. A job is leased to one worker at a time,
+// retried with backoff when it fails, and set aside as dead once it has
+// failed too many times.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/log"
+	"github.com/linksort/linksort/model"
+)
+
+// Handler runs a job. Returning an error retries the job later, unless the
+// error is Permanent or the job has no attempts left.
+type Handler func(ctx context.Context, job *model.Job) error
+
+type Queue struct {
+	// Workers is how many jobs run at once.
+	Workers int
+	// PollInterval is how long an idle worker waits before looking for jobs
+	// again.
+	PollInterval time.Duration
+	// Lease is how long a job is held by its worker. The worker renews it
+	// while the job runs, and a job whose worker died is picked up again once
+	// its lease runs out.
+	Lease time.Duration
+	// Timeout is how long a job may run before it is cancelled.
+	Timeout time.Duration
+	// MaxAttempts is how many times a job is tried before it is dead.
+	MaxAttempts int
+
+	store    model.JobStore
+	handlers map[string]Handler
+	// schedules are how often the periodic jobs run, by kind.
+	schedules map[string]time.Duration
+	// startup are the kinds of the jobs that are queued when the queue starts.
+	startup []string
+
+	// ctx is cancelled to abort running jobs when shutdown can't wait.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func New(store model.JobStore) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		Workers:      4,
+		PollInterval: 5 * time.Second,
+		Lease:        time.Minute,
+		Timeout:      time.Hour,
+		MaxAttempts:  5,
+		store:        store,
+		handlers:     make(map[string]Handler),
+		schedules:    make(map[string]time.Duration),
+		ctx:          ctx,
+		cancel:       cancel,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Register sets the handler for jobs of the given kind. Handlers must be
+// registered before Start.
+func (q *Queue) Register(kind string, h Handler) {
+	q.handlers[kind] = h
+}
+
+// Schedule makes jobs of the given kind run about once every interval, on one
+// server at a time. The job is queued when the queue starts, under its kind as
+// its unique key, and again each time it finishes or dies. Its handler must be
+// registered too, and it is called with an empty payload.
+func (q *Queue) Schedule(kind string, every time.Duration) {
+	q.schedules[kind] = every
+}
+
+// RunAtStart makes a job of the given kind run when the queue starts, such as
+// to backfill data. It is queued under its kind as its unique key, so that it
+// runs once even when several servers start together. Its handler must be
+// registered too, and it is called with an empty payload.
+func (q *Queue) RunAtStart(kind string) {
+	q.startup = append(q.startup, kind)
+}
+
+// Enqueue adds a job of the given kind to run as soon as a worker is free.
+// The payload is stored as BSON and read back with model.Job.Decode.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}) error {
+	op := errors.Opf("queue.Enqueue(%s)", kind)
+
+	if err := q.enqueue(ctx, kind, "", payload, time.Now()); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// EnqueueUnique is like Enqueue, except that the job isn't added when a job
+// with the same key is already waiting or running. This keeps work that only
+// needs doing once, such as a backfill, from being queued by every server.
+func (q *Queue) EnqueueUnique(ctx context.Context, kind, key string, payload interface{}) error {
+	op := errors.Opf("queue.EnqueueUnique(%s, %q)", kind, key)
+
+	err := q.enqueue(ctx, kind, key, payload, time.Now())
+	if err != nil && !isConflict(err) {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (q *Queue) enqueue(ctx context.Context, kind, key string, payload interface{}, runAt time.Time) error {
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	_, err = q.store.CreateJob(ctx, &model.Job{
+		Kind:        kind,
+		UniqueKey:   key,
+		Payload:     raw,
+		Status:      model.JobStatusQueued,
+		MaxAttempts: q.MaxAttempts,
+		RunAt:       runAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+
+	return err
+}
+
+// Start queues the periodic jobs and those to run at start, and starts the
+// workers. The workers only take jobs of the registered kinds. Jobs whose
+// worker died on their last attempt are set aside as dead once their lease
+// runs out.
+func (q *Queue) Start() {
+	op := errors.Op("queue.Start")
+
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	for kind := range q.schedules {
+		if err := q.EnqueueUnique(q.ctx, kind, kind, struct{}{}); err != nil {
+			log.Alarm(errors.E(op, err))
+		}
+	}
+
+	for _, kind := range q.startup {
+		if err := q.EnqueueUnique(q.ctx, kind, kind, struct{}{}); err != nil {
+			log.Alarm(errors.E(op, err))
+		}
+	}
+
+	for i := 0; i < q.Workers; i++ {
+		q.wg.Add(1)
+
+		go func() {
+			defer q.wg.Done()
+			q.work(kinds)
+		}()
+	}
+
+	q.wg.Add(1)
+
+	go func() {
+		defer q.wg.Done()
+		q.sweep(kinds)
+	}()
+}
+
+// Shutdown stops the workers from taking new jobs and waits for the running
+// ones to finish. If the context is done first, the running jobs are
+// cancelled and put back in the queue, and the context's error is returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		// Give the cancelled jobs a moment to be put back.
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work(kinds []string) {
+	op := errors.Op("queue.work")
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		job, err := q.store.ClaimJob(q.ctx, kinds, q.Lease)
+		if err != nil {
+			if !isNotFound(err) && q.ctx.Err() == nil {
+				log.Alarm(errors.E(op, err))
+			}
+
+			select {
+			case <-q.stop:
+				return
+			case <-time.After(q.PollInterval):
+			}
+
+			continue
+		}
+
+		q.run(job)
+	}
+}
+
+func (q *Queue) run(job *model.Job) {
+	op := errors.Opf("queue.run(%s, %q)", job.Kind, job.ID)
+
+	ctx, cancel := context.WithTimeout(q.ctx, q.Timeout)
+	defer cancel()
+
+	stopRenewing := q.renew(ctx, cancel, job)
+	err := q.call(ctx, job)
+	stopRenewing()
+
+	// The outcome is saved even when the job was cancelled by shutdown, so
+	// that it doesn't wait for its lease to run out.
+	sctx := context.Background()
+
+	if err == nil {
+		if err := q.store.CompleteJob(sctx, job); err != nil {
+			log.Alarm(errors.E(op, err))
+			return
+		}
+
+		q.reschedule(job)
+
+		return
+	}
+
+	job.LeaseUntil = nil
+
+	var later *laterError
+
+	switch {
+	case q.ctx.Err() != nil:
+		// The attempt is given back, since the job was cut off by shutdown.
+		job.LastError = err.Error()
+		job.Status = model.JobStatusQueued
+		job.RunAt = time.Now()
+		job.MaxAttempts++
+	case errors.As(err, &later):
+		// The attempt is given back, since the job didn't get to run.
+		job.Status = model.JobStatusQueued
+		job.RunAt = time.Now().Add(later.d)
+		job.MaxAttempts++
+	case isPermanent(err) || job.IsLastAttempt():
+		job.LastError = err.Error()
+		job.Status = model.JobStatusDead
+		log.Alarm(errors.E(op, err, errors.Str("job is dead")))
+	default:
+		job.LastError = err.Error()
+		job.Status = model.JobStatusQueued
+		job.RunAt = time.Now().Add(Backoff(job.Attempts))
+		log.Printf("job %s (%s) failed on attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+	}
+
+	if err := q.store.UpdateJob(sctx, job); err != nil {
+		log.Alarm(errors.E(op, err))
+		return
+	}
+
+	if job.Status == model.JobStatusDead {
+		q.reschedule(job)
+	}
+}
+
+// sweep dead-letters the expired jobs about once every lease until the queue
+// stops.
+func (q *Queue) sweep(kinds []string) {
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-time.After(q.Lease):
+		}
+
+		q.deadLetterExpired(kinds)
+	}
+}
+
+// deadLetterExpired sets aside as dead the jobs whose lease ran out on their
+// last attempt, such as when their worker died, since they are no longer
+// claimed.
+func (q *Queue) deadLetterExpired(kinds []string) {
+	op := errors.Op("queue.deadLetterExpired")
+
+	jobs, err := q.store.DeadLetterExpiredJobs(q.ctx, kinds)
+	if err != nil {
+		if q.ctx.Err() == nil {
+			log.Alarm(errors.E(op, err))
+		}
+
+		return
+	}
+
+	for _, job := range jobs {
+		log.Alarm(errors.E(op, errors.Strf("job %s (%s) is dead: %s", job.ID, job.Kind, job.LastError)))
+		q.reschedule(job)
+	}
+}
+
+// reschedule queues the next run of a periodic job that has finished.
+func (q *Queue) reschedule(job *model.Job) {
+	op := errors.Opf("queue.reschedule(%s)", job.Kind)
+
+	every, ok := q.schedules[job.Kind]
+	if !ok {
+		return
+	}
+
+	err := q.enqueue(context.Background(), job.Kind, job.Kind, struct{}{}, time.Now().Add(every))
+	if err != nil && !isConflict(err) {
+		log.Alarm(errors.E(op, err))
+	}
+}
+
+// renew keeps extending the job's lease until the returned function is called.
+// If the lease is lost, such as when the job took so long that another worker
+// picked it up, the job is cancelled.
+func (q *Queue) renew(ctx context.Context, cancel context.CancelFunc, job *model.Job) func() {
+	op := errors.Opf("queue.renew(%s, %q)", job.Kind, job.ID)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		t := time.NewTicker(q.Lease / 3)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := q.store.ExtendJobLease(ctx, job, q.Lease); err != nil {
+					log.Alarm(errors.E(op, err))
+					cancel()
+
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// call runs the job's handler, turning a panic into an error so that one bad
+// job doesn't take the server down.
+func (q *Queue) call(ctx context.Context, job *model.Job) (err error) {
+	h, ok := q.handlers[job.Kind]
+	if !ok {
+		return Permanent(errors.Strf("no handler for %q", job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Strf("panic: %v", r)
+		}
+	}()
+
+	return h(ctx, job)
+}
+
+// Backoff is how long to wait before retrying a job that has failed the given
+// number of times: 30 seconds, doubling each time up to an hour.
+func Backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+
+	if d > time.Hour {
+		d = time.Hour
+	}
+
+	return d
+}
+
+type laterError struct{ d time.Duration }
+
+func (e *laterError) Error() string { return fmt.Sprintf("run again in %v", e.d) }
+
+// Later puts the job back in the queue to run again after d, without counting
+// the attempt. It is for jobs that have to wait their turn, such as requests
+// to a site that was just sent one, so that they don't hold up a worker.
+func Later(d time.Duration) error {
+	return &laterError{d}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as one that retrying won't fix, so that the job is
+// dead right away.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+
+	return errors.As(err, &p)
+}
+
+func isNotFound(err error) bool {
+	var e *errors.Error
+
+	return errors.As(err, &e) && e.Status() == http.StatusNotFound
+}
+
+func isConflict(err error) bool {
+	var e *errors.Error
+
+	return errors.As(err, &e) && e.Status() == http.StatusConflict
+}
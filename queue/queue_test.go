@@ -0,0 +1,601 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/linksort/linksort/errors"
+	"github.com/linksort/linksort/model"
+)
+
+// memStore keeps jobs in memory. Like the real store, it drops completed jobs
+// and ignores updates from workers that lost their lease.
+type memStore struct {
+	mu   sync.Mutex
+	jobs map[primitive.ObjectID]*model.Job
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: make(map[primitive.ObjectID]*model.Job)}
+}
+
+func (s *memStore) CreateJob(_ context.Context, j *model.Job) (*model.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.UniqueKey != "" {
+		for _, other := range s.jobs {
+			if other.UniqueKey == j.UniqueKey {
+				return nil, errors.E(errors.Op("memStore.CreateJob"), errors.Str("duplicate job key"), http.StatusConflict)
+			}
+		}
+	}
+
+	j.Key = primitive.NewObjectID()
+	j.ID = j.Key.Hex()
+	c := *j
+	s.jobs[j.Key] = &c
+
+	return j, nil
+}
+
+func (s *memStore) ClaimJob(_ context.Context, kinds []string, lease time.Duration) (*model.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for _, j := range s.jobs {
+		due := j.Status == model.JobStatusQueued && !j.RunAt.After(now) ||
+			j.Status == model.JobStatusRunning && j.LeaseUntil != nil && !j.LeaseUntil.After(now) &&
+				j.Attempts < j.MaxAttempts
+
+		if !due || !contains(kinds, j.Kind) {
+			continue
+		}
+
+		until := now.Add(lease)
+		j.Status = model.JobStatusRunning
+		j.LeaseUntil = &until
+		j.Attempts++
+		c := *j
+
+		return &c, nil
+	}
+
+	return nil, errors.E(errors.Op("memStore.ClaimJob"), errors.Str("no documents"), http.StatusNotFound)
+}
+
+func (s *memStore) DeadLetterExpiredJobs(_ context.Context, kinds []string) ([]*model.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	dead := make([]*model.Job, 0)
+
+	for _, j := range s.jobs {
+		if j.Status != model.JobStatusRunning || j.LeaseUntil == nil || j.LeaseUntil.After(now) ||
+			j.Attempts < j.MaxAttempts || !contains(kinds, j.Kind) {
+			continue
+		}
+
+		j.Status = model.JobStatusDead
+		j.LeaseUntil = nil
+		j.LastError = "lease ran out on the last attempt"
+		j.UniqueKey = ""
+		c := *j
+		dead = append(dead, &c)
+	}
+
+	return dead, nil
+}
+
+func (s *memStore) ExtendJobLease(_ context.Context, j *model.Job, lease time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(j) {
+		return errors.Str("lease lost")
+	}
+
+	until := time.Now().Add(lease)
+	s.jobs[j.Key].LeaseUntil = &until
+
+	return nil
+}
+
+func (s *memStore) CompleteJob(_ context.Context, j *model.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(j) {
+		return errors.Str("lease lost")
+	}
+
+	delete(s.jobs, j.Key)
+
+	return nil
+}
+
+func (s *memStore) UpdateJob(_ context.Context, j *model.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(j) {
+		return errors.Str("lease lost")
+	}
+
+	if j.Status == model.JobStatusDead {
+		j.UniqueKey = ""
+	}
+
+	c := *j
+	s.jobs[j.Key] = &c
+
+	return nil
+}
+
+func (s *memStore) holds(j *model.Job) bool {
+	cur, ok := s.jobs[j.Key]
+
+	return ok && cur.Status == model.JobStatusRunning && cur.Attempts == j.Attempts
+}
+
+func (s *memStore) only(t *testing.T) *model.Job {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.jobs) != 1 {
+		t.Fatalf("got %d jobs, want 1", len(s.jobs))
+	}
+
+	for _, j := range s.jobs {
+		c := *j
+		return &c
+	}
+
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type payload struct {
+	LinkID string `bson:"linkid"`
+}
+
+func newTestQueue(store model.JobStore) *Queue {
+	q := New(store)
+	q.Workers = 1
+	q.PollInterval = 5 * time.Millisecond
+
+	return q
+}
+
+func TestQueue_Complete(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	got := make(chan string, 1)
+
+	q.Register("test", func(_ context.Context, job *model.Job) error {
+		var p payload
+		if err := job.Decode(&p); err != nil {
+			return err
+		}
+
+		got <- p.LinkID
+
+		return nil
+	})
+
+	if err := q.Enqueue(context.Background(), "test", payload{LinkID: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Start()
+
+	if id := <-got; id != "abc" {
+		t.Errorf("got payload %q, want %q", id, "abc")
+	}
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.jobs) != 0 {
+		t.Errorf("expected the job to be removed, got %d jobs", len(store.jobs))
+	}
+}
+
+func TestQueue_EnqueueUnique(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.MaxAttempts = 1
+
+	for i := 0; i < 2; i++ {
+		if err := q.EnqueueUnique(ctx, "test", "backfill", payload{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job := store.only(t)
+	if job.UniqueKey != "backfill" {
+		t.Errorf("got key %q, want %q", job.UniqueKey, "backfill")
+	}
+
+	q.Register("test", func(context.Context, *model.Job) error {
+		return errors.Str("boom")
+	})
+
+	q.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for store.only(t).Status != model.JobStatusDead {
+		if time.Now().After(deadline) {
+			t.Fatal("job never died")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := q.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dead job gives up its key.
+	if err := q.EnqueueUnique(ctx, "test", "backfill", payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(store.jobs); n != 2 {
+		t.Errorf("got %d jobs, want 2", n)
+	}
+}
+
+func TestQueue_RetryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.MaxAttempts = 2
+
+	q.Register("test", func(context.Context, *model.Job) error {
+		return errors.Str("flaky")
+	})
+
+	if err := q.Enqueue(ctx, "test", payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := store.ClaimJob(ctx, []string{"test"}, q.Lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.run(job)
+
+	retried := store.only(t)
+	if retried.Status != model.JobStatusQueued || retried.LastError == "" {
+		t.Fatalf("expected the job to be queued again: %+v", retried)
+	}
+
+	if wait := time.Until(retried.RunAt); wait < Backoff(1)-time.Second {
+		t.Errorf("retried too soon, in %v", wait)
+	}
+
+	// Make the retry due and fail it again.
+	store.jobs[retried.Key].RunAt = time.Now()
+
+	job, err = store.ClaimJob(ctx, []string{"test"}, q.Lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.run(job)
+
+	if dead := store.only(t); dead.Status != model.JobStatusDead {
+		t.Errorf("expected the job to be dead: %+v", dead)
+	}
+}
+
+func TestQueue_Permanent(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	q := newTestQueue(store)
+
+	q.Register("test", func(context.Context, *model.Job) error {
+		return errors.E(errors.Op("test"), Permanent(errors.Str("bad payload")))
+	})
+
+	if err := q.Enqueue(ctx, "test", payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := store.ClaimJob(ctx, []string{"test"}, q.Lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.run(job)
+
+	if dead := store.only(t); dead.Status != model.JobStatusDead || dead.Attempts != 1 {
+		t.Errorf("expected the job to be dead after one attempt: %+v", dead)
+	}
+}
+
+func TestQueue_Later(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.MaxAttempts = 1
+
+	q.Register("test", func(context.Context, *model.Job) error {
+		return errors.E(errors.Op("test"), Later(time.Minute))
+	})
+
+	if err := q.Enqueue(ctx, "test", payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := store.ClaimJob(ctx, []string{"test"}, q.Lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.run(job)
+
+	later := store.only(t)
+	if later.Status != model.JobStatusQueued || later.IsLastAttempt() {
+		t.Fatalf("expected the job to be queued again without using up its attempt: %+v", later)
+	}
+
+	if wait := time.Until(later.RunAt); wait < time.Minute-time.Second {
+		t.Errorf("put back too soon, in %v", wait)
+	}
+}
+
+func TestQueue_Schedule(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.MaxAttempts = 1
+	ran := make(chan struct{}, 2)
+	fail := true
+
+	q.Register("sweep", func(context.Context, *model.Job) error {
+		ran <- struct{}{}
+
+		if fail {
+			fail = false
+			return errors.Str("boom")
+		}
+
+		return nil
+	})
+	q.Schedule("sweep", time.Hour)
+
+	q.Start()
+	<-ran
+
+	if err := q.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job died, and the next run is queued anyway.
+	store.mu.Lock()
+	var next *model.Job
+	for _, j := range store.jobs {
+		if j.Status == model.JobStatusQueued {
+			next = j
+		}
+	}
+	store.mu.Unlock()
+
+	if next == nil || next.UniqueKey != "sweep" || time.Until(next.RunAt) < time.Hour-time.Second {
+		t.Fatalf("expected the next run to be queued in an hour: %+v", next)
+	}
+
+	next.RunAt = time.Now()
+
+	job, err := store.ClaimJob(ctx, []string{"sweep"}, q.Lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.run(job)
+	<-ran
+
+	if n := len(store.jobs); n != 2 {
+		t.Fatalf("expected the dead run and the next one, got %d jobs", n)
+	}
+
+	// Starting again, as another server would, doesn't queue it twice.
+	if err := q.EnqueueUnique(ctx, "sweep", "sweep", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(store.jobs); n != 2 {
+		t.Errorf("expected the periodic job to be queued once, got %d jobs", n)
+	}
+}
+
+func TestQueue_RunAtStart(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	ran := make(chan struct{}, 1)
+
+	q.Register("backfill", func(context.Context, *model.Job) error {
+		ran <- struct{}{}
+		return nil
+	})
+	q.RunAtStart("backfill")
+
+	q.Start()
+	<-ran
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if len(store.jobs) != 0 {
+		t.Errorf("expected the job to run once, got %d jobs left", len(store.jobs))
+	}
+}
+
+func TestQueue_ExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+
+	if _, err := store.CreateJob(ctx, &model.Job{Kind: "test", Status: model.JobStatusQueued, MaxAttempts: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := store.ClaimJob(ctx, []string{"test"}, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := store.ClaimJob(ctx, []string{"test"}, time.Minute)
+	if err != nil {
+		t.Fatal("expected a job with an expired lease to be claimed again:", err)
+	}
+
+	if err := store.CompleteJob(ctx, stale); err == nil {
+		t.Error("expected the worker that lost the lease to be fenced off")
+	}
+
+	if err := store.CompleteJob(ctx, fresh); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueue_ExpiredLeaseOnLastAttempt(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.MaxAttempts = 1
+	q.Register("test", func(context.Context, *model.Job) error { return nil })
+	q.Schedule("test", time.Hour)
+
+	if err := q.EnqueueUnique(ctx, "test", "test", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker dies while it holds the job.
+	if _, err := store.ClaimJob(ctx, []string{"test"}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.ClaimJob(ctx, []string{"test"}, time.Minute); err == nil {
+		t.Fatal("expected a job with no attempts left not to be claimed again")
+	}
+
+	q.deadLetterExpired([]string{"test"})
+
+	var dead, next int
+
+	for _, j := range store.jobs {
+		switch {
+		case j.Status == model.JobStatusDead:
+			dead++
+		case j.Status == model.JobStatusQueued && time.Until(j.RunAt) > 59*time.Minute:
+			next++
+		}
+	}
+
+	if dead != 1 || next != 1 {
+		t.Errorf("expected the job to be dead and its next run queued, got %d dead and %d queued", dead, next)
+	}
+}
+
+func TestQueue_ShutdownDrains(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	started := make(chan struct{})
+	finished := false
+
+	q.Register("test", func(context.Context, *model.Job) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished = true
+
+		return nil
+	})
+
+	if err := q.Enqueue(context.Background(), "test", payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Start()
+	<-started
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !finished {
+		t.Error("expected shutdown to wait for the running job")
+	}
+}
+
+func TestQueue_ShutdownTimeout(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.MaxAttempts = 1
+	started := make(chan struct{})
+
+	q.Register("test", func(ctx context.Context, _ *model.Job) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+
+	if err := q.Enqueue(context.Background(), "test", payload{}); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := q.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	if job := store.only(t); job.Status != model.JobStatusQueued || job.IsLastAttempt() {
+		t.Errorf("expected the cancelled job to be put back without using up its attempt: %+v", job)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		20: time.Hour,
+	} {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
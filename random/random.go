@@ -35,6 +35,19 @@ func Token() string {
 	return base64.StdEncoding.EncodeToString(randomBytes)[:32]
 }
 
+// URLToken returns a string derived from 32 bytes of a cryptographically
+// secure random number generator, which can be used in a URL as it is.
+func URLToken() string {
+	randomBytes := make([]byte, 32)
+
+	_, err := crand.Read(randomBytes)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes)
+}
+
 func UUID() string {
 	return uuid.NewString()
 }
@@ -0,0 +1,95 @@
+// Package ratelimit spaces out work that shares a key, such as requests to
+// the same domain.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter lets work for each key start at most once per interval. Work for
+// different keys is not limited.
+type Limiter struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func New(interval time.Duration) *Limiter {
+	return &Limiter{
+		interval: interval,
+		now:      time.Now,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// Reserve books the next free slot for the key and returns how long to wait
+// until it.
+func (l *Limiter) Reserve(key string) time.Duration {
+	d, _ := l.reserve(key, -1)
+
+	return d
+}
+
+// ReserveWithin is like Reserve, except that the slot is only booked when it
+// is at most max away. It reports whether it was booked.
+func (l *Limiter) ReserveWithin(key string, max time.Duration) (time.Duration, bool) {
+	return l.reserve(key, max)
+}
+
+// reserve books the next free slot for the key unless max isn't negative and
+// the slot is further away than that.
+func (l *Limiter) reserve(key string, max time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.forget(now)
+
+	at := l.next[key]
+	if at.Before(now) {
+		at = now
+	}
+
+	d := at.Sub(now)
+	if max >= 0 && d > max {
+		return d, false
+	}
+
+	l.next[key] = at.Add(l.interval)
+
+	return d, true
+}
+
+// Wait blocks until work for the key may start. It returns early with the
+// context's error if the context is done first. The slot stays booked either
+// way.
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	d := l.Reserve(key)
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// forget drops keys whose slots have all passed so that the map doesn't grow
+// without bound.
+func (l *Limiter) forget(now time.Time) {
+	for key, at := range l.next {
+		if !at.After(now) {
+			delete(l.next, key)
+		}
+	}
+}
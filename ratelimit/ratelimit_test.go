@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := New(2 * time.Second)
+	l.now = func() time.Time { return now }
+
+	for i, want := range []time.Duration{0, 2 * time.Second, 4 * time.Second} {
+		if got := l.Reserve("example.com"); got != want {
+			t.Errorf("reservation %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	if got := l.Reserve("other.com"); got != 0 {
+		t.Errorf("other key: got %v, want 0", got)
+	}
+
+	now = now.Add(10 * time.Second)
+
+	if got := l.Reserve("example.com"); got != 0 {
+		t.Errorf("after the interval: got %v, want 0", got)
+	}
+
+	if len(l.next) != 1 {
+		t.Errorf("expected passed keys to be forgotten, got %d keys", len(l.next))
+	}
+}
+
+func TestReserveWithin(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := New(2 * time.Second)
+	l.now = func() time.Time { return now }
+
+	l.Reserve("example.com")
+	l.Reserve("example.com")
+
+	if d, ok := l.ReserveWithin("example.com", 3*time.Second); ok || d != 4*time.Second {
+		t.Errorf("got %v, %v, want 4s and not booked", d, ok)
+	}
+
+	if d, ok := l.ReserveWithin("example.com", 4*time.Second); !ok || d != 4*time.Second {
+		t.Errorf("got %v, %v, want 4s and booked", d, ok)
+	}
+
+	if got := l.Reserve("example.com"); got != 6*time.Second {
+		t.Errorf("got %v, want the slot after the booked one at 6s", got)
+	}
+}
+
+func TestWait_ContextDone(t *testing.T) {
+	l := New(time.Hour)
+	l.Reserve("example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := l.Wait(ctx, "example.com"); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
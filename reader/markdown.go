@@ -0,0 +1,417 @@
+package reader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spaces = regexp.MustCompile(`\s+`)
+	// mdSpecial matches the characters that would be taken for Markdown if
+	// they were left as they are in text.
+	mdSpecial = regexp.MustCompile("([\\\\`*_\\[\\]<>])")
+)
+
+// blockElements are the elements rendered as blocks of their own, set apart
+// from the text around them.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Figure: true, atom.Figcaption: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Hr: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Caption: true,
+}
+
+// renderer renders sanitized HTML as Markdown, or as plain text when
+// markdown is false. Plain text is laid out the same way, without the markup.
+type renderer struct {
+	markdown bool
+}
+
+// render renders the sanitized HTML as Markdown or plain text.
+func render(sanitized string, markdown bool) (string, error) {
+	doc, err := html.Parse(strings.NewReader(sanitized))
+	if err != nil {
+		return "", fmt.Errorf("reader: failed to parse html: %w", err)
+	}
+
+	body := find(doc, atom.Body)
+	if body == nil {
+		return "", nil
+	}
+
+	r := &renderer{markdown: markdown}
+
+	return r.blocks(body), nil
+}
+
+// countWords counts the words of the text of the sanitized HTML. Runs of
+// punctuation don't count as words.
+func countWords(sanitized string) (int, error) {
+	doc, err := html.Parse(strings.NewReader(sanitized))
+	if err != nil {
+		return 0, fmt.Errorf("reader: failed to parse html: %w", err)
+	}
+
+	var (
+		count int
+		walk  func(*html.Node)
+	)
+
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			for _, field := range strings.Fields(n.Data) {
+				if strings.IndexFunc(field, isWordRune) >= 0 {
+					count++
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return count, nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockElements[n.DataAtom]
+}
+
+// blocks renders the children of the node as blocks separated by blank lines.
+// Runs of inline children make up paragraphs.
+func (r *renderer) blocks(n *html.Node) string {
+	var (
+		out    []string
+		inline strings.Builder
+	)
+
+	flush := func() {
+		if p := paragraph(inline.String()); p != "" {
+			out = append(out, p)
+		}
+
+		inline.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isBlock(c) {
+			inline.WriteString(r.inline(c))
+			continue
+		}
+
+		flush()
+
+		if b := r.block(c); b != "" {
+			out = append(out, b)
+		}
+	}
+
+	flush()
+
+	return strings.Join(out, "\n\n")
+}
+
+// paragraph trims the spaces around each line of the inline text, and drops
+// the empty lines at either end.
+func paragraph(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func (r *renderer) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := paragraph(strings.ReplaceAll(r.inlineChildren(n), "\n", " "))
+		if text == "" || !r.markdown {
+			return text
+		}
+
+		level, _ := strconv.Atoi(n.Data[1:])
+
+		return strings.Repeat("#", level) + " " + text
+	case atom.Hr:
+		if r.markdown {
+			return "---"
+		}
+
+		return ""
+	case atom.Pre:
+		code := strings.Trim(textContent(n), "\n")
+		if !r.markdown || code == "" {
+			return code
+		}
+
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+
+		return fence + "\n" + code + "\n" + fence
+	case atom.Blockquote:
+		inner := r.blocks(n)
+		if !r.markdown || inner == "" {
+			return inner
+		}
+
+		return prefixLines(inner, "> ", ">")
+	case atom.Ul, atom.Ol:
+		return r.list(n)
+	case atom.Dt:
+		text := paragraph(r.inlineChildren(n))
+		if r.markdown && text != "" {
+			return "**" + text + "**"
+		}
+
+		return text
+	case atom.Table:
+		return r.table(n)
+	default:
+		return r.blocks(n)
+	}
+}
+
+// list renders each item of the list on its own line, after a bullet or its
+// number, with the lines after the first indented to line up with it.
+func (r *renderer) list(n *html.Node) string {
+	var items []string
+
+	num := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = start
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+
+		body := prefixLines(r.blocks(c), strings.Repeat(" ", len(marker)), "")
+		items = append(items, marker+strings.TrimLeft(body, " "))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// table renders each row of the table on its own line. In Markdown, the
+// first row is taken to be the header.
+func (r *renderer) table(n *html.Node) string {
+	var rows [][]string
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+
+			switch c.DataAtom {
+			case atom.Tr:
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						text := paragraph(strings.ReplaceAll(r.inlineChildren(cell), "\n", " "))
+						if r.markdown {
+							text = strings.ReplaceAll(text, "|", "\\|")
+						}
+
+						cells = append(cells, text)
+					}
+				}
+
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+
+	lines := make([]string, 0, len(rows)+1)
+
+	for i, cells := range rows {
+		if !r.markdown {
+			lines = append(lines, strings.Join(cells, "\t"))
+			continue
+		}
+
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(cells)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (r *renderer) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(r.inline(c))
+	}
+
+	return b.String()
+}
+
+func (r *renderer) inline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		text := spaces.ReplaceAllString(n.Data, " ")
+		if r.markdown {
+			text = mdSpecial.ReplaceAllString(text, "\\$1")
+		}
+
+		return text
+	}
+
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if r.markdown {
+			return "\\\n"
+		}
+
+		return "\n"
+	case atom.Img:
+		if !r.markdown || attr(n, "src") == "" {
+			return ""
+		}
+
+		return fmt.Sprintf("![%s](%s)", mdSpecial.ReplaceAllString(attr(n, "alt"), "\\$1"), mdURL(attr(n, "src")))
+	}
+
+	inner := r.inlineChildren(n)
+	if !r.markdown {
+		return inner
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		href := attr(n, "href")
+		if href == "" || strings.TrimSpace(inner) == "" {
+			return inner
+		}
+
+		return fmt.Sprintf("[%s](%s)", strings.TrimSpace(inner), mdURL(href))
+	case atom.Em, atom.I:
+		return wrap(inner, "_")
+	case atom.Strong, atom.B:
+		return wrap(inner, "**")
+	case atom.Del, atom.S:
+		return wrap(inner, "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := spaces.ReplaceAllString(textContent(n), " ")
+		if strings.TrimSpace(code) == "" {
+			return code
+		}
+
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+
+		return fence + code + fence
+	default:
+		return inner
+	}
+}
+
+// wrap puts the marks around the text, leaving any spaces at its ends
+// outside of them so that the marks are still read as Markdown.
+func wrap(s, mark string) string {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return s
+	}
+
+	start := strings.Index(s, text)
+
+	return s[:start] + mark + text + mark + s[start+len(text):]
+}
+
+// mdURL makes the URL safe to put between the parentheses of a Markdown link.
+func mdURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
+
+// prefixLines puts the prefix before each line of s, or the empty prefix
+// before each empty line.
+func prefixLines(s, prefix, empty string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = empty
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var b strings.Builder
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+
+	return b.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
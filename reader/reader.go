@@ -0,0 +1,183 @@
+// Package reader turns the saved HTML of a page into something that is safe
+// and easy to read anywhere: sanitized HTML, plain text or Markdown.
+//
+// Only an allowlist of tags and attributes is kept. Links and images are made
+// absolute so that they work away from the page, and images can be rewritten
+// to load through an image proxy so that reading a page doesn't reveal the
+// reader to the sites it embeds images from.
+package reader
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const (
+	FormatHTML     = "html"
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+)
+
+// Formats are the formats a page can be rendered in.
+var Formats = []string{FormatHTML, FormatText, FormatMarkdown}
+
+// IsFormat reports whether s is one of the formats.
+func IsFormat(s string) bool {
+	for _, f := range Formats {
+		if s == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wordsPerMinute is how fast the reading time assumes people read.
+const wordsPerMinute = 230
+
+// Article is a page rendered for reading.
+type Article struct {
+	Format  string `json:"format"`
+	Content string `json:"content"`
+	// WordCount counts the words of the page's text.
+	WordCount int `json:"wordCount"`
+	// ReadingTime is about how many minutes it takes to read the page.
+	ReadingTime int `json:"readingTime"`
+}
+
+// Options say how to render a page.
+type Options struct {
+	// Format is one of the formats. It defaults to HTML.
+	Format string
+	// ImageProxy, if set, is the URL of an image proxy. Images are loaded
+	// through it by passing their URL in its 'url' query parameter.
+	ImageProxy string
+}
+
+// Render renders the HTML of the page at base for reading.
+func Render(rawhtml string, base *url.URL, opts *Options) (*Article, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatHTML
+	}
+
+	if !IsFormat(format) {
+		return nil, fmt.Errorf("reader: unknown format %q", format)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawhtml))
+	if err != nil {
+		return nil, fmt.Errorf("reader: failed to parse html: %w", err)
+	}
+
+	absolutize(doc, base)
+
+	if opts.ImageProxy != "" {
+		proxy, err := url.Parse(opts.ImageProxy)
+		if err != nil || !proxy.IsAbs() {
+			return nil, fmt.Errorf("reader: %q is not a valid image proxy", opts.ImageProxy)
+		}
+
+		proxyImages(doc, proxy)
+	}
+
+	full, err := doc.Find("body").Html()
+	if err != nil {
+		return nil, fmt.Errorf("reader: failed to render html: %w", err)
+	}
+
+	sanitized := strings.TrimSpace(policy().Sanitize(full))
+
+	words, err := countWords(sanitized)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Article{
+		Format:      format,
+		WordCount:   words,
+		ReadingTime: int(math.Ceil(float64(words) / wordsPerMinute)),
+	}
+
+	switch format {
+	case FormatHTML:
+		a.Content = sanitized
+	case FormatText, FormatMarkdown:
+		if a.Content, err = render(sanitized, format == FormatMarkdown); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// policy allows the tags and attributes that make up the text of a page and
+// nothing that could run, track or restyle it.
+func policy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements(
+		"p", "br", "hr", "div", "section", "article", "header", "footer",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"blockquote", "pre", "code", "kbd", "samp",
+		"em", "i", "strong", "b", "u", "s", "del", "ins", "mark",
+		"small", "sub", "sup", "abbr", "cite", "q", "span",
+		"ul", "ol", "li", "dl", "dt", "dd",
+		"table", "caption", "thead", "tbody", "tfoot", "tr", "th", "td",
+		"figure", "figcaption",
+	)
+	p.AllowAttrs("colspan", "rowspan").Matching(bluemonday.Integer).OnElements("td", "th")
+	p.AllowAttrs("title").OnElements("abbr")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.AllowAttrs("width", "height").Matching(bluemonday.Integer).OnElements("img")
+
+	return p
+}
+
+// absolutize makes the page's links and images point at absolute URLs.
+func absolutize(doc *goquery.Document, base *url.URL) {
+	for _, attr := range []struct{ selector, name string }{
+		{"a[href]", "href"},
+		{"img[src]", "src"},
+	} {
+		doc.Find(attr.selector).Each(func(_ int, s *goquery.Selection) {
+			ref, err := url.Parse(strings.TrimSpace(s.AttrOr(attr.name, "")))
+			if err != nil {
+				s.RemoveAttr(attr.name)
+				return
+			}
+
+			s.SetAttr(attr.name, base.ResolveReference(ref).String())
+		})
+	}
+}
+
+// proxyImages makes the page's web images load through the proxy.
+func proxyImages(doc *goquery.Document, proxy *url.URL) {
+	doc.Find("img[src]").Each(func(_ int, s *goquery.Selection) {
+		src, err := url.Parse(s.AttrOr("src", ""))
+		if err != nil || (src.Scheme != "http" && src.Scheme != "https") {
+			return
+		}
+
+		u := *proxy
+		q := u.Query()
+		q.Set("url", src.String())
+		u.RawQuery = q.Encode()
+
+		s.SetAttr("src", u.String())
+	})
+}
@@ -0,0 +1,123 @@
+package reader
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const page = `<html><head><title>Ignored</title><style>p { color: red; }</style></head><body>
+<h1>Release <em>notes</em></h1>
+<p onclick="track()">Version 2 is <b>out</b>. Read <a href="/docs/upgrade">the upgrade guide</a>.<br>It takes *minutes*.</p>
+<img src="img/diagram.png" alt="Diagram" onerror="track()">
+<script>track()</script>
+<iframe src="https://ads.example.net"></iframe>
+<ul><li>Faster</li><li>Smaller</li></ul>
+<blockquote><p>Finally.</p></blockquote>
+<pre>go get example.com/v2</pre>
+<p><a href="javascript:track()">Don't click</a></p>
+</body></html>`
+
+func TestRender(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/v2")
+
+	tests := []struct {
+		Name         string
+		GivenOptions *Options
+		Expect       []string
+		ExpectNot    []string
+	}{
+		{
+			Name:         "html",
+			GivenOptions: &Options{},
+			Expect: []string{
+				`<h1>Release <em>notes</em></h1>`,
+				`<a href="https://example.com/docs/upgrade" rel="nofollow noopener" target="_blank">the upgrade guide</a>`,
+				`<img src="https://example.com/blog/img/diagram.png" alt="Diagram"/>`,
+				`<li>Faster</li>`,
+			},
+			ExpectNot: []string{"onclick", "onerror", "script", "track()", "iframe", "style", "Ignored"},
+		},
+		{
+			Name:         "image proxy",
+			GivenOptions: &Options{ImageProxy: "https://images.example.org/fetch?w=800"},
+			Expect: []string{
+				`<img src="https://images.example.org/fetch?url=https%3A%2F%2Fexample.com%2Fblog%2Fimg%2Fdiagram.png&amp;w=800" alt="Diagram"/>`,
+			},
+		},
+		{
+			Name:         "text",
+			GivenOptions: &Options{Format: FormatText},
+			Expect: []string{
+				"Release notes\n\nVersion 2 is out. Read the upgrade guide.\nIt takes *minutes*.",
+				"- Faster\n- Smaller",
+				"Finally.\n\ngo get example.com/v2\n\nDon't click",
+			},
+			ExpectNot: []string{"<", "track()"},
+		},
+		{
+			Name:         "markdown",
+			GivenOptions: &Options{Format: FormatMarkdown},
+			Expect: []string{
+				"# Release _notes_",
+				"Version 2 is **out**. Read [the upgrade guide](https://example.com/docs/upgrade).\\\nIt takes \\*minutes\\*.",
+				"![Diagram](https://example.com/blog/img/diagram.png)",
+				"- Faster\n- Smaller",
+				"> Finally.",
+				"```\ngo get example.com/v2\n```",
+			},
+			ExpectNot: []string{"track()"},
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			a, err := Render(page, base, tcase.GivenOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tcase.Expect {
+				if !strings.Contains(a.Content, want) {
+					t.Errorf("expected the content to contain %q:\n%s", want, a.Content)
+				}
+			}
+
+			for _, notWant := range tcase.ExpectNot {
+				if strings.Contains(a.Content, notWant) {
+					t.Errorf("expected the content not to contain %q:\n%s", notWant, a.Content)
+				}
+			}
+
+			if a.WordCount != 21 || a.ReadingTime != 1 {
+				t.Errorf("got %d words and %d minutes, want 21 and 1", a.WordCount, a.ReadingTime)
+			}
+		})
+	}
+}
+
+func TestRender_ReadingTime(t *testing.T) {
+	base, _ := url.Parse("https://example.com")
+
+	a, err := Render("<p>"+strings.Repeat("word ", 1000)+"</p>", base, &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.WordCount != 1000 || a.ReadingTime != 5 {
+		t.Errorf("got %d words and %d minutes, want 1000 and 5", a.WordCount, a.ReadingTime)
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	base, _ := url.Parse("https://example.com")
+
+	for _, opts := range []*Options{
+		{Format: "pdf"},
+		{ImageProxy: "/relative"},
+	} {
+		if _, err := Render("<p>Text</p>", base, opts); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+// Package search parses the query language of the link search box.
+//
+// A query is a list of terms that all have to match. A term is a word, a
+// "quoted phrase" or a field operator such as site:arxiv.org. A term is
+// negated with a leading minus, and terms joined with OR match when either
+// does:
+//
+//	site:arxiv.org tag:/Science is:favorite before:2024-01-01
+//	"neural networks" -site:medium.com
+//	site:arxiv.org OR site:openreview.net
+//
+// Words and phrases are matched against the text of links with the full-text
+// index, where a link matches when it has any of the words and all of the
+// phrases.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The field operators.
+const (
+	FieldSite    = "site"
+	FieldTag     = "tag"
+	FieldUserTag = "usertag"
+	FieldFolder  = "folder"
+	FieldTitle   = "title"
+	FieldURL     = "url"
+	FieldIs      = "is"
+	FieldBefore  = "before"
+	FieldAfter   = "after"
+	FieldCreated = "created"
+)
+
+// The values of the is: operator.
+const (
+	IsFavorite   = "favorite"
+	IsAnnotated  = "annotated"
+	IsArticle    = "article"
+	IsRead       = "read"
+	IsUnread     = "unread"
+	IsInProgress = "inprogress"
+)
+
+var fields = map[string]bool{
+	FieldSite:    true,
+	FieldTag:     true,
+	FieldUserTag: true,
+	FieldFolder:  true,
+	FieldTitle:   true,
+	FieldURL:     true,
+	FieldIs:      true,
+	FieldBefore:  true,
+	FieldAfter:   true,
+	FieldCreated: true,
+}
+
+var isValues = map[string]bool{
+	IsFavorite:   true,
+	IsAnnotated:  true,
+	IsArticle:    true,
+	IsRead:       true,
+	IsUnread:     true,
+	IsInProgress: true,
+}
+
+var dateLayouts = []string{"2006-01-02", "2006/01/02", "2006-01", "2006"}
+
+// Query is a parsed search query.
+type Query struct {
+	// Text is the full-text part of the query in the syntax of MongoDB's
+	// $text operator.
+	Text string
+	// Clauses all have to match. A clause matches when any of its filters
+	// does.
+	Clauses [][]*Filter
+}
+
+// Filter is a field operator.
+type Filter struct {
+	Field  string
+	Value  string
+	Negate bool
+	// From and To are the range of creation dates of the before:, after: and
+	// created: operators. From is inclusive and To exclusive, and either can
+	// be zero.
+	From time.Time
+	To   time.Time
+	// Pos is where the filter starts in the query, in characters.
+	Pos int
+}
+
+// SyntaxError is returned for a query that can't be parsed.
+type SyntaxError struct {
+	// Pos is where the problem is in the query, in characters from zero.
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("search: %s at position %d", e.Msg, e.Pos)
+}
+
+type term struct {
+	pos    int
+	negate bool
+	phrase bool
+	// joined is set when the term is joined to the one before it with OR.
+	joined bool
+	text   string
+	filter *Filter
+}
+
+// Parse parses the query.
+func Parse(q string) (*Query, error) {
+	terms, err := lex([]rune(q))
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{}
+	text := make([]string, 0)
+
+	for i := 0; i < len(terms); {
+		j := i + 1
+		for j < len(terms) && terms[j].joined {
+			j++
+		}
+
+		group := terms[i:j]
+		i = j
+
+		if len(group) == 1 && group[0].filter == nil {
+			text = append(text, group[0].textSearch())
+
+			continue
+		}
+
+		clause := make([]*Filter, 0, len(group))
+
+		for _, t := range group {
+			switch {
+			case t.filter != nil:
+				clause = append(clause, t.filter)
+			case !t.negate && !t.phrase && len(clause) == 0:
+				// Words are matched when any of them is, so joining them
+				// with OR changes nothing.
+				text = append(text, t.text)
+			default:
+				return nil, &SyntaxError{
+					Pos: t.pos,
+					Msg: "OR can only join words or only field operators",
+				}
+			}
+		}
+
+		if len(clause) == 0 {
+			continue
+		}
+
+		if len(clause) < len(group) {
+			return nil, &SyntaxError{
+				Pos: group[0].pos,
+				Msg: "OR can only join words or only field operators",
+			}
+		}
+
+		query.Clauses = append(query.Clauses, clause)
+	}
+
+	query.Text = strings.Join(text, " ")
+
+	return query, nil
+}
+
+func (t *term) textSearch() string {
+	s := t.text
+	if t.phrase {
+		s = `"` + s + `"`
+	}
+
+	if t.negate {
+		s = "-" + s
+	}
+
+	return s
+}
+
+func lex(q []rune) ([]*term, error) {
+	terms := make([]*term, 0)
+	joinNext := false
+
+	for i := 0; i < len(q); {
+		if unicode.IsSpace(q[i]) {
+			i++
+
+			continue
+		}
+
+		start := i
+
+		// OR is only an operator in capitals, so that "or" is still a word.
+		if end := wordEnd(q, i); string(q[i:end]) == "OR" {
+			if len(terms) == 0 || joinNext {
+				return nil, &SyntaxError{Pos: start, Msg: "OR must come between two terms"}
+			}
+
+			joinNext = true
+			i = end
+
+			continue
+		}
+
+		t := &term{pos: start, joined: joinNext}
+		joinNext = false
+
+		if q[i] == '-' {
+			t.negate = true
+			i++
+
+			if i == len(q) || unicode.IsSpace(q[i]) {
+				return nil, &SyntaxError{Pos: start, Msg: "Nothing to exclude after -"}
+			}
+		}
+
+		if q[i] == '"' {
+			text, end, err := quoted(q, i)
+			if err != nil {
+				return nil, err
+			}
+
+			if strings.TrimSpace(text) == "" {
+				return nil, &SyntaxError{Pos: i, Msg: "Empty phrase"}
+			}
+
+			t.phrase = true
+			t.text = text
+			i = end
+		} else {
+			var err error
+
+			end := wordEnd(q, i)
+			word := string(q[i:end])
+
+			colon := strings.IndexRune(word, ':')
+			if colon > 0 && isOperator(word[:colon], word[colon+1:]) {
+				field := strings.ToLower(word[:colon])
+				valuePos := i + len([]rune(word[:colon])) + 1
+				value := word[colon+1:]
+
+				// The value of an operator can be quoted to include spaces.
+				if valuePos < len(q) && q[valuePos] == '"' {
+					value, end, err = quoted(q, valuePos)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				t.filter, err = newFilter(field, value, t.negate, start, valuePos)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				t.text = word
+			}
+
+			i = end
+		}
+
+		terms = append(terms, t)
+	}
+
+	if joinNext {
+		return nil, &SyntaxError{Pos: len(q), Msg: "OR must come between two terms"}
+	}
+
+	return terms, nil
+}
+
+// wordEnd returns the index after the word that starts at i.
+func wordEnd(q []rune, i int) int {
+	for i < len(q) && !unicode.IsSpace(q[i]) {
+		i++
+	}
+
+	return i
+}
+
+// quoted returns the text between the quote at i and the next one, and the
+// index after the closing quote.
+func quoted(q []rune, i int) (string, int, error) {
+	for j := i + 1; j < len(q); j++ {
+		if q[j] == '"' {
+			return string(q[i+1 : j]), j + 1, nil
+		}
+	}
+
+	return "", 0, &SyntaxError{Pos: i, Msg: "Missing closing quote"}
+}
+
+// isOperator reports whether a word with a colon is a field operator rather
+// than a word, such as a URL. Anything that looks like an operator is treated
+// as one, so that a misspelled operator is reported instead of quietly
+// searched for.
+func isOperator(name, value string) bool {
+	if fields[strings.ToLower(name)] {
+		return true
+	}
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+
+	return value != "" && !strings.HasPrefix(value, "//")
+}
+
+func newFilter(field, value string, negate bool, pos, valuePos int) (*Filter, error) {
+	if !fields[field] {
+		return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("Unknown operator %q", field)}
+	}
+
+	if value == "" {
+		return nil, &SyntaxError{Pos: valuePos, Msg: fmt.Sprintf("The %s: operator needs a value", field)}
+	}
+
+	f := &Filter{Field: field, Value: value, Negate: negate, Pos: pos}
+
+	switch field {
+	case FieldIs:
+		f.Value = strings.ToLower(value)
+		if !isValues[f.Value] {
+			return nil, &SyntaxError{
+				Pos: valuePos,
+				Msg: fmt.Sprintf("The is: operator takes favorite, annotated, article, read, unread or inprogress, not %q", value),
+			}
+		}
+	case FieldTag:
+		f.Value = strings.Trim(value, "/")
+	case FieldBefore:
+		d, _, err := parseDate(value, valuePos)
+		if err != nil {
+			return nil, err
+		}
+
+		f.To = d
+	case FieldAfter:
+		d, _, err := parseDate(value, valuePos)
+		if err != nil {
+			return nil, err
+		}
+
+		f.From = d
+	case FieldCreated:
+		from, to, err := parseRange(value, valuePos)
+		if err != nil {
+			return nil, err
+		}
+
+		f.From, f.To = from, to
+	}
+
+	return f, nil
+}
+
+// parseRange parses the value of created:, which is either a date, meaning
+// the whole day, month or year, or a range of dates such as
+// 2024-01-01..2024-03-01. Either end of a range can be left out.
+func parseRange(value string, pos int) (time.Time, time.Time, error) {
+	parts := strings.Split(value, "..")
+
+	switch len(parts) {
+	case 1:
+		return parseDate(value, pos)
+	case 2:
+		var from, to time.Time
+		var err error
+
+		if parts[0] != "" {
+			from, _, err = parseDate(parts[0], pos)
+			if err != nil {
+				return from, to, err
+			}
+		}
+
+		if parts[1] != "" {
+			// The end of the range includes the whole of its last day.
+			_, to, err = parseDate(parts[1], pos+len([]rune(parts[0]))+2)
+			if err != nil {
+				return from, to, err
+			}
+		}
+
+		if from.IsZero() && to.IsZero() {
+			return from, to, &SyntaxError{Pos: pos, Msg: "A date range needs a start or an end"}
+		}
+
+		return from, to, nil
+	default:
+		return time.Time{}, time.Time{}, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("%q is not a date range", value)}
+	}
+}
+
+// parseDate parses a date and returns the start of the period it names and
+// the start of the next one, so that 2024-01 is all of January.
+func parseDate(value string, pos int) (time.Time, time.Time, error) {
+	for _, layout := range dateLayouts {
+		d, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+
+		switch len(layout) {
+		case len("2006"):
+			return d, d.AddDate(1, 0, 0), nil
+		case len("2006-01"):
+			return d, d.AddDate(0, 1, 0), nil
+		default:
+			return d, d.AddDate(0, 0, 1), nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, &SyntaxError{
+		Pos: pos,
+		Msg: fmt.Sprintf("%q is not a date, such as 2024-01-31", value),
+	}
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Given  string
+		Expect *Query
+	}{
+		{
+			Name:   "words",
+			Given:  "  golang   generics ",
+			Expect: &Query{Text: "golang generics"},
+		},
+		{
+			Name:   "phrases and negation",
+			Given:  `"neural networks" -hype -"deep learning"`,
+			Expect: &Query{Text: `"neural networks" -hype -"deep learning"`},
+		},
+		{
+			Name:  "operators",
+			Given: "site:arxiv.org tag:/Science is:Favorite before:2024-01-01 transformers",
+			Expect: &Query{
+				Text: "transformers",
+				Clauses: [][]*Filter{
+					{{Field: FieldSite, Value: "arxiv.org", Pos: 0}},
+					{{Field: FieldTag, Value: "Science", Pos: 15}},
+					{{Field: FieldIs, Value: IsFavorite, Pos: 28}},
+					{{Field: FieldBefore, Value: "2024-01-01", To: date(2024, 1, 1), Pos: 40}},
+				},
+			},
+		},
+		{
+			Name:  "read state",
+			Given: "is:InProgress -is:read",
+			Expect: &Query{
+				Text: "",
+				Clauses: [][]*Filter{
+					{{Field: FieldIs, Value: IsInProgress, Pos: 0}},
+					{{Field: FieldIs, Value: IsRead, Negate: true, Pos: 14}},
+				},
+			},
+		},
+		{
+			Name:  "negated and quoted operator",
+			Given: `-site:medium.com folder:"To read"`,
+			Expect: &Query{
+				Text: "",
+				Clauses: [][]*Filter{
+					{{Field: FieldSite, Value: "medium.com", Negate: true, Pos: 0}},
+					{{Field: FieldFolder, Value: "To read", Pos: 17}},
+				},
+			},
+		},
+		{
+			Name:  "or",
+			Given: "site:arxiv.org OR site:openreview.net rust OR go",
+			Expect: &Query{
+				Text: "rust go",
+				Clauses: [][]*Filter{{
+					{Field: FieldSite, Value: "arxiv.org", Pos: 0},
+					{Field: FieldSite, Value: "openreview.net", Pos: 18},
+				}},
+			},
+		},
+		{
+			Name:  "date ranges",
+			Given: "created:2024-01..2024-03 created:2023 after:2022/06/01",
+			Expect: &Query{
+				Text: "",
+				Clauses: [][]*Filter{
+					{{Field: FieldCreated, Value: "2024-01..2024-03", From: date(2024, 1, 1), To: date(2024, 4, 1), Pos: 0}},
+					{{Field: FieldCreated, Value: "2023", From: date(2023, 1, 1), To: date(2024, 1, 1), Pos: 25}},
+					{{Field: FieldAfter, Value: "2022/06/01", From: date(2022, 6, 1), Pos: 38}},
+				},
+			},
+		},
+		{
+			Name:   "words that look like operators",
+			Given:  "https://go.dev 10:30 or",
+			Expect: &Query{Text: "https://go.dev 10:30 or"},
+		},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Name, func(t *testing.T) {
+			got, err := Parse(tcase.Given)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tcase.Expect) {
+				t.Errorf("got %+v, want %+v", got, tcase.Expect)
+
+				for i := range got.Clauses {
+					for _, f := range got.Clauses[i] {
+						t.Logf("clause %d: %+v", i, f)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	tests := []struct {
+		Given     string
+		ExpectPos int
+	}{
+		{Given: `golang "unclosed`, ExpectPos: 7},
+		{Given: "golang site:", ExpectPos: 12},
+		{Given: "golang sit:arxiv.org", ExpectPos: 7},
+		{Given: "is:pinned", ExpectPos: 3},
+		{Given: "before:yesterday", ExpectPos: 7},
+		{Given: "created:2024-01-01..soon", ExpectPos: 20},
+		{Given: "OR golang", ExpectPos: 0},
+		{Given: "golang OR", ExpectPos: 9},
+		{Given: "golang OR site:go.dev", ExpectPos: 0},
+		{Given: `site:go.dev OR "generic types"`, ExpectPos: 15},
+		{Given: "golang - rust", ExpectPos: 7},
+		{Given: `""`, ExpectPos: 0},
+	}
+
+	for _, tcase := range tests {
+		t.Run(tcase.Given, func(t *testing.T) {
+			_, err := Parse(tcase.Given)
+
+			serr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("expected a syntax error, got %v", err)
+			}
+
+			if serr.Pos != tcase.ExpectPos {
+				t.Errorf("got position %d, want %d (%s)", serr.Pos, tcase.ExpectPos, serr.Msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Package syndication writes feeds of links that feed readers can follow, in
+// the Atom and RSS 2.0 formats.
+package syndication
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Feed is a list of entries, newest first.
+type Feed struct {
+	// ID is a permanent URI for the feed that stays the same however the
+	// feed is reached.
+	ID          string
+	Title       string
+	Description string
+	// Link is the web page that the feed is about.
+	Link   string
+	Author string
+	// Updated is when the feed last changed.
+	Updated time.Time
+	Entries []*Entry
+}
+
+// Entry is an item in a feed.
+type Entry struct {
+	// ID is a permanent URI for the entry.
+	ID    string
+	Title string
+	Link  string
+	// Summary is a short plain text description of the entry, and Content is
+	// the whole of it as HTML.
+	Summary    string
+	Content    string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+}
+
+type atomFeed struct {
+	XMLName  xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string       `xml:"id"`
+	Title    atomText     `xml:"title"`
+	Subtitle *atomText    `xml:"subtitle,omitempty"`
+	Updated  string       `xml:"updated"`
+	Links    []atomLink   `xml:"link"`
+	Author   *atomPerson  `xml:"author,omitempty"`
+	Entries  []*atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// WriteAtom writes the feed to w as an Atom feed.
+func WriteAtom(w io.Writer, f *Feed) error {
+	doc := &atomFeed{
+		ID:      f.ID,
+		Title:   atomText{Type: "text", Body: f.Title},
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Entries: make([]*atomEntry, 0, len(f.Entries)),
+	}
+
+	if f.Description != "" {
+		doc.Subtitle = &atomText{Type: "text", Body: f.Description}
+	}
+
+	if f.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.Link, Rel: "alternate"})
+	}
+
+	// Entries without an author take the feed's, and a feed has to have one
+	// when its entries don't.
+	author := f.Author
+	if author == "" {
+		author = f.Title
+	}
+
+	doc.Author = &atomPerson{Name: author}
+
+	for _, e := range f.Entries {
+		entry := &atomEntry{
+			ID:        e.ID,
+			Title:     atomText{Type: "text", Body: e.Title},
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+		}
+
+		if e.Link != "" {
+			entry.Links = append(entry.Links, atomLink{Href: e.Link, Rel: "alternate"})
+		}
+
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: e.Summary}
+		}
+
+		if e.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: e.Content}
+		}
+
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return write(w, doc)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Body        string `xml:",chardata"`
+}
+
+// WriteRSS writes the feed to w as an RSS 2.0 feed. RSS items have a single
+// description, so it is the entry's content, or its summary if it has none.
+func WriteRSS(w io.Writer, f *Feed) error {
+	description := f.Description
+	if description == "" {
+		// Channels have to have a description.
+		description = f.Title
+	}
+
+	doc := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			Items:         make([]*rssItem, 0, len(f.Entries)),
+		},
+	}
+
+	for _, e := range f.Entries {
+		item := &rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Content,
+			GUID:        rssGUID{Body: e.ID},
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+			Categories:  e.Categories,
+		}
+
+		if item.Description == "" {
+			item.Description = e.Summary
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return write(w, doc)
+}
+
+func write(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("syndication: %w", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("syndication: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("syndication: %w", err)
+	}
+
+	return nil
+}
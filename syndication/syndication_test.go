@@ -0,0 +1,110 @@
+package syndication
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	saved := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	return &Feed{
+		ID:      "https://linksort.com/feeds/1",
+		Title:   "Go & Rust",
+		Link:    "https://linksort.com",
+		Author:  "Ada Lovelace",
+		Updated: saved.Add(time.Hour),
+		Entries: []*Entry{
+			{
+				ID:         "https://linksort.com/links/a",
+				Title:      "The <Rust> Book",
+				Link:       "https://doc.rust-lang.org/book/?a=1&b=2",
+				Summary:    "All about ownership",
+				Content:    "<p>All about <strong>ownership</strong></p>",
+				Categories: []string{"rust", "books"},
+				Published:  saved,
+				Updated:    saved.Add(time.Hour),
+			},
+			{
+				ID:        "https://linksort.com/links/b",
+				Title:     "Untitled",
+				Summary:   "Only a summary",
+				Published: saved,
+				Updated:   saved,
+			},
+		},
+	}
+}
+
+func wellFormed(t *testing.T, data []byte) {
+	t.Helper()
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("expected well-formed XML: %v\n%s", err, data)
+		}
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := WriteAtom(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+
+	wellFormed(t, buf.Bytes())
+
+	out := buf.String()
+	for _, want := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<title type="text">Go &amp; Rust</title>`,
+		"<updated>2024-03-01T13:00:00Z</updated>",
+		`<link href="https://linksort.com" rel="alternate"></link>`,
+		"<name>Ada Lovelace</name>",
+		"<id>https://linksort.com/links/a</id>",
+		`<title type="text">The &lt;Rust&gt; Book</title>`,
+		`<link href="https://doc.rust-lang.org/book/?a=1&amp;b=2" rel="alternate"></link>`,
+		"<published>2024-03-01T12:00:00Z</published>",
+		`<summary type="text">All about ownership</summary>`,
+		`<content type="html">&lt;p&gt;All about &lt;strong&gt;ownership&lt;/strong&gt;&lt;/p&gt;</content>`,
+		`<category term="rust"></category>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the feed to contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := WriteRSS(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+
+	wellFormed(t, buf.Bytes())
+
+	out := buf.String()
+	for _, want := range []string{
+		`<rss version="2.0">`,
+		"<description>Go &amp; Rust</description>",
+		"<lastBuildDate>Fri, 01 Mar 2024 13:00:00 +0000</lastBuildDate>",
+		`<guid isPermaLink="false">https://linksort.com/links/a</guid>`,
+		"<description>&lt;p&gt;All about &lt;strong&gt;ownership&lt;/strong&gt;&lt;/p&gt;</description>",
+		"<description>Only a summary</description>",
+		"<pubDate>Fri, 01 Mar 2024 12:00:00 +0000</pubDate>",
+		"<category>books</category>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the feed to contain %q:\n%s", want, out)
+		}
+	}
+}
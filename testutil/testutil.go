@@ -17,15 +17,20 @@ import (
 	"github.com/icrowley/fake"
 
 	"github.com/linksort/linksort/analyze"
+	"github.com/linksort/linksort/canonical"
 	"github.com/linksort/linksort/controller"
 	"github.com/linksort/linksort/db"
 	"github.com/linksort/linksort/email"
+	"github.com/linksort/linksort/embed"
+	"github.com/linksort/linksort/epub"
 	"github.com/linksort/linksort/errors"
 	"github.com/linksort/linksort/handler"
 	"github.com/linksort/linksort/handler/folder"
 	"github.com/linksort/linksort/handler/user"
+	"github.com/linksort/linksort/health"
 	"github.com/linksort/linksort/magic"
 	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/queue"
 	"github.com/linksort/linksort/random"
 )
 
@@ -36,6 +41,8 @@ var (
 	_h                 http.Handler
 	_userStore         model.UserStore
 	_linkStore         model.LinkStore
+	_linkChangeStore   model.LinkChangeStore
+	_revisionStore     model.RevisionStore
 	_conversationStore model.ConversationStore
 	_magic             = magic.New("test-secret")
 	_email             = email.NewLogger()
@@ -70,17 +77,30 @@ func Handler() http.Handler {
 		_txnClient = db.NewTxnClient(mongo)
 		_userStore = db.NewUserStore(mongo)
 		_linkStore = db.NewLinkStore(mongo)
+		_linkChangeStore = db.NewLinkChangeStore(mongo)
+		_revisionStore = db.NewRevisionStore(mongo)
 		_conversationStore = db.NewConversationStore(mongo)
+		jobQueue := queue.New(db.NewJobStore(mongo))
+		jobQueue.PollInterval = 50 * time.Millisecond
 		_h = handler.New(&handler.Config{
 			Transactor:        _txnClient,
 			UserStore:         _userStore,
 			LinkStore:         _linkStore,
+			RevisionStore:     _revisionStore,
+			SnapshotStore:     db.NewSnapshotStore(mongo),
+			LinkChangeStore:   _linkChangeStore,
+			ImportJobStore:    db.NewImportJobStore(mongo),
 			ConversationStore: _conversationStore,
 			Magic:             _magic,
 			Email:             _email,
 			Analyzer:          analyze.NewTestClient(),
+			HealthChecker:     health.NewTestChecker(),
+			ImageFetcher:      epub.NewTestImageFetcher(),
+			Queue:             jobQueue,
+			Embedder:          embed.NewLocal(),
 			BedrockClient:     &MockBedrockClient{},
 		})
+		jobQueue.Start()
 	})
 
 	return _h
@@ -114,17 +134,32 @@ func NewUser(t *testing.T, ctx context.Context) (*model.User, string) {
 func NewLink(t *testing.T, ctx context.Context, u *model.User) *model.Link {
 	t.Helper()
 
+	return NewLinkWithURL(t, ctx, u, fmt.Sprintf("https://%s/%s", fake.DomainName(), random.String(8)))
+}
+
+// NewLinkWithURL saves a link with the given URL straight to the store, so
+// that it isn't checked for duplicates, as if it was saved before URLs were
+// canonicalized.
+func NewLinkWithURL(t *testing.T, ctx context.Context, u *model.User, url string) *model.Link {
+	t.Helper()
+
+	curl, err := canonical.URL(url)
+	if err != nil {
+		t.Error(err)
+	}
+
 	l, err := _linkStore.CreateLink(ctx, &model.Link{
-		UserID:      u.ID,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		URL:         fmt.Sprintf("https://%s/%s", fake.DomainName(), random.String(8)),
-		Title:       fake.ProductName(),
-		Favicon:     fmt.Sprintf("https://%s/favicon.ico", fake.DomainName()),
-		Corpus:      fake.Paragraphs(),
-		Description: fake.Paragraph(),
-		Site:        fake.Company(),
-		IsArticle:   true,
+		UserID:       u.ID,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		URL:          url,
+		CanonicalURL: curl,
+		Title:        fake.ProductName(),
+		Favicon:      fmt.Sprintf("https://%s/favicon.ico", fake.DomainName()),
+		Corpus:       fake.Paragraphs(),
+		Description:  fake.Paragraph(),
+		Site:         fake.Company(),
+		IsArticle:    true,
 	})
 	if err != nil {
 		t.Error(err)
@@ -133,6 +168,73 @@ func NewLink(t *testing.T, ctx context.Context, u *model.User) *model.Link {
 	return l
 }
 
+// SetLinkHealth saves the link with the given health, as if its URL had just
+// been checked, and puts off checking it again.
+func SetLinkHealth(t *testing.T, ctx context.Context, l *model.Link, h *model.LinkHealth) *model.Link {
+	t.Helper()
+
+	next := time.Now().Add(24 * time.Hour)
+	l.Health = h
+	l.HealthCheckAfter = &next
+
+	l, err := _linkStore.UpdateLink(ctx, l)
+	if err != nil {
+		t.Error(err)
+	}
+
+	return l
+}
+
+// SetLinkChanged records a change to the watched link's page from before to
+// after, as if it had just been found, and puts off checking it again.
+func SetLinkChanged(t *testing.T, ctx context.Context, l *model.Link, before, after string) *model.LinkChange {
+	t.Helper()
+
+	c := model.NewLinkChange(l, before, after)
+	next := time.Now().Add(24 * time.Hour)
+	l.IsWatched = true
+	l.IsChanged = true
+	l.LastChangedAt = &c.DetectedAt
+	l.WatchedText = after
+	l.WatchAfter = &next
+
+	if _, err := _linkStore.UpdateLink(ctx, l); err != nil {
+		t.Error(err)
+	}
+
+	if err := _linkChangeStore.CreateLinkChange(ctx, c); err != nil {
+		t.Error(err)
+	}
+
+	return c
+}
+
+// LinkRevisions returns the revisions that are kept for the link with the
+// given ID, even if the link itself is gone.
+func LinkRevisions(t *testing.T, ctx context.Context, linkID string) []*model.LinkRevision {
+	t.Helper()
+
+	revs, err := _revisionStore.GetRevisionsByLink(ctx, linkID, &model.Pagination{Size: 100})
+	if err != nil {
+		t.Error(err)
+	}
+
+	return revs
+}
+
+// LinkChanges returns the changes that are kept for the page of the link with
+// the given ID, even if the link itself is gone.
+func LinkChanges(t *testing.T, ctx context.Context, linkID string) []*model.LinkChange {
+	t.Helper()
+
+	changes, err := _linkChangeStore.GetLinkChangesByLink(ctx, linkID, &model.Pagination{Size: 100})
+	if err != nil {
+		t.Error(err)
+	}
+
+	return changes
+}
+
 func NewFolder(
 	t *testing.T,
 	ctx context.Context,
@@ -166,6 +268,25 @@ func UpdateUser(t *testing.T, ctx context.Context, u *model.User) *model.User {
 	return u
 }
 
+// Digest returns the digest that would be emailed to the user now.
+func Digest(t *testing.T, ctx context.Context, u *model.User) *model.Digest {
+	t.Helper()
+
+	d := &controller.Digester{
+		Store:     _linkStore,
+		UserStore: _userStore,
+		Email:     _email,
+		Magic:     _magic,
+	}
+
+	digest, err := d.GetDigest(ctx, u, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return digest
+}
+
 func Magic(t *testing.T) *magic.Client {
 	t.Helper()
 
@@ -0,0 +1,295 @@
+// Package vault writes links as a zip of Markdown notes that note-taking apps
+// like Obsidian and Logseq can open as a vault.
+//
+// Each link is a note with YAML front matter, in a directory that mirrors
+// the folder it is in. Notes are named after their link's title and ID, so
+// exporting again gives the same names and the new vault can be unzipped
+// over the old one.
+package vault
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/linksort/linksort/model"
+	"github.com/linksort/linksort/reader"
+)
+
+const (
+	// maxNameLength is the most characters of a title or folder name that
+	// go in a file or directory name.
+	maxNameLength = 80
+	// shortIDLength is how much of an ID tells apart notes or directories
+	// that would otherwise have the same name.
+	shortIDLength = 8
+)
+
+// Writer writes notes to a zip file.
+type Writer struct {
+	zw *zip.Writer
+	// dirs are the directories of the folders, by folder ID.
+	dirs map[string]string
+	// paths are the paths that have been written, in lower case since
+	// not every file system tells case apart.
+	paths map[string]bool
+}
+
+// NewWriter returns a Writer that writes to w, with a directory for every
+// folder below root.
+func NewWriter(w io.Writer, root *model.Folder) *Writer {
+	vw := &Writer{
+		zw:    zip.NewWriter(w),
+		dirs:  make(map[string]string),
+		paths: make(map[string]bool),
+	}
+
+	if root == nil {
+		return vw
+	}
+
+	vw.dirs[root.ID] = ""
+	taken := make(map[string]bool)
+
+	// Parents are walked before their children, so each parent's
+	// directory is known by the time its children's are named.
+	root.Walk(func(parent, node *model.Folder) bool {
+		name := clean(node.Name)
+		if name == "" {
+			name = "Untitled"
+		}
+
+		dir := path.Join(vw.dirs[parent.ID], name)
+		if taken[strings.ToLower(dir)] {
+			dir = path.Join(vw.dirs[parent.ID], fmt.Sprintf("%s %s", name, head(node.ID)))
+		}
+
+		taken[strings.ToLower(dir)] = true
+		vw.dirs[node.ID] = dir
+
+		return true
+	})
+
+	return vw
+}
+
+// AddLink writes the link as a note. Links that aren't in a folder, or whose
+// folder no longer exists, go at the top of the vault.
+func (w *Writer) AddLink(link *model.Link) error {
+	note, err := Note(link)
+	if err != nil {
+		return err
+	}
+
+	name := clean(link.Title)
+	if name == "" {
+		name = clean(link.Site)
+	}
+
+	if name == "" {
+		name = "Untitled"
+	}
+
+	dir := w.dirs[link.FolderID]
+
+	p := path.Join(dir, fmt.Sprintf("%s %s.md", name, tail(link.ID)))
+	if w.paths[strings.ToLower(p)] {
+		p = path.Join(dir, fmt.Sprintf("%s %s.md", name, link.ID))
+	}
+
+	w.paths[strings.ToLower(p)] = true
+
+	f, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     p,
+		Modified: link.UpdatedAt,
+		Method:   zip.Deflate,
+	})
+	if err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+
+	if _, err := io.WriteString(f, note); err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+
+	return nil
+}
+
+// Close finishes the zip file. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.zw.Close(); err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+
+	return nil
+}
+
+// Note returns the link as a Markdown note. Its summary and the text of its
+// page are converted from HTML, and its annotation and highlights are kept
+// as they were written.
+func Note(link *model.Link) (string, error) {
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(link.Title))
+	fmt.Fprintf(&b, "url: %s\n", strconv.Quote(link.URL))
+	fmt.Fprintf(&b, "site: %s\n", strconv.Quote(link.Site))
+	writeList(&b, "tags", tags(link.TagPaths))
+	writeList(&b, "userTags", link.UserTags)
+	fmt.Fprintf(&b, "favorite: %t\n", link.IsFavorite)
+	fmt.Fprintf(&b, "created: %s\n", link.CreatedAt.UTC().Format(time.RFC3339))
+	b.WriteString("---\n")
+
+	title := strings.Join(strings.Fields(link.Title), " ")
+	if title == "" {
+		title = link.URL
+	}
+
+	fmt.Fprintf(&b, "\n# %s\n", title)
+
+	if s := strings.TrimSpace(link.Annotation); s != "" {
+		fmt.Fprintf(&b, "\n## Annotation\n\n%s\n", s)
+	}
+
+	if strings.TrimSpace(link.Summary) != "" {
+		s, err := markdown(link.Summary, link.URL)
+		if err != nil {
+			return "", err
+		}
+
+		if s != "" {
+			fmt.Fprintf(&b, "\n## Summary\n\n%s\n", s)
+		}
+	}
+
+	if len(link.Highlights) > 0 {
+		b.WriteString("\n## Highlights\n")
+
+		for _, h := range link.Highlights {
+			fmt.Fprintf(&b, "\n> %s\n", strings.Join(strings.Split(strings.TrimSpace(h.Quote), "\n"), "\n> "))
+
+			if note := strings.TrimSpace(h.Note); note != "" {
+				fmt.Fprintf(&b, "\n%s\n", note)
+			}
+		}
+	}
+
+	if strings.TrimSpace(link.Corpus) != "" {
+		s, err := markdown(link.Corpus, link.URL)
+		if err != nil {
+			return "", err
+		}
+
+		if s != "" {
+			fmt.Fprintf(&b, "\n## Content\n\n%s\n", s)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// markdown converts HTML from the link's page, or about it, to Markdown.
+func markdown(s, rawURL string) (string, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+
+	article, err := reader.Render(s, base, &reader.Options{Format: reader.FormatMarkdown})
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+
+	return strings.TrimSpace(article.Content), nil
+}
+
+func writeList(b *strings.Builder, key string, items []string) {
+	if len(items) == 0 {
+		fmt.Fprintf(b, "%s: []\n", key)
+		return
+	}
+
+	fmt.Fprintf(b, "%s:\n", key)
+
+	for _, item := range items {
+		fmt.Fprintf(b, "  - %s\n", strconv.Quote(item))
+	}
+}
+
+// tags turns tag paths into nested tags, like "Science/Physics". Paths that
+// another path is below are left out, since a nested tag implies its parents.
+func tags(paths []string) []string {
+	out := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		below := false
+
+		for _, other := range paths {
+			if strings.HasPrefix(other, p+"/") {
+				below = true
+				break
+			}
+		}
+
+		if below {
+			continue
+		}
+
+		// Tags can't have spaces in them.
+		tag := strings.Join(strings.Fields(strings.Trim(p, "/")), "-")
+		if tag != "" {
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
+
+// clean makes s safe to use as a file or directory name. Characters that
+// file systems or note links don't allow become spaces, and the name is cut
+// short if it is long.
+func clean(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|#^[]`, r) {
+			return ' '
+		}
+
+		return r
+	}, s)
+
+	s = strings.Join(strings.Fields(s), " ")
+
+	if utf8.RuneCountInString(s) > maxNameLength {
+		s = string([]rune(s)[:maxNameLength])
+	}
+
+	// Names that start with a dot are hidden, and some file systems drop
+	// dots at the end.
+	return strings.TrimSpace(strings.Trim(s, "."))
+}
+
+// head and tail shorten IDs. Folder IDs are random, so any of them will do,
+// but link IDs start with the time the link was saved, which many links
+// share after an import.
+func head(id string) string {
+	if len(id) <= shortIDLength {
+		return id
+	}
+
+	return id[:shortIDLength]
+}
+
+func tail(id string) string {
+	if len(id) <= shortIDLength {
+		return id
+	}
+
+	return id[len(id)-shortIDLength:]
+}
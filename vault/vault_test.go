@@ -0,0 +1,180 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linksort/linksort/model"
+)
+
+func readVault(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[f.Name] = string(b)
+	}
+
+	return files
+}
+
+func names(files map[string]string) []string {
+	out := make([]string, 0, len(files))
+	for name := range files {
+		out = append(out, name)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+func TestWriter(t *testing.T) {
+	root := &model.Folder{Name: "root", ID: "root"}
+	science := &model.Folder{Name: "Science: Physics", ID: "aaaaaaaa-1111", Children: []*model.Folder{}}
+	again := &model.Folder{Name: "science  physics", ID: "bbbbbbbb-2222", Children: []*model.Folder{}}
+	optics := &model.Folder{Name: ".Optics.", ID: "cccccccc-3333", Children: []*model.Folder{}}
+	science.Children = append(science.Children, optics)
+	root.Children = []*model.Folder{science, again}
+
+	links := []*model.Link{
+		{ID: "65f1a2b3c4d5e6f7a8b9c0d1", Title: "Light / Waves?", URL: "https://example.com/light", FolderID: "cccccccc-3333"},
+		{ID: "65f1a2b3c4d5e6f7a8b9c0d2", Title: "Light / Waves?", URL: "https://example.com/waves", FolderID: "bbbbbbbb-2222"},
+		{ID: "65f1a2b3c4d5e6f7a8b9c0d3", Site: "example.com", URL: "https://example.com", FolderID: "root"},
+		{ID: "65f1a2b3c4d5e6f7a8b9c0d4", Title: "Gone", URL: "https://example.com/gone", FolderID: "deleted"},
+	}
+
+	export := func() map[string]string {
+		var buf bytes.Buffer
+
+		w := NewWriter(&buf, root)
+		for _, l := range links {
+			if err := w.AddLink(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		return readVault(t, buf.Bytes())
+	}
+
+	files := export()
+
+	want := []string{
+		"Gone a8b9c0d4.md",
+		"Science Physics/Optics/Light Waves a8b9c0d1.md",
+		"example.com a8b9c0d3.md",
+		"science physics bbbbbbbb/Light Waves a8b9c0d2.md",
+	}
+
+	if got := names(files); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the files\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	if again := names(export()); strings.Join(again, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected exporting again to give the same files, got\n%s", strings.Join(again, "\n"))
+	}
+}
+
+func TestNote(t *testing.T) {
+	note, err := Note(&model.Link{
+		ID:         "65f1a2b3c4d5e6f7a8b9c0d1",
+		Title:      `The "Rust" Book`,
+		URL:        "https://doc.rust-lang.org/book/",
+		Site:       "rust-lang.org",
+		TagPaths:   []string{"/Computers", "/Computers/Programming Languages", "/Books"},
+		UserTags:   []string{"to read"},
+		IsFavorite: true,
+		CreatedAt:  time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Annotation: "Read this\n\nagain",
+		Summary:    "<p>It is about <strong>ownership</strong>.</p>",
+		Highlights: model.HighlightList{{Quote: "Ownership rules", Note: "Key idea"}},
+		Corpus:     `<h2>Intro</h2><p>See <a href="/ch01">chapter one</a>.</p>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `---
+title: "The \"Rust\" Book"
+url: "https://doc.rust-lang.org/book/"
+site: "rust-lang.org"
+tags:
+  - "Computers/Programming-Languages"
+  - "Books"
+userTags:
+  - "to read"
+favorite: true
+created: 2024-03-01T12:30:00Z
+---
+
+# The "Rust" Book
+
+## Annotation
+
+Read this
+
+again
+
+## Summary
+
+It is about **ownership**.
+
+## Highlights
+
+> Ownership rules
+
+Key idea
+
+## Content
+
+## Intro
+
+See [chapter one](https://doc.rust-lang.org/ch01).
+`
+
+	if note != want {
+		t.Errorf("expected the note\n%s\ngot\n%s", want, note)
+	}
+}
+
+func TestNote_Empty(t *testing.T) {
+	note, err := Note(&model.Link{URL: "https://example.com", CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"tags: []\n", "userTags: []\n", "favorite: false\n", "# https://example.com\n"} {
+		if !strings.Contains(note, want) {
+			t.Errorf("expected the note to contain %q:\n%s", want, note)
+		}
+	}
+
+	if strings.Contains(note, "## ") {
+		t.Errorf("expected no sections in a note without any:\n%s", note)
+	}
+}